@@ -3,3 +3,12 @@ module github.com/hktalent/go-pjs
 go 1.18
 
 require github.com/pkg/errors v0.9.1
+
+require golang.org/x/text v0.14.0
+
+require gopkg.in/yaml.v3 v3.0.1
+
+require (
+	go.starlark.net v0.0.0-20230302034142-4b1e35fe2254
+	golang.org/x/sys v0.5.0 // indirect
+)
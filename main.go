@@ -1,18 +1,329 @@
 package main
 
 import (
-	"github.com/hktalent/go-pjs/pkg"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hktalent/go-pjs/pkg"
 )
 
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage:
+  %[1]s [dump [-renumber] [-explain] [flags]] <file>          dump the stream structure and print the parsed objects
+  %[1]s report [-f format] [-t template] [flags] <file>...   render an analysis report per file
+  %[1]s json [flags] <file>                                  print the minimal JSON of the parsed objects
+  %[1]s schema <name>                                        print the JSON Schema of an output (%[2]s)
+  %[1]s scan [-bundle out.zip] [-store dir] [flags] <archive>...
+                                                           carve and analyze the streams of zip/tar archives
+  %[1]s serve [-addr addr] [-f format] [flags]               analyze the payloads POSTed to /analyze
+  %[1]s capabilities                                         print the supported elements, extensions and limits
+  %[1]s minimize [-o out] [-match text] [-analyst name] [flags] <file>
+                                                           reduce a payload failing to parse to a minimal reproducer
+  %[1]s compat [-json] <file>...                             tell whether a stock JVM would read the streams
+  %[1]s infer [-check schema.json] [flags] <file>...          infer the JSON Schema of the classes of many streams
+  %[1]s coverage [-json] <file>...                             tell which protocol features a corpus exercises
+  %[1]s record -listen addr -target addr [-rules rules.yaml] -o session.jsonl
+                                                           relay TCP connections to a server, capturing them
+  %[1]s replay -target addr [-replace seq=file] [-rules rules.yaml] <session>
+                                                           replay the client messages of a captured session
+  %[1]s fuzz [-n count] [-seed n] [-kinds list] [-o pattern] <file>
+                                                           write structure-aware mutations of a valid stream
+  %[1]s defuse [-magic] [-o out] [-analyst name] <file>      rewrite a malicious payload into an inert variant
+  %[1]s jvmdiff [-json] [-jvm java] [flags] <file>...        tell where the parses diverge from ObjectInputStream
+  %[1]s risk [-json] <jar|dir>...                            tell which gadget chains an application's classes allow
+  %[1]s decrypt [-keys vault.yaml] [-keylist keys.txt] [-workers n] [-progress] [-o out] [-json] <file>
+                                                           try the keys of a vault or a key list on an encrypted payload
+  %[1]s jmx [-session] [-json] [flags] <file>                describe the JMX-over-RMI calls and returns of a capture
+  %[1]s misp -url url [-key key] [-insecure] [-tag tag] [flags] <file>...
+                                                           create a MISP event per analyzed file
+`, os.Args[0], strings.Join(pkg.SchemaNames(), ", "))
+	os.Exit(2)
+}
+
 func main() {
-	//os.Args = []string{"", "/Users/51pwn/MyWork/TestPoc/CVE-2022-21306.dat"}
-	os.Args = []string{"", "/Users/51pwn/MyWork/vulScanPro/mtx/x1.date"}
-	if data, err := ioutil.ReadFile(os.Args[1]); nil == err {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	defer pkg.CloseExternalPlugins()
+
+	switch os.Args[1] {
+	case "report":
+		report(os.Args[2:])
+	case "json":
+		minimalJSON(os.Args[2:])
+	case "scan":
+		scan(os.Args[2:])
+	case "serve":
+		serve(os.Args[2:])
+	case "capabilities":
+		capabilities()
+	case "minimize":
+		minimize(os.Args[2:])
+	case "compat":
+		compat(os.Args[2:])
+	case "infer":
+		infer(os.Args[2:])
+	case "coverage":
+		coverage(os.Args[2:])
+	case "record":
+		record(os.Args[2:])
+	case "replay":
+		replay(os.Args[2:])
+	case "fuzz":
+		fuzz(os.Args[2:])
+	case "jmx":
+		jmx(os.Args[2:])
+	case "misp":
+		misp(os.Args[2:])
+	case "defuse":
+		defuse(os.Args[2:])
+	case "jvmdiff":
+		jvmdiff(os.Args[2:])
+	case "risk":
+		risk(os.Args[2:])
+	case "decrypt":
+		decrypt(os.Args[2:])
+	case "schema":
+		if len(os.Args) < 3 {
+			usage()
+		}
+
+		schema(os.Args[2])
+	case "dump":
+		fs := flag.NewFlagSet("dump", flag.ExitOnError)
+		renumber := fs.Bool("renumber", false, "renumber the handles densely in traversal order before dumping")
+		maxDumpBytes := fs.Int("max-dump-bytes", 0, "print at most this many bytes of a block data or long string value")
+		explain := fs.Bool("explain", false, "end each line with an explanation of the element from the specification")
+		splitBytes := fs.Int("split-bytes", 0, "write the dump to numbered files of at most this many bytes")
+		out := fs.String("o", "", "file name pattern of the split dump, <file>.%03d.txt by default")
+		options := parserFlags(fs)
+		_ = fs.Parse(os.Args[2:])
+
+		if fs.NArg() != 1 {
+			usage()
+		}
+
+		dumpOptions := append(options(), pkg.SetMaxDumpBytes(*maxDumpBytes), pkg.SetDumpExplain(*explain))
+
+		var pager *pkg.Pager
+
+		if *splitBytes > 0 {
+			if *out == "" {
+				*out = fs.Arg(0) + ".%03d.txt"
+			}
+
+			pager = pkg.NewFileSplitter(*out, *splitBytes)
+			dumpOptions = append(dumpOptions, pkg.SetDumpWriter(pager))
+		}
+
+		dump(fs.Arg(0), *renumber, dumpOptions...)
+
+		if pager != nil {
+			if err := pager.Close(); err != nil {
+				log.Fatalln(err)
+			}
+		}
+	case "-h", "-help", "--help":
+		usage()
+	default:
+		dump(os.Args[1], false)
+	}
+}
+
+// metadataFlags declares the flags appending a metadata block to the samples written by a tool, the returned func
+// appends it once flags are parsed.
+func metadataFlags(fs *flag.FlagSet, tool string) func([]byte) []byte {
+	enabled := fs.Bool("metadata", false, "append a provenance block (analyst, time, version) after the stream")
+	analyst := fs.String("analyst", "", "analyst recorded in the provenance block, implies -metadata")
+
+	return func(data []byte) []byte {
+		if !*enabled && *analyst == "" {
+			return data
+		}
+
+		res, err := pkg.AppendMetadata(data, pkg.NewSampleMetadata(*analyst, tool))
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		return res
+	}
+}
+
+// parserFlags declares the flags configuring the parser, the returned func builds the options once flags are parsed.
+func parserFlags(fs *flag.FlagSet) func() []pkg.Option {
+	relocations := fs.String("relocations", "", "map shaded class names back to their originals with a relocation file")
+	mapping := fs.String("mapping", "", "map obfuscated class and field names back to their originals with a "+
+		"ProGuard or R8 mapping file")
+	memoryCap := fs.Int64("memory-cap", 0, "stop parsing before the first element which would take the memory over this many bytes")
+	charset := fs.String("charset", "", "render block data as text with a charset: "+strings.Join(pkg.CharsetNames(), ", "))
+	warnings := fs.String("warnings", "ignore", "stream anomalies which do not stop the parse: ignore, log or fatal")
+
+	var classCharsets, unknownClasses, classPath, goPlugins, execPlugins []string
+
+	fs.Func("class-charset", "per-class charset override, class=charset (repeatable)", func(s string) error {
+		classCharsets = append(classCharsets, s)
+
+		return nil
+	})
+
+	fs.Func("unknown-class", "skip the data of a class or package.* like an unresolvable class (repeatable)",
+		func(s string) error {
+			unknownClasses = append(unknownClasses, s)
+
+			return nil
+		})
+
+	fs.Func("classpath", "flag the classes absent from or of another version in an application jar or directory "+
+		"(repeatable)", func(s string) error {
+		classPath = append(classPath, s)
+
+		return nil
+	})
+
+	fs.Func("plugin", "load the detectors, post-processors and formats of a Go plugin .so (repeatable)",
+		func(s string) error {
+			goPlugins = append(goPlugins, s)
+
+			return nil
+		})
+
+	fs.Func("plugin-exec", "run an external plugin command, arguments separated by spaces (repeatable)",
+		func(s string) error {
+			execPlugins = append(execPlugins, s)
+
+			return nil
+		})
+
+	pluginsLoaded := false
+
+	return func() []pkg.Option {
+		var options []pkg.Option
+
+		// before anything is parsed, once however many times the options are built
+		if !pluginsLoaded {
+			pluginsLoaded = true
+
+			for _, path := range goPlugins {
+				if err := pkg.LoadGoPlugin(path); err != nil {
+					log.Fatalln(err)
+				}
+			}
+
+			for _, command := range execPlugins {
+				args := strings.Fields(command)
+				if len(args) == 0 {
+					log.Fatalln("empty plugin command")
+				}
+
+				if _, err := pkg.StartExternalPlugin(args[0], args[1:]...); err != nil {
+					log.Fatalln(err)
+				}
+			}
+		}
+
+		if *memoryCap > 0 {
+			options = append(options, pkg.SetMemoryCap(*memoryCap))
+		}
+
+		if *relocations != "" {
+			r, err := pkg.LoadRelocations(*relocations)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			options = append(options, pkg.SetRelocations(r))
+		}
+
+		if *mapping != "" {
+			m, err := pkg.LoadProGuardMapping(*mapping)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			options = append(options, pkg.SetProGuardMapping(m))
+		}
+
+		if *charset != "" {
+			enc, err := pkg.LookupCharset(*charset)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			options = append(options, pkg.SetCharset(enc))
+		}
+
+		for _, cc := range classCharsets {
+			idx := strings.LastIndex(cc, "=")
+			if idx < 0 {
+				log.Fatalf("invalid class charset '%s', want class=charset\n", cc)
+			}
+
+			enc, err := pkg.LookupCharset(cc[idx+1:])
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			options = append(options, pkg.SetClassCharset(cc[:idx], enc))
+		}
 
-		if c, err := pkg.ParseSerializedObject(data); nil == err {
+		if len(unknownClasses) > 0 {
+			options = append(options, pkg.UnknownClasses(unknownClasses...))
+		}
+
+		if len(classPath) > 0 {
+			cp, err := pkg.LoadClassPath(classPath...)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			options = append(options, pkg.SetClassPath(cp))
+		}
+
+		switch *warnings {
+		case "ignore":
+		case "log":
+			options = append(options, pkg.WithWarningHandler(func(w pkg.Warning) { log.Println(w) }))
+		case "fatal":
+			options = append(options, pkg.WithWarningHandler(func(w pkg.Warning) { log.Fatalln(w) }))
+		default:
+			log.Fatalf("unknown warnings mode '%s'\n", *warnings)
+		}
+
+		return options
+	}
+}
+
+func dump(file string, renumber bool, options ...pkg.Option) {
+	if data, err := ioutil.ReadFile(file); nil == err {
+		if renumber {
+			if data, err = pkg.RenumberHandles(data); err != nil {
+				log.Println(err)
+
+				return
+			}
+		}
+
+		if err = pkg.DumpSerializedObject(data, options...); err != nil {
+			log.Printf("%+v\n", err)
+		}
+
+		if c, err := pkg.ParseSerializedObject(data, options...); nil == err {
 			log.Println(c)
 		} else {
 			log.Println(err)
@@ -20,5 +331,1350 @@ func main() {
 	} else {
 		log.Println(err)
 	}
+}
+
+func report(args []string) {
+	formats := make([]string, 0, len(pkg.OutputFormats))
+	for name := range pkg.OutputFormats {
+		formats = append(formats, name)
+	}
+
+	sort.Strings(formats)
+
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	format := fs.String("f", "md", "report format: "+strings.Join(formats, ", "))
+	tpl := fs.String("t", "", "render with a Go text/html template file instead of a built-in format")
+	minSeverity := fs.String("min-severity", "info", "drop the findings below a severity")
+	baseline := fs.String("baseline", "", "drop the findings accepted by a baseline file")
+	writeBaseline := fs.String("write-baseline", "", "write the reported findings to a baseline file")
+	excel := fs.Bool("excel", false, "with -f csv, write a byte order mark and CRLF line ends for Excel")
+
+	var suppress, scripts []string
+
+	fs.Func("suppress", "drop the findings of a rule ID (repeatable)", func(s string) error {
+		suppress = append(suppress, s)
+
+		return nil
+	})
+
+	fs.Func("script", "run a Starlark script as a detector (repeatable)", func(s string) error {
+		scripts = append(scripts, s)
+
+		return nil
+	})
+
+	parserOptions := parserFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	filter := &pkg.FindingFilter{Suppress: suppress}
+
+	var err error
+
+	if filter.MinSeverity, err = pkg.ParseSeverity(*minSeverity); err != nil {
+		log.Fatalln(err)
+	}
+
+	if *baseline != "" {
+		if filter.Baseline, err = pkg.LoadBaseline(*baseline); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	var template *pkg.ReportTemplate
+
+	if *tpl != "" {
+		if template, err = pkg.LoadReportTemplate(*tpl); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	for _, path := range scripts {
+		script, err := pkg.LoadScript(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		pkg.RegisterDetector("script:"+path, script.Detector())
+	}
+
+	// plugins may register the format
+	options := parserOptions()
+
+	output, exists := pkg.OutputFormats[*format]
+	if !exists && template == nil {
+		log.Fatalf("unknown report format '%s'\n", *format)
+	}
+
+	accepted := pkg.NewBaseline()
+
+	// a single sheet with a row per file
+	var sheet *pkg.CSVSummaryWriter
+
+	if template == nil && *format == "csv" {
+		if sheet, err = pkg.NewCSVSummaryWriter(os.Stdout, *excel); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	for _, file := range fs.Args() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Println(err)
+
+			continue
+		}
+
+		analysis := pkg.Analyze(data, options...)
+		filter.Apply(analysis)
+
+		for _, f := range analysis.Findings {
+			accepted.Add(pkg.BaselineEntry{RuleID: f.RuleID, Class: f.Class, Fingerprint: analysis.Fingerprint})
+		}
+
+		switch {
+		case template != nil:
+			content, _ := pkg.ParseSerializedObject(data, options...)
+			err = template.Render(os.Stdout, file, analysis, content)
+		case sheet != nil:
+			err = sheet.Write(file, analysis)
+		default:
+			err = output(os.Stdout, file, analysis)
+		}
+
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	if sheet != nil {
+		if err = sheet.Flush(); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	if *writeBaseline != "" {
+		if err = accepted.Save(*writeBaseline); err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+func minimalJSON(args []string) {
+	fs := flag.NewFlagSet("json", flag.ExitOnError)
+	longs := fs.Bool("longs-as-strings", false, "emit longs as strings")
+	tagged := fs.Bool("tagged-floats", false, "emit NaN and infinite numbers as tagged values")
+	bits := fs.Bool("float-bits", false, "emit the raw bit pattern of floats and doubles")
+	rawHex := fs.Bool("raw-hex", false, "emit the primitives with the hex of their encoding, as the dump shows them")
+	dates := fs.String("dates", pkg.DateRaw, "date rendering: raw, rfc3339 or epoch-millis")
+	zone := fs.String("zone", "UTC", "zone of rfc3339 dates (IANA name or Local)")
+	splitBytes := fs.Int("split-bytes", 0, "write JSON arrays of whole elements to numbered files of at most this many bytes")
+	out := fs.String("o", "", "file name pattern of the split output, <file>.%03d.json by default")
+	splitReset := fs.Bool("split-reset", false, "print a JSON line per run of elements between TC_RESET elements")
+	transform := fs.String("transform", "", "comma separated transformers of the output, in order: "+
+		strings.Join(pkg.TransformerNames(), ", "))
+	script := fs.String("script", "", "print the findings, extracted values and output of a Starlark script instead")
+	parserOptions := parserFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	var options []pkg.JSONOption
+
+	chain, err := pkg.ParseTransformers(*transform)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	options = append(options, pkg.JSONTransform(chain...))
+
+	if *longs {
+		options = append(options, pkg.JSONLongsAsStrings())
+	}
+
+	if *tagged {
+		options = append(options, pkg.JSONTaggedFloats())
+	}
+
+	if *bits {
+		options = append(options, pkg.JSONFloatBits())
+	}
+
+	if *rawHex {
+		options = append(options, pkg.JSONRawHex())
+	}
+
+	switch *dates {
+	case pkg.DateRaw, pkg.DateRFC3339, pkg.DateEpochMillis:
+	default:
+		log.Fatalf("unknown date rendering '%s'\n", *dates)
+	}
+
+	loc, err := time.LoadLocation(*zone)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	options = append(options, pkg.JSONDates(*dates, loc))
+
+	data, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *splitReset {
+		segments, err := pkg.ParseSegments(data, parserOptions()...)
+		if err != nil {
+			log.Println(err)
+		}
+
+		for _, segment := range segments {
+			b, err := pkg.MarshalMinimal(segment.Elements, options...)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			line, _ := json.Marshal(struct {
+				Offset   int64           `json:"offset"`
+				Size     int64           `json:"size"`
+				Elements json.RawMessage `json:"elements"`
+			}{segment.Offset, segment.Size, b})
+
+			fmt.Println(string(line))
+		}
+
+		return
+	}
+
+	content, err := pkg.ParseSerializedObject(data, parserOptions()...)
+	if err != nil {
+		log.Println(err)
+	}
+
+	if *script != "" {
+		s, err := pkg.LoadScript(*script)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		res, err := s.Run(content)
+		if err != nil {
+			log.Println(err)
+		}
+
+		b, _ := json.Marshal(res)
+		fmt.Println(string(b))
+
+		return
+	}
+
+	if *splitBytes > 0 {
+		if *out == "" {
+			*out = fs.Arg(0) + ".%03d.json"
+		}
+
+		if err = pkg.MarshalMinimalPages(content, *splitBytes, pkg.SplitFiles(*out), options...); err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	b, err := pkg.MarshalMinimal(content, options...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Println(string(b))
+}
+
+func scan(args []string) {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	bundle := fs.String("bundle", "", "write the results, carved streams and extracted files to a zip")
+	storeDir := fs.String("store", "", "write the carved streams and extracted files to a content-addressed directory")
+	parserOptions := parserFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	options := parserOptions()
+
+	var store *pkg.ArtifactStore
+
+	if *storeDir != "" {
+		var err error
+
+		if store, err = pkg.OpenArtifactStore(*storeDir); err != nil {
+			log.Fatalln(err)
+		}
+
+		defer func() {
+			written, duplicates := store.Stats()
+			log.Printf("%d artifacts written to %s, %d already stored\n", written, *storeDir, duplicates)
+
+			if err := store.Close(); err != nil {
+				log.Fatalln(err)
+			}
+		}()
+	}
+
+	var all []*pkg.ArchiveEntry
+
+	for _, file := range fs.Args() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Println(err)
+
+			continue
+		}
+
+		entries, err := pkg.ScanArchive(data, options...)
+		if err != nil {
+			log.Println(file, err)
+		}
+
+		if store != nil {
+			if err := store.PutScan(file, data, entries); err != nil {
+				log.Fatalln(err)
+			}
+		}
+
+		for _, entry := range entries {
+			for _, stream := range entry.Streams {
+				fmt.Printf("%s!/%s@%d\t%s\t%d findings\n", file, entry.Path, stream.Offset, stream.Analysis.Verdict(),
+					len(stream.Analysis.Findings))
+			}
+
+			if fs.NArg() > 1 {
+				entry.Path = filepath.Base(file) + "/" + entry.Path
+			}
+		}
+
+		all = append(all, entries...)
+	}
+
+	if *bundle != "" {
+		f, err := os.Create(*bundle)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if err = pkg.WriteBundle(f, all); err == nil {
+			err = f.Close()
+		}
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "listen address")
+	format := fs.String("f", "json", "report format")
+	queue := fs.Int("queue", 16, "jobs queued in front of each stage")
+	maxInFlight := fs.Int64("max-inflight", 256<<20, "payload bytes in flight before refusing with 503")
+	timeout := fs.Duration("timeout", 30*time.Second, "time allowed to each payload")
+	maxPayload := fs.Int("max-payload", 64<<20, "payload size refused with 413")
+	maxReport := fs.Int("max-report", 16<<20, "report size refused with 422")
+	sandbox := fs.Bool("sandbox", false, "parse and analyze each payload in a child process")
+	sandboxMemory := fs.Int64("sandbox-memory", 1<<30, "heap size of the sandbox children")
+	sandboxCPU := fs.Duration("sandbox-cpu", time.Minute, "CPU time of the sandbox children")
+	keys := fs.String("keys", "", "YAML key vault decrypting the encrypted payloads")
+	reportURL := fs.String("report-url", "", "link to the report in webhook notifications, %s is replaced by the sha256")
+
+	var webhooks []*pkg.Webhook
+
+	fs.Func("webhook", "notify url[,json|slack|teams[,min-severity]] of the findings (repeatable)", func(s string) error {
+		webhook, err := pkg.ParseWebhook(s)
+		if err == nil {
+			webhooks = append(webhooks, webhook)
+		}
+
+		return err
+	})
+	workers := map[string]*int{}
+
+	for _, stage := range []string{pkg.StageDecode, pkg.StageParse, pkg.StageAnalyze, pkg.StageReport} {
+		workers[stage] = fs.Int(stage+"-workers", runtime.NumCPU(), "concurrency of the "+stage+" stage")
+	}
+
+	parserOptions := parserFlags(fs)
+	_ = fs.Parse(args)
+
+	// re-executed by the parent with the same flags
+	if pkg.IsSandboxChild() {
+		if err := pkg.RunSandboxChild(os.Stdin, os.Stdout, parserOptions()...); err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	// plugins may register the format
+	options := parserOptions()
+
+	output, exists := pkg.OutputFormats[*format]
+	if !exists {
+		log.Fatalf("unknown report format '%s'\n", *format)
+	}
+
+	config := pkg.PipelineConfig{
+		Workers:          map[string]int{},
+		QueueSize:        *queue,
+		MaxInFlightBytes: *maxInFlight,
+		Format:           output,
+		Options:          options,
+		Timeout:          *timeout,
+		MaxPayloadSize:   *maxPayload,
+		MaxReportSize:    *maxReport,
+	}
+
+	for stage, n := range workers {
+		config.Workers[stage] = *n
+	}
+
+	if *sandbox {
+		config.Sandbox = &pkg.Sandbox{Limits: pkg.SandboxLimits{Memory: *sandboxMemory, CPU: *sandboxCPU}}
+	}
+
+	if *keys != "" {
+		vault, err := pkg.LoadKeyVault(*keys)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		config.KeyVault = vault
+	}
+
+	for _, webhook := range webhooks {
+		webhook.ReportURL = *reportURL
+	}
+
+	pipeline := pkg.NewPipeline(config)
+	defer pipeline.Close()
+
+	http.HandleFunc("/analyze", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST a payload", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		// read one byte past the limit at most, so that oversized payloads are refused without being held
+		body := r.Body
+		if *maxPayload > 0 {
+			body = http.MaxBytesReader(w, r.Body, int64(*maxPayload)+1)
+		}
+
+		payload, err := ioutil.ReadAll(body)
+
+		switch {
+		case *maxPayload > 0 && len(payload) > *maxPayload:
+			writeRequestError(w, &pkg.RequestError{Status: http.StatusRequestEntityTooLarge, Code: "payload_too_large",
+				Message: fmt.Sprintf("payload too large: more than %d bytes", *maxPayload)})
+
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
 
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+
+		res, err := pipeline.Submit(r.Context(), name, payload)
+		if err != nil {
+			writeRequestError(w, pkg.AsRequestError(err))
+
+			return
+		}
+
+		// notify once the report is out, the client does not wait for the webhooks
+		if len(webhooks) > 0 {
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+
+				if err := pkg.NotifyAll(ctx, webhooks, name, res.Analysis); err != nil {
+					log.Println(err)
+				}
+			}()
+		}
+
+		_, _ = w.Write(res.Report)
+	})
+
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(pipeline.Metrics())
+	})
+
+	log.Fatalln(http.ListenAndServe(*addr, nil))
+}
+
+// writeRequestError writes the structured error body of serve, asking busy clients to retry.
+func writeRequestError(w http.ResponseWriter, re *pkg.RequestError) {
+	if re.Status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(re.Status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": re})
+}
+
+func minimize(args []string) {
+	fs := flag.NewFlagSet("minimize", flag.ExitOnError)
+	out := fs.String("o", "", "reproducer file, <file>.min by default")
+	match := fs.String("match", "", "keep inputs whose parse error contains this text, the same failure by default")
+	withMetadata := metadataFlags(fs, "minimize")
+	parserOptions := parserFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	data, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	options := parserOptions()
+
+	fails := func(candidate []byte) bool {
+		_, err := pkg.ParseSerializedObject(candidate, options...)
+
+		return err != nil && strings.Contains(err.Error(), *match)
+	}
+
+	if *match == "" {
+		if fails, err = pkg.SameParseFailure(data, options...); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	res, err := pkg.Minimize(data, fails)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *out == "" {
+		*out = fs.Arg(0) + ".min"
+	}
+
+	if err = ioutil.WriteFile(*out, withMetadata(res), 0644); err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("%s: %d -> %d bytes\n", *out, len(data), len(res))
+}
+
+// defuse writes an inert variant of a payload for archival and prints its edits.
+func defuse(args []string) {
+	fs := flag.NewFlagSet("defuse", flag.ExitOnError)
+	out := fs.String("o", "", "defused file, <file>.defused by default")
+	magic := fs.Bool("magic", false, "also zero the stream magic, so that no ObjectInputStream reads the file")
+	withMetadata := metadataFlags(fs, "defuse")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	data, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	res, edits, err := pkg.Defuse(data, *magic)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *out == "" {
+		*out = fs.Arg(0) + ".defused"
+	}
+
+	if err = ioutil.WriteFile(*out, withMetadata(res), 0644); err != nil {
+		log.Fatalln(err)
+	}
+
+	for _, edit := range edits {
+		fmt.Println(edit)
+	}
+
+	fmt.Printf("%s: %d edits\n", *out, len(edits))
+}
+
+// jvmdiff prints the divergences of the parses of files from ObjectInputStream, read in a helper JVM. It exits with
+// status 1 when one diverges.
+func jvmdiff(args []string) {
+	fs := flag.NewFlagSet("jvmdiff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the divergences as a JSON object keyed by file")
+	java := fs.String("jvm", "java", "java command running the ObjectInputStream helper")
+	parserOptions := parserFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	options := parserOptions()
+	results := map[string][]string{}
+	diverged := false
+
+	jvm, err := pkg.StartJVM(*java)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	defer func() { _ = jvm.Close() }()
+
+	for _, file := range fs.Args() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		read, err := jvm.Deserialize(data)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		divergences := pkg.JVMDivergences(data, read, options...)
+		results[file] = divergences
+		diverged = diverged || len(divergences) > 0
+
+		if *asJSON {
+			continue
+		}
+
+		if len(divergences) == 0 {
+			fmt.Printf("%s: read like ObjectInputStream\n", file)
+		} else {
+			fmt.Printf("%s: %d divergences from ObjectInputStream\n", file, len(divergences))
+		}
+
+		for _, divergence := range divergences {
+			fmt.Printf("  jvm: %s\n", divergence)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(results); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	if diverged {
+		os.Exit(1)
+	}
+}
+
+// risk prints which known gadget chains the classes of an application allow, it exits with status 1 when one is
+// constructible.
+func risk(args []string) {
+	fs := flag.NewFlagSet("risk", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the chains as a JSON array")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	classPath, err := pkg.LoadClassPath(fs.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	risks := pkg.AssessGadgetRisk(classPath)
+	constructible := false
+
+	for _, r := range risks {
+		constructible = constructible || r.Status == pkg.ChainConstructible
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(risks); err != nil {
+			log.Fatalln(err)
+		}
+	} else {
+		for _, r := range risks {
+			switch r.Status {
+			case pkg.ChainConstructible:
+				fmt.Printf("%s: %s from %s\n", r.Chain, r.Status, strings.Join(r.Sources, ", "))
+			case pkg.ChainPartial:
+				fmt.Printf("%s: %s, missing %s\n", r.Chain, r.Status, strings.Join(r.Missing, ", "))
+			default:
+				fmt.Printf("%s: %s\n", r.Chain, r.Status)
+			}
+		}
+
+		fmt.Printf("%d classes indexed\n", classPath.Len())
+	}
+
+	if constructible {
+		os.Exit(1)
+	}
+}
+
+// decrypt prints which profiles of a key vault decrypt a payload, or tries a key list in parallel, and writes the
+// stream decrypted, it exits with status 1 when no key decrypts the payload.
+func decrypt(args []string) {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	keys := fs.String("keys", "", "YAML key vault")
+	keyList := fs.String("keylist", "", "file of keys to try in parallel after the vault ones, one per line")
+	algorithm := fs.String("algorithm", pkg.KeyAESCBC, "algorithm of the key list keys")
+	workers := fs.Int("workers", runtime.NumCPU(), "concurrency of the key list trial")
+	progress := fs.Bool("progress", false, "print the progress of the key list trial every second to stderr")
+	out := fs.String("o", "", "output file of the decrypted stream, <file>.ser by default")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 || (*keys == "" && *keyList == "") {
+		usage()
+	}
+
+	vault := &pkg.KeyVault{}
+
+	if *keys != "" {
+		var err error
+
+		if vault, err = pkg.LoadKeyVault(*keys); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var found *pkg.KeyResult
+
+	if *keyList == "" {
+		results := vault.TryKeys(data)
+
+		for i := range results {
+			if results[i].OK && found == nil {
+				found = &results[i]
+			}
+
+			if !*asJSON && results[i].OK {
+				fmt.Printf("%s: decrypted, %d bytes\n", results[i].Profile, len(results[i].Data))
+			} else if !*asJSON {
+				fmt.Printf("%s: %s\n", results[i].Profile, results[i].Error)
+			}
+		}
+
+		if *asJSON {
+			printIndentedJSON(results)
+		}
+	} else {
+		profiles, err := pkg.LoadKeyList(*keyList, *algorithm)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		trial := &pkg.KeyTrial{Workers: *workers}
+
+		if *progress {
+			trial.Progress = func(p pkg.KeyTrialProgress) {
+				fmt.Fprintf(os.Stderr, "%d/%d keys tried, %.0f keys/s\n", p.Tried, p.Total, p.Rate)
+			}
+		}
+
+		res, err := trial.Run(context.Background(), data, append(vault.Profiles, profiles...))
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		found = res.Found
+
+		if *asJSON {
+			printIndentedJSON(res)
+		} else {
+			if found != nil {
+				fmt.Printf("%s: decrypted, %d bytes\n", found.Profile, len(found.Data))
+			} else {
+				fmt.Println("no key decrypts the payload")
+			}
+
+			fmt.Printf("%d/%d keys tried in %.2fs, %.0f keys/s\n", res.Tried, res.Total, res.Seconds, res.Rate)
+		}
+	}
+
+	if found == nil {
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		*out = fs.Arg(0) + ".ser"
+	}
+
+	if err := ioutil.WriteFile(*out, found.Data, 0644); err != nil {
+		log.Fatalln(err)
+	}
+
+	if !*asJSON {
+		fmt.Printf("wrote %s\n", *out)
+	}
+}
+
+// printIndentedJSON prints a value as indented JSON.
+func printIndentedJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(v); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// compat prints the ObjectInputStream compatibility of files, it exits with status 1 when one is rejected.
+func compat(args []string) {
+	fs := flag.NewFlagSet("compat", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the verdicts as a JSON object keyed by file")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	results := map[string]*pkg.Compatibility{}
+	rejected := false
+
+	for _, file := range fs.Args() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		res := pkg.CheckCompatibility(data)
+		results[file] = res
+		rejected = rejected || res.Verdict == pkg.CompatRejected
+
+		if *asJSON {
+			continue
+		}
+
+		fmt.Printf("%s: %s\n", file, res.Verdict)
+
+		for _, reason := range res.Reasons {
+			fmt.Printf("  offset %d (%s): %s\n", reason.Offset, reason.Check, reason.Detail)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(results); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	if rejected {
+		os.Exit(1)
+	}
+}
+
+func infer(args []string) {
+	fs := flag.NewFlagSet("infer", flag.ExitOnError)
+	check := fs.String("check", "", "print the deviations of the streams from a schema written by infer instead")
+	parserOptions := parserFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	options := parserOptions()
+
+	if *check != "" {
+		checkSchema(*check, fs.Args(), options)
+
+		return
+	}
+
+	inference := pkg.NewSchemaInference()
+
+	for _, file := range fs.Args() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Println(err)
+
+			continue
+		}
+
+		content, err := pkg.ParseSerializedObject(data, options...)
+		if err != nil {
+			log.Printf("%s: %v\n", file, err)
+		}
+
+		inference.Add(content)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(inference.JSONSchema()); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func coverage(args []string) {
+	fs := flag.NewFlagSet("coverage", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the matrix as JSON")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	cov := pkg.NewCoverage()
+
+	for _, file := range fs.Args() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Println(err)
+
+			continue
+		}
+
+		if err = cov.Add(file, data); err != nil {
+			log.Printf("%s: %v\n", file, err)
+		}
+	}
+
+	matrix := cov.Matrix()
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(matrix); err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
+	fmt.Printf("%d streams, %d incomplete\n", matrix.Streams, len(matrix.Incomplete))
+
+	for _, category := range []string{pkg.CoverageElements, pkg.CoverageFlags, pkg.CoverageFieldTypes,
+		pkg.CoverageArrayTypes} {
+		entries := matrix.Categories[category]
+		fmt.Printf("\n%s (%d/%d standard seen)\n", category, len(entries)-len(matrix.Missing[category]),
+			len(entries))
+
+		for _, entry := range entries {
+			fmt.Printf("  %-40s %10d %8d streams\n", entry.Name, entry.Count, entry.Streams)
+		}
+	}
+}
+
+func checkSchema(path string, files []string, options []pkg.Option) {
+	learned, err := pkg.LoadLearnedSchema(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	deviating := false
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		content, err := pkg.ParseSerializedObject(data, options...)
+		if err != nil {
+			log.Printf("%s: %v\n", file, err)
+		}
+
+		deviations := learned.Check(content)
+		if len(deviations) == 0 {
+			fmt.Printf("%s: conforming\n", file)
+
+			continue
+		}
+
+		deviating = true
+
+		fmt.Printf("%s: deviating\n", file)
+
+		for _, d := range deviations {
+			fmt.Printf("  %s %s", d.Kind, d.Class)
+
+			if d.Field != "" {
+				fmt.Printf(".%s", d.Field)
+			}
+
+			fmt.Printf(": %s (%d times)\n", d.Detail, d.Count)
+		}
+	}
+
+	if deviating {
+		os.Exit(1)
+	}
+}
+
+func record(args []string) {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:0", "listen address")
+	target := fs.String("target", "", "address of the server")
+	out := fs.String("o", "", "session file")
+	rules := fs.String("rules", "", "YAML substitution rules applied to the client messages")
+	_ = fs.Parse(args)
+
+	if *target == "" || *out == "" {
+		usage()
+	}
+
+	subs := loadSubstitutions(*rules)
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	defer f.Close()
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	log.Printf("relaying %s to %s\n", l.Addr(), *target)
+
+	recorder := &pkg.Recorder{Target: *target, Out: pkg.NewSessionWriter(f), OnError: func(conn int, err error) {
+		log.Printf("connection %d: %v\n", conn, err)
+	}}
+
+	if subs != nil {
+		recorder.Rewrite = subs.Rewrite
+	}
+
+	log.Fatalln(recorder.Serve(l))
+}
+
+func fuzz(args []string) {
+	fs := flag.NewFlagSet("fuzz", flag.ExitOnError)
+	count := fs.Int("n", 100, "number of variants")
+	seed := fs.Int64("seed", 1, "seed of the mutations, the same seed giving the same variants")
+	kinds := fs.String("kinds", "", "comma separated mutation kinds, all by default: "+
+		strings.Join(pkg.MutationKinds(), ", "))
+	out := fs.String("o", "", "file name pattern of the variants, <file>.%04d.ser by default")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	if *out == "" {
+		*out = fs.Arg(0) + ".%04d.ser"
+	}
+
+	data, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var selected []string
+
+	if *kinds != "" {
+		selected = strings.Split(*kinds, ",")
+	}
+
+	mutator, err := pkg.NewMutator(data, *seed, selected...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	for i := 1; i <= *count; i++ {
+		variant, mutation := mutator.Mutate()
+		name := fmt.Sprintf(*out, i)
+
+		if err = ioutil.WriteFile(name, variant, 0o644); err != nil {
+			log.Fatalln(err)
+		}
+
+		fmt.Printf("%s: %s\n", name, mutation)
+	}
+}
+
+func jmx(args []string) {
+	fs := flag.NewFlagSet("jmx", flag.ExitOnError)
+	session := fs.Bool("session", false, "read a session file written by record instead of raw traffic")
+	asJSON := fs.Bool("json", false, "print the messages as JSON Lines")
+	parserOptions := parserFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	data, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	msgs := []pkg.SessionMessage{{Conn: 1, Direction: pkg.DirectionClient, Data: data}}
+
+	if *session {
+		if msgs, err = pkg.ReadSession(bytes.NewReader(data)); err != nil {
+			log.Fatalln(err)
+		}
+
+		msgs = pkg.CoalesceSession(msgs)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+
+	for _, msg := range msgs {
+		decoded, err := pkg.DecodeJMX(msg.Data, parserOptions()...)
+		if err != nil {
+			continue
+		}
+
+		for _, m := range decoded {
+			arrow := "->"
+			if m.Kind == pkg.JMXReturn {
+				arrow = "<-"
+			}
+
+			switch {
+			case *asJSON:
+				if err = enc.Encode(m); err != nil {
+					log.Fatalln(err)
+				}
+			case *session:
+				fmt.Printf("conn %d seq %d offset %d: %s %s\n", msg.Conn, msg.Seq, m.Offset, arrow, m.Description)
+			default:
+				fmt.Printf("offset %d: %s %s\n", m.Offset, arrow, m.Description)
+			}
+
+			if m.Error != "" && !*asJSON {
+				fmt.Printf("  error: %s\n", m.Error)
+			}
+		}
+	}
+}
+
+// loadSubstitutions loads the substitution rules of a file, nil without file.
+func loadSubstitutions(path string) *pkg.Substitutions {
+	if path == "" {
+		return nil
+	}
+
+	subs, err := pkg.LoadSubstitutions(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return subs
+}
+
+func replay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	target := fs.String("target", "", "address of the test server")
+	timeout := fs.Duration("timeout", 5*time.Second, "time allowed to each server response")
+	timing := fs.Bool("timing", false, "wait between the client messages as long as in the capture")
+	out := fs.String("o", "", "write the replayed session, responses included, to this file")
+	rules := fs.String("rules", "", "YAML substitution rules applied to the client messages")
+	config := pkg.ReplayConfig{Edits: map[int][]byte{}}
+
+	fs.Func("replace", "send the content of a file instead of a client message, seq=file (repeatable)", func(s string) error {
+		idx := strings.Index(s, "=")
+		if idx < 0 {
+			return fmt.Errorf("invalid replacement '%s', want seq=file", s)
+		}
+
+		seq, err := strconv.Atoi(s[:idx])
+		if err != nil {
+			return err
+		}
+
+		if config.Edits[seq], err = ioutil.ReadFile(s[idx+1:]); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	_ = fs.Parse(args)
+
+	if *target == "" || fs.NArg() != 1 {
+		usage()
+	}
+
+	config.ReadTimeout, config.KeepTiming = *timeout, *timing
+
+	if subs := loadSubstitutions(*rules); subs != nil {
+		config.Rewrite = func(data []byte) []byte {
+			return subs.Rewrite(pkg.DirectionClient, data)
+		}
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	msgs, err := pkg.ReadSession(f)
+	f.Close()
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var w *pkg.SessionWriter
+
+	if *out != "" {
+		o, err := os.Create(*out)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		defer o.Close()
+
+		w = pkg.NewSessionWriter(o)
+	}
+
+	conns := map[int][]pkg.SessionMessage{}
+
+	var order []int
+
+	for _, msg := range msgs {
+		if _, exists := conns[msg.Conn]; !exists {
+			order = append(order, msg.Conn)
+		}
+
+		conns[msg.Conn] = append(conns[msg.Conn], msg)
+	}
+
+	for _, id := range order {
+		conn, err := net.DialTimeout("tcp", *target, *timeout)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		replayed, err := pkg.Replay(conn, conns[id], config)
+		conn.Close()
+
+		for _, msg := range replayed {
+			fmt.Printf("connection %d: %s %d bytes\n", id, msg.Direction, len(msg.Data))
+
+			if w != nil {
+				if err := w.Write(id, msg.Direction, msg.Data); err != nil {
+					log.Fatalln(err)
+				}
+			}
+		}
+
+		if err != nil {
+			log.Printf("connection %d: %v\n", id, err)
+		}
+	}
+}
+
+func capabilities() {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(pkg.CurrentCapabilities()); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func schema(name string) {
+	s, exists := pkg.Schemas[name]
+	if !exists {
+		log.Fatalf("unknown schema '%s'\n", name)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(s); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// misp creates a MISP event per analyzed file and prints the event IDs, it exits with status 1 when a file fails.
+func misp(args []string) {
+	fs := flag.NewFlagSet("misp", flag.ExitOnError)
+	endpoint := fs.String("url", "", "base URL of the MISP instance")
+	key := fs.String("key", os.Getenv("MISP_KEY"), "API key of the MISP instance, $MISP_KEY by default")
+	insecure := fs.Bool("insecure", false, "do not verify the TLS certificate of the instance")
+	distribution := fs.Int("distribution", 0, "event distribution, 0 for the organisation only")
+	timeout := fs.Duration("timeout", time.Minute, "time allowed to each event submission")
+
+	var tags []string
+
+	fs.Func("tag", "tag the events (repeatable)", func(s string) error {
+		tags = append(tags, s)
+
+		return nil
+	})
+
+	parserOptions := parserFlags(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 || *endpoint == "" {
+		usage()
+	}
+
+	client := pkg.NewMISPClient(*endpoint, *key, *insecure)
+	client.Distribution = *distribution
+	client.Tags = tags
+
+	options := parserOptions()
+	failed := false
+
+	for _, file := range fs.Args() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Println(err)
+
+			failed = true
+
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		id, err := client.SubmitEvent(ctx, filepath.Base(file), pkg.Analyze(data, options...))
+		cancel()
+
+		if err != nil {
+			log.Printf("%s: %v\n", file, err)
+
+			failed = true
+
+			continue
+		}
+
+		fmt.Printf("%s: event %s\n", file, id)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
 }
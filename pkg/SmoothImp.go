@@ -1,5 +1,12 @@
 package pkg
 
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
 func (this *Smooth) add(b1 byte) {
 	this.data = append([]byte{b1}, this.data...)
 }
@@ -26,3 +33,79 @@ func (this *Smooth) peek() uint8 {
 
 	return b
 }
+
+// remaining returns the number of input bytes left to pop, -1 when the parser does not read a buffer.
+func (this *Smooth) remaining() int64 {
+	remaining := this._p.remaining()
+	if remaining < 0 {
+		return -1
+	}
+
+	return remaining + int64(len(this.data))
+}
+
+// take pops the n bytes of a value named what, failing when the input ends first instead of reading zeros like pop.
+func (this *Smooth) take(n int, what string) []byte {
+	b := make([]byte, n)
+
+	for i := range b {
+		if 0 < len(this.data) {
+			b[i] = this.pop()
+
+			continue
+		}
+
+		x, err := this._p.readUInt8()
+		if err != nil {
+			panic(errors.Wrapf(io.ErrUnexpectedEOF, "premature end of input reading %s", what))
+		}
+
+		b[i] = x
+	}
+
+	return b
+}
+
+// uint16 pops a big-endian uint16 named what, with its bytes.
+func (this *Smooth) uint16(what string) (uint16, []byte) {
+	b := this.take(2, what)
+
+	return binary.BigEndian.Uint16(b), b
+}
+
+// uint32 pops a big-endian uint32 named what, with its bytes.
+func (this *Smooth) uint32(what string) (uint32, []byte) {
+	b := this.take(4, what)
+
+	return binary.BigEndian.Uint32(b), b
+}
+
+// uint64 pops a big-endian uint64 named what, with its bytes.
+func (this *Smooth) uint64(what string) (uint64, []byte) {
+	b := this.take(8, what)
+
+	return binary.BigEndian.Uint64(b), b
+}
+
+// checkLength fails when n items of at least unit bytes cannot fit in the rest of the input, so that hostile lengths
+// are reported where they are read rather than by a long loop of reads past the end.
+func (this *Smooth) checkLength(n uint64, unit int, what string) {
+	if err := lengthError(n, unit, this.remaining(), what); err != nil {
+		panic(err)
+	}
+}
+
+// lengthError tells whether n items of at least unit bytes exceed the remaining bytes of the input (nothing is known
+// when remaining is negative). The error is a truncation, its cause being io.ErrUnexpectedEOF.
+func lengthError(n uint64, unit int, remaining int64, what string) error {
+	if remaining < 0 || n <= uint64(remaining)/uint64(unit) {
+		return nil
+	}
+
+	if unit == 1 {
+		return errors.Wrapf(io.ErrUnexpectedEOF, "%s %d exceeds the %d remaining bytes", what, n, remaining)
+	}
+
+	return errors.Wrapf(io.ErrUnexpectedEOF, "%s %d exceeds the %d remaining bytes (%d bytes each at least)", what, n,
+		remaining, unit)
+}
@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// newTestSmooth returns the Smooth reader of a parser reading b as a buffer, so that its remaining bytes are known.
+func newTestSmooth(b []byte) *Smooth {
+	return &NewSerializedObjectParser(bytes.NewReader(b), bufferOptions(b, nil)...)._data
+}
+
+// smoothPanic returns the error f panics with.
+func smoothPanic(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var isError bool
+			if err, isError = r.(error); !isError {
+				panic(r)
+			}
+		}
+	}()
+
+	f()
+
+	return nil
+}
+
+func TestSmoothIntegers(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e}
+	smooth := newTestSmooth(data)
+
+	if v, raw := smooth.uint16("u16"); v != 0x0102 || !bytes.Equal(raw, data[:2]) {
+		t.Errorf("uint16: got %#x %x", v, raw)
+	}
+
+	if v, raw := smooth.uint32("u32"); v != 0x03040506 || !bytes.Equal(raw, data[2:6]) {
+		t.Errorf("uint32: got %#x %x", v, raw)
+	}
+
+	// exact fit: the last 8 bytes
+	if v, raw := smooth.uint64("u64"); v != 0x0708090a0b0c0d0e || !bytes.Equal(raw, data[6:]) {
+		t.Errorf("uint64: got %#x %x", v, raw)
+	}
+
+	if remaining := smooth.remaining(); remaining != 0 {
+		t.Errorf("got %d remaining bytes, want 0", remaining)
+	}
+}
+
+func TestSmoothTruncated(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		read func(smooth *Smooth)
+	}{
+		{"uint16", []byte{0x01}, func(smooth *Smooth) { smooth.uint16("u16") }},
+		{"uint32", []byte{0x01, 0x02, 0x03}, func(smooth *Smooth) { smooth.uint32("u32") }},
+		{"uint64", []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07}, func(smooth *Smooth) { smooth.uint64("u64") }},
+		{"take", nil, func(smooth *Smooth) { smooth.take(1, "byte") }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := smoothPanic(func() { test.read(newTestSmooth(test.data)) })
+			if errors.Cause(err) != io.ErrUnexpectedEOF {
+				t.Errorf("got %v, want a premature end of input", err)
+			}
+		})
+	}
+}
+
+func TestSmoothTakePushedBack(t *testing.T) {
+	smooth := newTestSmooth([]byte{0x01, 0x02, 0x03})
+
+	if b := smooth.peek(); b != 0x01 {
+		t.Fatalf("peek: got %#x", b)
+	}
+
+	if b := smooth.take(3, "bytes"); !bytes.Equal(b, []byte{0x01, 0x02, 0x03}) {
+		t.Errorf("take: got %x, want the peeked byte first", b)
+	}
+}
+
+func TestSmoothCheckLength(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		n    uint64
+		unit int
+		fail bool
+	}{
+		{"exact fit", []byte("abc"), 3, 1, false},
+		{"utf length over the remaining bytes", []byte("abc"), 4, 1, true},
+		{"negative long length", []byte("abc"), 0xffffffffffffffff, 1, true},
+		{"items of several bytes", []byte("abcdef"), 3, 2, false},
+		{"items of several bytes over", []byte("abcdef"), 4, 2, true},
+		{"empty", nil, 0, 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := smoothPanic(func() { newTestSmooth(test.data).checkLength(test.n, test.unit, "length") })
+
+			switch {
+			case test.fail && errors.Cause(err) != io.ErrUnexpectedEOF:
+				t.Errorf("got %v, want a truncation", err)
+			case !test.fail && err != nil:
+				t.Errorf("got %v, want no error", err)
+			}
+		})
+	}
+}
+
+func TestSmoothCheckLengthUnknownInput(t *testing.T) {
+	// a parser reading a stream knows nothing of the remaining bytes
+	smooth := &NewSerializedObjectParser(bytes.NewReader(nil))._data
+
+	if err := smoothPanic(func() { smooth.checkLength(1<<40, 1, "length") }); err != nil {
+		t.Errorf("got %v, want no error", err)
+	}
+}
+
+func TestDumpLengths(t *testing.T) {
+	header := []byte{STREAM_MAGIC1, STREAM_MAGIC2, 0x00, STREAM_VERSION}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"utf length over the remaining bytes", []byte{TC_STRING, 0x00, 0x04, 'a', 'b', 'c'},
+			"utf length 4 exceeds the 3 remaining bytes"},
+		{"negative long utf length", []byte{TC_LONGSTRING, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 'a'},
+			"long utf length 18446744073709551615 exceeds the 1 remaining bytes"},
+		{"truncated long utf length", []byte{TC_LONGSTRING, 0x00, 0x00}, "premature end of input reading long utf length"},
+		{"exact fit", []byte{TC_STRING, 0x00, 0x03, 'a', 'b', 'c'}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := DumpSerializedObject(append(append([]byte{}, header...), test.data...), SetDumpWriter(io.Discard))
+
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("got %v, want no error", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Errorf("got %v, want %q", err, test.err)
+			}
+		})
+	}
+}
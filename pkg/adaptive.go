@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrMemoryCap is the cause of the error returned when parsing the rest of a stream would exceed the memory cap set
+// with SetMemoryCap.
+var ErrMemoryCap = errors.New("memory cap exceeded")
+
+// SetMemoryCap caps the memory ParseSerializedObject and Analyze may allocate. Streams whose Estimate fits under the
+// cap are parsed as usual, the others are parsed element by element with interned strings and parsing stops before
+// the first top-level element which would take the memory over the cap, returning the elements parsed so far along
+// with an ErrMemoryCap error. The cap only truncates the result: every element returned is parsed in full, and a
+// first element larger than the cap leaves nothing to return.
+func SetMemoryCap(maxMemory int64) Option {
+	return func(this *SerializedObjectParser) {
+		this.memoryCap = maxMemory
+	}
+}
+
+// SetContext aborts parsing with the context error once ctx is done, bounding the time spent on a stream.
+func SetContext(ctx context.Context) Option {
+	return func(this *SerializedObjectParser) {
+		this.ctx = ctx
+	}
+}
+
+// parseWithinCap parses buf with this parser, switching to the incremental path when its estimate exceeds the
+// memory cap.
+func (this *SerializedObjectParser) parseWithinCap(buf []byte) ([]interface{}, error) {
+	if this.memoryCap <= 0 {
+		return this.ParseSerializedObject()
+	}
+
+	est := Estimate(buf)
+	if !est.Exceeds(this.memoryCap) {
+		return this.ParseSerializedObject()
+	}
+
+	return this.parseIncrementally(est)
+}
+
+// parseIncrementally parses the top-level elements one at a time, charging each with its estimated memory.
+// Elements past the end of an incomplete estimate cannot be charged and are parsed as they come.
+func (this *SerializedObjectParser) parseIncrementally(est *SizeEstimate) (content []interface{}, err error) {
+	if this.interner == nil {
+		this.interner = NewInterner()
+	}
+
+	var used int64
+
+	for i := 0; ; i++ {
+		if i < len(est.elementMemory) {
+			if used += est.elementMemory[i]; used > this.memoryCap {
+				return content, errors.Wrapf(ErrMemoryCap, "element %d needs about %d bytes out of %d",
+					i, used, this.memoryCap)
+			}
+		}
+
+		var el *Element
+
+		if el, err = this.NextElement(); err != nil {
+			if err == io.EOF {
+				err = nil
+			} else if errors.Cause(err) == io.EOF {
+				err = errors.New("premature end of input")
+			}
+
+			return
+		}
+
+		content = append(content, el.Content)
+
+		if this.stopAfterFirst {
+			return
+		}
+	}
+}
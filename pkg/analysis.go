@@ -0,0 +1,364 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Severity ranks analysis findings.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"info", "low", "medium", "high", "critical"}
+
+// String returns the severity name.
+func (this Severity) String() string {
+	if this < 0 || int(this) >= len(severityNames) {
+		return "unknown"
+	}
+
+	return severityNames[this]
+}
+
+// MarshalText encodes the severity by name.
+func (this Severity) MarshalText() ([]byte, error) {
+	return []byte(this.String()), nil
+}
+
+// UnmarshalText decodes a severity name.
+func (this *Severity) UnmarshalText(text []byte) error {
+	s, err := ParseSeverity(string(text))
+	if err == nil {
+		*this = s
+	}
+
+	return err
+}
+
+// ParseSeverity parses a severity name.
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(n, name) {
+			return Severity(i), nil
+		}
+	}
+
+	return SeverityInfo, errors.Errorf("unknown severity '%s'", name)
+}
+
+// Finding is something noteworthy found while analyzing a stream.
+type Finding struct {
+	RuleID   string   `json:"ruleId"`
+	Severity Severity `json:"severity"`
+	Title    string   `json:"title"`
+	Class    string   `json:"class,omitempty"` // class which triggered the rule
+	Chain    string   `json:"chain,omitempty"` // gadget chain the class belongs to
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// GadgetRule flags a class known to be part of deserialization gadget chains.
+type GadgetRule struct {
+	ID       string
+	Severity Severity
+	Chain    string // name of the best known chain using the class
+}
+
+// KnownGadgetClasses maps class names to the rule raised when an instance or descriptor of the class is found.
+var KnownGadgetClasses = map[string]GadgetRule{
+	"org.apache.commons.collections.functors.InvokerTransformer":                {"GADGET-CC-INVOKER", SeverityCritical, "CommonsCollections1"},
+	"org.apache.commons.collections.functors.ChainedTransformer":                {"GADGET-CC-CHAINED", SeverityHigh, "CommonsCollections1"},
+	"org.apache.commons.collections.functors.InstantiateTransformer":            {"GADGET-CC-INSTANTIATE", SeverityCritical, "CommonsCollections3"},
+	"org.apache.commons.collections.map.LazyMap":                                {"GADGET-CC-LAZYMAP", SeverityHigh, "CommonsCollections1"},
+	"org.apache.commons.collections.keyvalue.TiedMapEntry":                      {"GADGET-CC-TIEDMAPENTRY", SeverityHigh, "CommonsCollections6"},
+	"org.apache.commons.collections4.functors.InvokerTransformer":               {"GADGET-CC4-INVOKER", SeverityCritical, "CommonsCollections2"},
+	"org.apache.commons.collections4.functors.InstantiateTransformer":           {"GADGET-CC4-INSTANTIATE", SeverityCritical, "CommonsCollections4"},
+	"org.apache.commons.collections4.comparators.TransformingComparator":        {"GADGET-CC4-COMPARATOR", SeverityHigh, "CommonsCollections2"},
+	"org.apache.commons.beanutils.BeanComparator":                               {"GADGET-CB-BEANCOMPARATOR", SeverityHigh, "CommonsBeanutils1"},
+	"com.sun.org.apache.xalan.internal.xsltc.trax.TemplatesImpl":                {"GADGET-TEMPLATESIMPL", SeverityCritical, "TemplatesImpl"},
+	"org.apache.xalan.xsltc.trax.TemplatesImpl":                                 {"GADGET-TEMPLATESIMPL", SeverityCritical, "TemplatesImpl"},
+	"sun.reflect.annotation.AnnotationInvocationHandler":                        {"GADGET-ANNOTATION-HANDLER", SeverityHigh, "CommonsCollections1"},
+	"javax.management.BadAttributeValueExpException":                            {"GADGET-BADATTRIBUTE", SeverityHigh, "CommonsCollections5"},
+	"org.springframework.beans.factory.ObjectFactory":                           {"GADGET-SPRING-OBJECTFACTORY", SeverityHigh, "Spring1"},
+	"org.springframework.core.SerializableTypeWrapper$MethodInvokeTypeProvider": {"GADGET-SPRING-TYPEPROVIDER", SeverityCritical, "Spring1"},
+	"com.sun.rowset.JdbcRowSetImpl":                                             {"GADGET-JDBCROWSET", SeverityCritical, "JdbcRowSetImpl"},
+	"org.codehaus.groovy.runtime.ConvertedClosure":                              {"GADGET-GROOVY-CLOSURE", SeverityCritical, "Groovy1"},
+	"org.codehaus.groovy.runtime.MethodClosure":                                 {"GADGET-GROOVY-METHODCLOSURE", SeverityCritical, "Groovy1"},
+	"com.mchange.v2.c3p0.impl.PoolBackedDataSourceBase":                         {"GADGET-C3P0", SeverityCritical, "C3P0"},
+	"org.hibernate.engine.spi.TypedValue":                                       {"GADGET-HIBERNATE", SeverityHigh, "Hibernate1"},
+	"java.net.URL":                                                              {"PROBE-URL", SeverityLow, "URLDNS"},
+}
+
+// ClassSummary counts the instances of a class in a stream.
+type ClassSummary struct {
+	Name             string `json:"name"`
+	SerialVersionUID string `json:"serialVersionUID"`
+	Instances        int    `json:"instances"`
+	ClassPath        string `json:"classPath,omitempty"` // status in the class path of the application, see SetClassPath
+	WireName         string `json:"wireName,omitempty"`  // name in the stream when a mapping renamed the class
+}
+
+// Analysis summarizes a serialized stream.
+type Analysis struct {
+	SHA256      string         `json:"sha256"`
+	Size        int            `json:"size"`
+	Elements    int            `json:"elements"`
+	Classes     []ClassSummary `json:"classes"`
+	Protocol    *ProtocolInfo  `json:"protocol"`
+	Fingerprint string         `json:"fingerprint"`           // hash of the sorted class name@serialVersionUID list
+	ContentHash string         `json:"contentHash,omitempty"` // see ContentHash
+	Findings    []Finding      `json:"findings"`
+	GadgetPaths []GadgetPath   `json:"gadgetPaths,omitempty"` // trigger paths of the detected gadget chains
+	Libraries   []Library      `json:"libraries,omitempty"`   // probable Maven artifacts of the classes
+	Suppressed  int            `json:"suppressed,omitempty"`  // findings dropped by a FindingFilter
+	IOCs        []IOC          `json:"iocs"`
+	Strings     []string       `json:"strings,omitempty"` // notable strings: commands, paths, URLs
+	Error       string         `json:"error,omitempty"`
+	Trailing    *TrailingData  `json:"trailing,omitempty"` // input left after the last complete top-level element
+	Provenance  *Provenance    `json:"provenance,omitempty"`
+
+	raw []byte // analyzed stream, used for report excerpts
+}
+
+// Analyze parses a serialized stream and summarizes its classes, structural fingerprint and findings.
+func Analyze(buf []byte, options ...Option) *Analysis {
+	parsed := parseForAnalysis(buf, options)
+	if err := parsed.analyze(); err != nil {
+		parsed.res.Error = err.Error()
+	}
+
+	return parsed.res
+}
+
+// parsedStream is a stream parsed for analysis, split from the analysis itself so that both can run in distinct
+// Pipeline stages.
+type parsedStream struct {
+	res       *Analysis
+	content   []interface{}
+	cache     *ClassCache
+	classPath *ClassPath
+	err       error
+}
+
+func parseForAnalysis(buf []byte, options []Option) *parsedStream {
+	sum := sha256.Sum256(buf)
+	res := &Analysis{SHA256: hex.EncodeToString(sum[:]), Size: len(buf), raw: buf}
+	res.Provenance = NewProvenance(options...)
+	cache := NewClassCache()
+
+	options = bufferOptions(buf, options, SetClassCache(cache))
+	parser := NewSerializedObjectParser(bytes.NewReader(buf), options...)
+
+	content, err := parser.parseWithinCap(buf)
+	res.Protocol = parser.Protocol()
+	res.Trailing = parser.Trailing()
+
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	return &parsedStream{res: res, content: content, cache: cache, classPath: parser.classPath, err: err}
+}
+
+// analyze summarizes the parsed stream and runs the detectors, panics are returned as a PanicError.
+func (this *parsedStream) analyze() (err error) {
+	defer recoverPanic(&err, this.res.raw, -1)
+
+	res, content, cache := this.res, this.content, this.cache
+	res.Elements = len(content)
+
+	instances := map[string]int{}
+	wireNames := map[string]string{}
+
+	walkContent(content, func(v interface{}) {
+		cls, isClazz := v.(*clazz)
+		if obj, isMap := v.(map[string]interface{}); isMap {
+			if cls, isClazz = obj["class"].(*clazz); isClazz && cls != nil {
+				instances[cls.name+"@"+cls.serialVersionUID]++
+			}
+		}
+
+		for ; isClazz && cls != nil; cls = cls.super {
+			if wire := cls.wireName(); wire != cls.name {
+				wireNames[cls.name+"@"+cls.serialVersionUID] = wire
+			}
+		}
+	})
+
+	ids := make([]string, 0, cache.Len())
+
+	for _, stat := range cache.Stats() {
+		id := stat.Name + "@" + stat.SerialVersionUID
+		ids = append(ids, id)
+		summary := ClassSummary{
+			Name:             stat.Name,
+			SerialVersionUID: stat.SerialVersionUID,
+			Instances:        instances[id],
+			WireName:         wireNames[id],
+		}
+
+		if this.classPath != nil {
+			summary.ClassPath = this.classPath.Status(stat.Name, stat.SerialVersionUID)
+		}
+
+		res.Classes = append(res.Classes, summary)
+
+		if rule, isGadget := KnownGadgetClasses[stat.Name]; isGadget {
+			finding := Finding{
+				RuleID:   rule.ID,
+				Severity: rule.Severity,
+				Title:    "known gadget class (" + rule.Chain + ")",
+				Class:    stat.Name,
+				Chain:    rule.Chain,
+			}
+
+			// the chain breaks at a class the application cannot load
+			switch summary.ClassPath {
+			case ClassAbsent:
+				finding.Detail = "class absent from the class path of the application"
+			case ClassMismatch:
+				finding.Detail = "serialVersionUID " + this.classPath.Lookup(stat.Name).SerialVersionUID +
+					" in the class path of the application"
+			}
+
+			res.Findings = append(res.Findings, finding)
+		}
+	}
+
+	res.Libraries = libraries(res.Classes)
+
+	sort.Strings(ids)
+	fp := sha256.Sum256([]byte(strings.Join(ids, "\n")))
+	res.Fingerprint = hex.EncodeToString(fp[:])
+
+	if hash, err := ContentHash(content); err == nil {
+		res.ContentHash = hash
+	}
+
+	res.IOCs = ExtractIOCs(content)
+	res.Strings = notableStrings(content)
+
+	for _, detector := range sortedDetectors() {
+		res.Findings = append(res.Findings, detector(res, content)...)
+	}
+
+	sort.SliceStable(res.Findings, func(i, j int) bool { return res.Findings[i].Severity > res.Findings[j].Severity })
+
+	for _, f := range res.Findings {
+		if f.Chain != "" {
+			res.GadgetPaths = GadgetPaths(content)
+
+			break
+		}
+	}
+
+	return nil
+}
+
+// notableMarkers flag strings worth showing in summaries.
+var notableMarkers = []string{
+	"://", "/bin/", "cmd.exe", "cmd /c", "powershell", "bash -c", "sh -c", "curl ", "wget ", "nc ", ".exe", ".jar",
+	"exec", "runtime", "processbuilder", "classloader", "jndi", "ldap", "rmi:", "base64", "/etc/", "c:\\",
+}
+
+// maxNotableStrings bounds the number of notable strings kept per analysis.
+const maxNotableStrings = 20
+
+// notableStrings collects the strings of parsed content which look like commands, paths or URLs.
+func notableStrings(content interface{}) []string {
+	var notable []string
+
+	seen := map[string]bool{}
+
+	walkContent(content, func(v interface{}) {
+		s, isString := v.(string)
+		if !isString || seen[s] {
+			return
+		}
+
+		lower := strings.ToLower(s)
+
+		for _, marker := range notableMarkers {
+			if strings.Contains(lower, marker) {
+				seen[s] = true
+				notable = append(notable, s)
+
+				return
+			}
+		}
+	})
+
+	sort.Strings(notable)
+
+	if len(notable) > maxNotableStrings {
+		notable = notable[:maxNotableStrings]
+	}
+
+	return notable
+}
+
+// Verdict rates the analysis from its most severe finding: malicious (high or critical), suspicious or clean.
+func (this *Analysis) Verdict() string {
+	switch {
+	case len(this.Findings) == 0:
+		return "clean"
+	case this.Findings[0].Severity >= SeverityHigh:
+		return "malicious"
+	}
+
+	return "suspicious"
+}
+
+// walkContent calls fn for every value of a parsed graph, shared objects are visited once.
+func walkContent(content interface{}, fn func(interface{})) {
+	seen := map[uintptr]bool{}
+
+	var walk func(interface{})
+
+	walk = func(v interface{}) {
+		if o, isMap := v.(map[string]interface{}); isMap {
+			if seen[mapIdentity(o)] {
+				return
+			}
+
+			seen[mapIdentity(o)] = true
+		}
+
+		fn(v)
+
+		switch o := v.(type) {
+		case map[string]interface{}:
+			for k, child := range o {
+				if k != "class" {
+					walk(child)
+				}
+			}
+		case []interface{}:
+			for _, child := range o {
+				walk(child)
+			}
+		}
+	}
+
+	walk(content)
+}
+
+// walkObjects calls fn for every parsed object of a graph, shared objects are visited once.
+func walkObjects(content interface{}, fn func(map[string]interface{})) {
+	walkContent(content, func(v interface{}) {
+		if o, isMap := v.(map[string]interface{}); isMap {
+			fn(o)
+		}
+	})
+}
@@ -0,0 +1,277 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxArchiveEntrySize bounds the number of bytes read from an archive entry.
+const maxArchiveEntrySize = 64 << 20
+
+// streamMagic starts every serialized stream.
+var streamMagic = []byte{STREAM_MAGIC1, STREAM_MAGIC2, 0x00, 0x05}
+
+// classFileMagic starts every class file.
+var classFileMagic = []byte{0xca, 0xfe, 0xba, 0xbe}
+
+// ArchiveEntry is a file of a scanned archive and the serialized streams carved from it.
+type ArchiveEntry struct {
+	Path    string          `json:"path"`
+	Size    int             `json:"size"`
+	Streams []*CarvedStream `json:"streams,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// CarvedStream is a serialized stream found at some offset of an archive entry.
+type CarvedStream struct {
+	Offset    int                `json:"offset"`
+	Size      int                `json:"size"`
+	Analysis  *Analysis          `json:"analysis"`
+	Embedded  []EmbeddedStream   `json:"embedded,omitempty"`  // streams nested in throwables
+	Artifacts []*ExtractedObject `json:"artifacts,omitempty"` // files held by byte arrays
+	Data      []byte             `json:"-"`
+}
+
+// ExtractedObject is a file found in a byte array of a parsed stream, such as the class files of TemplatesImpl.
+type ExtractedObject struct {
+	Kind string `json:"kind"`
+	Size int    `json:"size"`
+	Data []byte `json:"-"`
+}
+
+// ScanArchive carves the serialized streams of every file of a zip (jar, war...) or tar archive, gzipped or not,
+// and analyzes them.
+func ScanArchive(data []byte, options ...Option) (_ []*ArchiveEntry, err error) {
+	defer recoverPanic(&err, data, -1)
+
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading gzip archive")
+		}
+
+		if data, err = ioutil.ReadAll(io.LimitReader(gz, maxArchiveEntrySize)); err != nil {
+			return nil, errors.Wrap(err, "error reading gzip archive")
+		}
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return scanZip(data, options)
+	case len(data) > 262 && string(data[257:262]) == "ustar":
+		return scanTar(data, options)
+	}
+
+	return nil, errors.New("not a zip or tar archive")
+}
+
+func scanZip(data []byte, options []Option) ([]*ArchiveEntry, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading zip archive")
+	}
+
+	var entries []*ArchiveEntry
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			entries = append(entries, &ArchiveEntry{Path: f.Name, Error: err.Error()})
+
+			continue
+		}
+
+		b, err := ioutil.ReadAll(io.LimitReader(rc, maxArchiveEntrySize))
+		rc.Close()
+
+		entries = append(entries, scanEntry(f.Name, b, err, options))
+	}
+
+	return entries, nil
+}
+
+func scanTar(data []byte, options []Option) ([]*ArchiveEntry, error) {
+	tr := tar.NewReader(bytes.NewReader(data))
+
+	var entries []*ArchiveEntry
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return entries, errors.Wrap(err, "error reading tar archive")
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		b, err := ioutil.ReadAll(io.LimitReader(tr, maxArchiveEntrySize))
+		entries = append(entries, scanEntry(strings.TrimPrefix(hdr.Name, "./"), b, err, options))
+	}
+}
+
+// scanEntry carves the streams of an archive entry read with err.
+func scanEntry(name string, data []byte, err error, options []Option) *ArchiveEntry {
+	entry := &ArchiveEntry{Path: name, Size: len(data)}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	for off := 0; off < len(data); {
+		idx := bytes.Index(data[off:], streamMagic)
+		if idx < 0 {
+			break
+		}
+
+		off += idx
+
+		n := carveStream(data[off:])
+		if n == 0 {
+			off++
+
+			continue
+		}
+
+		stream := &CarvedStream{Offset: off, Size: n, Data: data[off : off+n]}
+		stream.Analysis = Analyze(stream.Data, options...)
+
+		if content, err := ParseSerializedObject(stream.Data, options...); err == nil {
+			if throwables := AnalyzeThrowables(content, options...); throwables != nil {
+				stream.Embedded = throwables.Embedded
+			}
+
+			stream.Artifacts = extractObjects(content)
+		}
+
+		entry.Streams = append(entry.Streams, stream)
+		off += n
+	}
+
+	return entry
+}
+
+// carveStream returns the length of the serialized stream starting data, up to its last complete element.
+func carveStream(data []byte) int {
+	parser := NewSerializedObjectParser(bytes.NewReader(data), SetMaxDataBlockSize(len(data)))
+
+	var n int
+
+	for {
+		el, err := parser.NextElement()
+		if err != nil {
+			return n
+		}
+
+		n = int(el.Offset + el.Size)
+	}
+}
+
+// extractObjects collects the class files held by the byte arrays of parsed content.
+func extractObjects(content []interface{}) []*ExtractedObject {
+	var objects []*ExtractedObject
+
+	walkContent(content, func(v interface{}) {
+		arr, isArray := v.([]interface{})
+		if !isArray || len(arr) < len(classFileMagic) {
+			return
+		}
+
+		b := make([]byte, 0, len(arr))
+
+		for _, c := range arr {
+			i, isByte := c.(int8)
+			if !isByte {
+				return
+			}
+
+			b = append(b, byte(i))
+		}
+
+		if bytes.HasPrefix(b, classFileMagic) {
+			objects = append(objects, &ExtractedObject{Kind: ArtifactClass, Size: len(b), Data: b})
+		}
+	})
+
+	return objects
+}
+
+// WriteBundle writes the results of ScanArchive as a zip mirroring the archive: each entry with carved streams gets
+// a directory holding result.json, and per stream the carved stream, its nested streams and extracted files.
+func WriteBundle(w io.Writer, entries []*ArchiveEntry) error {
+	zw := zip.NewWriter(w)
+
+	for _, entry := range entries {
+		if len(entry.Streams) == 0 && entry.Error == "" {
+			continue
+		}
+
+		dir := bundlePath(entry.Path)
+
+		b, err := json.MarshalIndent(entry, "", "  ")
+		if err != nil {
+			return errors.Wrapf(err, "error encoding the result of %s", entry.Path)
+		}
+
+		if err = writeBundleFile(zw, path.Join(dir, "result.json"), b); err != nil {
+			return err
+		}
+
+		for _, stream := range entry.Streams {
+			prefix := path.Join(dir, fmt.Sprintf("stream-%d", stream.Offset))
+
+			if err = writeBundleFile(zw, prefix+".ser", stream.Data); err != nil {
+				return err
+			}
+
+			for i, embedded := range stream.Embedded {
+				if err = writeBundleFile(zw, fmt.Sprintf("%s/embedded-%d.ser", prefix, i), embedded.Data); err != nil {
+					return err
+				}
+			}
+
+			for i, artifact := range stream.Artifacts {
+				name := fmt.Sprintf("%s/artifact-%d.%s", prefix, i, artifact.Kind)
+				if err = writeBundleFile(zw, name, artifact.Data); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return errors.Wrap(zw.Close(), "error writing bundle")
+}
+
+func writeBundleFile(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.Create(name)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+
+	return errors.Wrapf(err, "error writing %s to bundle", name)
+}
+
+// bundlePath maps an archive entry name to a relative bundle directory.
+func bundlePath(name string) string {
+	name = strings.TrimLeft(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+	if name == "" {
+		name = "_"
+	}
+
+	return name
+}
@@ -0,0 +1,171 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of the items of an ArtifactStore.
+const (
+	ArtifactStream   = "stream"   // stream carved from a file
+	ArtifactEmbedded = "embedded" // stream nested in a throwable
+	ArtifactClass    = "class"    // class file held by a byte array
+)
+
+// ArtifactRecord is a line of the manifest of an ArtifactStore: an item and where it was found.
+type ArtifactRecord struct {
+	SHA256       string `json:"sha256"`
+	Kind         string `json:"kind"`
+	Size         int    `json:"size"`
+	Source       string `json:"source"`       // payload holding the item, e.g. the scanned archive
+	SourceSHA256 string `json:"sourceSha256"` // of the payload
+	Path         string `json:"path"`         // of the item in the payload, e.g. WEB-INF/a.ser@128/embedded-0
+}
+
+// ArtifactStore is a content-addressed directory of extracted items: each item is written once, as
+// <dir>/<first 2 hex digits>/<sha256>, however many payloads or runs hold it, and manifest.jsonl records every
+// occurrence with its source payload and path.
+type ArtifactStore struct {
+	dir        string
+	mu         sync.Mutex
+	manifest   *os.File
+	written    int
+	duplicates int
+}
+
+// OpenArtifactStore creates or reopens a store, the items already stored not being written again.
+func OpenArtifactStore(dir string) (*ArtifactStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "error creating artifact store")
+	}
+
+	manifest, err := os.OpenFile(filepath.Join(dir, "manifest.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening artifact store manifest")
+	}
+
+	return &ArtifactStore{dir: dir, manifest: manifest}, nil
+}
+
+// Path returns the path of the item with a SHA-256.
+func (this *ArtifactStore) Path(sum string) string {
+	return filepath.Join(this.dir, sum[:2], sum)
+}
+
+// Put stores an item unless the store holds it already and records its occurrence, returning its record.
+func (this *ArtifactStore) Put(data []byte, kind, source, sourceSHA256, path string) (*ArtifactRecord, error) {
+	sum := sha256.Sum256(data)
+	record := &ArtifactRecord{SHA256: hex.EncodeToString(sum[:]), Kind: kind, Size: len(data), Source: source,
+		SourceSHA256: sourceSHA256, Path: path}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if err = this.write(record.SHA256, data); err != nil {
+		return nil, err
+	}
+
+	if _, err = this.manifest.Write(append(line, '\n')); err != nil {
+		return nil, errors.Wrap(err, "error writing artifact store manifest")
+	}
+
+	return record, nil
+}
+
+// write writes an item through a temporary file, so that an interrupted write leaves no partial item behind.
+func (this *ArtifactStore) write(sum string, data []byte) error {
+	name := this.Path(sum)
+
+	if _, err := os.Stat(name); err == nil {
+		this.duplicates++
+
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return errors.Wrap(err, "error writing artifact")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "error writing artifact")
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return errors.Wrapf(err, "error writing artifact %s", sum)
+	}
+
+	this.written++
+
+	return nil
+}
+
+// PutScan stores the carved streams of the results of ScanArchive, their nested streams and extracted files, the
+// source being the scanned archive. Paths are <entry>@<offset>, followed by /embedded-<n> or /artifact-<n>.
+func (this *ArtifactStore) PutScan(source string, data []byte, entries []*ArchiveEntry) error {
+	sum := sha256.Sum256(data)
+	sourceSHA256 := hex.EncodeToString(sum[:])
+
+	for _, entry := range entries {
+		for _, stream := range entry.Streams {
+			prefix := fmt.Sprintf("%s@%d", entry.Path, stream.Offset)
+
+			if _, err := this.Put(stream.Data, ArtifactStream, source, sourceSHA256, prefix); err != nil {
+				return err
+			}
+
+			for i, embedded := range stream.Embedded {
+				path := fmt.Sprintf("%s/embedded-%d", prefix, i)
+				if _, err := this.Put(embedded.Data, ArtifactEmbedded, source, sourceSHA256, path); err != nil {
+					return err
+				}
+			}
+
+			for i, artifact := range stream.Artifacts {
+				path := fmt.Sprintf("%s/artifact-%d", prefix, i)
+				if _, err := this.Put(artifact.Data, artifact.Kind, source, sourceSHA256, path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Stats returns the number of items written and of the occurrences of items already stored.
+func (this *ArtifactStore) Stats() (written, duplicates int) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	return this.written, this.duplicates
+}
+
+// Close closes the manifest.
+func (this *ArtifactStore) Close() error {
+	return this.manifest.Close()
+}
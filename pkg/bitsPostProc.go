@@ -0,0 +1,59 @@
+package pkg
+
+func init() {
+	// keyed by name, these classes being decoded the same way whatever their serialVersionUID
+	objectPostProcs["java.util.BitSet"] = bitSetPostProc
+	objectPostProcs["java.util.concurrent.atomic.AtomicBoolean"] = atomicBooleanPostProc
+
+	for _, name := range []string{
+		"java.util.concurrent.atomic.AtomicIntegerArray",
+		"java.util.concurrent.atomic.AtomicLongArray",
+		"java.util.concurrent.atomic.AtomicReferenceArray",
+	} {
+		objectPostProcs[name] = atomicArrayPostProc
+	}
+
+	// the boxed primitives (Integer, Long, Boolean, Character...), AtomicInteger and AtomicLong hold a single
+	// value field, promoted as is by the minimal output
+}
+
+// bitSetPostProc decodes a java.util.BitSet to the sorted indexes of its set bits, from the long words of its bits
+// field (little-endian bit order).
+func bitSetPostProc(obj map[string]interface{}) {
+	words, isArray := obj["bits"].([]interface{})
+	if !isArray {
+		return
+	}
+
+	indexes := []int{}
+
+	for i, w := range words {
+		word, isLong := w.(int64)
+		if !isLong {
+			continue
+		}
+
+		for bit := 0; bit < 64; bit++ {
+			if uint64(word)&(1<<uint(bit)) != 0 {
+				indexes = append(indexes, i*64+bit)
+			}
+		}
+	}
+
+	obj["value"] = indexes
+}
+
+// atomicBooleanPostProc decodes the int value of an AtomicBoolean.
+func atomicBooleanPostProc(obj map[string]interface{}) {
+	if v, isInt := obj["value"].(int32); isInt {
+		obj["value"] = v != 0
+	}
+}
+
+// atomicArrayPostProc promotes the array field of the atomic arrays to their value.
+func atomicArrayPostProc(obj map[string]interface{}) {
+	if array, exists := obj["array"]; exists {
+		obj["value"] = array
+		delete(obj, "array")
+	}
+}
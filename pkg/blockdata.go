@@ -0,0 +1,91 @@
+package pkg
+
+import "fmt"
+
+// Kinds of BlockDataIssue.
+const (
+	BlockDataFlags      = "flags"      // class descriptor flags ObjectInputStream rejects
+	BlockDataUnexpected = "unexpected" // block data, or its end, where an object is read
+	BlockDataUnwrapped  = "unwrapped"  // raw data where block data is required
+)
+
+// BlockDataIssue is a block data segmentation which contradicts the SC_WRITE_METHOD, SC_BLOCK_DATA and
+// SC_EXTERNALIZABLE flags of the class descriptors, and which ObjectInputStream rejects.
+type BlockDataIssue struct {
+	Kind   string `json:"kind"`
+	Offset int    `json:"offset"`
+	Detail string `json:"detail"`
+}
+
+// blockDataCheck collects the block data issues while an estimator walks a stream.
+type blockDataCheck struct {
+	issues []BlockDataIssue
+}
+
+// element checks the content element of type tc read at offset, blockOK telling whether block data may be read
+// there: at the top level, in annotations, in the class data written by writeObject and in external data.
+func (this *blockDataCheck) element(offset int, tc byte, blockOK bool) {
+	switch _, known := contentTags[tc]; {
+	case tc == TC_ENDBLOCKDATA:
+		// annotations consume their own end
+		this.add(BlockDataUnexpected, offset, "end of block data where an object is expected")
+	case (tc == TC_BLOCKDATA || tc == TC_BLOCKDATALONG) && !blockOK:
+		this.add(BlockDataUnexpected, offset, "block data where an object or field value is expected, "+
+			"the class may lack SC_WRITE_METHOD")
+	case !known && blockOK:
+		this.add(BlockDataUnwrapped, offset, fmt.Sprintf("byte %#02x outside of block data, primitive data "+
+			"written by writeObject or writeExternal must be in TC_BLOCKDATA segments", tc))
+	}
+}
+
+// flags checks the flags of the class descriptor cls read at offset.
+func (this *blockDataCheck) flags(offset int, cls *estimateClass) {
+	if cls.flags&SC_SERIALIZABLE != 0 && cls.flags&SC_EXTERNALIZABLE != 0 {
+		this.add(BlockDataFlags, offset, fmt.Sprintf("%s: serializable and externalizable flags conflict", cls.name))
+	}
+}
+
+func (this *blockDataCheck) add(kind string, offset int, detail string) {
+	this.issues = append(this.issues, BlockDataIssue{Kind: kind, Offset: offset, Detail: detail})
+}
+
+// CheckBlockData verifies that the block data segments of a stream are where its class descriptors declare them:
+// class data written by writeObject (SC_WRITE_METHOD) and version 2 external data (SC_BLOCK_DATA) wrap their
+// primitive data in TC_BLOCKDATA segments up to TC_ENDBLOCKDATA, while default field values are never block data.
+// The error tells why the walk stopped early, the issues found so far being returned along with it.
+func CheckBlockData(data []byte) ([]BlockDataIssue, error) {
+	this := &estimator{b: data, res: &SizeEstimate{}, blocks: &blockDataCheck{}}
+	err := this.walk()
+
+	return this.blocks.issues, err
+}
+
+// blockDataRules maps the BlockDataIssue kinds to their rule IDs and titles.
+var blockDataRules = map[string][2]string{
+	BlockDataFlags:      {"BLK-FLAGS", "conflicting class descriptor flags"},
+	BlockDataUnexpected: {"BLK-UNEXPECTED", "block data where an object is expected"},
+	BlockDataUnwrapped:  {"BLK-UNWRAPPED", "data outside of block data"},
+}
+
+func init() {
+	RegisterDetector("blockdata", blockDataDetector)
+}
+
+// blockDataDetector raises a finding per block data issue, ObjectInputStream would reject the stream.
+func blockDataDetector(analysis *Analysis, _ []interface{}) []Finding {
+	issues, _ := CheckBlockData(analysis.raw)
+
+	var findings []Finding
+
+	for _, issue := range issues {
+		rule := blockDataRules[issue.Kind]
+		findings = append(findings, Finding{
+			RuleID:   rule[0],
+			Severity: SeverityMedium,
+			Title:    rule[1] + ", the stream was handcrafted",
+			Detail:   fmt.Sprintf("offset %d: %s", issue.Offset, issue.Detail),
+		})
+	}
+
+	return findings
+}
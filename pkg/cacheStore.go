@@ -0,0 +1,183 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CacheEntry is an entry read from a persisted cache file.
+// Key and Value are parsed serialized objects, or the raw bytes when they are not Java serialized.
+type CacheEntry struct {
+	Offset int         `json:"offset"`
+	Key    interface{} `json:"key,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+	Expiry *time.Time  `json:"expiry,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// CacheReader reads the entries of a cache persistence file.
+type CacheReader func(data []byte, options ...Option) ([]*CacheEntry, error)
+
+// CacheReaders maps cache persistence formats to their reader.
+var CacheReaders = map[string]CacheReader{
+	"ehcache":    ReadEhcacheDiskStore,
+	"infinispan": ReadInfinispanStore,
+}
+
+// CacheReaderNames returns the sorted names of CacheReaders.
+func CacheReaderNames() []string {
+	names := make([]string, 0, len(CacheReaders))
+	for name := range CacheReaders {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// ReadCacheFile detects the format of a cache persistence file and reads its entries.
+func ReadCacheFile(data []byte, options ...Option) ([]*CacheEntry, error) {
+	if bytes.HasPrefix(data, infinispanMagic) {
+		return ReadInfinispanStore(data, options...)
+	}
+
+	return ReadEhcacheDiskStore(data, options...)
+}
+
+// ReadEhcacheDiskStore reads an Ehcache 2.x disk store .data file, a sequence of serialized net.sf.ehcache.Element
+// objects (written at the offsets recorded by the .index file, with free space in between).
+func ReadEhcacheDiskStore(data []byte, options ...Option) (_ []*CacheEntry, err error) {
+	defer recoverPanic(&err, data, -1)
+
+	var entries []*CacheEntry
+
+	magic := []byte{STREAM_MAGIC1, STREAM_MAGIC2, 0x00, STREAM_VERSION}
+
+	for offset := 0; ; {
+		idx := bytes.Index(data[offset:], magic)
+		if idx < 0 {
+			break
+		}
+
+		entry := &CacheEntry{Offset: offset + idx}
+		content, n, err := ParseFirst(data[entry.Offset:], options...)
+
+		if err != nil {
+			entry.Error = err.Error()
+		}
+
+		if element, isMap := content.(map[string]interface{}); isMap && objectClassName(element) == "net.sf.ehcache.Element" {
+			entry.Key, entry.Value = element["key"], element["value"]
+
+			entry.Expiry = ehcacheExpiry(element)
+		} else {
+			entry.Value = content
+		}
+
+		entries = append(entries, entry)
+
+		if n < len(magic) {
+			n = len(magic)
+		}
+
+		offset = entry.Offset + n
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("no serialized cache element found")
+	}
+
+	return entries, nil
+}
+
+// ehcacheExpiry computes the expiry of an Element from its creation time and time to live.
+func ehcacheExpiry(element map[string]interface{}) *time.Time {
+	ttl, hasTTL := element["timeToLive"].(int32)
+	if !hasTTL || ttl <= 0 {
+		return nil
+	}
+
+	// only older versions serialize the creation time as a field, newer ones keep it in the writeObject data
+	created, hasCreated := element["creationTime"].(int64)
+	if !hasCreated {
+		return nil
+	}
+
+	t := time.Unix(0, (created+int64(ttl)*1000)*int64(time.Millisecond)).UTC()
+
+	return &t
+}
+
+// infinispanMagic starts Infinispan SingleFileStore files.
+var infinispanMagic = []byte("FCS1")
+
+const infinispanHeaderSize = 24 // size, key length, data length, metadata length (ints), expiry time (long)
+
+// ReadInfinispanStore reads an Infinispan SingleFileStore file: after the FCS1 magic every entry has a fixed header
+// followed by the marshalled key, value and metadata. Keys and values written with the Java serialization
+// marshaller are parsed, other ones are kept as raw bytes. Free (deleted) entries are skipped.
+func ReadInfinispanStore(data []byte, options ...Option) (_ []*CacheEntry, err error) {
+	defer recoverPanic(&err, data, -1)
+
+	if !bytes.HasPrefix(data, infinispanMagic) {
+		return nil, errors.New("not an Infinispan SingleFileStore file: FCS1 magic not found")
+	}
+
+	var entries []*CacheEntry
+
+	for offset := len(infinispanMagic); offset+infinispanHeaderSize <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[offset:]))
+		keyLen := int(binary.BigEndian.Uint32(data[offset+4:]))
+		dataLen := int(binary.BigEndian.Uint32(data[offset+8:]))
+		metadataLen := int(binary.BigEndian.Uint32(data[offset+12:]))
+		expiry := int64(binary.BigEndian.Uint64(data[offset+16:]))
+
+		if size < infinispanHeaderSize || offset+size > len(data) ||
+			infinispanHeaderSize+keyLen+dataLen+metadataLen > size {
+			return entries, errors.Errorf("invalid Infinispan entry header at offset %d", offset)
+		}
+
+		if keyLen > 0 {
+			entry := &CacheEntry{Offset: offset}
+			keyStart := offset + infinispanHeaderSize
+
+			entry.Key = parseCacheBlob(entry, data[keyStart:keyStart+keyLen], options)
+			entry.Value = parseCacheBlob(entry, data[keyStart+keyLen:keyStart+keyLen+dataLen], options)
+
+			if expiry > 0 {
+				t := time.Unix(0, expiry*int64(time.Millisecond)).UTC()
+				entry.Expiry = &t
+			}
+
+			entries = append(entries, entry)
+		}
+
+		offset += size
+	}
+
+	return entries, nil
+}
+
+// parseCacheBlob parses a Java serialized key or value, other marshalling formats are returned as is.
+func parseCacheBlob(entry *CacheEntry, blob []byte, options []Option) interface{} {
+	idx := bytes.Index(blob, []byte{STREAM_MAGIC1, STREAM_MAGIC2})
+	if idx < 0 {
+		return blob
+	}
+
+	content, err := ParseSerializedObject(blob[idx:], options...)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if len(content) == 1 {
+		return content[0]
+	}
+
+	return content
+}
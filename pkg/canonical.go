@@ -0,0 +1,222 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Date formats of MarshalMinimal, see JSONDates.
+const (
+	DateRaw         = "raw"          // time.Time encoding, in the zone decoded from the stream
+	DateRFC3339     = "rfc3339"      // RFC 3339 with nanoseconds, in the configured zone
+	DateEpochMillis = "epoch-millis" // milliseconds since the Unix epoch
+)
+
+// jsonExport holds the numeric fidelity settings of MarshalMinimal.
+type jsonExport struct {
+	longsAsStrings bool
+	taggedFloats   bool
+	floatBits      bool
+	rawHex         bool
+	dateFormat     string
+	dateZone       *time.Location
+	transformers   []Transformer // see JSONTransform
+}
+
+// JSONOption configures MarshalMinimal.
+type JSONOption func(*jsonExport)
+
+// JSONLongsAsStrings emits Java longs as decimal strings, longs exceed the safe integer range of JSON consumers
+// using doubles (±2^53).
+func JSONLongsAsStrings() JSONOption {
+	return func(this *jsonExport) {
+		this.longsAsStrings = true
+	}
+}
+
+// JSONTaggedFloats emits NaN and infinite floats and doubles as {"$float": "NaN" | "Infinity" | "-Infinity"} instead
+// of failing, JSON having no representation for them.
+func JSONTaggedFloats() JSONOption {
+	return func(this *jsonExport) {
+		this.taggedFloats = true
+	}
+}
+
+// JSONDates sets the rendering of the points in time decoded from java.util.Date, java.util.Calendar,
+// java.sql.Timestamp and java.time values: DateRaw, DateRFC3339 in zone (UTC when nil) or DateEpochMillis.
+func JSONDates(format string, zone *time.Location) JSONOption {
+	return func(this *jsonExport) {
+		this.dateFormat, this.dateZone = format, zone
+	}
+}
+
+// JSONFloatBits emits floats and doubles as {"value": v, "bits": "0x..."} with their raw IEEE 754 bit pattern,
+// keeping NaN payloads and negative zero. The value of NaN and infinite numbers is their name.
+func JSONFloatBits() JSONOption {
+	return func(this *jsonExport) {
+		this.floatBits = true
+	}
+}
+
+// JSONRawHex emits the primitive values as {"value": v, "hex": "0x..."} with their big-endian encoding in the
+// stream, as the dump shows them. Chars, decoded to strings, are left as is; booleans are written 0x01 or 0x00.
+func JSONRawHex() JSONOption {
+	return func(this *jsonExport) {
+		this.rawHex = true
+	}
+}
+
+// MarshalMinimal encodes parsed content as byte-stable minimal JSON: the minimal representation of the content with
+// object keys sorted, no HTML escaping, no insignificant whitespace and the shortest round-trip formatting of numbers.
+// Equal content always produces the same bytes.
+func MarshalMinimal(content []interface{}, options ...JSONOption) (_ []byte, err error) {
+	defer recoverPanic(&err, nil, -1)
+
+	var buf bytes.Buffer
+
+	export := &jsonExport{}
+	for _, option := range options {
+		option(export)
+	}
+
+	minimal := Transform(jsonFriendlyArray(content), export.transformers...)
+	if export.longsAsStrings || export.taggedFloats || export.floatBits || export.rawHex || export.dateFormat != "" {
+		for i, v := range minimal {
+			minimal[i] = export.value(v)
+		}
+	}
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	// encoding/json sorts map keys and formats floats deterministically
+	if err = enc.Encode(minimal); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// ContentHash is the SHA-256 (hex) of the MarshalMinimal encoding of parsed content. Streams which only differ in
+// their encoding (handle layout, block data splitting, class descriptor details) but hold the same values share it.
+func ContentHash(content []interface{}) (string, error) {
+	b, err := MarshalMinimal(content)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// value applies the numeric fidelity settings to a minimal value, maps and slices are updated in place.
+func (this *jsonExport) value(v interface{}) interface{} {
+	switch o := v.(type) {
+	case []interface{}:
+		for i, e := range o {
+			o[i] = this.value(e)
+		}
+	case map[string]interface{}:
+		for k, e := range o {
+			o[k] = this.value(e)
+		}
+	case time.Time:
+		return this.date(o)
+	case bool:
+		if o {
+			return this.hex(v, "0x01")
+		}
+
+		return this.hex(v, "0x00")
+	case int8:
+		return this.hex(v, fmt.Sprintf("0x%02x", uint8(o)))
+	case int16:
+		return this.hex(v, fmt.Sprintf("0x%04x", uint16(o)))
+	case int32:
+		return this.hex(v, fmt.Sprintf("0x%08x", uint32(o)))
+	case int64:
+		if this.longsAsStrings {
+			return this.hex(fmt.Sprint(o), fmt.Sprintf("0x%016x", uint64(o)))
+		}
+
+		return this.hex(v, fmt.Sprintf("0x%016x", uint64(o)))
+	case float32:
+		return this.float(float64(o), fmt.Sprintf("0x%08x", math.Float32bits(o)), o)
+	case float64:
+		return this.float(o, fmt.Sprintf("0x%016x", math.Float64bits(o)), o)
+	}
+
+	return v
+}
+
+func (this *jsonExport) float(f float64, bits string, v interface{}) interface{} {
+	var special interface{}
+
+	switch {
+	case math.IsNaN(f):
+		special = "NaN"
+	case math.IsInf(f, 1):
+		special = "Infinity"
+	case math.IsInf(f, -1):
+		special = "-Infinity"
+	}
+
+	if !this.floatBits && !this.rawHex {
+		if special != nil && this.taggedFloats {
+			return map[string]interface{}{"$float": special}
+		}
+
+		return v
+	}
+
+	res := map[string]interface{}{"value": v}
+	if special != nil {
+		res["value"] = special
+	}
+
+	if this.floatBits {
+		res["bits"] = bits
+	}
+
+	if this.rawHex {
+		res["hex"] = bits
+	}
+
+	return res
+}
+
+// hex pairs a primitive value with its encoding when JSONRawHex is set.
+func (this *jsonExport) hex(v interface{}, hex string) interface{} {
+	if this.rawHex {
+		return map[string]interface{}{"value": v, "hex": hex}
+	}
+
+	return v
+}
+
+func (this *jsonExport) date(t time.Time) interface{} {
+	switch this.dateFormat {
+	case DateRFC3339:
+		zone := this.dateZone
+		if zone == nil {
+			zone = time.UTC
+		}
+
+		return t.In(zone).Format(time.RFC3339Nano)
+	case DateEpochMillis:
+		ms := t.UnixNano() / int64(time.Millisecond)
+		if this.longsAsStrings {
+			return fmt.Sprint(ms)
+		}
+
+		return ms
+	}
+
+	return t
+}
@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ElementCapability tells which engines read a content element: the dump (DumpSerializedObject) and the parser
+// (ParseSerializedObject). Vendor-specific elements (see RegisterTagHandler) are named TC_VENDORxx.
+type ElementCapability struct {
+	Tag   string `json:"tag"`
+	Name  string `json:"name"`
+	Dump  bool   `json:"dump"`
+	Parse bool   `json:"parse"`
+}
+
+// ExternalPluginCapability describes a running external plugin.
+type ExternalPluginCapability struct {
+	Name      string   `json:"name"`
+	Detectors []string `json:"detectors,omitempty"`
+	PostProcs []string `json:"postProcs,omitempty"`
+	Formats   []string `json:"formats,omitempty"`
+}
+
+// Capabilities is the feature set of the running go-pjs, for orchestration systems to check a deployment.
+type Capabilities struct {
+	Version         string                     `json:"version"`   // see CurrentVersion
+	RulesHash       string                     `json:"rulesHash"` // see RulesHash
+	Elements        []ElementCapability        `json:"elements"`
+	PostProcs       []string                   `json:"postProcs"` // class signatures (name@serialVersionUID) or names
+	Detectors       []string                   `json:"detectors"`
+	OutputFormats   []string                   `json:"outputFormats"`
+	Charsets        []string                   `json:"charsets"`
+	Schemas         []string                   `json:"schemas"`
+	ExternalPlugins []ExternalPluginCapability `json:"externalPlugins"`
+	ExternalReaders []string                   `json:"externalReaders"` // classes with a version 1 external data reader
+	Limits          map[string]int             `json:"limits"`
+}
+
+// CurrentCapabilities lists the supported content elements, the registered post-processors, detectors, output
+// formats and external plugins, and the limits in effect.
+func CurrentCapabilities() *Capabilities {
+	res := &Capabilities{
+		Version:   CurrentVersion(),
+		RulesHash: RulesHash(),
+		Charsets:  CharsetNames(),
+		Schemas:   SchemaNames(),
+		Limits: map[string]int{
+			"maxDataBlockSize":    bufferSize,
+			"maxScriptSteps":      maxScriptSteps,
+			"maxScriptDepth":      maxScriptDepth,
+			"maxEmbeddedDepth":    maxEmbeddedDepth,
+			"maxEstimateDepth":    maxEstimateDepth,
+			"maxNotableStrings":   maxNotableStrings,
+			"maxPluginResponse":   maxPluginResponse,
+			"maxArchiveEntrySize": maxArchiveEntrySize,
+		},
+	}
+
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	tags := make([]int, 0, len(contentTags))
+	for tc := range contentTags {
+		tags = append(tags, int(tc))
+	}
+
+	sort.Ints(tags)
+
+	for _, tc := range tags {
+		tag := contentTags[byte(tc)]
+		_, parsed := knownParsers[tag.name]
+		res.Elements = append(res.Elements, ElementCapability{
+			Tag:   fmt.Sprintf("0x%02x", tc),
+			Name:  elementConstName(tag.name),
+			Dump:  tag.dump != nil,
+			Parse: parsed || tag.handler != nil,
+		})
+	}
+
+	for signature := range KnownPostProcs {
+		res.PostProcs = append(res.PostProcs, signature)
+	}
+
+	for signature := range objectPostProcs {
+		res.PostProcs = append(res.PostProcs, signature)
+	}
+
+	for name := range Detectors {
+		res.Detectors = append(res.Detectors, name)
+	}
+
+	for name := range OutputFormats {
+		res.OutputFormats = append(res.OutputFormats, name)
+	}
+
+	res.ExternalReaders = []string{}
+	for name := range externalReaders {
+		res.ExternalReaders = append(res.ExternalReaders, name)
+	}
+
+	sort.Strings(res.PostProcs)
+	sort.Strings(res.ExternalReaders)
+	sort.Strings(res.Detectors)
+	sort.Strings(res.OutputFormats)
+
+	res.ExternalPlugins = []ExternalPluginCapability{}
+
+	for p := range externalPlugins {
+		res.ExternalPlugins = append(res.ExternalPlugins, ExternalPluginCapability{
+			Name: p.Name, Detectors: p.Detectors, PostProcs: p.PostProcs, Formats: p.Formats,
+		})
+	}
+
+	sort.Slice(res.ExternalPlugins, func(i, j int) bool { return res.ExternalPlugins[i].Name < res.ExternalPlugins[j].Name })
+
+	return res
+}
+
+// elementConstName returns the TC_ constant name of a type name, e.g. TC_BLOCKDATALONG for BlockDataLong.
+func elementConstName(name string) string {
+	b := []byte("TC_")
+	for _, c := range []byte(name) {
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+
+		b = append(b, c)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Charsets maps charset names (lower case) to their encoding, for rendering block data written with a platform
+// charset (e.g. String.getBytes() in writeObject) as text.
+var Charsets = map[string]encoding.Encoding{
+	"utf-8":        unicode.UTF8,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"latin1":       charmap.ISO8859_1,
+	"windows-1252": charmap.Windows1252,
+	"cp1252":       charmap.Windows1252,
+	"gbk":          simplifiedchinese.GBK,
+	"gb2312":       simplifiedchinese.GBK,
+	"gb18030":      simplifiedchinese.GB18030,
+	"big5":         traditionalchinese.Big5,
+	"shift-jis":    japanese.ShiftJIS,
+	"shift_jis":    japanese.ShiftJIS,
+	"sjis":         japanese.ShiftJIS,
+	"euc-jp":       japanese.EUCJP,
+	"euc-kr":       korean.EUCKR,
+}
+
+// CharsetNames returns the sorted names of Charsets.
+func CharsetNames() []string {
+	names := make([]string, 0, len(Charsets))
+	for name := range Charsets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// LookupCharset returns the encoding of a charset name.
+func LookupCharset(name string) (encoding.Encoding, error) {
+	enc, known := Charsets[strings.ToLower(name)]
+	if !known {
+		return nil, errors.Errorf("unknown charset '%s'", name)
+	}
+
+	return enc, nil
+}
+
+// SetCharset renders block data as text with the given charset: the dump prints a Text line after the contents
+// and parsed objects get the decoded block data under "@text".
+func SetCharset(enc encoding.Encoding) Option {
+	return func(this *SerializedObjectParser) {
+		this.charset = enc
+	}
+}
+
+// SetClassCharset overrides the charset used for the block data written by the given class.
+func SetClassCharset(className string, enc encoding.Encoding) Option {
+	return func(this *SerializedObjectParser) {
+		if this.classCharsets == nil {
+			this.classCharsets = make(map[string]encoding.Encoding)
+		}
+
+		this.classCharsets[className] = enc
+	}
+}
+
+// decodeText renders block data written by className as text, ok is false when no charset applies or the data
+// does not decode to valid text.
+func (this *SerializedObjectParser) decodeText(className string, b []byte) (text string, ok bool) {
+	enc := this.charset
+	if classEnc, exists := this.classCharsets[className]; exists {
+		enc = classEnc
+	}
+
+	if enc == nil {
+		return "", false
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(b)
+	if err != nil || !utf8.Valid(decoded) || strings.ContainsRune(string(decoded), utf8.RuneError) {
+		return "", false
+	}
+
+	return string(decoded), true
+}
+
+// annotationText decodes the block data of the annotations written by cls.
+func (this *SerializedObjectParser) annotationText(cls *clazz, anns []interface{}) []interface{} {
+	if this.charset == nil && this.classCharsets == nil {
+		return nil
+	}
+
+	var texts []interface{}
+
+	for _, ann := range anns {
+		if b, isBlock := ann.([]byte); isBlock {
+			if text, ok := this.decodeText(cls.name, b); ok {
+				texts = append(texts, text)
+			}
+		}
+	}
+
+	return texts
+}
+
+// printBlockText prints the text of dumped block data when a charset applies to the class being dumped.
+func (this *SerializedObjectParser) printBlockText(b []byte) {
+	className := ""
+	if n := len(this.annotationClasses); n > 0 {
+		className = this.annotationClasses[n-1]
+	}
+
+	if text, ok := this.decodeText(className, b); ok {
+		this.print("Text - " + text)
+	}
+}
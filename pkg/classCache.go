@@ -0,0 +1,161 @@
+package pkg
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ClassStat holds cross-stream statistics for a single class layout seen by a ClassCache.
+type ClassStat struct {
+	Key              string `json:"key"`
+	Name             string `json:"name"`
+	SerialVersionUID string `json:"serialVersionUID"`
+	Flags            uint8  `json:"flags"`
+	FieldCount       int    `json:"fieldCount"`
+	Count            int    `json:"count"`   // number of descriptors parsed with this layout
+	Streams          int    `json:"streams"` // number of distinct streams the layout appeared in
+}
+
+// classLayout is the stream independent part of a class descriptor which can be shared between parsers.
+type classLayout struct {
+	name             string
+	serialVersionUID string
+	flags            uint8
+	fields           []*field
+	plan             *decodePlan // nil when a field type is unknown
+	stat             *ClassStat
+}
+
+// ClassCache is a concurrency-safe class descriptor cache which can be shared by many parsers (see SetClassCache).
+// Descriptors are keyed by name, serialVersionUID, flags and field layout, a parser that meets an already known
+// layout reuses the cached field descriptions and decode plan instead of keeping its own copy.
+type ClassCache struct {
+	mu      sync.Mutex
+	layouts map[string]*classLayout
+}
+
+// NewClassCache creates an empty ClassCache.
+func NewClassCache() *ClassCache {
+	return &ClassCache{layouts: make(map[string]*classLayout)}
+}
+
+// SetClassCache shares the given ClassCache with the parser.
+func SetClassCache(cache *ClassCache) Option {
+	return func(this *SerializedObjectParser) {
+		this.classCache = cache
+	}
+}
+
+// classLayoutKey builds the cache key of a class descriptor.
+func classLayoutKey(cls *clazz) string {
+	var sb strings.Builder
+
+	sb.WriteString(cls.name)
+	sb.WriteString("@")
+	sb.WriteString(cls.serialVersionUID)
+	sb.WriteString("#")
+	sb.WriteString(strconv.Itoa(int(cls.flags)))
+
+	for _, f := range cls.fields {
+		sb.WriteString(";")
+		sb.WriteString(f.typeName)
+		sb.WriteString(f.name)
+
+		if f.className != "" {
+			sb.WriteString(":")
+			sb.WriteString(f.className)
+		}
+	}
+
+	return sb.String()
+}
+
+// lookup returns the cached layout of cls, registering it when it is not yet known.
+// seen holds the keys already counted for the calling stream.
+func (this *ClassCache) lookup(cls *clazz, seen map[string]bool) *classLayout {
+	key := classLayoutKey(cls)
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	layout, exists := this.layouts[key]
+	if !exists {
+		plan := cls.plan
+		if plan == nil {
+			plan, _ = compileDecodePlan(cls.fields, nil)
+		}
+
+		layout = &classLayout{
+			name:             cls.name,
+			serialVersionUID: cls.serialVersionUID,
+			flags:            cls.flags,
+			fields:           cls.fields,
+			plan:             plan,
+			stat: &ClassStat{
+				Key:              key,
+				Name:             cls.name,
+				SerialVersionUID: cls.serialVersionUID,
+				Flags:            cls.flags,
+				FieldCount:       len(cls.fields),
+			},
+		}
+		this.layouts[key] = layout
+	}
+
+	layout.stat.Count++
+
+	if !seen[key] {
+		seen[key] = true
+		layout.stat.Streams++
+	}
+
+	return layout
+}
+
+// Len returns the number of distinct class layouts in the cache.
+func (this *ClassCache) Len() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	return len(this.layouts)
+}
+
+// Stats returns a snapshot of the per class statistics, most frequently seen classes first.
+func (this *ClassCache) Stats() []ClassStat {
+	this.mu.Lock()
+	stats := make([]ClassStat, 0, len(this.layouts))
+
+	for _, layout := range this.layouts {
+		stats = append(stats, *layout.stat)
+	}
+	this.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+
+		return stats[i].Key < stats[j].Key
+	})
+
+	return stats
+}
+
+// cachedClass replaces the stream independent parts of cls with the shared cached copy when a cache is in use.
+func (this *SerializedObjectParser) cachedClass(cls *clazz) {
+	if this.classCache == nil {
+		return
+	}
+
+	if this.classCacheSeen == nil {
+		this.classCacheSeen = make(map[string]bool)
+	}
+
+	layout := this.classCache.lookup(cls, this.classCacheSeen)
+	cls.name = layout.name
+	cls.serialVersionUID = layout.serialVersionUID
+	cls.fields = layout.fields
+	cls.plan = layout.plan
+}
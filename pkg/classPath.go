@@ -0,0 +1,495 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Class path statuses of the classes of an analysis, see SetClassPath.
+const (
+	ClassPresent  = "present"  // the class path holds the class with the serialVersionUID of the stream
+	ClassMismatch = "mismatch" // the class path holds another version of the class, InvalidClassException
+	ClassAbsent   = "absent"   // the class path does not hold the class, ClassNotFoundException
+)
+
+// jdkPackages are the packages of the classes which the JDK provides to any application.
+var jdkPackages = []string{"java.", "javax.", "jdk.", "sun.", "com.sun."}
+
+// ClassPathEntry is a class found in the jars of an application.
+type ClassPathEntry struct {
+	Name             string `json:"name"`
+	SerialVersionUID string `json:"serialVersionUID"` // declared or computed like ObjectStreamClass, empty when unknown
+	Source           string `json:"source"`           // jar, or directory, holding the class
+}
+
+// ClassPath indexes the classes of an application by name, the first class of a name hiding the later ones like a
+// class loader does.
+type ClassPath struct {
+	classes map[string]*ClassPathEntry
+	sources []string
+}
+
+// NewClassPath returns an empty class path.
+func NewClassPath() *ClassPath {
+	return &ClassPath{classes: map[string]*ClassPathEntry{}}
+}
+
+// LoadClassPath indexes the class files of jars (or wars, ears) and directories, in class path order. The jars nested
+// in an archive, such as the libraries of a Spring Boot jar or a war, and the jars and class files of a directory are
+// indexed along.
+func LoadClassPath(paths ...string) (*ClassPath, error) {
+	res := NewClassPath()
+
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !fi.IsDir() {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+
+			if err = res.AddJar(path, data); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		err = filepath.Walk(path, func(file string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() {
+				return err
+			}
+
+			switch strings.ToLower(filepath.Ext(file)) {
+			case ".jar", ".war", ".ear":
+				data, err := ioutil.ReadFile(file)
+				if err != nil {
+					return err
+				}
+
+				return res.AddJar(file, data)
+			case ".class":
+				data, err := ioutil.ReadFile(file)
+				if err != nil {
+					return err
+				}
+
+				return errors.Wrap(res.AddClass(path, data), file)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// AddJar indexes the class files of a jar and of the jars nested in it, source naming the jar.
+func (this *ClassPath) AddJar(source string, data []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return errors.Wrapf(err, "error reading jar %s", source)
+	}
+
+	this.sources = append(this.sources, source)
+
+	for _, f := range zr.File {
+		isClass := strings.HasSuffix(f.Name, ".class") && !strings.HasPrefix(f.Name, "META-INF/versions/")
+		isJar := strings.HasSuffix(f.Name, ".jar")
+
+		if f.FileInfo().IsDir() || !isClass && !isJar {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return errors.Wrapf(err, "error reading %s!/%s", source, f.Name)
+		}
+
+		b, err := ioutil.ReadAll(io.LimitReader(rc, maxArchiveEntrySize))
+		rc.Close()
+
+		if err != nil {
+			return errors.Wrapf(err, "error reading %s!/%s", source, f.Name)
+		}
+
+		if isJar {
+			err = this.AddJar(source+"!/"+f.Name, b)
+		} else {
+			err = this.AddClass(source, b)
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "%s!/%s", source, f.Name)
+		}
+	}
+
+	return nil
+}
+
+// AddClass indexes a class file, source naming the jar or directory holding it. Module descriptors are ignored.
+func (this *ClassPath) AddClass(source string, data []byte) error {
+	cf, err := readClassFile(data)
+	if err != nil || cf == nil {
+		return err
+	}
+
+	if _, exists := this.classes[cf.name]; !exists {
+		this.classes[cf.name] = &ClassPathEntry{Name: cf.name, SerialVersionUID: cf.serialVersionUID(), Source: source}
+	}
+
+	return nil
+}
+
+// Lookup returns the class of a name, nil when the class path does not hold it.
+func (this *ClassPath) Lookup(name string) *ClassPathEntry {
+	return this.classes[name]
+}
+
+// Len returns the number of classes of the class path.
+func (this *ClassPath) Len() int {
+	return len(this.classes)
+}
+
+// Status tells whether the class path holds a class of a stream with its serialVersionUID (hex): ClassPresent,
+// ClassMismatch or ClassAbsent. It returns "" for arrays and for the JDK classes the class path does not hold, the
+// JDK providing them.
+func (this *ClassPath) Status(name, serialVersionUID string) string {
+	entry := this.classes[name]
+
+	switch {
+	case entry != nil && (entry.SerialVersionUID == "" || entry.SerialVersionUID == serialVersionUID):
+		return ClassPresent
+	case entry != nil:
+		return ClassMismatch
+	case strings.HasPrefix(name, "[") || name == proxyClassName:
+		return ""
+	}
+
+	for _, prefix := range jdkPackages {
+		if strings.HasPrefix(name, prefix) {
+			return ""
+		}
+	}
+
+	return ClassAbsent
+}
+
+// SetClassPath enriches the classes of an analysis with their status in the class path of the target application,
+// see ClassSummary.
+func SetClassPath(classPath *ClassPath) Option {
+	return func(this *SerializedObjectParser) {
+		this.classPath = classPath
+	}
+}
+
+// Access flags of the class files, see the JVM specification.
+const (
+	accPrivate      = 0x0002
+	accStatic       = 0x0008
+	accFinal        = 0x0010
+	accTransient    = 0x0080
+	accInterface    = 0x0200
+	accAbstract     = 0x0400
+	accEnum         = 0x4000
+	accModule       = 0x8000
+	classModifiers  = 0x0611 // public, final, interface and abstract
+	fieldModifiers  = 0x00df // public, private, protected, static, final, volatile and transient
+	methodModifiers = 0x0d3f // public, private, protected, static, final, synchronized, native, abstract and strict
+)
+
+// classMember is a field or method of a class file, its name and descriptor as modified UTF-8.
+type classMember struct {
+	access           int
+	name, descriptor string
+	constant         []byte // ConstantValue of a field, the bytes of its constant pool entry
+}
+
+// classFile is what the serialVersionUID of a class is computed from.
+type classFile struct {
+	access     int
+	name       string // binary name, with dots
+	super      string
+	interfaces []string
+	fields     []classMember
+	methods    []classMember
+}
+
+// classFileReader reads the big-endian items of a class file.
+type classFileReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (this *classFileReader) bytes(n int) []byte {
+	if this.err != nil || n > len(this.b)-this.pos {
+		this.err = errors.New("truncated class file")
+
+		if n > 8 {
+			return nil
+		}
+
+		return make([]byte, n)
+	}
+
+	this.pos += n
+
+	return this.b[this.pos-n : this.pos]
+}
+
+func (this *classFileReader) u1() int {
+	return int(this.bytes(1)[0])
+}
+
+func (this *classFileReader) u2() int {
+	return int(binary.BigEndian.Uint16(this.bytes(2)))
+}
+
+func (this *classFileReader) u4() int {
+	return int(binary.BigEndian.Uint32(this.bytes(4)))
+}
+
+// readClassFile reads the name, access flags, super types and members of a class file, nil for a module descriptor.
+func readClassFile(data []byte) (*classFile, error) {
+	if !bytes.HasPrefix(data, classFileMagic) {
+		return nil, errors.New("not a class file")
+	}
+
+	rd := &classFileReader{b: data, pos: 8}
+
+	// constant pool entries by index: utf8 strings, class name indexes and the bytes of the other constants
+	count := rd.u2()
+	utf8 := make([]string, count)
+	classNames := make([]int, count)
+	constants := make([][]byte, count)
+
+	for i := 1; i < count && rd.err == nil; i++ {
+		switch tag := rd.u1(); tag {
+		case 1:
+			utf8[i] = string(rd.bytes(rd.u2()))
+		case 7:
+			classNames[i] = rd.u2()
+		case 8, 16, 19, 20:
+			rd.bytes(2)
+		case 15:
+			rd.bytes(3)
+		case 3, 4, 9, 10, 11, 12, 17, 18:
+			constants[i] = rd.bytes(4)
+		case 5, 6:
+			constants[i] = rd.bytes(8)
+			i++
+		default:
+			return nil, errors.Errorf("unknown constant pool tag %d", tag)
+		}
+	}
+
+	className := func(idx int) string {
+		if idx <= 0 || idx >= count || classNames[idx] >= count {
+			return ""
+		}
+
+		return strings.ReplaceAll(utf8[classNames[idx]], "/", ".")
+	}
+
+	utf := func(idx int) string {
+		if idx >= count {
+			return ""
+		}
+
+		return utf8[idx]
+	}
+
+	cf := &classFile{access: rd.u2()}
+	thisClass := rd.u2()
+	cf.name, cf.super = className(thisClass), className(rd.u2())
+
+	for n := rd.u2(); n > 0 && rd.err == nil; n-- {
+		cf.interfaces = append(cf.interfaces, className(rd.u2()))
+	}
+
+	members := func() (res []classMember) {
+		for n := rd.u2(); n > 0 && rd.err == nil; n-- {
+			m := classMember{access: rd.u2(), name: utf(rd.u2()), descriptor: utf(rd.u2())}
+
+			for attrs := rd.u2(); attrs > 0 && rd.err == nil; attrs-- {
+				name, size := utf(rd.u2()), rd.u4()
+				attr := rd.bytes(size)
+
+				if name == "ConstantValue" && size == 2 {
+					if idx := int(binary.BigEndian.Uint16(attr)); idx < count {
+						m.constant = constants[idx]
+					}
+				}
+			}
+
+			res = append(res, m)
+		}
+
+		return
+	}
+
+	cf.fields, cf.methods = members(), members()
+
+	// nested classes take their modifiers from the InnerClasses attribute, as Class.getModifiers
+	for attrs := rd.u2(); attrs > 0 && rd.err == nil; attrs-- {
+		name, size := utf(rd.u2()), rd.u4()
+		attr := &classFileReader{b: rd.bytes(size)}
+
+		if name != "InnerClasses" {
+			continue
+		}
+
+		for n := attr.u2(); n > 0 && attr.err == nil; n-- {
+			inner, _, _, access := attr.u2(), attr.u2(), attr.u2(), attr.u2()
+			if inner == thisClass {
+				cf.access = access
+			}
+		}
+	}
+
+	if rd.err != nil {
+		return nil, rd.err
+	}
+
+	if cf.access&accModule != 0 {
+		return nil, nil
+	}
+
+	return cf, nil
+}
+
+// serialVersionUID returns the declared serialVersionUID of a class (hex), or the default one computed like
+// ObjectStreamClass.computeDefaultSUID. It returns "" when the declared value is not a constant.
+func (this *classFile) serialVersionUID() string {
+	if this.access&accEnum != 0 && this.super == "java.lang.Enum" {
+		return "0000000000000000"
+	}
+
+	for _, f := range this.fields {
+		if f.name == "serialVersionUID" && f.descriptor == "J" && f.access&(accStatic|accFinal) == accStatic|accFinal {
+			if len(f.constant) != 8 {
+				return ""
+			}
+
+			return fmt.Sprintf("%x", f.constant)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	writeUTF := func(s string) {
+		_ = binary.Write(&buf, binary.BigEndian, uint16(len(s)))
+		buf.WriteString(s)
+	}
+
+	writeInt := func(i int) {
+		_ = binary.Write(&buf, binary.BigEndian, int32(i))
+	}
+
+	var methods, constructors []classMember
+
+	hasInitializer := false
+
+	for _, m := range this.methods {
+		switch m.name {
+		case "<clinit>":
+			hasInitializer = true
+		case "<init>":
+			if m.access&accPrivate == 0 {
+				constructors = append(constructors, m)
+			}
+		default:
+			methods = append(methods, m)
+		}
+	}
+
+	access := this.access & classModifiers
+	if access&accInterface != 0 {
+		if len(methods) > 0 {
+			access |= accAbstract
+		} else {
+			access &^= accAbstract
+		}
+	}
+
+	writeUTF(this.name)
+	writeInt(access)
+
+	interfaces := append([]string(nil), this.interfaces...)
+	sort.Strings(interfaces)
+
+	for _, name := range interfaces {
+		writeUTF(name)
+	}
+
+	fields := append([]classMember(nil), this.fields...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+
+	for _, f := range fields {
+		access := f.access & fieldModifiers
+		if access&accPrivate == 0 || access&(accStatic|accTransient) == 0 {
+			writeUTF(f.name)
+			writeInt(access)
+			writeUTF(f.descriptor)
+		}
+	}
+
+	if hasInitializer {
+		writeUTF("<clinit>")
+		writeInt(accStatic)
+		writeUTF("()V")
+	}
+
+	sort.SliceStable(constructors, func(i, j int) bool { return constructors[i].descriptor < constructors[j].descriptor })
+
+	for _, m := range constructors {
+		writeUTF("<init>")
+		writeInt(m.access & methodModifiers)
+		writeUTF(strings.ReplaceAll(m.descriptor, "/", "."))
+	}
+
+	sort.SliceStable(methods, func(i, j int) bool {
+		if methods[i].name != methods[j].name {
+			return methods[i].name < methods[j].name
+		}
+
+		return methods[i].descriptor < methods[j].descriptor
+	})
+
+	for _, m := range methods {
+		if m.access&accPrivate == 0 {
+			writeUTF(m.name)
+			writeInt(m.access & methodModifiers)
+			writeUTF(strings.ReplaceAll(m.descriptor, "/", "."))
+		}
+	}
+
+	sum := sha1.Sum(buf.Bytes())
+
+	var hash uint64
+
+	for i := 7; i >= 0; i-- {
+		hash = hash<<8 | uint64(sum[i])
+	}
+
+	return fmt.Sprintf("%016x", hash)
+}
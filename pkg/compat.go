@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Compatibility verdicts.
+const (
+	CompatAccepted = "accepted" // a stock JVM reads the stream
+	CompatRejected = "rejected" // ObjectInputStream throws while reading the stream
+	CompatUnknown  = "unknown"  // the stream could not be checked entirely, see the reasons
+)
+
+// Compatibility tells whether a stock JVM would read a stream, its classes being on the class path.
+type Compatibility struct {
+	Verdict string         `json:"verdict"`
+	Reasons []CompatReason `json:"reasons"`
+}
+
+// CompatReason is a reason of a rejected or unknown verdict.
+type CompatReason struct {
+	Check  string `json:"check"`  // structure, references or blockdata
+	Offset int    `json:"offset"` // offset of the offending element
+	Detail string `json:"detail"`
+}
+
+// CheckCompatibility walks a stream once with the reference and block data validators to tell whether
+// ObjectInputStream would read it, without a Java process. The structure is checked along: truncated streams, illegal
+// or vendor content elements and invalid handles of class descriptors are rejected. Version 1 external data and
+// nestings deeper than the estimator walks cannot be checked, their verdict is unknown unless a problem was found
+// before them. Class resolution, serialVersionUID mismatches and the readObject methods of the classes are out of
+// reach, an accepted stream may still fail in the JVM because of them.
+func CheckCompatibility(data []byte) (res *Compatibility) {
+	this := &estimator{b: data, res: &SizeEstimate{}, refs: &referenceCheck{}, blocks: &blockDataCheck{}}
+	res = &Compatibility{Verdict: CompatAccepted, Reasons: []CompatReason{}}
+
+	err := this.walk()
+	this.refs.classify(len(this.handles))
+
+	for _, issue := range this.refs.issues {
+		res.Reasons = append(res.Reasons, CompatReason{Check: "references", Offset: issue.Offset,
+			Detail: fmt.Sprintf("%s reference to handle %#x: %s", issue.Kind, issue.Handle, issue.Detail)})
+	}
+
+	for _, issue := range this.blocks.issues {
+		res.Reasons = append(res.Reasons, CompatReason{Check: "blockdata", Offset: issue.Offset, Detail: issue.Detail})
+	}
+
+	if len(res.Reasons) > 0 {
+		res.Verdict = CompatRejected
+	}
+
+	if err == nil {
+		return res
+	}
+
+	res.Reasons = append(res.Reasons, CompatReason{Check: "structure", Offset: this.pos, Detail: err.Error()})
+
+	if _, isPanic := err.(*PanicError); isPanic || errors.Cause(err) == errNotWalkable {
+		if res.Verdict == CompatAccepted {
+			res.Verdict = CompatUnknown
+		}
+	} else {
+		res.Verdict = CompatRejected
+	}
+
+	return res
+}
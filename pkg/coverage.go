@@ -0,0 +1,234 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Coverage categories of a CoverageMatrix.
+const (
+	CoverageElements   = "elements"   // content elements, by TC_ constant name
+	CoverageFlags      = "flags"      // flag combinations of the class descriptors
+	CoverageFieldTypes = "fieldTypes" // types of the declared fields
+	CoverageArrayTypes = "arrayTypes" // element types of the arrays
+)
+
+// typeCodeNames names the field and array element type codes.
+var typeCodeNames = map[byte]string{
+	'B': "byte", 'Z': "boolean", 'C': "char", 'S': "short", 'I': "int", 'F': "float", 'J': "long", 'D': "double",
+	'L': "object", '[': "array",
+}
+
+// standardFlags are the flag combinations written by the JDK: plain and custom serialization, enums, version 1
+// and version 2 externalizable classes.
+var standardFlags = []byte{
+	SC_SERIALIZABLE, SC_SERIALIZABLE | SC_WRITE_METHOD, SC_SERIALIZABLE | SC_ENUM,
+	SC_EXTERNALIZABLE, SC_EXTERNALIZABLE | SC_BLOCK_DATA,
+}
+
+// CoverageEntry tells how often a feature of the protocol occurs in a corpus.
+type CoverageEntry struct {
+	Name    string `json:"name"`
+	Count   int    `json:"count"`   // occurrences
+	Streams int    `json:"streams"` // streams with at least one occurrence
+}
+
+// CoverageMatrix is the protocol surface a corpus exercises. Every category lists the standard features, seen or
+// not, followed by the others seen (vendor elements, unusual flags).
+type CoverageMatrix struct {
+	Streams    int                        `json:"streams"`
+	Incomplete map[string]string          `json:"incomplete,omitempty"` // streams not walked to the end -> error
+	Categories map[string][]CoverageEntry `json:"categories"`
+	Missing    map[string][]string        `json:"missing"` // standard features of each category never seen
+}
+
+// Coverage accumulates the content elements, class descriptor flags and field and array types of the streams of
+// a corpus, so users can check their test sets exercise the whole protocol and prioritize parser features.
+type Coverage struct {
+	streams    int
+	incomplete map[string]string
+	counts     map[string]map[string]*CoverageEntry
+}
+
+// NewCoverage returns an empty Coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{incomplete: map[string]string{}, counts: map[string]map[string]*CoverageEntry{}}
+}
+
+// Add walks a stream named name. A stream which cannot be walked to the end counts the features before the error,
+// which is returned and reported in the matrix.
+func (this *Coverage) Add(name string, data []byte) error {
+	this.streams++
+
+	seen := &coverage{counts: map[string]map[string]int{}}
+	walker := &estimator{b: data, res: &SizeEstimate{}, cover: seen}
+
+	err := walker.walk()
+	if err != nil {
+		this.incomplete[name] = err.Error()
+	}
+
+	for category, counts := range seen.counts {
+		entries := this.counts[category]
+		if entries == nil {
+			entries = map[string]*CoverageEntry{}
+			this.counts[category] = entries
+		}
+
+		for name, n := range counts {
+			entry := entries[name]
+			if entry == nil {
+				entry = &CoverageEntry{Name: name}
+				entries[name] = entry
+			}
+
+			entry.Count += n
+			entry.Streams++
+		}
+	}
+
+	return err
+}
+
+// Streams returns the number of streams added.
+func (this *Coverage) Streams() int {
+	return this.streams
+}
+
+// Matrix returns the coverage of the streams added, the standard features of each category first in protocol
+// order, then the others by name.
+func (this *Coverage) Matrix() *CoverageMatrix {
+	res := &CoverageMatrix{Streams: this.streams, Categories: map[string][]CoverageEntry{},
+		Missing: map[string][]string{}}
+
+	if len(this.incomplete) > 0 {
+		res.Incomplete = this.incomplete
+	}
+
+	standard := map[string][]string{
+		CoverageFieldTypes: typeCodeOrder(),
+		CoverageArrayTypes: typeCodeOrder(),
+	}
+
+	for tc := 0; tc < 256; tc++ {
+		if tag, exists := contentTags[byte(tc)]; exists && tag.handler == nil {
+			standard[CoverageElements] = append(standard[CoverageElements], elementConstName(tag.name))
+		}
+	}
+
+	for _, flags := range standardFlags {
+		standard[CoverageFlags] = append(standard[CoverageFlags], flagsName(flags))
+	}
+
+	for _, category := range []string{CoverageElements, CoverageFlags, CoverageFieldTypes, CoverageArrayTypes} {
+		entries := this.counts[category]
+		listed := map[string]bool{}
+		missing := []string{}
+		matrix := []CoverageEntry{}
+
+		for _, name := range standard[category] {
+			listed[name] = true
+
+			if entry := entries[name]; entry != nil {
+				matrix = append(matrix, *entry)
+			} else {
+				matrix = append(matrix, CoverageEntry{Name: name})
+				missing = append(missing, name)
+			}
+		}
+
+		var others []string
+		for name := range entries {
+			if !listed[name] {
+				others = append(others, name)
+			}
+		}
+
+		sort.Strings(others)
+
+		for _, name := range others {
+			matrix = append(matrix, *entries[name])
+		}
+
+		res.Categories[category] = matrix
+		res.Missing[category] = missing
+	}
+
+	return res
+}
+
+// typeCodeOrder returns the names of the type codes, primitives first.
+func typeCodeOrder() []string {
+	var res []string
+	for _, typeCode := range []byte("BCDFIJSZL[") {
+		res = append(res, typeCodeNames[typeCode])
+	}
+
+	return res
+}
+
+// flagsName returns the SC_ constant names of class descriptor flags, e.g. SC_WRITE_METHOD|SC_SERIALIZABLE.
+func flagsName(flags byte) string {
+	if flags == 0 {
+		return "none"
+	}
+
+	var names []string
+
+	for _, flag := range mutationFlags {
+		if flags&flag.flag != 0 {
+			names = append(names, flag.name)
+			flags &^= flag.flag
+		}
+	}
+
+	if flags != 0 {
+		names = append(names, fmt.Sprintf("%#02x", flags))
+	}
+
+	return strings.Join(names, "|")
+}
+
+// coverage counts the features of a stream while an estimator walks it.
+type coverage struct {
+	counts map[string]map[string]int
+}
+
+func (this *coverage) add(category, name string) {
+	counts := this.counts[category]
+	if counts == nil {
+		counts = map[string]int{}
+		this.counts[category] = counts
+	}
+
+	counts[name]++
+}
+
+func (this *coverage) element(tc byte) {
+	if tag, exists := contentTags[tc]; exists {
+		this.add(CoverageElements, elementConstName(tag.name))
+	} else {
+		this.add(CoverageElements, fmt.Sprintf("%#02x", tc))
+	}
+}
+
+func (this *coverage) flags(flags byte) {
+	this.add(CoverageFlags, flagsName(flags))
+}
+
+func (this *coverage) field(typeCode byte) {
+	this.add(CoverageFieldTypes, typeCodeName(typeCode))
+}
+
+func (this *coverage) array(typeCode byte) {
+	this.add(CoverageArrayTypes, typeCodeName(typeCode))
+}
+
+func typeCodeName(typeCode byte) string {
+	if name, exists := typeCodeNames[typeCode]; exists {
+		return name
+	}
+
+	return fmt.Sprintf("%q", typeCode)
+}
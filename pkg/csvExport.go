@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// csvSummaryHeader names the columns of CSVSummaryWriter rows.
+var csvSummaryHeader = []string{
+	"filename", "size", "sha256", "verdict", "top severity", "findings", "top classes", "fingerprint", "notable strings", "error",
+	"go-pjs version", "rules hash",
+}
+
+// csvTopClasses is the number of classes listed per row.
+const csvTopClasses = 5
+
+// CSVSummaryWriter writes a spreadsheet friendly summary of batch scans, one row per payload.
+type CSVSummaryWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVSummaryWriter writes the header row and returns the writer. When excel is set a UTF-8 byte order mark is
+// written first and rows end with CRLF so that Excel opens the file with the right encoding.
+func NewCSVSummaryWriter(w io.Writer, excel bool) (*CSVSummaryWriter, error) {
+	if excel {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return nil, errors.Wrap(err, "write byte order mark")
+		}
+	}
+
+	this := &CSVSummaryWriter{w: csv.NewWriter(w)}
+	this.w.UseCRLF = excel
+
+	if err := this.w.Write(csvSummaryHeader); err != nil {
+		return nil, errors.Wrap(err, "write header")
+	}
+
+	return this, nil
+}
+
+// Write adds the row of a payload.
+func (this *CSVSummaryWriter) Write(name string, analysis *Analysis) error {
+	classes := append([]ClassSummary(nil), analysis.Classes...)
+	sort.SliceStable(classes, func(i, j int) bool { return classes[i].Instances > classes[j].Instances })
+
+	if len(classes) > csvTopClasses {
+		classes = classes[:csvTopClasses]
+	}
+
+	top := make([]string, len(classes))
+	for i, cls := range classes {
+		top[i] = cls.Name + " (" + strconv.Itoa(cls.Instances) + ")"
+	}
+
+	findings := make([]string, len(analysis.Findings))
+	for i, f := range analysis.Findings {
+		findings[i] = f.RuleID
+	}
+
+	severity := ""
+	if len(analysis.Findings) > 0 {
+		severity = analysis.Findings[0].Severity.String()
+	}
+
+	var version, rulesHash string
+	if analysis.Provenance != nil {
+		version, rulesHash = analysis.Provenance.Version, analysis.Provenance.RulesHash
+	}
+
+	row := []string{
+		name,
+		strconv.Itoa(analysis.Size),
+		analysis.SHA256,
+		analysis.Verdict(),
+		severity,
+		strings.Join(findings, "; "),
+		strings.Join(top, "; "),
+		analysis.Fingerprint,
+		strings.Join(analysis.Strings, "; "),
+		analysis.Error,
+		version,
+		rulesHash,
+	}
+
+	for i, cell := range row {
+		row[i] = csvSafeCell(cell)
+	}
+
+	return errors.Wrap(this.w.Write(row), "write row")
+}
+
+// csvSafeCell quotes a cell which a spreadsheet would run as a formula, such as a notable string of a payload
+// starting with '=', by prefixing it with a single quote.
+func csvSafeCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+// Flush writes buffered rows.
+func (this *CSVSummaryWriter) Flush() error {
+	this.w.Flush()
+
+	return this.w.Error()
+}
+
+// csvOutput renders a single analysis as a one row CSV summary, batches use a single CSVSummaryWriter instead.
+func csvOutput(w io.Writer, name string, analysis *Analysis) error {
+	cw, err := NewCSVSummaryWriter(w, false)
+	if err != nil {
+		return err
+	}
+
+	if err := cw.Write(name, analysis); err != nil {
+		return err
+	}
+
+	return cw.Flush()
+}
@@ -0,0 +1,55 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestCSVSummaryWriterFormulas(t *testing.T) {
+	var out bytes.Buffer
+
+	cw, err := NewCSVSummaryWriter(&out, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analysis := &Analysis{
+		Classes: []ClassSummary{{Name: "+a.B", Instances: 1}},
+		Strings: []string{"=cmd|' /C calc.exe'!A0", "c:\\x.exe"},
+	}
+
+	for _, name := range []string{"@payload.ser", "-payload.ser", "payload.ser"} {
+		if err = cw.Write(name, analysis); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = cw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows, want the header and 3 rows", len(rows))
+	}
+
+	for i, want := range []string{"'@payload.ser", "'-payload.ser", "payload.ser"} {
+		row := rows[i+1]
+		if row[0] != want {
+			t.Errorf("filename: got %q, want %q", row[0], want)
+		}
+
+		if row[6] != "'+a.B (1)" {
+			t.Errorf("top classes: got %q", row[6])
+		}
+
+		if row[8] != "'=cmd|' /C calc.exe'!A0; c:\\x.exe" {
+			t.Errorf("notable strings: got %q", row[8])
+		}
+	}
+}
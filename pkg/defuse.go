@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Defusal kinds, see Defuse.
+const (
+	DefuseMagic    = "magic"    // zero the stream magic, no ObjectInputStream reads the stream any more
+	DefuseUID      = "uid"      // zero the serialVersionUID of a gadget class, its class no longer resolves
+	DefuseBytecode = "bytecode" // zero a class file embedded in a byte array, such as the _bytecodes of TemplatesImpl
+)
+
+// Defuse rewrites a payload into an inert variant of the same size and structure, for archival: the
+// serialVersionUIDs of the known gadget classes (see KnownGadgetClasses) are zeroed, so that deserializing it fails
+// with an InvalidClassException before any gadget runs, and the class files embedded in byte arrays are zeroed. With
+// magic, the stream magic is zeroed too so that no ObjectInputStream even starts reading the result. The edits
+// record the original uids and magic. Streams which Estimate cannot walk completely are refused, as are streams with
+// nothing to defuse: defusing the result again fails, and never restores the zeroed bytes.
+func Defuse(data []byte, magic bool) ([]byte, []Mutation, error) {
+	sites := &mutationSites{}
+	walker := &estimator{b: data, res: &SizeEstimate{}, sites: sites}
+
+	if err := walker.walk(); err != nil {
+		return nil, nil, err
+	}
+
+	res := append([]byte(nil), data...)
+
+	var edits []Mutation
+
+	for _, site := range sites.classes {
+		uid := res[site.uid : site.uid+8]
+
+		if _, isGadget := KnownGadgetClasses[site.name]; isGadget && !isZero(uid) {
+			edits = append(edits, Mutation{Kind: DefuseUID, Offset: site.uid,
+				Detail: fmt.Sprintf("serialVersionUID of %s %x zeroed", site.name, uid)})
+			zero(uid)
+		}
+	}
+
+	for _, site := range sites.arrays {
+		content := res[site.size+4 : site.size+4+site.n*site.elemSize]
+
+		if site.name == "[B" && bytes.HasPrefix(content, classFileMagic) {
+			zero(content)
+
+			edits = append(edits, Mutation{Kind: DefuseBytecode, Offset: site.size + 4,
+				Detail: fmt.Sprintf("class file of %d bytes zeroed", site.n)})
+		}
+	}
+
+	if len(edits) == 0 {
+		return nil, nil, errors.New("no gadget class or bytecode to defuse")
+	}
+
+	if magic {
+		edits = append(edits, Mutation{Kind: DefuseMagic, Offset: 0, Detail: fmt.Sprintf("stream magic %x zeroed", res[:2])})
+		zero(res[:2])
+	}
+
+	return res, edits, nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -9,37 +9,55 @@ import (
 
 	//_ "encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"strconv"
 	//_ "strings"
 	//_ "time"
 
 	"github.com/pkg/errors"
 )
 
-// ParseSerializedObject parses a serialized java object.
-func ParseSerializedObject(buf []byte) (content []interface{}, err error) {
-	option := SetMaxDataBlockSize(len(buf))
-	this := NewSerializedObjectParser(bytes.NewReader(buf), option)
+// ParseSerializedObject parses a serialized java object, see SetMemoryCap for the handling of oversized streams.
+func ParseSerializedObject(buf []byte, options ...Option) (content []interface{}, err error) {
+	options = bufferOptions(buf, options)
+
+	return NewSerializedObjectParser(bytes.NewReader(buf), options...).parseWithinCap(buf)
+}
+
+// DumpSerializedObject prints a human readable dump of a serialized java object (SerializationDumper style), the
+// error is a PanicError when the dump panics.
+func DumpSerializedObject(buf []byte, options ...Option) (err error) {
+	options = bufferOptions(buf, options)
+	this := NewSerializedObjectParser(bytes.NewReader(buf), options...)
+
+	defer this.recoverPanic(&err)
 
 	this.parseStream()
-	return nil, nil
+
+	return
 }
 
-// ParseSerializedObject parses a serialized java object from stream.
+// ParseSerializedObject parses a serialized java object from stream, panics are returned as a PanicError.
 func (this *SerializedObjectParser) ParseSerializedObject() (content []interface{}, err error) {
-	if err = this.magic(); err != nil {
-		return
-	}
+	defer this.recoverPanic(&err)
 
-	if err = this.version(); err != nil {
+	if err = this.header(); err != nil {
 		return
 	}
 
-	for !this.end() {
+	for {
+		if this.skipResets(); this.end() {
+			break
+		}
+
 		var nxt interface{}
 
+		start := this.Offset()
+
 		if nxt, err = this.content(nil); err != nil {
 			if errors.Cause(err).Error() == io.EOF.Error() {
 				err = errors.New("premature end of input")
@@ -49,15 +67,197 @@ func (this *SerializedObjectParser) ParseSerializedObject() (content []interface
 		}
 
 		content = append(content, nxt)
+		this.parsedEnd = this.Offset()
+		this.addToSegment(nxt, start)
+
+		if this.stopAfterFirst {
+			break
+		}
+	}
+
+	return
+}
+
+// ParseFirst parses the first top-level element of a serialized java object and ignores whatever follows it,
+// n is the number of input bytes consumed (stream header included).
+func ParseFirst(buf []byte, options ...Option) (content interface{}, n int, err error) {
+	options = bufferOptions(buf, options, StopAfterFirstObject())
+	this := NewSerializedObjectParser(bytes.NewReader(buf), options...)
+
+	var contents []interface{}
+
+	contents, err = this.ParseSerializedObject()
+	if len(contents) > 0 {
+		content = contents[0]
+	}
+
+	n = int(this.Consumed())
+
+	return
+}
+
+// StopAfterFirstObject stops parsing after the first top-level element so trailing data is never read.
+func StopAfterFirstObject() Option {
+	return func(this *SerializedObjectParser) {
+		this.stopAfterFirst = true
+	}
+}
+
+// Consumed returns the number of input bytes consumed by the parser so far.
+func (this *SerializedObjectParser) Consumed() int64 {
+	if this.src == nil {
+		return 0
+	}
+
+	return this.src.n - int64(this.rd.Buffered())
+}
+
+// Offset returns the position of the parser in the original stream, i.e. Consumed plus the ResumeAt offset.
+func (this *SerializedObjectParser) Offset() int64 {
+	return this.baseOffset + this.Consumed()
+}
+
+// remaining returns the number of input bytes left to read, -1 when the parser does not read a buffer.
+func (this *SerializedObjectParser) remaining() int64 {
+	if this.input == nil {
+		return -1
+	}
+
+	return int64(len(this.input)) - this.Offset()
+}
+
+// Element is a top-level element of a stream together with its location in the input.
+type Element struct {
+	Offset  int64       `json:"offset"` // offset of the first byte of the element in the original stream
+	Size    int64       `json:"size"`   // number of bytes the element consumed
+	Content interface{} `json:"content"`
+}
+
+// ResumeAt tells the parser that its input starts at the given offset of the original stream.
+// When offset is greater than zero the stream header is assumed to be already consumed, so parsing resumes directly
+// with the next top-level element. Handles assigned before offset are unknown to the parser, references to them
+// resolve to nil.
+func ResumeAt(offset int64) Option {
+	return func(this *SerializedObjectParser) {
+		this.baseOffset = offset
+		this.headerRead = offset > 0
+	}
+}
+
+// Checkpoint captures the state needed to resume parsing a stream at a top-level element boundary.
+type Checkpoint struct {
+	Offset  int64
+	handles []interface{}
+	floor   int
+}
+
+// Checkpoint returns a snapshot of the parser position and assigned handles, it should be taken between
+// top-level elements.
+func (this *SerializedObjectParser) Checkpoint() *Checkpoint {
+	return &Checkpoint{
+		Offset:  this.Offset(),
+		handles: append([]interface{}(nil), this.handles...),
+		floor:   this.handleFloor,
 	}
+}
+
+// ResumeFrom is like ResumeAt but also restores the handles of a Checkpoint,
+// so references to objects parsed before the checkpoint keep resolving.
+func ResumeFrom(cp *Checkpoint) Option {
+	return func(this *SerializedObjectParser) {
+		ResumeAt(cp.Offset)(this)
+		this.handles = append([]interface{}(nil), cp.handles...)
+		this.handleFloor = cp.floor
+	}
+}
+
+// header reads and checks the stream header once.
+func (this *SerializedObjectParser) header() (err error) {
+	if this.headerRead {
+		return
+	}
+
+	if err = this.magic(); err != nil {
+		return
+	}
+
+	if err = this.version(); err != nil {
+		return
+	}
+
+	this.headerRead = true
+	this.parsedEnd = this.Offset()
+
+	return
+}
+
+// NextElement reads the next top-level element of the stream, the stream header is read first when needed.
+// It returns io.EOF when no more input is available, and panics as a PanicError.
+func (this *SerializedObjectParser) NextElement() (el *Element, err error) {
+	defer this.recoverPanic(&err)
+
+	if err = this.header(); err != nil {
+		return
+	}
+
+	if this.skipResets(); this.end() {
+		return nil, io.EOF
+	}
+
+	el = &Element{Offset: this.Offset()}
+
+	if el.Content, err = this.content(nil); err != nil {
+		return nil, err
+	}
+
+	el.Size = this.Offset() - el.Offset
+	this.parsedEnd = this.Offset()
+	this.addToSegment(el.Content, el.Offset)
 
 	return
 }
 
+// ParseElements parses the top-level elements of buf starting at offset (zero to start with the stream header).
+// A truncated trailing element is not an error: parsing stops before it and next is the offset to resume from once
+// more data has been appended, which makes it suitable for incremental parsing of log-style files of records.
+func ParseElements(buf []byte, offset int, options ...Option) (elements []*Element, next int, err error) {
+	if offset < 0 || offset > len(buf) {
+		return nil, offset, errors.Errorf("invalid offset %d for input of %d bytes", offset, len(buf))
+	}
+
+	options = bufferOptions(buf, options, ResumeAt(int64(offset)))
+	this := NewSerializedObjectParser(bytes.NewReader(buf[offset:]), options...)
+	next = offset
+
+	for {
+		var el *Element
+
+		if el, err = this.NextElement(); err != nil {
+			if isTruncated(err) {
+				err = nil
+			}
+
+			return
+		}
+
+		elements = append(elements, el)
+		next = int(el.Offset + el.Size)
+	}
+}
+
+// isTruncated checks whether err was caused by running out of input.
+func isTruncated(err error) bool {
+	cause := errors.Cause(err)
+
+	return cause == io.EOF || cause == io.ErrUnexpectedEOF
+}
+
 // ParseSerializedObjectMinimal parses a serialized java object and returns the minimal object representation
 // (i.e. without all the class info, etc...).
-func ParseSerializedObjectMinimal(buf []byte) (content []interface{}, err error) {
-	if content, err = ParseSerializedObject(buf); err == nil {
+func ParseSerializedObjectMinimal(buf []byte, options ...Option) (content []interface{}, err error) {
+	defer recoverPanic(&err, buf, -1)
+
+	if content, err = ParseSerializedObject(buf, options...); err == nil {
 		content = jsonFriendlyArray(content)
 	}
 
@@ -67,6 +267,8 @@ func ParseSerializedObjectMinimal(buf []byte) (content []interface{}, err error)
 // ParseSerializedObjectMinimal parses a serialized java object from stream
 // and returns the minimal object representation (i.e. without all the class info, etc...).
 func (this *SerializedObjectParser) ParseSerializedObjectMinimal() (content []interface{}, err error) {
+	defer this.recoverPanic(&err)
+
 	if content, err = this.ParseSerializedObject(); err == nil {
 		content = jsonFriendlyArray(content)
 	}
@@ -116,8 +318,8 @@ func jsonFriendlyMap(mapObj map[string]interface{}) (jsonMap map[string]interfac
 	jsonMap = make(map[string]interface{})
 
 	for k, v := range mapObj {
-		// filter out `extends` keyword which just contains internal inheritance hierarchy
-		if k == "extends" {
+		// filter out `extends` keyword which just contains internal inheritance hierarchy, and raw annotation bytes
+		if k == "extends" || k == "@raw" {
 			continue
 		}
 		// filter out internal class definitions
@@ -135,7 +337,8 @@ func init() {
 		"BlockDataLong": parseBlockDataLong,
 		"BlockData":     parseBlockData,
 		"EndBlockData":  parseEndBlockData,
-		"ClassDesc":     parseClassDesc,
+		"ClassDesc":      parseClassDesc,
+		"ProxyClassDesc": parseProxyClassDesc,
 		"Class":         parseClass,
 		"Array":         parseArray,
 		"LongString":    parseLongString,
@@ -146,28 +349,6 @@ func init() {
 	}
 }
 
-// typeNames includes all known type names.
-var typeNames = []string{
-	"Null",
-	"Reference",
-	"ClassDesc",
-	"Object",
-	"String",
-	"Array",
-	"Class",
-	"BlockData",
-	"EndBlockData",
-	"Reset",
-	"BlockDataLong",
-	"Exception",
-	"LongString",
-	"ProxyClassDesc",
-	"Enum",
-}
-
-// typeNameMax is used to ensure an encountered type is known.
-var typeNameMax = uint8(len(typeNames) - 1)
-
 // allowedClazzNames includes all allowed names when parsing a class descriptor.
 var allowedClazzNames = map[string]bool{
 	"ClassDesc":      true,
@@ -191,11 +372,16 @@ var KnownPostProcs = map[string]PostProc{
 	"java.util.ArrayDeque@207cda2e240da08b": listPostProc,
 	"java.util.Hashtable@13bb0f25214ae4b8":  mapPostProc,
 	"java.util.HashMap@0507dac1c31660d1":    mapPostProc,
+	"java.util.TreeMap@0cc1f63e2d256ae6":    treeMapPostProc,
 	"java.util.EnumMap@065d7df7be907ca1":    enumMapPostProc,
 	"java.util.HashSet@ba44859596b8b734":    hashSetPostProc,
 	"java.util.Date@686a81014b597419":       datePostProc,
 }
 
+// externalPostProcs lists the KnownPostProcs of Externalizable classes, which run on the block data written by
+// writeExternal instead of field values and annotations.
+var externalPostProcs = map[string]bool{}
+
 // primitiveHandler are used to read primitive values.
 type primitiveHandler func(this *SerializedObjectParser) (interface{}, error)
 
@@ -209,11 +395,12 @@ func SetMaxDataBlockSize(maxSize int) Option {
 
 // NewSerializedObjectParser reads serialized java objects from stream.
 func NewSerializedObjectParser(rd io.Reader, options ...Option) *SerializedObjectParser {
-	buf := bufio.NewReaderSize(rd, bufferSize)
 	sop := &SerializedObjectParser{
-		rd:                     buf,
-		maxDataBlockSize:       buf.Size(),
-		_handleValue:           0x7e0000,
+		src:                    &countingReader{rd: rd},
+		bufferSize:             bufferSize,
+		handleBase:             baseWireHandle,
+		_handleValue:           baseWireHandle,
+		indentUnit:             defaultIndent,
 		_data:                  Smooth{data: []byte{}},
 		_classDataDescriptions: []*ClassDataDesc{},
 		so:                     &SerObject{},
@@ -224,6 +411,12 @@ func NewSerializedObjectParser(rd io.Reader, options ...Option) *SerializedObjec
 		option(sop)
 	}
 
+	sop.parsedEnd = sop.baseOffset
+	sop.rd = bufio.NewReaderSize(sop.src, sop.bufferSize)
+	if sop.maxDataBlockSize == 0 {
+		sop.maxDataBlockSize = sop.rd.Size()
+	}
+
 	return sop
 }
 
@@ -317,11 +510,15 @@ func (this *SerializedObjectParser) newHandle(obj interface{}) interface{} {
 }
 
 func (this *SerializedObjectParser) print(s ...interface{}) {
-	fmt.Printf(this._indent)
+	w := this.dumpWriter()
+	fmt.Fprint(w, this._indent)
 	for _, x := range s {
-		fmt.Printf("%v", x)
+		fmt.Fprintf(w, "%v", x)
+	}
+	if this.explain {
+		this.printExplanation(w, s...)
 	}
-	fmt.Println("")
+	fmt.Fprintln(w)
 }
 func (this *SerializedObjectParser) byteToHex(s uint8) string {
 	var data = []byte{s}
@@ -329,7 +526,7 @@ func (this *SerializedObjectParser) byteToHex(s uint8) string {
 }
 
 func (this *SerializedObjectParser) increaseIndent() {
-	this._indent = this._indent + "  "
+	this._indent = this._indent + this.indent()
 }
 
 func (this *SerializedObjectParser) readNewEnum() {
@@ -363,26 +560,24 @@ func (this *SerializedObjectParser) readNewEnum() {
  * already below 2.
  ******************/
 func (this *SerializedObjectParser) decreaseIndent() {
-	if len(this._indent) < 2 {
+	if len(this._indent) < len(this.indent()) {
 		log.Panicln("Error: Illegal indentation decrease.")
 	}
-	this._indent = this._indent[0 : len(this._indent)-2]
+	this._indent = this._indent[0 : len(this._indent)-len(this.indent())]
 }
 
 func (this *SerializedObjectParser) readUtf() string {
 	var content = ""
 	var hex = ""
-	var b1, b2 uint8
-	var len int
-	//length
-	b1 = this._data.pop()
-	b2 = this._data.pop()
+	var b1 uint8
 
-	len = (int(b1<<8) & 0xff00) + int(b2&0xff)
-	this.print("Length - ", len, " - 0x"+this.byteToHex(b1)+" "+this.byteToHex(b2))
+	//length
+	len, raw := this._data.uint16("utf length")
+	this._data.checkLength(uint64(len), 1, "utf length")
+	this.print("Length - ", len, " - 0x"+this.spacedHex(raw))
 
 	//Contents
-	for i := 0; i < len; {
+	for i := uint16(0); i < len; {
 		i += 1
 		b1 = this._data.pop()
 		content += fmt.Sprintf("%c", b1)
@@ -390,7 +585,7 @@ func (this *SerializedObjectParser) readUtf() string {
 	}
 	this.print("Value - " + content + " - 0x" + hex)
 	//Return the string
-	return content
+	return this.intern(content)
 }
 func (this *SerializedObjectParser) readTC_CLASSDESC() *ClassDataDesc {
 	var cdd = NewClassDataDesc()
@@ -407,12 +602,20 @@ func (this *SerializedObjectParser) readTC_CLASSDESC() *ClassDataDesc {
 	//className
 	this.print("className")
 	this.increaseIndent()
-	cdd.addClass(this.readUtf()) //Add the class name to the class data description
+	className := this.readUtf()
+	if original := this.proguard.Class(className); original != className {
+		this.print("Deobfuscated - " + original)
+		className = original
+	}
+	if relocated := this.relocations.Relocate(className); relocated != className {
+		this.print("Relocated - " + relocated)
+		className = relocated
+	}
+	cdd.addClass(className) //Add the class name to the class data description
 	this.decreaseIndent()
 
 	//serialVersionUID
-	this.print("serialVersionUID - 0x" + this.byteToHex(this._data.pop()) + " " + this.byteToHex(this._data.pop()) + " " + this.byteToHex(this._data.pop()) + " " + this.byteToHex(this._data.pop()) +
-		" " + this.byteToHex(this._data.pop()) + " " + this.byteToHex(this._data.pop()) + " " + this.byteToHex(this._data.pop()) + " " + this.byteToHex(this._data.pop()))
+	this.print("serialVersionUID - 0x" + this.spacedHex(this._data.take(8, "serialVersionUID")))
 
 	//newHandle
 	cdd.setLastClassHandle(this.newHandle1()) //Set the reference handle for the most recently added class
@@ -543,62 +746,16 @@ func (this *SerializedObjectParser) readClassAnnotation() {
  *	TC_BLOCKDATALONG	(0x7a)
  ******************/
 func (this *SerializedObjectParser) readContentElement() error {
-	//Peek the next byte and delegate to the appropriate method
+	//Peek the next byte and delegate to the reader of the tag
 	t1 := this._data.peek()
-	switch t1 {
-	case TC_OBJECT: //TC_OBJECT
-		this.readNewObject()
-		break
-
-	case TC_CLASS: //TC_CLASS
-		this.readNewClass()
-		break
-
-	case TC_ARRAY: //TC_ARRAY
-		this.readNewArray()
-		break
-
-	//TC_STRING
-	case TC_STRING, TC_LONGSTRING: //TC_LONGSTRING
-		this.readNewString()
-		break
-
-	case TC_ENUM: //TC_ENUM
-		this.readNewEnum()
-		break
-	//TC_CLASSDESC
-	case TC_CLASSDESC, TC_PROXYCLASSDESC: //TC_PROXYCLASSDESC
-		this.readNewClassDesc()
-		break
-
-	case TC_REFERENCE: //TC_REFERENCE
-		this.readPrevObject()
-		break
-
-	case TC_NULL: //TC_NULL
-		this.readNullReference()
-		break
 
-	case TC_EXCEPTION: //TC_EXCEPTION
-		this.readException()
-		break
-
-	case TC_RESET: //TC_RESET
-		this.handleReset()
-		break
-
-	case TC_BLOCKDATA: //TC_BLOCKDATA
-		this.readBlockData()
-		break
-
-	case TC_BLOCKDATALONG: //TC_BLOCKDATALONG
-		this.readLongBlockData()
-		break
-
-	default:
-		//this.print("Invalid content element type 0x" + this.byteToHex(this._data.peek()))
+	tag, known := contentTags[t1]
+	if !known || tag.dump == nil {
 		return errors.New(fmt.Sprintf("Error: Illegal content element type: %d.", t1))
 	}
+
+	tag.dump(this)
+
 	return nil
 }
 
@@ -636,7 +793,7 @@ func (this *SerializedObjectParser) readFieldDesc(cdd *ClassDataDesc) {
 		break
 
 	case 'J': //long
-		this.print("Long - L - 0x" + this.byteToHex(b1))
+		this.print("Long - J - 0x" + this.byteToHex(b1))
 		break
 
 	case 'S': //Short
@@ -663,14 +820,25 @@ func (this *SerializedObjectParser) readFieldDesc(cdd *ClassDataDesc) {
 	//fieldName
 	this.print("fieldName")
 	this.increaseIndent()
-	cdd.setLastFieldName(this.readUtf()) //Set the name of the most recently added field
+	fieldName := this.readUtf()
+	className := cdd.getClassDetails(cdd.getClassCount() - 1).getClassName()
+	if original := this.proguard.Field(className, fieldName); original != fieldName {
+		this.print("Deobfuscated - " + original)
+		fieldName = original
+	}
+	cdd.setLastFieldName(fieldName) //Set the name of the most recently added field
 	this.decreaseIndent()
 
 	//className1 (if non-primitive type)
 	if b1 == '[' || b1 == 'L' {
 		this.print("className1")
 		this.increaseIndent()
-		cdd.setLastFieldClassName1(this.readNewString()) //Set the className1 of the most recently added field
+		className1 := this.readNewString()
+		if original := this.proguard.Signature(className1); original != className1 {
+			this.print("Deobfuscated - " + original)
+			className1 = original
+		}
+		cdd.setLastFieldClassName1(className1) //Set the className1 of the most recently added field
 		this.decreaseIndent()
 	}
 }
@@ -741,60 +909,35 @@ func (this *SerializedObjectParser) readTC_LONGSTRING() string {
  * (long)length		contents
  ******************/
 func (this *SerializedObjectParser) readLongUtf() string {
-	var content = ""
-	var hex = ""
-	var b1, b2, b3, b4, b5, b6, b7, b8 byte
-	var len uint64
-
 	//Length
-	b1 = this._data.pop()
-	b2 = this._data.pop()
-	b3 = this._data.pop()
-	b4 = this._data.pop()
-	b5 = this._data.pop()
-	b6 = this._data.pop()
-	b7 = this._data.pop()
-	b8 = this._data.pop()
-	len = (uint64(b1<<56) & 0xff00000000000000) +
-		(uint64(b2<<48) & 0xff000000000000) +
-		(uint64(b3<<40) & 0xff0000000000) +
-		(uint64(b4<<32) & 0xff00000000) +
-		(uint64(b5<<24) & 0xff000000) +
-		(uint64(b6<<16) & 0xff0000) +
-		(uint64(b7<<8) & 0xff00) +
-		uint64(b8&0xff)
-	this.print("Length - ", len, " - 0x"+this.byteToHex(b1)+" "+this.byteToHex(b2)+" "+this.byteToHex(b3)+" "+this.byteToHex(b4)+" "+
-		this.byteToHex(b5)+" "+this.byteToHex(b6)+" "+this.byteToHex(b7)+" "+this.byteToHex(b8))
+	len, rawLen := this._data.uint64("long utf length")
+	this._data.checkLength(len, 1, "long utf length")
+	this.print("Length - ", len, " - 0x"+this.spacedHex(rawLen))
 
 	//Contents
+	var raw []byte
 	var l uint64 = 0
 	for l < len {
 		l += 1
-		b1 = this._data.pop()
-		content += fmt.Sprintf("%c", b1)
-		hex += this.byteToHex(b1)
+		raw = append(raw, this._data.pop())
 	}
-	this.print("Value - " + content + " - 0x" + hex)
+	this.printStringValue(raw)
 
 	//Return the string
-	return content
+	return latin1(raw)
 }
 
 func (this *SerializedObjectParser) readFields(cdd *ClassDataDesc) {
-	var b1, b2 byte
-	var count uint
-
 	//count
-	b1 = this._data.pop()
-	b2 = this._data.pop()
-	count = (uint(b1<<8) & 0xff00) + uint(b2&0xff)
-	this.print("fieldCount - ", count, " - 0x"+this.byteToHex(b1)+" "+this.byteToHex(b2))
+	count, raw := this._data.uint16("field count")
+	this._data.checkLength(uint64(count), 3, "field count")
+	this.print("fieldCount - ", count, " - 0x"+this.spacedHex(raw))
 
 	//fieldDesc
 	if count > 0 {
 		this.print("Fields")
 		this.increaseIndent()
-		var i uint = 0
+		var i uint16 = 0
 		for i < count {
 			this.print(i, ":")
 			this.increaseIndent()
@@ -874,24 +1017,15 @@ func (this *SerializedObjectParser) readTC_PROXYCLASSDESC() *ClassDataDesc {
  * (int)count	(utf)proxyInterfaceName[count]	classAnnotation		superClassDesc
  ******************/
 func (this *SerializedObjectParser) readProxyClassDescInfo(cdd *ClassDataDesc) {
-	var b1, b2, b3, b4 byte
-	var count int
-
 	//count
-	b1 = this._data.pop()
-	b2 = this._data.pop()
-	b3 = this._data.pop()
-	b4 = this._data.pop()
-	count = (int(b1<<24) & 0xff000000) +
-		(int(b2<<16) & 0xff0000) +
-		(int(b3<<8) & 0xff00) +
-		(int(b4) & 0xff)
-	this.print("Interface count - ", count, " - 0x"+this.byteToHex(b1)+" "+this.byteToHex(b2)+" "+this.byteToHex(b3)+" "+this.byteToHex(b4))
+	count, raw := this._data.uint32("interface count")
+	this._data.checkLength(uint64(count), 2, "interface count")
+	this.print("Interface count - ", count, " - 0x"+this.spacedHex(raw))
 
 	//proxyInterfaceName[count]
 	this.print("proxyInterfaceNames")
 	this.increaseIndent()
-	for i := 0; i < count; {
+	for i := uint32(0); i < count; {
 		i += 1
 		this.print(i, ":")
 		this.increaseIndent()
@@ -1025,6 +1159,7 @@ func (this *SerializedObjectParser) readClassData(cdd *ClassDataDesc) {
 				//Start the object annotations section and indent
 				this.print("objectAnnotation")
 				this.increaseIndent()
+				this.annotationClasses = append(this.annotationClasses, cd.getClassName())
 
 				//Loop until we have a TC_ENDBLOCKDATA
 				var x1 = this._data.peek()
@@ -1041,6 +1176,7 @@ func (this *SerializedObjectParser) readClassData(cdd *ClassDataDesc) {
 				//Pop and print the TC_ENDBLOCKDATA element
 				this._data.pop()
 				this.print("TC_ENDBLOCKDATA - 0x78")
+				this.annotationClasses = this.annotationClasses[:len(this.annotationClasses)-1]
 
 				//Revert indent
 				this.decreaseIndent()
@@ -1081,14 +1217,12 @@ func (this *SerializedObjectParser) readClassDataField(cf *ClassField) {
  ******************/
 func (this *SerializedObjectParser) readByteField() {
 	var b1 byte = this._data.pop()
-	c1 := fmt.Sprintf("%c", b1)
+	value := strconv.Itoa(int(int8(b1)))
 	if b1 >= 0x20 && b1 <= TC_ENUM {
 		//Print with ASCII
-		this.print("(byte)", c1, " (ASCII: "+c1+") - 0x"+this.byteToHex(b1))
-	} else {
-		//Just print byte value
-		this.print("(byte)", b1, " - 0x"+this.byteToHex(b1))
+		value += " (ASCII: " + string(rune(b1)) + ")"
 	}
+	this.printPrimitive("byte", value, b1)
 }
 
 /*******************
@@ -1097,25 +1231,15 @@ func (this *SerializedObjectParser) readByteField() {
 func (this *SerializedObjectParser) readCharField() {
 	var b1 byte = this._data.pop()
 	var b2 byte = this._data.pop()
-	c1 := fmt.Sprintf("%c", byte((uint32(b1<<8)&0xff00)+uint32(b2&0xff)))
-	this.print("(char)" + c1 + " - 0x" + this.byteToHex(b1) + " " + this.byteToHex(b2))
+	this.printPrimitive("char", string(rune(uint16(b1)<<8|uint16(b2))), b1, b2)
 }
 
 /*******************
  * Read a float field.
  ******************/
 func (this *SerializedObjectParser) readFloatField() {
-	var b1, b2, b3, b4 byte
-	b1 = this._data.pop()
-	b2 = this._data.pop()
-	b3 = this._data.pop()
-	b4 = this._data.pop()
-	var xx1 float64 = float64((uint32(b1<<24) & 0xff000000) +
-		(uint32(b2<<16) & 0xff0000) +
-		(uint32(b3<<8) & 0xff00) +
-		uint32(b4&0xff))
-	this.print("(float)", xx1, " - 0x"+this.byteToHex(b1)+" "+this.byteToHex(b2)+" "+this.byteToHex(b3)+
-		" "+this.byteToHex(b4))
+	b := this._data.take(4, "float value")
+	this.printPrimitive("float", formatFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(b))), 32), b...)
 }
 
 /*******************
@@ -1174,51 +1298,24 @@ func (this *SerializedObjectParser) readFieldValue(typeCode byte) {
  * Read an int field.
  ******************/
 func (this *SerializedObjectParser) readIntField() {
-	var b1, b2, b3, b4 byte
-	b1 = this._data.pop()
-	b2 = this._data.pop()
-	b3 = this._data.pop()
-	b4 = this._data.pop()
-	this.print("(int)", (int)((uint32(b1<<24)&0xff000000)+
-		(uint32(b2<<16)&0xff0000)+
-		(uint32(b3<<8)&0xff00)+
-		uint32(b4&0xff)), " - 0x"+this.byteToHex(b1)+" "+this.byteToHex(b2)+" "+this.byteToHex(b3)+
-		" "+this.byteToHex(b4))
+	b := this._data.take(4, "int value")
+	this.printPrimitive("int", strconv.FormatInt(int64(int32(binary.BigEndian.Uint32(b))), 10), b...)
 }
 
 /*******************
  * Read a long field.
  ******************/
 func (this *SerializedObjectParser) readLongField() {
-	var b1, b2, b3, b4, b5, b6, b7, b8 byte
-	b1 = this._data.pop()
-	b2 = this._data.pop()
-	b3 = this._data.pop()
-	b4 = this._data.pop()
-	b5 = this._data.pop()
-	b6 = this._data.pop()
-	b7 = this._data.pop()
-	b8 = this._data.pop()
-	this.print("(long)", (uint64(b1<<56)&0xff00000000000000)+
-		(uint64(b2<<48)&0xff000000000000)+
-		(uint64(b3<<40)&0xff0000000000)+
-		(uint64(b4<<32)&0xff00000000)+
-		(uint64(b5<<24)&0xff000000)+
-		(uint64(b6<<16)&0xff0000)+
-		(uint64(b7<<8)&0xff00)+
-		uint64(b8&0xff), " - 0x"+this.byteToHex(b1)+
-		" "+this.byteToHex(b2)+" "+this.byteToHex(b3)+" "+this.byteToHex(b4)+" "+this.byteToHex(b5)+" "+this.byteToHex(b6)+" "+
-		this.byteToHex(b7)+" "+this.byteToHex(b8))
+	b := this._data.take(8, "long value")
+	this.printPrimitive("long", strconv.FormatInt(int64(binary.BigEndian.Uint64(b)), 10), b...)
 }
 
 /*******************
  * Read a short field.
  ******************/
 func (this *SerializedObjectParser) readShortField() {
-	var b1, b2 byte
-	b1 = this._data.pop()
-	b2 = this._data.pop()
-	this.print("(short)", uint16((uint16(b1<<8)&0xff00)+uint16(b2&0xff)), " - 0x"+this.byteToHex(b1)+" "+this.byteToHex(b2))
+	b := this._data.take(2, "short value")
+	this.printPrimitive("short", strconv.FormatInt(int64(int16(binary.BigEndian.Uint16(b))), 10), b...)
 }
 
 /*******************
@@ -1226,11 +1323,7 @@ func (this *SerializedObjectParser) readShortField() {
  ******************/
 func (this *SerializedObjectParser) readBooleanField() {
 	var b1 = this._data.pop()
-	var x1 = "true"
-	if b1 == 0 {
-		x1 = "false"
-	}
-	this.print("(boolean)" + x1 + " - 0x" + this.byteToHex(b1))
+	this.printPrimitive("boolean", strconv.FormatBool(b1 != 0), b1)
 }
 
 /*******************
@@ -1318,26 +1411,8 @@ func (this *SerializedObjectParser) readObjectField() {
  * Read a double field.
  ******************/
 func (this *SerializedObjectParser) readDoubleField() {
-	var b1, b2, b3, b4, b5, b6, b7, b8 byte
-	b1 = this._data.pop()
-	b2 = this._data.pop()
-	b3 = this._data.pop()
-	b4 = this._data.pop()
-	b5 = this._data.pop()
-	b6 = this._data.pop()
-	b7 = this._data.pop()
-	b8 = this._data.pop()
-	var xx uint64 = (uint64(b1<<56) & 0xff00000000000000) +
-		(uint64(b2<<48) & 0xff000000000000) +
-		(uint64(b3<<40) & 0xff0000000000) +
-		(uint64(b4<<32) & 0xff00000000) +
-		(uint64(b5<<24) & 0xff000000) +
-		(uint64(b6<<16) & 0xff0000) +
-		(uint64(b7<<8) & 0xff00) +
-		uint64(b8&0xff)
-	this.print("(double)", xx, " - 0x"+this.byteToHex(b1)+
-		" "+this.byteToHex(b2)+" "+this.byteToHex(b3)+" "+this.byteToHex(b4)+" "+this.byteToHex(b5)+" "+this.byteToHex(b6)+" "+
-		this.byteToHex(b7)+" "+this.byteToHex(b8))
+	b := this._data.take(8, "double value")
+	this.printPrimitive("double", formatFloat(math.Float64frombits(binary.BigEndian.Uint64(b)), 64), b...)
 }
 
 // 读新class
@@ -1366,8 +1441,7 @@ func (this *SerializedObjectParser) readNewClass() {
 func (this *SerializedObjectParser) readNewArray() {
 	var cdd *ClassDataDesc
 	var cd *ClassDetails
-	var b1, b2, b3, b4 byte
-	var size int
+	var b1 byte
 
 	//TC_ARRAY
 	b1 = this._data.pop()
@@ -1391,15 +1465,18 @@ func (this *SerializedObjectParser) readNewArray() {
 	this.newHandle1()
 
 	//Array size
-	b1 = this._data.pop()
-	b2 = this._data.pop()
-	b3 = this._data.pop()
-	b4 = this._data.pop()
-	size = int((uint32(b1<<24) & 0xff000000) +
-		(uint32(b2<<16) & 0xff0000) +
-		(uint32(b3<<8) & 0xff00) +
-		(uint32(b4) & 0xff))
-	this.print("Array size - ", size, " - 0x"+this.byteToHex(b1)+" "+this.byteToHex(b2)+" "+this.byteToHex(b3)+" "+this.byteToHex(b4))
+	n, raw := this._data.uint32("array size")
+	size := int(int32(n))
+	this.print("Array size - ", size, " - 0x"+this.spacedHex(raw))
+	if size < 0 {
+		log.Panicf("Error: Illegal array size %d", size)
+	}
+
+	elemSize, isPrimitive := primitiveSizes[cd.getClassName()[1]]
+	if !isPrimitive {
+		elemSize = 1
+	}
+	this._data.checkLength(uint64(size), elemSize, "array size")
 
 	//Array data
 	this.print("Values")
@@ -1410,8 +1487,7 @@ func (this *SerializedObjectParser) readNewArray() {
 		this.increaseIndent()
 
 		//Read the field values based on the classDesc read above
-		x1 := []byte(cd.getClassName()[0:1])
-		this.readFieldValue(x1[0])
+		this.readFieldValue(cd.getClassName()[1])
 
 		//Revert indent
 		this.decreaseIndent()
@@ -1449,8 +1525,7 @@ func (this *SerializedObjectParser) readTC_STRING() string {
 }
 
 func (this *SerializedObjectParser) readPrevObject() int {
-	var b1, b2, b3, b4 byte
-	var handle uint32
+	var b1 byte
 
 	//TC_REFERENCE
 	b1 = this._data.pop()
@@ -1461,19 +1536,8 @@ func (this *SerializedObjectParser) readPrevObject() int {
 	this.increaseIndent()
 
 	//Reference handle
-	b1 = this._data.pop()
-	b2 = this._data.pop()
-	b3 = this._data.pop()
-	b4 = this._data.pop()
-
-	var a11 = []byte{b1, b2, b3, b4}
-	handle = binary.BigEndian.Uint32(a11)
-	//handle = (uint32(b1<<24) & 0xff000000) +
-	//(uint32(b2<<16) & 0xff0000) +
-	//(uint32(b3<<8) & 0xff00) +
-	//(uint32(b4) & 0xff)
-
-	this.print("Handle - ", handle, " - 0x"+this.byteToHex(b1)+" "+this.byteToHex(b2)+" "+this.byteToHex(b3)+" "+this.byteToHex(b4))
+	handle, raw := this._data.uint32("handle")
+	this.print("Handle - ", handle, " - 0x"+this.spacedHex(raw))
 
 	//Revert indent
 	this.decreaseIndent()
@@ -1495,10 +1559,15 @@ func (this *SerializedObjectParser) readNullReference() {
 
 func (this *SerializedObjectParser) readException() {}
 
-func (this *SerializedObjectParser) handleReset() {}
+func (this *SerializedObjectParser) handleReset() {
+	//TC_RESET
+	this.print("TC_RESET - 0x" + this.byteToHex(this._data.pop()))
+
+	//The handles restart from the first one
+	this._handleValue = this.handleBase
+}
 
 func (this *SerializedObjectParser) readBlockData() {
-	contents := ""
 	var len int
 	var b1 byte
 
@@ -1511,23 +1580,20 @@ func (this *SerializedObjectParser) readBlockData() {
 	this.increaseIndent()
 
 	//size
-	len = int(this._data.pop() & 0xFF)
+	len = int(this._data.take(1, "block data length")[0])
+	this._data.checkLength(uint64(len), 1, "block data length")
 	this.print("Length - ", len, " - 0x"+this.byteToHex((byte)(len&0xff)))
 
 	//contents
-	for i := 0; i < len; i += 1 {
-		contents += this.byteToHex(this._data.pop())
-	}
-	this.print("Contents - 0x" + contents)
+	raw := this.dumpBytes("Contents - ", uint64(len), this.blockTextWanted())
+	this.printBlockText(raw)
 
 	//Drop indent back
 	this.decreaseIndent()
 }
 
 func (this *SerializedObjectParser) readLongBlockData() {
-	contents := ""
-	var len uint32
-	var b1, b2, b3, b4 byte
+	var b1 byte
 
 	//TC_BLOCKDATALONG
 	b1 = this._data.pop()
@@ -1538,23 +1604,13 @@ func (this *SerializedObjectParser) readLongBlockData() {
 	this.increaseIndent()
 
 	//size
-	b1 = this._data.pop()
-	b2 = this._data.pop()
-	b3 = this._data.pop()
-	b4 = this._data.pop()
-	len = (uint32(b1<<24) & 0xff000000) +
-		(uint32(b2<<16) & 0xff0000) +
-		(uint32(b3<<8) & 0xff00) +
-		(uint32(b4) & 0xff)
-	this.print("Length - ", len, " - 0x"+this.byteToHex(b1)+" "+this.byteToHex(b2)+" "+this.byteToHex(b3)+" "+this.byteToHex(b4))
+	len, rawLen := this._data.uint32("block data length")
+	this._data.checkLength(uint64(len), 1, "block data length")
+	this.print("Length - ", len, " - 0x"+this.spacedHex(rawLen))
 
 	//contents
-	var l uint32 = 0
-	for l < len {
-		l += 1
-		contents += this.byteToHex(this._data.pop())
-	}
-	this.print("Contents - 0x" + contents)
+	raw := this.dumpBytes("Contents - ", uint64(len), this.blockTextWanted())
+	this.printBlockText(raw)
 
 	//Drop indent back
 	this.decreaseIndent()
@@ -1564,55 +1620,97 @@ func (this *SerializedObjectParser) readLongBlockData() {
 func (this *SerializedObjectParser) content(allowedNames map[string]bool) (content interface{}, err error) {
 	var tc uint8
 
-	tc = this._data.peek()
+	if this.ctx != nil {
+		if err = this.ctx.Err(); err != nil {
+			return
+		}
+	}
+
+	if tc, err = this.readUInt8(); err != nil {
+		err = errors.Wrap(err, "error reading content type")
+
+		return
+	}
+
 	this.so.Tc_Type = tc
-	switch tc {
-	case TC_NULL: // = 0x70 // 空指针
-		this.readNullReference()
-	case TC_REFERENCE: // = 0x71
-		this.readPrevObject()
-	case TC_CLASSDESC, TC_PROXYCLASSDESC: // = 0x7D TC_PROXYCLASSDESC: // = 0x72 // TC_CLASSDESC. 指定这是一个新类。
-		this.readNewClassDesc()
-	case TC_OBJECT: // = 0x73 // TC_OBJECT.  指定这是一个新的Object.
-		this.readNewObject()
-	case TC_STRING, TC_LONGSTRING: // = 0x7C: // = 0x74
-		this.readNewString()
-	case TC_ARRAY: // = 0x75
-		this.readNewArray()
-	case TC_CLASS: // = 0x76
-		this.readNewClass()
-	case TC_BLOCKDATA: // = 0x77
-		this.readBlockData()
-	case TC_ENDBLOCKDATA: // = 0x78
-	case TC_RESET: // = 0x79
-		this.handleReset()
-	case TC_BLOCKDATALONG: // = 0x7A
-		this.readLongBlockData()
-	case TC_EXCEPTION: // = 0x7B
-		this.readException()
-	case TC_ENUM: // = 0x7E
-		this.readNewEnum()
-	default: // 异常情况
+
+	tag, known := contentTags[tc]
+	if !known {
+		err = errors.Errorf("unknown content type %#x", tc)
+
+		return
 	}
 
-	return nil, nil
+	name := tag.name
+
+	if allowedNames != nil && !allowedNames[name] {
+		err = errors.Errorf("content type %s is not allowed here", name)
+
+		return
+	}
+
+	if tag.handler != nil {
+		if content, err = tag.handler(&TagReader{p: this}); err != nil {
+			err = errors.Wrapf(err, "error parsing %s", name)
+		} else if content == nil {
+			return this.content(allowedNames)
+		}
+
+		return
+	}
+
+	parse, known := knownParsers[name]
+	if !known {
+		err = errors.Errorf("unable to parse content type %s", name)
+
+		return
+	}
+
+	if content, err = parse(this); err != nil {
+		err = errors.Wrapf(err, "error parsing %s", name)
+	}
+
+	return
+}
+
+// countingReader counts the bytes read from the underlying reader, and keeps them when recording.
+type countingReader struct {
+	rd        io.Reader
+	n         int64
+	recording bool
+	recorded  []byte
 }
 
-// end check has next byte in stream.
+func (this *countingReader) Read(p []byte) (n int, err error) {
+	n, err = this.rd.Read(p)
+	this.n += int64(n)
+
+	if this.recording {
+		this.recorded = append(this.recorded, p[:n]...)
+	}
+
+	return
+}
+
+// end check has next byte in stream, a metadata block ending the stream (see AppendMetadata).
 func (this *SerializedObjectParser) end() bool {
 	if this.rd.Buffered() == 0 {
-		_, eof := this.rd.Peek(1)
-
-		return eof != nil
+		if _, eof := this.rd.Peek(1); eof != nil {
+			return true
+		}
 	}
 
-	return false
+	return this.atMetadata()
 }
 
 // readString reads a string of length cnt bytes.
 func (this *SerializedObjectParser) readString(cnt int, asHex bool) (s string, err error) {
 	this.buf.Reset()
 
+	if err = lengthError(uint64(cnt), 1, this.remaining(), "string length"); err != nil {
+		return
+	}
+
 	// Prevented to allocate an extremely large block of memory.
 	if cnt > this.maxDataBlockSize {
 		err = errors.Errorf("block data exceeds size of reader buffer. " +
@@ -1633,6 +1731,8 @@ func (this *SerializedObjectParser) readString(cnt int, asHex bool) (s string, e
 		s = this.buf.String()
 	}
 
+	s = this.intern(s)
+
 	return
 }
 
@@ -1776,6 +1876,7 @@ func (this *SerializedObjectParser) version() error {
 		return errors.Errorf("protocol version not recognized: wanted 5 got %d", ver)
 	}
 	this.so.STREAM_VERSION = STREAM_VERSION
+	this.protocol.StreamVersion = int(ver)
 
 	return nil
 }
@@ -1860,6 +1961,51 @@ type clazz struct {
 	name             string
 	flags            uint8
 	isEnum           bool
+	interfaces       []string    // interfaces of a dynamic proxy class, named $Proxy
+	relocatedFrom    string      // shaded name of the class, see SetRelocations
+	obfuscatedName   string      // name of the class in the stream, see SetProGuardMapping
+	rawAnnotations   []byte      // see KeepAnnotationBytes
+	plan             *decodePlan // reader of the field values, see classPlan
+}
+
+// wireName returns the name of the class as written in the stream, before SetProGuardMapping and SetRelocations.
+func (cls *clazz) wireName() string {
+	switch {
+	case cls.obfuscatedName != "":
+		return cls.obfuscatedName
+	case cls.relocatedFrom != "":
+		return cls.relocatedFrom
+	}
+
+	return cls.name
+}
+
+// MarshalJSON encodes the class descriptor of an object (annotations excepted), see Schemas["dump"].
+func (cls *clazz) MarshalJSON() ([]byte, error) {
+	type jsonField struct {
+		Name      string `json:"name"`
+		Type      string `json:"type"`
+		ClassName string `json:"className,omitempty"`
+	}
+
+	fields := make([]jsonField, len(cls.fields))
+	for i, f := range cls.fields {
+		fields[i] = jsonField{Name: f.name, Type: f.typeName, ClassName: f.className}
+	}
+
+	return json.Marshal(&struct {
+		Name             string      `json:"name"`
+		SerialVersionUID string      `json:"serialVersionUID"`
+		Flags            uint8       `json:"flags"`
+		IsEnum           bool        `json:"isEnum,omitempty"`
+		Interfaces       []string    `json:"interfaces,omitempty"`
+		Fields           []jsonField `json:"fields,omitempty"`
+		Super            *clazz      `json:"super,omitempty"`
+		RelocatedFrom    string      `json:"relocatedFrom,omitempty"`
+		ObfuscatedName   string      `json:"obfuscatedName,omitempty"`
+		RawAnnotations   []byte      `json:"rawAnnotations,omitempty"`
+	}{cls.name, cls.serialVersionUID, cls.flags, cls.isEnum, cls.interfaces, fields, cls.super, cls.relocatedFrom,
+		cls.obfuscatedName, cls.rawAnnotations})
 }
 
 // classDesc reads a class descriptor.
@@ -1902,6 +2048,17 @@ func parseClassDesc(this *SerializedObjectParser) (x interface{}, err error) {
 		return
 	}
 
+	if original := this.proguard.Class(cls.name); original != cls.name {
+		cls.obfuscatedName, cls.name = cls.name, original
+	}
+
+	// the mapping names the fields after the original class
+	mappedName := cls.name
+
+	if relocated := this.relocations.Relocate(cls.name); relocated != cls.name {
+		cls.relocatedFrom, cls.name = cls.name, relocated
+	}
+
 	const serialVersionUIDLength = 8
 	if cls.serialVersionUID, err = this.readString(serialVersionUIDLength, true); err != nil {
 		err = errors.Wrap(err, "error reading class serialVersionUID")
@@ -1936,15 +2093,24 @@ func parseClassDesc(this *SerializedObjectParser) (x interface{}, err error) {
 			return
 		}
 
+		f.name, f.className = this.proguard.Field(mappedName, f.name), this.proguard.Signature(f.className)
 		cls.fields = append(cls.fields, f)
 	}
 
+	this.classPlan(cls)
+
+	annotationsStart := this.Consumed()
+
 	if cls.annotations, err = this.annotations(nil); err != nil {
 		err = errors.Wrap(err, "error reading class annotations")
 
 		return
 	}
 
+	cls.rawAnnotations = this.rawSince(annotationsStart)
+
+	this.checkClass(cls)
+
 	if cls.super, err = this.classDesc(); err != nil {
 		err = errors.Wrap(err, "error reading class super")
 
@@ -1956,6 +2122,67 @@ func parseClassDesc(this *SerializedObjectParser) (x interface{}, err error) {
 	return
 }
 
+// proxyClassName names the classes of dynamic proxies, whose actual names are generated at runtime.
+const proxyClassName = "$Proxy"
+
+// parseProxyClassDesc parses the class descriptor of a dynamic proxy: the names of the interfaces it implements. The
+// proxy class, named $Proxy, has no field of its own; its superclass java.lang.reflect.Proxy holds the invocation
+// handler.
+func parseProxyClassDesc(this *SerializedObjectParser) (x interface{}, err error) {
+	cls := &clazz{name: proxyClassName, serialVersionUID: "0000000000000000", flags: SC_SERIALIZABLE}
+
+	this.newHandle(cls)
+
+	var count int32
+
+	if count, err = this.readInt32(); err != nil {
+		err = errors.Wrap(err, "error reading proxy interface count")
+
+		return
+	}
+
+	if count < 0 {
+		return nil, errors.Errorf("invalid proxy interface count %d", count)
+	}
+
+	// every name takes at least its 2-byte length
+	if err = lengthError(uint64(count), 2, this.remaining(), "proxy interface count"); err != nil {
+		return
+	}
+
+	for i := 0; i < int(count); i++ {
+		var name string
+
+		if name, err = this.utf(); err != nil {
+			err = errors.Wrap(err, "error reading proxy interface name")
+
+			return
+		}
+
+		cls.interfaces = append(cls.interfaces, this.relocations.Relocate(this.proguard.Class(name)))
+	}
+
+	annotationsStart := this.Consumed()
+
+	if cls.annotations, err = this.annotations(nil); err != nil {
+		err = errors.Wrap(err, "error reading proxy class annotations")
+
+		return
+	}
+
+	cls.rawAnnotations = this.rawSince(annotationsStart)
+
+	if cls.super, err = this.classDesc(); err != nil {
+		err = errors.Wrap(err, "error reading proxy class super")
+
+		return
+	}
+
+	x = cls
+
+	return
+}
+
 func parseClass(this *SerializedObjectParser) (cd interface{}, err error) {
 	if cd, err = this.classDesc(); err != nil {
 		err = errors.Wrap(err, "error parsing class")
@@ -1977,11 +2204,12 @@ func parseReference(this *SerializedObjectParser) (ref interface{}, err error) {
 		return
 	}
 
-	const refIDMask = 0x7e0000
-	i := int(refIdx - refIDMask)
+	i := int(refIdx) - this.handleBase + this.handleFloor
 
-	if i > -1 && i < len(this.handles) {
+	if i >= this.handleFloor && i < len(this.handles) {
 		ref = this.handles[i]
+	} else if this.strict {
+		err = errors.Errorf("invalid handle %#x", uint32(refIdx))
 	}
 
 	return
@@ -2016,7 +2244,19 @@ func parseArray(this *SerializedObjectParser) (arr interface{}, err error) {
 		return
 	}
 
-	primHandler, exists := primitiveHandlers[string(cls.name[1])]
+	if elemSize, isPrimitive := primitiveSizes[cls.name[1]]; isPrimitive && this.primitiveOverrides[cls.name[1:2]] == nil {
+		if size > 0 {
+			if err = lengthError(uint64(size), elemSize, this.remaining(), "array size"); err != nil {
+				return
+			}
+		}
+
+		arr, err = this.readPrimitiveArray(cls.name[1], int(size))
+
+		return
+	}
+
+	primHandler, exists := this.primitiveHandler(string(cls.name[1]))
 	if !exists {
 		err = errors.Errorf("unknown field type '%s'", string(cls.name[1]))
 
@@ -2084,6 +2324,8 @@ func parseEnum(this *SerializedObjectParser) (enum interface{}, err error) {
 }
 
 func parseBlockData(this *SerializedObjectParser) (bd interface{}, err error) {
+	this.protocol.BlockData = true
+
 	var size uint8
 
 	if size, err = this.readUInt8(); err != nil {
@@ -2102,6 +2344,8 @@ func parseBlockData(this *SerializedObjectParser) (bd interface{}, err error) {
 }
 
 func parseBlockDataLong(this *SerializedObjectParser) (bdl interface{}, err error) {
+	this.protocol.BlockData = true
+
 	var size uint32
 
 	if size, err = this.readUInt32(); err != nil {
@@ -2110,6 +2354,10 @@ func parseBlockDataLong(this *SerializedObjectParser) (bdl interface{}, err erro
 		return
 	}
 
+	if err = lengthError(uint64(size), 1, this.remaining(), "block data length"); err != nil {
+		return
+	}
+
 	// Prevented to allocate an extremely large block of memory.
 	if int(size) > this.maxDataBlockSize {
 		err = errors.Errorf("block data exceeds size of reader buffer. " +
@@ -2163,6 +2411,10 @@ func parseEndBlockData(_ *SerializedObjectParser) (interface{}, error) {
 
 // values reads primitive field values.
 func (this *SerializedObjectParser) values(cls *clazz) (vals map[string]interface{}, err error) {
+	if cls.plan != nil {
+		return cls.plan.read(this)
+	}
+
 	var exists bool
 
 	var handler primitiveHandler
@@ -2174,7 +2426,7 @@ func (this *SerializedObjectParser) values(cls *clazz) (vals map[string]interfac
 			continue
 		}
 
-		if handler, exists = primitiveHandlers[field.typeName]; !exists {
+		if handler, exists = this.primitiveHandler(field.typeName); !exists {
 			err = errors.Errorf("unknown field type '%s'", field.typeName)
 
 			return
@@ -2202,6 +2454,8 @@ func (this *SerializedObjectParser) annotationsAsMap(cls *clazz, isBlock bool) (
 
 	var anns []interface{}
 
+	annotationsStart := this.Consumed()
+
 	if anns, err = this.annotations(nil); err != nil {
 		err = errors.Wrap(err, "error reading annotations")
 
@@ -2210,10 +2464,24 @@ func (this *SerializedObjectParser) annotationsAsMap(cls *clazz, isBlock bool) (
 
 	data["@"] = anns
 
-	if !isBlock {
-		if postproc, exists := KnownPostProcs[cls.name+"@"+cls.serialVersionUID]; exists {
-			data, err = postproc(data, anns)
-		}
+	if this.skipCustomData {
+		return
+	}
+
+	if raw := this.rawSince(annotationsStart); raw != nil {
+		data["@raw"] = raw
+	}
+
+	texts := this.annotationText(cls, anns)
+	if len(texts) > 0 {
+		data["@text"] = texts
+	}
+
+	signature := cls.name + "@" + cls.serialVersionUID
+	if postproc, exists := this.postProc(signature); exists && (!isBlock || externalPostProcs[signature]) {
+		data, err = postproc(data, anns)
+	} else if len(anns) > 0 && len(texts) == 0 {
+		this.warn(WarningAnnotation, "%d annotation elements of %s left uninterpreted", len(anns), cls.name)
 	}
 
 	return
@@ -2240,9 +2508,11 @@ func (this *SerializedObjectParser) classData(cls *clazz) (data map[string]inter
 		return this.annotationsAsMap(cls, false)
 
 	case ScExternalizeWithBlockData: // SC_EXTERNALIZABLE without SC_BLOCKDATA
-		return nil, errors.New("unable to parse version 1 external content")
+		return this.externalV1Data(cls)
 
 	case ScExternalizeWithoutBlockData: // SC_EXTERNALIZABLE with SC_BLOCKDATA
+		this.protocol.ExternalV2++
+
 		return this.annotationsAsMap(cls, true)
 
 	default:
@@ -2301,6 +2571,28 @@ func parseObject(this *SerializedObjectParser) (obj interface{}, err error) {
 
 	deferredHandle := this.newDeferredHandle()
 
+	if this.isUnknownClass(cls) {
+		err = this.skipObjectData(cls, objMap)
+		obj = deferredHandle(objMap)
+
+		return
+	}
+
+	// the write-method data of the objects nested in an unknown object is read as usual
+	skipping := this.skipCustomData
+	this.skipCustomData = false
+
+	defer func() {
+		this.skipCustomData = skipping
+	}()
+
+	hooks, hooked := this.hooksOf(cls)
+	if hooked {
+		if err = this.runHook(hooks.Before, "pre-decode", cls, objMap); err != nil {
+			return
+		}
+	}
+
 	seen := map[*clazz]bool{}
 	if err = this.recursiveClassData(cls, objMap, seen); err != nil {
 		err = errors.Wrap(err, "error reading recursive class data")
@@ -2308,6 +2600,16 @@ func parseObject(this *SerializedObjectParser) (obj interface{}, err error) {
 		return
 	}
 
+	if postproc, exists := this.objectPostProc(cls); exists {
+		postproc(objMap)
+	}
+
+	if hooked {
+		if err = this.runHook(hooks.After, "post-decode", cls, objMap); err != nil {
+			return
+		}
+	}
+
 	obj = deferredHandle(objMap)
 
 	return
@@ -2369,7 +2671,18 @@ func listPostProc(fields map[string]interface{}, data []interface{}) (map[string
 
 // mapPostProc populates the object value with a map of key/value pairs.
 func mapPostProc(fields map[string]interface{}, data []interface{}) (map[string]interface{}, error) {
-	size, err := postProcSize(data, 4)
+	return mapEntries(fields, data, 4)
+}
+
+// treeMapPostProc populates the object value of a java.util.TreeMap, whose size is not preceded by a capacity.
+func treeMapPostProc(fields map[string]interface{}, data []interface{}) (map[string]interface{}, error) {
+	return mapEntries(fields, data, 0)
+}
+
+// mapEntries populates the object value with the key/value pairs following the size at sizeOffset of the
+// block data.
+func mapEntries(fields map[string]interface{}, data []interface{}, sizeOffset int) (map[string]interface{}, error) {
+	size, err := postProcSize(data, sizeOffset)
 	if err != nil {
 		return nil, err
 	}
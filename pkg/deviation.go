@@ -0,0 +1,186 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Deviation kinds.
+const (
+	DeviationClass   = "class"   // class absent from the learned schema
+	DeviationField   = "field"   // field absent from the learned class
+	DeviationType    = "type"    // value of a JSON type never seen for the field
+	DeviationSize    = "size"    // string or array longer than ever seen for the field
+	DeviationMissing = "missing" // field present in every learned instance, absent or null here
+)
+
+// Deviation is a difference between a stream and a learned schema, repeated Count times.
+type Deviation struct {
+	Kind   string `json:"kind"`
+	Class  string `json:"class"`
+	Field  string `json:"field,omitempty"`
+	Detail string `json:"detail"`
+	Count  int    `json:"count"`
+}
+
+// LearnedSchema is a schema inferred by SchemaInference, against which streams are checked.
+type LearnedSchema struct {
+	classes map[string]*learnedClass
+}
+
+type learnedClass struct {
+	fields   map[string]*learnedField
+	required []string
+}
+
+type learnedField struct {
+	types     map[string]bool
+	maxLength int // -1 when no string was seen
+	maxItems  int // -1 when no array was seen
+}
+
+// LearnSchema returns the LearnedSchema of an inference.
+func LearnSchema(inference *SchemaInference) (*LearnedSchema, error) {
+	b, err := json.Marshal(inference.JSONSchema())
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseLearnedSchema(b)
+}
+
+// LoadLearnedSchema reads a schema written by the infer command.
+func LoadLearnedSchema(path string) (*LearnedSchema, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := ParseLearnedSchema(b)
+
+	return schema, errors.Wrapf(err, "error reading learned schema %s", path)
+}
+
+// ParseLearnedSchema parses a schema in the format of SchemaInference.JSONSchema.
+func ParseLearnedSchema(b []byte) (*LearnedSchema, error) {
+	var doc struct {
+		Defs map[string]struct {
+			Properties map[string]struct {
+				Type      interface{} `json:"type"`
+				MaxLength *int        `json:"maxLength"`
+				MaxItems  *int        `json:"maxItems"`
+			} `json:"properties"`
+			Required []string `json:"required"`
+		} `json:"$defs"`
+	}
+
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	schema := &LearnedSchema{classes: map[string]*learnedClass{}}
+
+	for name, def := range doc.Defs {
+		lc := &learnedClass{fields: map[string]*learnedField{}, required: def.Required}
+
+		for fieldName, prop := range def.Properties {
+			lf := &learnedField{types: map[string]bool{}, maxLength: -1, maxItems: -1}
+
+			switch t := prop.Type.(type) {
+			case string:
+				lf.types[t] = true
+			case []interface{}:
+				for _, e := range t {
+					if kind, isString := e.(string); isString {
+						lf.types[kind] = true
+					}
+				}
+			}
+
+			if prop.MaxLength != nil {
+				lf.maxLength = *prop.MaxLength
+			}
+
+			if prop.MaxItems != nil {
+				lf.maxItems = *prop.MaxItems
+			}
+
+			lc.fields[fieldName] = lf
+		}
+
+		schema.classes[name] = lc
+	}
+
+	return schema, nil
+}
+
+// Check returns the deviations of parsed content from the schema, by kind, class and field.
+func (this *LearnedSchema) Check(content []interface{}) []Deviation {
+	index := map[string]*Deviation{}
+
+	var keys []string
+
+	add := func(kind, class, field, format string, args ...interface{}) {
+		key := kind + "\x00" + class + "\x00" + field
+		if d, exists := index[key]; exists {
+			d.Count++
+
+			return
+		}
+
+		index[key] = &Deviation{Kind: kind, Class: class, Field: field, Detail: fmt.Sprintf(format, args...), Count: 1}
+		keys = append(keys, key)
+	}
+
+	walkClassFields(content, func(cls *clazz, fields map[string]interface{}) {
+		lc, known := this.classes[cls.name]
+		if !known {
+			add(DeviationClass, cls.name, "", "class never seen")
+
+			return
+		}
+
+		for _, name := range lc.required {
+			if fields[name] == nil {
+				add(DeviationMissing, cls.name, name, "field always set in the learned streams")
+			}
+		}
+
+		for name, v := range fields {
+			lf, known := lc.fields[name]
+			if !known {
+				add(DeviationField, cls.name, name, "field never seen")
+
+				continue
+			}
+
+			if kind := jsonKind(v); !lf.types[kind] {
+				add(DeviationType, cls.name, name, "%s value, never seen", kind)
+			}
+
+			switch o := v.(type) {
+			case string:
+				if lf.maxLength >= 0 && len(o) > lf.maxLength {
+					add(DeviationSize, cls.name, name, "string longer than %d", lf.maxLength)
+				}
+			case []interface{}:
+				if lf.maxItems >= 0 && len(o) > lf.maxItems {
+					add(DeviationSize, cls.name, name, "array longer than %d", lf.maxItems)
+				}
+			}
+		}
+	})
+
+	sort.Strings(keys)
+
+	deviations := make([]Deviation, len(keys))
+	for i, key := range keys {
+		deviations[i] = *index[key]
+	}
+
+	return deviations
+}
@@ -0,0 +1,192 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// dumpHexChunk is the number of bytes hex encoded at once by the dump.
+const dumpHexChunk = 4096
+
+// SetDumpWriter sends the output of DumpSerializedObject to w instead of the standard output.
+func SetDumpWriter(w io.Writer) Option {
+	return func(this *SerializedObjectParser) {
+		this.dumpOut = w
+	}
+}
+
+// SetMaxDumpBytes caps the bytes of a block data or long string value printed by DumpSerializedObject, the rest
+// being replaced by an elision marker. Values are streamed to the dump writer in chunks whatever the cap, so that
+// multi-MB contents do not build giant strings.
+func SetMaxDumpBytes(n int) Option {
+	return func(this *SerializedObjectParser) {
+		this.maxDumpBytes = n
+	}
+}
+
+func (this *SerializedObjectParser) dumpWriter() io.Writer {
+	if this.dumpOut == nil {
+		return os.Stdout
+	}
+
+	return this.dumpOut
+}
+
+// hexLine prints a dump line ending with the hex of a value, written chunk by chunk.
+type hexLine struct {
+	w       io.Writer
+	max     int // SetMaxDumpBytes
+	printed int
+	elided  uint64
+	enc     []byte
+	note    string // see SetDumpExplain
+}
+
+// hexLine starts a dump line with prefix, the hex of the value following.
+func (this *SerializedObjectParser) hexLine(prefix string) *hexLine {
+	w := this.dumpWriter()
+	_, _ = io.WriteString(w, this._indent+prefix+"0x")
+
+	line := &hexLine{w: w, max: this.maxDumpBytes}
+	if this.explain {
+		line.note = explain(prefix)
+	}
+
+	return line
+}
+
+// capped returns the start of b still to be printed.
+func (this *hexLine) capped(b []byte) []byte {
+	if this.max > 0 && len(b) > this.max-this.printed {
+		return b[:this.max-this.printed]
+	}
+
+	return b
+}
+
+// write prints the hex of the next bytes of the value.
+func (this *hexLine) write(b []byte) {
+	kept := this.capped(b)
+	this.elided += uint64(len(b) - len(kept))
+	this.printed += len(kept)
+
+	for len(kept) > 0 {
+		n := len(kept)
+		if n > dumpHexChunk {
+			n = dumpHexChunk
+		}
+
+		if this.enc == nil {
+			this.enc = make([]byte, 2*dumpHexChunk)
+		}
+
+		hex.Encode(this.enc, kept[:n])
+		_, _ = this.w.Write(this.enc[:2*n])
+		kept = kept[n:]
+	}
+}
+
+// end ends the line, with the elision marker when bytes were not printed.
+func (this *hexLine) end() {
+	if this.elided > 0 {
+		_, _ = fmt.Fprintf(this.w, " ... (%d bytes elided)", this.elided)
+	}
+
+	if this.note != "" {
+		_, _ = io.WriteString(this.w, "  // "+this.note)
+	}
+
+	_, _ = io.WriteString(this.w, "\n")
+}
+
+// dumpBytes pops the n next bytes and prints them in hex after prefix. It returns the printed bytes when keep is set,
+// for the text of block data.
+func (this *SerializedObjectParser) dumpBytes(prefix string, n uint64, keep bool) (kept []byte) {
+	line := this.hexLine(prefix)
+	chunk := make([]byte, 0, dumpHexChunk)
+
+	for n > 0 {
+		chunk = chunk[:0]
+		for ; n > 0 && len(chunk) < dumpHexChunk; n-- {
+			chunk = append(chunk, this._data.pop())
+		}
+
+		if keep {
+			kept = append(kept, line.capped(chunk)...)
+		}
+
+		line.write(chunk)
+	}
+
+	line.end()
+
+	return kept
+}
+
+// blockTextWanted tells whether printBlockText may print the text of block data.
+func (this *SerializedObjectParser) blockTextWanted() bool {
+	return this.charset != nil || this.classCharsets != nil
+}
+
+// printStringValue prints the text and hex of a long string value, both capped by SetMaxDumpBytes.
+func (this *SerializedObjectParser) printStringValue(raw []byte) {
+	text := raw
+	if this.maxDumpBytes > 0 && len(text) > this.maxDumpBytes {
+		text = text[:this.maxDumpBytes]
+	}
+
+	line := this.hexLine("Value - " + latin1(text) + " - ")
+	line.write(raw)
+	line.end()
+}
+
+// latin1 returns the string of the runes of bytes b, as printed by the dump.
+func latin1(b []byte) string {
+	var sb strings.Builder
+
+	for _, c := range b {
+		sb.WriteRune(rune(c))
+	}
+
+	return sb.String()
+}
+
+// printPrimitive prints a primitive value decoded from raw, followed by the hex of raw.
+func (this *SerializedObjectParser) printPrimitive(typeName, value string, raw ...byte) {
+	this.print("(" + typeName + ")" + value + " - 0x" + this.spacedHex(raw))
+}
+
+// spacedHex returns the hex of the bytes of a value, separated by spaces.
+func (this *SerializedObjectParser) spacedHex(raw []byte) string {
+	hexes := make([]string, len(raw))
+	for i, b := range raw {
+		hexes[i] = this.byteToHex(b)
+	}
+
+	return strings.Join(hexes, " ")
+}
+
+// formatFloat formats a float or double like the minimal JSON: the shortest representation reading back to the
+// same value, without exponent from 1e-6 to 1e21, whatever the locale. NaN and infinite values have their Java names.
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+
+	return strconv.FormatFloat(f, format, -1, bitSize)
+}
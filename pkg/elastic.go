@@ -0,0 +1,171 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ElasticIndexTemplate is the composable index template of analysis documents, %s is replaced by the index name.
+const ElasticIndexTemplate = `{
+  "index_patterns": ["%s*"],
+  "template": {
+    "mappings": {
+      "properties": {
+        "@timestamp":  {"type": "date"},
+        "name":        {"type": "keyword"},
+        "sha256":      {"type": "keyword"},
+        "size":        {"type": "long"},
+        "elements":    {"type": "integer"},
+        "fingerprint": {"type": "keyword"},
+        "verdict":     {"type": "keyword"},
+        "error":       {"type": "text"},
+        "classes": {
+          "type": "nested",
+          "properties": {
+            "name":             {"type": "keyword"},
+            "serialVersionUID": {"type": "keyword"},
+            "instances":        {"type": "integer"}
+          }
+        },
+        "findings": {
+          "type": "nested",
+          "properties": {
+            "ruleId":   {"type": "keyword"},
+            "severity": {"type": "keyword"},
+            "title":    {"type": "text"},
+            "class":    {"type": "keyword"},
+            "detail":   {"type": "text"}
+          }
+        },
+        "iocs": {
+          "type": "nested",
+          "properties": {
+            "type":  {"type": "keyword"},
+            "value": {"type": "keyword"}
+          }
+        }
+      }
+    }
+  }
+}`
+
+// ElasticExporter pushes analysis documents to an Elasticsearch or OpenSearch cluster.
+// Documents are identified by the sample hash, exporting a sample again overwrites its document.
+type ElasticExporter struct {
+	Endpoint string // base URL of the cluster, e.g. https://localhost:9200
+	Index    string
+	Username string // basic authentication, used when APIKey is empty
+	Password string
+	APIKey   string
+	Client   *http.Client // http.DefaultClient when nil
+}
+
+// elasticDocument is the indexed form of an Analysis.
+type elasticDocument struct {
+	*Analysis
+	Timestamp time.Time `json:"@timestamp"`
+	Name      string    `json:"name"`
+	Verdict   string    `json:"verdict"`
+}
+
+// NewElasticExporter creates an exporter writing to index at endpoint.
+func NewElasticExporter(endpoint, index string) *ElasticExporter {
+	return &ElasticExporter{Endpoint: strings.TrimRight(endpoint, "/"), Index: index}
+}
+
+// PutIndexTemplate installs ElasticIndexTemplate for the exporter index.
+func (this *ElasticExporter) PutIndexTemplate(ctx context.Context) error {
+	body := strings.Replace(ElasticIndexTemplate, "%s", this.Index, 1)
+
+	return this.do(ctx, http.MethodPut, "/_index_template/"+url.PathEscape(this.Index), "application/json", []byte(body))
+}
+
+// Export indexes the analysis of a sample.
+func (this *ElasticExporter) Export(ctx context.Context, name string, analysis *Analysis) error {
+	doc, err := json.Marshal(this.document(name, analysis))
+	if err != nil {
+		return errors.Wrap(err, "encode document")
+	}
+
+	return this.do(ctx, http.MethodPut, "/"+url.PathEscape(this.Index)+"/_doc/"+analysis.SHA256, "application/json", doc)
+}
+
+// ExportAll indexes many analyses with a single bulk request, keyed by sample name.
+func (this *ElasticExporter) ExportAll(ctx context.Context, analyses map[string]*Analysis) error {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+
+	for name, analysis := range analyses {
+		action := map[string]interface{}{"index": map[string]string{"_index": this.Index, "_id": analysis.SHA256}}
+		if err := enc.Encode(action); err != nil {
+			return errors.Wrap(err, "encode bulk action")
+		}
+
+		if err := enc.Encode(this.document(name, analysis)); err != nil {
+			return errors.Wrap(err, "encode document")
+		}
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	return this.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", buf.Bytes())
+}
+
+func (this *ElasticExporter) document(name string, analysis *Analysis) *elasticDocument {
+	return &elasticDocument{Analysis: analysis, Timestamp: time.Now().UTC(), Name: name, Verdict: analysis.Verdict()}
+}
+
+// do sends a request to the cluster and checks its status, bulk responses are checked for item errors.
+func (this *ElasticExporter) do(ctx context.Context, method, path, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, this.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	if this.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+this.APIKey)
+	} else if this.Username != "" {
+		req.SetBasicAuth(this.Username, this.Password)
+	}
+
+	client := this.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "%s %s", method, path)
+	}
+
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode >= 300 {
+		return errors.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var bulk struct {
+		Errors bool `json:"errors"`
+	}
+
+	if path == "/_bulk" && json.Unmarshal(respBody, &bulk) == nil && bulk.Errors {
+		return errors.New("bulk request failed for some documents")
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// EncryptedRegion is a region of bytes likely to be an encrypted serialized payload, see FindEncryptedRegions.
+type EncryptedRegion struct {
+	Offset   int      `json:"offset"`   // of the region in the data
+	Length   int      `json:"length"`   // of the ciphertext, once decoded
+	Encoding string   `json:"encoding"` // raw, base64 or hex
+	Entropy  float64  `json:"entropy"`  // bits per byte of the ciphertext
+	Hints    []string `json:"hints,omitempty"`
+}
+
+// minEncryptedSize is the size of the smallest ciphertext flagged: an IV and one AES block.
+const minEncryptedSize = 2 * 16
+
+// minEncodedRun is the length of the shortest base64 or hex run decoded, a base64 IV and one AES block.
+const minEncodedRun = 44
+
+// contextMarkers name the encrypted payloads by the text found just before them.
+var contextMarkers = []struct{ marker, hint string }{
+	{"rememberme", "Shiro rememberMe cookie"},
+	{"viewstate", "JSF ViewState"},
+}
+
+// knownMagics start compressed or archived data, which has the entropy of ciphertext.
+var knownMagics = [][]byte{{0x1f, 0x8b}, []byte("PK\x03\x04"), []byte("BZh"), {0x28, 0xb5, 0x2f, 0xfd}}
+
+// FindEncryptedRegions flags the regions of data likely to be an encrypted serialized payload, guiding the use of
+// decrypt where no stream magic is visible: the whole data when it is not a stream, and the base64 and hex runs it
+// holds, such as cookies in a capture or string fields of a stream. A region is flagged when its ciphertext has the
+// entropy of random bytes and is not mostly printable; its hints tell the probable layout, from its length relative
+// to the AES block, its first block and the text before it.
+func FindEncryptedRegions(data []byte) []EncryptedRegion {
+	var res []EncryptedRegion
+
+	if !bytes.HasPrefix(data, streamMagic) && !isPrintable(data) {
+		if region, ok := encryptedRegion(data, 0, "raw"); ok {
+			res = append(res, region)
+		}
+	}
+
+	for start := 0; start < len(data); {
+		if !isEncodedChar(data[start]) {
+			start++
+
+			continue
+		}
+
+		// padding ends a run, as in rememberMe=<base64>
+		end := start
+		for end < len(data) && isEncodedChar(data[end]) {
+			end++
+		}
+
+		for pad := 0; end < len(data) && pad < 2 && data[end] == '='; pad++ {
+			end++
+		}
+
+		if end-start >= minEncodedRun {
+			if region, ok := decodedRegion(data[start:end], start); ok {
+				region.Hints = append(region.Hints, contextHints(data[:start])...)
+				res = append(res, region)
+			}
+		}
+
+		start = end
+	}
+
+	return res
+}
+
+// decodedRegion decodes a hex or base64 run and checks the ciphertext.
+func decodedRegion(run []byte, offset int) (EncryptedRegion, bool) {
+	text := string(run)
+
+	if len(text)%2 == 0 && strings.Trim(text, "0123456789abcdefABCDEF") == "" {
+		if b, err := hex.DecodeString(text); err == nil {
+			return encryptedRegion(b, offset, "hex")
+		}
+	}
+
+	// long identifiers decode to random looking bytes too, but seldom hold digits
+	if !strings.ContainsAny(text, "0123456789") {
+		return EncryptedRegion{}, false
+	}
+
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding,
+		base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(text); err == nil {
+			return encryptedRegion(b, offset, "base64")
+		}
+	}
+
+	return EncryptedRegion{}, false
+}
+
+// encryptedRegion checks that b looks like ciphertext and describes its layout.
+func encryptedRegion(b []byte, offset int, encoding string) (EncryptedRegion, bool) {
+	if len(b) < minEncryptedSize || bytes.HasPrefix(b, streamMagic) {
+		return EncryptedRegion{}, false
+	}
+
+	for _, magic := range knownMagics {
+		if bytes.HasPrefix(b, magic) {
+			return EncryptedRegion{}, false
+		}
+	}
+
+	// the entropy of n random bytes stays below log2(n) for small n
+	entropy := byteEntropy(b)
+	if entropy < 0.85*math.Log2(math.Min(float64(len(b)), 256)) || printableRatio(b) > 0.75 {
+		return EncryptedRegion{}, false
+	}
+
+	region := EncryptedRegion{Offset: offset, Length: len(b), Encoding: encoding, Entropy: math.Round(entropy*100) / 100}
+
+	switch {
+	case len(b)%16 == 0:
+		region.Hints = append(region.Hints, "multiple of the AES block: CBC with an IV prefix")
+	case (len(b)-32)%16 == 0 || (len(b)-20)%16 == 0:
+		region.Hints = append(region.Hints, "AES blocks and an HMAC-SHA256 or HMAC-SHA1: CBC with a mac")
+	default:
+		region.Hints = append(region.Hints, "not a multiple of the AES block: GCM with a nonce prefix, or CTR")
+	}
+
+	switch iv := b[:16]; {
+	case bytes.Count(iv, iv[:1]) == len(iv):
+		region.Hints = append(region.Hints, fmt.Sprintf("constant IV %#02x", iv[0]))
+	case isPrintable(iv):
+		region.Hints = append(region.Hints, fmt.Sprintf("text IV %q", iv))
+	}
+
+	return region, true
+}
+
+// contextHints names the payload from the text just before it, e.g. rememberMe=.
+func contextHints(before []byte) []string {
+	if len(before) > 32 {
+		before = before[len(before)-32:]
+	}
+
+	lower := strings.ToLower(string(before))
+
+	var res []string
+
+	for _, m := range contextMarkers {
+		if strings.Contains(lower, m.marker) {
+			res = append(res, m.hint)
+		}
+	}
+
+	return res
+}
+
+// byteEntropy returns the Shannon entropy of b in bits per byte.
+func byteEntropy(b []byte) float64 {
+	var counts [256]int
+
+	for _, c := range b {
+		counts[c]++
+	}
+
+	var res float64
+
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(b))
+			res -= p * math.Log2(p)
+		}
+	}
+
+	return res
+}
+
+// printableRatio returns the share of printable ASCII bytes of b.
+func printableRatio(b []byte) float64 {
+	n := 0
+
+	for _, c := range b {
+		if c >= 0x20 && c < 0x7f || c == '\n' || c == '\r' || c == '\t' {
+			n++
+		}
+	}
+
+	return float64(n) / float64(len(b))
+}
+
+// isEncodedChar tells whether c is a base64 character, standard or URL-safe, padding excluded.
+func isEncodedChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '/' || c == '-' ||
+		c == '_'
+}
+
+func init() {
+	RegisterDetector("encrypted", encryptedDetector)
+}
+
+// encryptedDetector raises a finding per region likely to be an encrypted serialized payload.
+func encryptedDetector(analysis *Analysis, _ []interface{}) []Finding {
+	var findings []Finding
+
+	for _, region := range FindEncryptedRegions(analysis.raw) {
+		findings = append(findings, Finding{
+			RuleID:   "ENC-PAYLOAD",
+			Severity: SeverityLow,
+			Title:    "probable encrypted payload, try decrypt with the known keys",
+			Detail: fmt.Sprintf("offset %d: %d bytes %s, entropy %.2f bits/byte; %s", region.Offset, region.Length,
+				region.Encoding, region.Entropy, strings.Join(region.Hints, "; ")),
+		})
+	}
+
+	return findings
+}
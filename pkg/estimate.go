@@ -0,0 +1,648 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Approximate heap cost of the values built by ParseSerializedObject, used by Estimate.
+const (
+	estimatedObjectCost  = 400 // object map, extends map and class pointer
+	estimatedFieldCost   = 64  // map entry and boxed value
+	estimatedStringCost  = 32  // string header and interface value
+	estimatedArrayCost   = 48  // slice header and interface value
+	estimatedElementCost = 16  // interface value of an array element
+)
+
+// maxEstimateDepth bounds the nesting walked by Estimate.
+const maxEstimateDepth = 10000
+
+// errNotWalkable is the cause of the walk errors of valid streams the estimator cannot walk.
+var errNotWalkable = errors.New("cannot be walked")
+
+// SizeEstimate predicts the size of the parsed view of a stream.
+type SizeEstimate struct {
+	Elements      int    `json:"elements"` // top-level elements
+	Objects       int    `json:"objects"`
+	Classes       int    `json:"classes"`
+	Strings       int    `json:"strings"`
+	StringBytes   int64  `json:"stringBytes"`
+	Arrays        int    `json:"arrays"`
+	ArrayElements int64  `json:"arrayElements"`
+	BlockData     int64  `json:"blockData"` // bytes of block data
+	Handles       int    `json:"handles"`
+	MaxDepth      int    `json:"maxDepth"`
+	Memory        int64  `json:"memory"`          // approximate bytes allocated by a full parse
+	Complete      bool   `json:"complete"`        // the whole stream was walked
+	Error         string `json:"error,omitempty"` // why the walk stopped early
+
+	elementMemory []int64 // Memory share of each walked top-level element
+}
+
+// Exceeds checks whether a full parse is expected to allocate more than maxMemory bytes. An incomplete estimate
+// exceeds any limit, its stream being better handled by the incremental ParseElements / NextElement path.
+func (this *SizeEstimate) Exceeds(maxMemory int64) bool {
+	return !this.Complete || this.Memory > maxMemory
+}
+
+// estimateClass is the layout of a class descriptor as needed to skip the data of its instances.
+type estimateClass struct {
+	name   string
+	flags  byte
+	fields []byte   // type codes
+	names  []string // field names
+	super  *estimateClass
+}
+
+// estimator walks a stream through its length prefixes and class layouts without building any value.
+type estimator struct {
+	b       []byte
+	pos     int
+	handles []*estimateClass
+	kinds   []byte // content element type of each handle
+	res     *SizeEstimate
+	depth   int
+	expect  byte            // kind of element expected by the next content call, see contentOf
+	blockOK bool            // the next content call may read block data, see blockContent
+	renum   *renumbering    // see RenumberHandles
+	refs    *referenceCheck // see CheckReferences
+	blocks  *blockDataCheck // see CheckBlockData
+	subst   *substitution   // see Substitutions.Apply
+	sites   *mutationSites  // see NewMutator
+	cover   *coverage       // see Coverage.Add
+}
+
+// Estimate walks the length prefixes and class layouts of a stream to cheaply predict the element counts and the
+// memory a full parse needs, so services can route oversized payloads to the incremental path before parsing.
+// Version 1 external data cannot be walked and leaves the estimate incomplete.
+func Estimate(data []byte) *SizeEstimate {
+	this := &estimator{b: data, res: &SizeEstimate{}}
+
+	err := this.walk()
+	if err != nil {
+		this.res.Error = err.Error()
+	}
+
+	this.res.Complete = err == nil
+
+	return this.res
+}
+
+func (this *estimator) walk() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r, this.b, int64(this.pos))
+		}
+	}()
+
+	header, err := this.take(4)
+	if err != nil {
+		return err
+	}
+
+	if header[0] != STREAM_MAGIC1 || header[1] != STREAM_MAGIC2 {
+		return errors.New("invalid stream magic")
+	}
+
+	for this.pos < len(this.b) && !bytes.HasPrefix(this.b[this.pos:], metadataMarker) {
+		memory := this.res.Memory
+
+		if _, err := this.blockContent(); err != nil {
+			return errors.Wrapf(err, "element %d at offset %d", this.res.Elements, this.pos)
+		}
+
+		this.res.Elements++
+		this.res.elementMemory = append(this.res.elementMemory, this.res.Memory-memory)
+	}
+
+	return nil
+}
+
+func (this *estimator) take(n int) ([]byte, error) {
+	if n < 0 || len(this.b)-this.pos < n {
+		return nil, errors.New("premature end of input")
+	}
+
+	b := this.b[this.pos : this.pos+n]
+	this.pos += n
+
+	return b, nil
+}
+
+func (this *estimator) uint16() (int, error) {
+	b, err := this.take(2)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(binary.BigEndian.Uint16(b)), nil
+}
+
+func (this *estimator) uint32() (uint32, error) {
+	b, err := this.take(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint32(b), nil
+}
+
+func (this *estimator) utf() (string, error) {
+	n, err := this.uint16()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := this.take(n)
+
+	return string(b), err
+}
+
+func (this *estimator) newHandle(kind byte, cls *estimateClass) {
+	this.handles = append(this.handles, cls)
+	this.kinds = append(this.kinds, kind)
+	this.res.Handles++
+
+	if this.renum != nil {
+		this.renum.assign()
+	}
+}
+
+// resetHandles clears the handle table, as TC_RESET and TC_EXCEPTION do.
+func (this *estimator) resetHandles() {
+	if this.refs != nil {
+		this.refs.classify(len(this.handles))
+	}
+
+	if this.subst != nil {
+		this.subst.gen++
+	}
+
+	this.handles, this.kinds = this.handles[:0], this.kinds[:0]
+}
+
+// contentOf walks a content element which must be of the given kind: a class descriptor (TC_CLASSDESC) or a string
+// (TC_STRING), only checked for references.
+func (this *estimator) contentOf(kind byte) (*estimateClass, error) {
+	this.expect = kind
+
+	return this.content()
+}
+
+// blockContent walks a content element read where block data may be, at the top level of the stream and in
+// annotations, class data written by writeObject and external data.
+func (this *estimator) blockContent() (*estimateClass, error) {
+	this.blockOK = true
+
+	return this.content()
+}
+
+// content walks a content element, it returns the class layout of class descriptors.
+func (this *estimator) content() (cls *estimateClass, err error) {
+	expect, blockOK := this.expect, this.blockOK
+	this.expect, this.blockOK = 0, false
+
+	this.depth++
+	defer func() { this.depth-- }()
+
+	if this.depth > this.res.MaxDepth {
+		this.res.MaxDepth = this.depth
+	}
+
+	if this.depth > maxEstimateDepth {
+		return nil, errors.Wrap(errNotWalkable, "maximum nesting depth exceeded")
+	}
+
+	tc, err := this.take(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if this.blocks != nil {
+		this.blocks.element(this.pos-1, tc[0], blockOK)
+	}
+
+	if this.cover != nil {
+		this.cover.element(tc[0])
+	}
+
+	switch tc[0] {
+	case TC_NULL, TC_ENDBLOCKDATA:
+		return nil, nil
+	case TC_REFERENCE:
+		h, err := this.uint32()
+		if err != nil {
+			return nil, err
+		}
+
+		idx := int(h) - baseWireHandle
+
+		if this.refs != nil {
+			this.refs.check(this.pos-5, h, idx, expect, this.kinds)
+		}
+
+		if this.subst != nil {
+			this.subst.reference(this.pos-5, idx, expect != 0)
+		}
+
+		if idx >= 0 && idx < len(this.handles) {
+			if this.renum != nil {
+				this.renum.reference(this.pos-4, idx)
+			}
+
+			return this.handles[idx], nil
+		}
+
+		// the layout of an unknown class is needed to go on
+		if this.refs != nil && expect != TC_CLASSDESC {
+			return nil, nil
+		}
+
+		return nil, errors.Errorf("invalid handle %#x", h)
+	case TC_CLASSDESC:
+		return this.classDesc()
+	case TC_PROXYCLASSDESC:
+		return this.proxyClassDesc()
+	case TC_OBJECT:
+		return nil, this.object(this.pos - 1)
+	case TC_STRING, TC_LONGSTRING:
+		return nil, this.string(tc[0] == TC_LONGSTRING)
+	case TC_ARRAY:
+		return nil, this.array()
+	case TC_CLASS:
+		if _, err = this.contentOf(TC_CLASSDESC); err == nil {
+			this.newHandle(TC_CLASS, nil)
+		}
+
+		return nil, err
+	case TC_ENUM:
+		if _, err = this.contentOf(TC_CLASSDESC); err == nil {
+			this.newHandle(TC_ENUM, nil)
+			this.res.Objects++
+			this.res.Memory += estimatedObjectCost
+			_, err = this.contentOf(TC_STRING)
+		}
+
+		return nil, err
+	case TC_BLOCKDATA, TC_BLOCKDATALONG:
+		return nil, this.blockData(tc[0] == TC_BLOCKDATALONG)
+	case TC_RESET:
+		this.resetHandles()
+
+		if this.renum != nil {
+			this.renum.reset(this.pos-1, false)
+		}
+
+		return nil, nil
+	case TC_EXCEPTION:
+		this.resetHandles()
+
+		if this.renum != nil {
+			this.renum.reset(this.pos-1, true)
+		}
+
+		_, err = this.content()
+		this.resetHandles()
+
+		if this.renum != nil {
+			this.renum.reset(this.pos, true)
+		}
+
+		return nil, err
+	}
+
+	if tag, exists := contentTags[tc[0]]; exists && tag.handler != nil {
+		return nil, errors.Errorf("vendor content element %#02x cannot be estimated", tc[0])
+	}
+
+	return nil, errors.Errorf("illegal content element type %#02x", tc[0])
+}
+
+func (this *estimator) classDesc() (*estimateClass, error) {
+	cls := &estimateClass{}
+
+	var err error
+	if cls.name, err = this.utf(); err != nil {
+		return nil, err
+	}
+
+	uid := this.pos
+
+	if _, err = this.take(8); err != nil {
+		return nil, err
+	}
+
+	this.newHandle(TC_CLASSDESC, cls)
+	this.res.Classes++
+
+	flags, err := this.take(1)
+	if err != nil {
+		return nil, err
+	}
+
+	cls.flags = flags[0]
+
+	if this.sites != nil {
+		this.sites.class(cls.name, uid, this.pos-1)
+	}
+
+	if this.blocks != nil {
+		this.blocks.flags(this.pos-1, cls)
+	}
+
+	if this.cover != nil {
+		this.cover.flags(cls.flags)
+	}
+
+	count, err := this.uint16()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < count; i++ {
+		typeCode, err := this.take(1)
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := this.utf()
+		if err != nil {
+			return nil, err
+		}
+
+		if typeCode[0] == 'L' || typeCode[0] == '[' {
+			if _, err = this.contentOf(TC_STRING); err != nil {
+				return nil, err
+			}
+		}
+
+		if this.cover != nil {
+			this.cover.field(typeCode[0])
+		}
+
+		cls.fields = append(cls.fields, typeCode[0])
+		cls.names = append(cls.names, name)
+	}
+
+	if err = this.annotations(); err != nil {
+		return nil, err
+	}
+
+	cls.super, err = this.contentOf(TC_CLASSDESC)
+
+	return cls, err
+}
+
+func (this *estimator) proxyClassDesc() (*estimateClass, error) {
+	cls := &estimateClass{name: proxyClassName, flags: SC_SERIALIZABLE}
+	this.newHandle(TC_PROXYCLASSDESC, cls)
+	this.res.Classes++
+
+	count, err := this.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := uint32(0); i < count; i++ {
+		if _, err = this.utf(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = this.annotations(); err != nil {
+		return nil, err
+	}
+
+	cls.super, err = this.contentOf(TC_CLASSDESC)
+
+	return cls, err
+}
+
+// annotations walks content elements up to TC_ENDBLOCKDATA.
+func (this *estimator) annotations() error {
+	for {
+		if this.pos < len(this.b) && this.b[this.pos] == TC_ENDBLOCKDATA {
+			this.pos++
+
+			if this.cover != nil {
+				this.cover.element(TC_ENDBLOCKDATA)
+			}
+
+			return nil
+		}
+
+		if _, err := this.blockContent(); err != nil {
+			return err
+		}
+	}
+}
+
+// object walks an object whose TC_OBJECT byte is at offset start.
+func (this *estimator) object(start int) error {
+	from := len(this.handles)
+
+	cls, err := this.contentOf(TC_CLASSDESC)
+	if err != nil {
+		return err
+	}
+
+	this.newHandle(TC_OBJECT, nil)
+	this.res.Objects++
+	this.res.Memory += estimatedObjectCost
+
+	var hierarchy []*estimateClass
+	for c := cls; c != nil; c = c.super {
+		hierarchy = append([]*estimateClass{c}, hierarchy...)
+	}
+
+	for _, c := range hierarchy {
+		switch {
+		case c.flags&SC_EXTERNALIZABLE != 0 && c.flags&SC_BLOCK_DATA == 0:
+			return errors.Wrapf(errNotWalkable, "version 1 external data of %s", c.name)
+		case c.flags&SC_EXTERNALIZABLE != 0:
+			err = this.annotations()
+		default:
+			if err = this.values(c); err == nil && c.flags&SC_WRITE_METHOD != 0 {
+				err = this.annotations()
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if this.subst != nil {
+		this.subst.object(cls, start, this.pos, from, len(this.handles))
+	}
+
+	return nil
+}
+
+// primitiveSizes maps primitive type codes to the size of their values.
+var primitiveSizes = map[byte]int{'B': 1, 'Z': 1, 'C': 2, 'S': 2, 'I': 4, 'F': 4, 'J': 8, 'D': 8}
+
+func (this *estimator) values(cls *estimateClass) error {
+	for i, typeCode := range cls.fields {
+		this.res.Memory += estimatedFieldCost
+
+		start, from := this.pos, len(this.handles)
+
+		if this.subst != nil {
+			this.subst.enter(cls.names[i])
+		}
+
+		if size, isPrimitive := primitiveSizes[typeCode]; isPrimitive {
+			if _, err := this.take(size); err != nil {
+				return err
+			}
+		} else if _, err := this.content(); err != nil {
+			return err
+		}
+
+		if this.subst != nil {
+			this.subst.value(typeCode, cls.name, cls.names[i], start, this.pos, from, len(this.handles))
+			this.subst.leave()
+		}
+	}
+
+	return nil
+}
+
+func (this *estimator) string(long bool) error {
+	var n int64
+
+	if long {
+		b, err := this.take(8)
+		if err != nil {
+			return err
+		}
+
+		n = int64(binary.BigEndian.Uint64(b))
+	} else {
+		l, err := this.uint16()
+		if err != nil {
+			return err
+		}
+
+		n = int64(l)
+	}
+
+	if n < 0 || n > int64(len(this.b)-this.pos) {
+		return errors.New("premature end of input")
+	}
+
+	this.pos += int(n)
+	this.newHandle(TC_STRING, nil)
+	this.res.Strings++
+	this.res.StringBytes += n
+	this.res.Memory += estimatedStringCost + n
+
+	return nil
+}
+
+func (this *estimator) array() error {
+	cls, err := this.contentOf(TC_CLASSDESC)
+	if err != nil {
+		return err
+	}
+
+	if cls == nil || len(cls.name) < 2 || cls.name[0] != '[' {
+		return errors.New("invalid array class")
+	}
+
+	this.newHandle(TC_ARRAY, nil)
+
+	size, err := this.uint32()
+	if err != nil {
+		return err
+	}
+
+	n := int64(int32(size))
+	if n < 0 {
+		return errors.Errorf("invalid array size %d", n)
+	}
+
+	this.res.Arrays++
+
+	if this.cover != nil {
+		this.cover.array(cls.name[1])
+	}
+	this.res.ArrayElements += n
+	this.res.Memory += estimatedArrayCost + n*estimatedElementCost
+
+	if elemSize, isPrimitive := primitiveSizes[cls.name[1]]; isPrimitive {
+		if n*int64(elemSize) > int64(len(this.b)-this.pos) {
+			return errors.New("premature end of input")
+		}
+
+		if this.sites != nil {
+			this.sites.array(cls.name, this.pos-4, elemSize, int(n))
+		}
+
+		this.pos += int(n) * elemSize
+
+		return nil
+	}
+
+	for i := int64(0); i < n; i++ {
+		start, from := this.pos, len(this.handles)
+
+		if this.subst != nil {
+			this.subst.enter(fmt.Sprint(i))
+		}
+
+		if _, err = this.content(); err != nil {
+			return err
+		}
+
+		if this.subst != nil {
+			this.subst.value(cls.name[1], "", "", start, this.pos, from, len(this.handles))
+			this.subst.leave()
+		}
+	}
+
+	return nil
+}
+
+func (this *estimator) blockData(long bool) error {
+	var n int
+
+	start := this.pos - 1
+
+	if long {
+		l, err := this.uint32()
+		if err != nil {
+			return err
+		}
+
+		if int64(l) > int64(len(this.b)-this.pos) {
+			return errors.New("premature end of input")
+		}
+
+		n = int(l)
+	} else {
+		l, err := this.take(1)
+		if err != nil {
+			return err
+		}
+
+		n = int(l[0])
+	}
+
+	if _, err := this.take(n); err != nil {
+		return err
+	}
+
+	this.res.BlockData += int64(n)
+	this.res.Memory += estimatedArrayCost + int64(n)
+
+	if this.sites != nil {
+		this.sites.blockData(start, long, n)
+	}
+
+	return nil
+}
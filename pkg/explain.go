@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SetDumpExplain makes DumpSerializedObject end each line with a short explanation of the element from the
+// serialization specification, as a teaching aid for analysts new to the format.
+func SetDumpExplain(explain bool) Option {
+	return func(this *SerializedObjectParser) {
+		this.explain = explain
+	}
+}
+
+// dumpExplanations explain the dump lines by prefix, the first matching prefix winning.
+var dumpExplanations = []struct {
+	prefix, text string
+}{
+	{"STREAM_MAGIC", "magic number starting every serialization stream"},
+	{"STREAM_VERSION", "protocol version, 5 since JDK 1.2"},
+	{"Contents - ", "raw bytes of the block, read by the readObject or readExternal method of the class"},
+	{"Contents", "content elements, one per top-level writeObject call"},
+	{"TC_OBJECT", "new object: its class descriptor, then the field values of each serializable class from the " +
+		"topmost superclass down"},
+	{"TC_CLASSDESC", "new class descriptor: name, serialVersionUID, flags, fields, annotations and superclass"},
+	{"TC_PROXYCLASSDESC", "class descriptor of a dynamic proxy: the interfaces it implements"},
+	{"TC_STRING", "new string, modified UTF-8 with a 2-byte length"},
+	{"TC_LONGSTRING", "new string of more than 65535 bytes, with an 8-byte length"},
+	{"TC_ARRAY", "new array: its class descriptor, size and elements"},
+	{"TC_CLASS", "java.lang.Class object, written as its class descriptor"},
+	{"TC_ENUM", "enum constant: its class descriptor and the name of the constant"},
+	{"TC_NULL", "null reference"},
+	{"TC_REFERENCE", "back reference to an element written earlier, by handle"},
+	{"TC_BLOCKDATALONG", "optional data of a writeObject or writeExternal method, with a 4-byte length"},
+	{"TC_BLOCKDATA", "optional data of a writeObject or writeExternal method, up to 255 bytes"},
+	{"TC_ENDBLOCKDATA", "end of the optional data of a class or of its annotations"},
+	{"TC_RESET", "reset: the handles assigned so far are discarded"},
+	{"TC_EXCEPTION", "exception thrown while writing, the handles are reset around it"},
+	{"className1", "class name of an object field, as a JVM type signature"},
+	{"className", "fully qualified name of the class"},
+	{"Relocated - ", "class name rewritten by the relocation rules"},
+	{"Deobfuscated - ", "class or field name rewritten by the ProGuard mapping"},
+	{"serialVersionUID", "version of the class, must match the local class for the JVM to read it"},
+	{"newHandle", "handle assigned to the element, for later TC_REFERENCE from 0x7e0000"},
+	{"fieldCount", "number of serializable fields of the class, superclasses excluded"},
+	{"Fields", "field descriptions: type code, name and, for objects, class name"},
+	{"fieldName", "name of the field, modified UTF-8"},
+	{"Byte - ", "field type code: byte"},
+	{"Char - ", "field type code: char"},
+	{"Double - ", "field type code: double"},
+	{"Float - ", "field type code: float"},
+	{"Int - ", "field type code: int"},
+	{"Long - ", "field type code: long"},
+	{"Short - ", "field type code: short"},
+	{"Boolean - ", "field type code: boolean"},
+	{"Object - ", "field type code: object, its class name follows"},
+	{"Array - ", "field type code: array, its class name follows"},
+	{"classAnnotations", "data of ObjectOutputStream.annotateClass, usually empty"},
+	{"superClassDesc", "class descriptor of the serializable superclass, TC_NULL at the top"},
+	{"proxyInterfaceNames", "names of the interfaces of the proxy class"},
+	{"Interface count", "number of interfaces of the proxy class"},
+	{"classdata", "field values of each class of the hierarchy, topmost superclass first"},
+	{"values", "values of the fields in descriptor order, primitives first"},
+	{"objectAnnotation", "optional data written by the writeObject or writeExternal method, up to TC_ENDBLOCKDATA"},
+	{"Array size", "number of elements of the array"},
+	{"Values", "elements of the array"},
+	{"Length - ", "length of the following value in bytes"},
+	{"Value - ", "the value, modified UTF-8"},
+	{"Handle - ", "handle of the referenced element"},
+	{"Text - ", "block data decoded with the charset of the class"},
+	{"Vendor element", "proprietary content element, see RegisterTagHandler"},
+	{"(object)", "object field, its content element follows"},
+	{"(array)", "array field, its content element follows"},
+	{"(", "value of the field, big-endian"},
+}
+
+// flagExplanations explain the class descriptor flags.
+var flagExplanations = []struct {
+	flag byte
+	text string
+}{
+	{SC_WRITE_METHOD, "a writeObject method may follow the field values with optional data"},
+	{SC_SERIALIZABLE, "the field values are written by default serialization"},
+	{SC_EXTERNALIZABLE, "writeExternal writes the whole instance data instead of the fields"},
+	{SC_BLOCK_DATA, "the external data is block data (protocol version 2)"},
+	{SC_ENUM, "enum constants are written as their name"},
+}
+
+// explain returns the explanation of a dump line, empty when there is none.
+func explain(line string) string {
+	if strings.HasPrefix(line, "classDescFlags - 0x") && len(line) >= 21 {
+		flags, err := strconv.ParseUint(line[19:21], 16, 8)
+		if err != nil {
+			return ""
+		}
+
+		var texts []string
+		for _, e := range flagExplanations {
+			if byte(flags)&e.flag != 0 {
+				texts = append(texts, e.text)
+			}
+		}
+
+		if byte(flags)&SC_EXTERNALIZABLE != 0 && byte(flags)&SC_BLOCK_DATA == 0 {
+			texts = append(texts, "the external data is raw (protocol version 1) and cannot be skipped")
+		}
+
+		return strings.Join(texts, "; ")
+	}
+
+	for _, e := range dumpExplanations {
+		if strings.HasPrefix(line, e.prefix) {
+			return e.text
+		}
+	}
+
+	return ""
+}
+
+// printExplanation ends the dump line made of s with its explanation.
+func (this *SerializedObjectParser) printExplanation(w io.Writer, s ...interface{}) {
+	var line strings.Builder
+	for _, x := range s {
+		fmt.Fprintf(&line, "%v", x)
+	}
+
+	if text := explain(line.String()); text != "" {
+		fmt.Fprint(w, "  // ", text)
+	}
+}
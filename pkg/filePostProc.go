@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"encoding/binary"
+)
+
+func init() {
+	KnownPostProcs["java.io.File@042da4450e0de4ff"] = filePostProc
+	KnownPostProcs["java.net.URI@ac01782e439e49ab"] = uriPostProc
+
+	// the sun.nio.fs paths are not Serializable in the JDK, they only show up written by custom serializers and
+	// their serialVersionUID is computed
+	for _, name := range []string{"sun.nio.fs.UnixPath", "sun.nio.fs.WindowsPath", "sun.nio.fs.BsdPath"} {
+		objectPostProcs[name] = nioPathPostProc
+	}
+}
+
+// filePostProc decodes a java.io.File: its path field followed by the separator char of the writing platform in
+// block data. The value is the path, the separator is kept under "separator".
+func filePostProc(fields map[string]interface{}, data []interface{}) (map[string]interface{}, error) {
+	path, isString := fields["path"].(string)
+	if !isString {
+		return fields, nil
+	}
+
+	if b := blockBytes(data); len(b) >= 2 {
+		fields["separator"] = string(rune(binary.BigEndian.Uint16(b)))
+	}
+
+	fields["value"] = path
+
+	return fields, nil
+}
+
+// uriPostProc decodes a java.net.URI, written as its string form.
+func uriPostProc(fields map[string]interface{}, _ []interface{}) (map[string]interface{}, error) {
+	if s, isString := fields["string"].(string); isString {
+		fields["value"] = s
+	}
+
+	return fields, nil
+}
+
+// nioPathPostProc decodes the path of a sun.nio.fs path, held as bytes (UnixPath) or a string (WindowsPath).
+func nioPathPostProc(obj map[string]interface{}) {
+	switch path := obj["path"].(type) {
+	case string:
+		obj["value"] = path
+	case []interface{}:
+		b := make([]byte, 0, len(path))
+
+		for _, v := range path {
+			if c, isByte := v.(int8); isByte {
+				b = append(b, byte(c))
+			}
+		}
+
+		obj["value"] = string(b)
+	}
+}
@@ -0,0 +1,158 @@
+package pkg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// BaselineEntry is an accepted finding: a rule raised by a class, in the samples of a structural fingerprint or in
+// any sample when Fingerprint is empty.
+type BaselineEntry struct {
+	RuleID      string `json:"ruleId"`
+	Class       string `json:"class,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// Baseline lists the findings accepted in recurring scans.
+type Baseline struct {
+	Findings []BaselineEntry `json:"findings"`
+
+	index map[BaselineEntry]bool
+}
+
+// NewBaseline accepts the current findings of analyses, for their fingerprint.
+func NewBaseline(analyses ...*Analysis) *Baseline {
+	res := &Baseline{Findings: []BaselineEntry{}}
+
+	for _, a := range analyses {
+		for _, f := range a.Findings {
+			res.Add(BaselineEntry{RuleID: f.RuleID, Class: f.Class, Fingerprint: a.Fingerprint})
+		}
+	}
+
+	return res
+}
+
+// LoadBaseline reads a baseline file written by Save.
+func LoadBaseline(path string) (*Baseline, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading baseline")
+	}
+
+	res := &Baseline{}
+	if err = json.Unmarshal(b, res); err != nil {
+		return nil, errors.Wrap(err, "error decoding baseline")
+	}
+
+	return res, nil
+}
+
+// Save writes the baseline file, entries sorted.
+func (this *Baseline) Save(path string) error {
+	sort.Slice(this.Findings, func(i, j int) bool {
+		a, b := this.Findings[i], this.Findings[j]
+		if a.RuleID != b.RuleID {
+			return a.RuleID < b.RuleID
+		}
+
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+
+		return a.Fingerprint < b.Fingerprint
+	})
+
+	b, err := json.MarshalIndent(this, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(ioutil.WriteFile(path, append(b, '\n'), 0o644), "error writing baseline")
+}
+
+// Add accepts a finding, findings already accepted are ignored.
+func (this *Baseline) Add(entry BaselineEntry) {
+	if this.Accepts(entry) {
+		return
+	}
+
+	this.Findings = append(this.Findings, entry)
+	this.index[entry] = true
+}
+
+// Accepts checks whether a finding is in the baseline, for its fingerprint or any.
+func (this *Baseline) Accepts(entry BaselineEntry) bool {
+	if this == nil {
+		return false
+	}
+
+	if this.index == nil {
+		this.index = make(map[BaselineEntry]bool, len(this.Findings))
+		for _, e := range this.Findings {
+			this.index[e] = true
+		}
+	}
+
+	anySample := entry
+	anySample.Fingerprint = ""
+
+	return this.index[entry] || this.index[anySample]
+}
+
+// FindingFilter drops the findings below a severity, of suppressed rules or accepted by a baseline.
+type FindingFilter struct {
+	MinSeverity Severity
+	Suppress    []string // rule IDs
+	Baseline    *Baseline
+}
+
+// Apply filters the findings of an analysis in place and counts the dropped ones in Suppressed.
+func (this *FindingFilter) Apply(analysis *Analysis) {
+	suppressed := make(map[string]bool, len(this.Suppress))
+	for _, id := range this.Suppress {
+		suppressed[id] = true
+	}
+
+	kept := analysis.Findings[:0]
+
+	for _, f := range analysis.Findings {
+		accepted := this.Baseline.Accepts(BaselineEntry{RuleID: f.RuleID, Class: f.Class, Fingerprint: analysis.Fingerprint})
+
+		if f.Severity < this.MinSeverity || suppressed[f.RuleID] || accepted {
+			analysis.Suppressed++
+
+			continue
+		}
+
+		kept = append(kept, f)
+	}
+
+	analysis.Findings = kept
+
+	if analysis.Provenance != nil {
+		this.describe(analysis.Provenance.Options)
+	}
+}
+
+// describe adds the filter settings to the options of a Provenance.
+func (this *FindingFilter) describe(options map[string]string) {
+	if this.MinSeverity > SeverityInfo {
+		options["minSeverity"] = this.MinSeverity.String()
+	}
+
+	if len(this.Suppress) > 0 {
+		suppress := append([]string(nil), this.Suppress...)
+		sort.Strings(suppress)
+		options["suppress"] = strings.Join(suppress, ",")
+	}
+
+	if this.Baseline != nil {
+		options["baseline"] = strconv.Itoa(len(this.Baseline.Findings)) + " findings"
+	}
+}
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// defaultMaxFrameSize limits the size of a single frame unless changed with SetMaxFrameSize.
+const defaultMaxFrameSize = 64 << 20
+
+// Frame is a single length-prefixed record read by a FrameReader.
+type Frame struct {
+	Index   int           `json:"index"`  // index of the frame in the input
+	Offset  int64         `json:"offset"` // offset of the length prefix in the input
+	Length  int           `json:"length"` // length of the record, prefix excluded
+	Content []interface{} `json:"content"`
+}
+
+// FrameReader iterates over framings where each serialized object is preceded by a 4-byte big-endian length,
+// as found in JMS dumps and many custom TCP protocols. Every record is parsed as an independent stream.
+type FrameReader struct {
+	rd           io.Reader
+	options      []Option
+	maxFrameSize int
+	index        int
+	offset       int64
+}
+
+// NewFrameReader creates a FrameReader, options are applied to the parser of every frame.
+func NewFrameReader(rd io.Reader, options ...Option) *FrameReader {
+	return &FrameReader{rd: rd, options: options, maxFrameSize: defaultMaxFrameSize}
+}
+
+// SetMaxFrameSize changes the maximum accepted frame length.
+func (this *FrameReader) SetMaxFrameSize(maxSize int) *FrameReader {
+	this.maxFrameSize = maxSize
+
+	return this
+}
+
+// Next reads and parses the next frame, it returns io.EOF once the input is exhausted.
+// A parse error of a frame is returned together with the frame so the caller may decide to continue.
+func (this *FrameReader) Next() (frame *Frame, err error) {
+	var length uint32
+
+	if err = binary.Read(this.rd, binary.BigEndian, &length); err != nil {
+		if err != io.EOF {
+			err = errors.Wrap(err, "error reading frame length")
+		}
+
+		return
+	}
+
+	if int64(length) > int64(this.maxFrameSize) {
+		return nil, errors.Errorf("frame %d at offset %d exceeds maximum size: %d > %d",
+			this.index, this.offset, length, this.maxFrameSize)
+	}
+
+	data := make([]byte, length)
+
+	if _, err = io.ReadFull(this.rd, data); err != nil {
+		return nil, errors.Wrapf(err, "error reading frame %d at offset %d", this.index, this.offset)
+	}
+
+	frame = &Frame{Index: this.index, Offset: this.offset, Length: int(length)}
+	this.index++
+	this.offset += int64(length) + 4
+
+	options := append([]Option{SetMaxDataBlockSize(len(data))}, this.options...)
+
+	if frame.Content, err = NewSerializedObjectParser(bytes.NewReader(data), options...).ParseSerializedObject(); err != nil {
+		err = errors.Wrapf(err, "error parsing frame %d at offset %d", frame.Index, frame.Offset)
+	}
+
+	return
+}
+
+// ParseFramed parses all length-prefixed records of buf, stopping at the first error.
+func ParseFramed(buf []byte, options ...Option) (frames []*Frame, err error) {
+	defer recoverPanic(&err, buf, -1)
+
+	fr := NewFrameReader(bytes.NewReader(buf), options...)
+
+	for {
+		var frame *Frame
+
+		if frame, err = fr.Next(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+
+			return
+		}
+
+		frames = append(frames, frame)
+	}
+}
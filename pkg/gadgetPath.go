@@ -0,0 +1,228 @@
+package pkg
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxGadgetArgs bounds the number of argument values shown per step of a gadget path.
+const maxGadgetArgs = 4
+
+// gadgetContainers maps the classes chaining gadgets to the field holding them: their path ends with the list of
+// the chained gadgets instead of a path per gadget.
+var gadgetContainers = map[string]string{
+	"org.apache.commons.collections.functors.ChainedTransformer":  "iTransformers",
+	"org.apache.commons.collections4.functors.ChainedTransformer": "iTransformers",
+}
+
+// GadgetPath is the trigger path of a gadget chain through the parsed graph, from a top-level object down to the
+// last known gadget class, e.g. HashSet → TiedMapEntry("foo") → LazyMap → ChainedTransformer[…].
+type GadgetPath struct {
+	Chain   string   `json:"chain"`   // chain of the first gadget class of the path
+	Classes []string `json:"classes"` // classes of the steps, the top-level object first
+	Path    string   `json:"path"`    // steps with the argument values (commands, URLs...) found at each step
+}
+
+// GadgetPaths renders the trigger paths of the gadget chains of parsed content: the objects of known gadget classes
+// (see KnownGadgetClasses) and dynamic proxies reached from a top-level object, with the strings and classes held
+// by their fields. Paths to a gadget leading to another gadget are left out.
+func GadgetPaths(content []interface{}) []GadgetPath {
+	var paths [][]map[string]interface{}
+
+	visited := map[uintptr]bool{}
+
+	for _, c := range content {
+		if root, isMap := c.(map[string]interface{}); isMap {
+			collectGadgetPaths(root, nil, visited, &paths)
+		}
+	}
+
+	inner := map[uintptr]bool{}
+	for _, path := range paths {
+		for _, obj := range path[:len(path)-1] {
+			inner[mapIdentity(obj)] = true
+		}
+	}
+
+	var res []GadgetPath
+
+	seen := map[string]bool{}
+
+	for _, path := range paths {
+		last := path[len(path)-1]
+		if inner[mapIdentity(last)] || len(path) == 1 && !isGadgetStep(last) {
+			continue
+		}
+
+		gadgetPath := renderGadgetPath(path)
+		if !seen[gadgetPath.Path] {
+			seen[gadgetPath.Path] = true
+			res = append(res, gadgetPath)
+		}
+	}
+
+	return res
+}
+
+// collectGadgetPaths walks the objects reachable from obj, in field name order, and records the path of steps
+// leading to every gadget step. Objects are walked once, at their first path.
+func collectGadgetPaths(obj map[string]interface{}, path []map[string]interface{}, visited map[uintptr]bool,
+	paths *[][]map[string]interface{}) {
+	if visited[mapIdentity(obj)] {
+		return
+	}
+
+	visited[mapIdentity(obj)] = true
+
+	if len(path) == 0 || isGadgetStep(obj) {
+		path = append(path[:len(path):len(path)], obj)
+		*paths = append(*paths, path)
+	}
+
+	if _, isContainer := gadgetContainers[objectClassName(obj)]; isContainer {
+		return
+	}
+
+	for _, name := range gadgetFieldNames(obj) {
+		walkGadgetValue(obj[name], path, visited, paths)
+	}
+}
+
+// walkGadgetValue walks the objects of a field value, arrays included.
+func walkGadgetValue(v interface{}, path []map[string]interface{}, visited map[uintptr]bool,
+	paths *[][]map[string]interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if _, isObject := val["class"].(*clazz); isObject {
+			collectGadgetPaths(val, path, visited, paths)
+		}
+	case []interface{}:
+		for _, e := range val {
+			walkGadgetValue(e, path, visited, paths)
+		}
+	}
+}
+
+// gadgetFieldNames returns the sorted names of the fields of an object, annotations included.
+func gadgetFieldNames(obj map[string]interface{}) []string {
+	names := make([]string, 0, len(obj))
+
+	for name := range obj {
+		if name != "class" && name != "extends" && name != "value" && name != "@raw" && name != "@text" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// isGadgetStep tells whether an object is a step of a gadget path: an instance of a known gadget class, a gadget
+// container or a dynamic proxy.
+func isGadgetStep(obj map[string]interface{}) bool {
+	name := objectClassName(obj)
+	_, isGadget := KnownGadgetClasses[name]
+	_, isContainer := gadgetContainers[name]
+
+	return isGadget || isContainer || name == proxyClassName
+}
+
+// renderGadgetPath renders the steps of a path, the chained gadgets of a last container step included.
+func renderGadgetPath(path []map[string]interface{}) GadgetPath {
+	var res GadgetPath
+
+	steps := make([]string, 0, len(path))
+
+	for _, obj := range path {
+		name := objectClassName(obj)
+		res.Classes = append(res.Classes, name)
+
+		if rule, isGadget := KnownGadgetClasses[name]; isGadget && res.Chain == "" {
+			res.Chain = rule.Chain
+		}
+
+		steps = append(steps, gadgetStep(obj))
+	}
+
+	res.Path = strings.Join(steps, " → ")
+
+	return res
+}
+
+// gadgetStep renders an object of a gadget path: its simple class name and the argument values of its fields, the
+// gadgets of a container being listed in brackets.
+func gadgetStep(obj map[string]interface{}) string {
+	name := objectClassName(obj)
+
+	if name == proxyClassName {
+		cls := obj["class"].(*clazz)
+		interfaces := make([]string, len(cls.interfaces))
+
+		for i, iface := range cls.interfaces {
+			interfaces[i] = simpleClassName(iface)
+		}
+
+		return "Proxy[" + strings.Join(interfaces, ", ") + "]"
+	}
+
+	if field, isContainer := gadgetContainers[name]; isContainer {
+		var chained []string
+
+		walkGadgetObjects(obj[field], func(gadget map[string]interface{}) {
+			chained = append(chained, gadgetStep(gadget))
+		})
+
+		return simpleClassName(name) + "[" + strings.Join(chained, ", ") + "]"
+	}
+
+	var args, arrayArgs []string
+
+	// the values held directly first, such as the method name of InvokerTransformer before its arguments
+	for _, field := range gadgetFieldNames(obj) {
+		if array, isArray := obj[field].([]interface{}); isArray {
+			arrayArgs = appendGadgetArgs(arrayArgs, array)
+		} else {
+			args = appendGadgetArgs(args, obj[field])
+		}
+	}
+
+	args = append(args, arrayArgs...)
+
+	if len(args) > maxGadgetArgs {
+		args = append(args[:maxGadgetArgs], "…")
+	}
+
+	if len(args) == 0 {
+		return simpleClassName(name)
+	}
+
+	return simpleClassName(name) + "(" + strings.Join(args, ", ") + ")"
+}
+
+// walkGadgetObjects calls fn for the objects of a value, arrays included.
+func walkGadgetObjects(v interface{}, fn func(map[string]interface{})) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		fn(val)
+	case []interface{}:
+		for _, e := range val {
+			walkGadgetObjects(e, fn)
+		}
+	}
+}
+
+// appendGadgetArgs appends the strings and classes of a field value, arrays included, other objects being steps of
+// their own or irrelevant.
+func appendGadgetArgs(args []string, v interface{}) []string {
+	switch val := v.(type) {
+	case string, *clazz:
+		args = append(args, briefValue(val))
+	case []interface{}:
+		for _, e := range val {
+			args = appendGadgetArgs(args, e)
+		}
+	}
+
+	return args
+}
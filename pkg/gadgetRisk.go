@@ -0,0 +1,135 @@
+package pkg
+
+import (
+	"sort"
+	"strings"
+)
+
+// Gadget chain statuses of AssessGadgetRisk.
+const (
+	ChainConstructible = "constructible" // the class path holds every library class of the chain
+	ChainPartial       = "partial"       // the class path holds some library classes of the chain
+	ChainUnavailable   = "unavailable"   // the class path holds no library class of the chain
+)
+
+// KnownGadgetChains maps the chains of KnownGadgetClasses relying on libraries to the library classes a payload of
+// the chain needs, the JDK classes they also need being assumed available.
+var KnownGadgetChains = map[string][]string{
+	"CommonsCollections1": {
+		"org.apache.commons.collections.functors.ChainedTransformer",
+		"org.apache.commons.collections.functors.ConstantTransformer",
+		"org.apache.commons.collections.functors.InvokerTransformer",
+		"org.apache.commons.collections.map.LazyMap",
+	},
+	"CommonsCollections2": {
+		"org.apache.commons.collections4.comparators.TransformingComparator",
+		"org.apache.commons.collections4.functors.InvokerTransformer",
+	},
+	"CommonsCollections3": {
+		"org.apache.commons.collections.functors.ChainedTransformer",
+		"org.apache.commons.collections.functors.ConstantTransformer",
+		"org.apache.commons.collections.functors.InstantiateTransformer",
+		"org.apache.commons.collections.map.LazyMap",
+	},
+	"CommonsCollections4": {
+		"org.apache.commons.collections4.comparators.TransformingComparator",
+		"org.apache.commons.collections4.functors.ChainedTransformer",
+		"org.apache.commons.collections4.functors.ConstantTransformer",
+		"org.apache.commons.collections4.functors.InstantiateTransformer",
+	},
+	"CommonsCollections5": {
+		"org.apache.commons.collections.functors.ChainedTransformer",
+		"org.apache.commons.collections.functors.ConstantTransformer",
+		"org.apache.commons.collections.functors.InvokerTransformer",
+		"org.apache.commons.collections.keyvalue.TiedMapEntry",
+		"org.apache.commons.collections.map.LazyMap",
+	},
+	"CommonsCollections6": {
+		"org.apache.commons.collections.functors.ChainedTransformer",
+		"org.apache.commons.collections.functors.ConstantTransformer",
+		"org.apache.commons.collections.functors.InvokerTransformer",
+		"org.apache.commons.collections.keyvalue.TiedMapEntry",
+		"org.apache.commons.collections.map.LazyMap",
+	},
+	"CommonsBeanutils1": {
+		"org.apache.commons.beanutils.BeanComparator",
+		"org.apache.commons.collections.comparators.ComparableComparator",
+	},
+	"Spring1": {
+		"org.springframework.beans.factory.ObjectFactory",
+		"org.springframework.beans.factory.support.AutowireUtils$ObjectFactoryDelegatingInvocationHandler",
+		"org.springframework.core.SerializableTypeWrapper$MethodInvokeTypeProvider",
+	},
+	"Groovy1": {
+		"org.codehaus.groovy.runtime.ConvertedClosure",
+		"org.codehaus.groovy.runtime.MethodClosure",
+	},
+	"C3P0": {
+		"com.mchange.v2.c3p0.PoolBackedDataSource",
+		"com.mchange.v2.c3p0.impl.PoolBackedDataSourceBase",
+	},
+	"Hibernate1": {
+		"org.hibernate.engine.spi.TypedValue",
+		"org.hibernate.tuple.component.PojoComponentTuplizer",
+		"org.hibernate.type.ComponentType",
+	},
+}
+
+// ChainRisk tells whether a known gadget chain can be built from the classes of an application.
+type ChainRisk struct {
+	Chain   string   `json:"chain"`
+	Status  string   `json:"status"`
+	Present []string `json:"present,omitempty"` // library classes of the chain found in the class path
+	Missing []string `json:"missing,omitempty"` // library classes of the chain absent from the class path
+	Sources []string `json:"sources,omitempty"` // jars holding the classes found
+}
+
+// AssessGadgetRisk tells which chains of KnownGadgetChains can be built from the classes of an application,
+// independently of any payload: constructible chains first, then the partial ones, by name. The serialVersionUIDs of
+// the classes found are not checked, a payload being built against the versions of the application.
+func AssessGadgetRisk(classPath *ClassPath) []ChainRisk {
+	res := make([]ChainRisk, 0, len(KnownGadgetChains))
+
+	for chain, classes := range KnownGadgetChains {
+		risk := ChainRisk{Chain: chain}
+		sources := map[string]bool{}
+
+		for _, name := range classes {
+			if entry := classPath.Lookup(name); entry != nil {
+				risk.Present = append(risk.Present, name)
+				sources[entry.Source] = true
+			} else {
+				risk.Missing = append(risk.Missing, name)
+			}
+		}
+
+		for source := range sources {
+			risk.Sources = append(risk.Sources, source)
+		}
+
+		sort.Strings(risk.Sources)
+
+		switch {
+		case len(risk.Missing) == 0:
+			risk.Status = ChainConstructible
+		case len(risk.Present) > 0:
+			risk.Status = ChainPartial
+		default:
+			risk.Status = ChainUnavailable
+		}
+
+		res = append(res, risk)
+	}
+
+	rank := map[string]int{ChainConstructible: 0, ChainPartial: 1, ChainUnavailable: 2}
+
+	sort.Slice(res, func(i, j int) bool {
+		if rank[res[i].Status] != rank[res[j].Status] {
+			return rank[res[i].Status] < rank[res[j].Status]
+		}
+
+		return strings.ToLower(res[i].Chain) < strings.ToLower(res[j].Chain)
+	})
+
+	return res
+}
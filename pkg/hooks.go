@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ClassHook is called with the parser and the object being decoded, "class" and "extends" only before the class data
+// and every field after it. It may add entries to the object; an error aborts the parse.
+type ClassHook func(sop *SerializedObjectParser, node map[string]interface{}) error
+
+// ClassHooks are the hooks of a class, either may be nil.
+type ClassHooks struct {
+	Before ClassHook // called before the class data is read
+	After  ClassHook // called once the object is decoded and post-processed
+}
+
+// WithClassHooks sets the hooks of the instances of a class, given by name, serialVersionUID (16 hex digits) or
+// signature (name@serialVersionUID), the signature being matched first and the name last. Only the class of the
+// object is matched, not its super classes; hooks set again for the same class replace the previous ones.
+func WithClassHooks(class string, hooks ClassHooks) Option {
+	return func(this *SerializedObjectParser) {
+		if this.classHooks == nil {
+			this.classHooks = map[string]ClassHooks{}
+		}
+
+		this.classHooks[strings.ToLower(class)] = hooks
+	}
+}
+
+// hooksOf returns the hooks of a class, see WithClassHooks.
+func (this *SerializedObjectParser) hooksOf(cls *clazz) (ClassHooks, bool) {
+	if this.classHooks == nil {
+		return ClassHooks{}, false
+	}
+
+	for _, key := range []string{cls.name + "@" + cls.serialVersionUID, cls.serialVersionUID, cls.name} {
+		if hooks, exists := this.classHooks[strings.ToLower(key)]; exists {
+			return hooks, true
+		}
+	}
+
+	return ClassHooks{}, false
+}
+
+// runHook calls a hook of a class, if set.
+func (this *SerializedObjectParser) runHook(hook ClassHook, stage string, cls *clazz, node map[string]interface{}) error {
+	if hook == nil {
+		return nil
+	}
+
+	if err := hook(this, node); err != nil {
+		return errors.Wrapf(err, "%s hook of %s", stage, cls.name)
+	}
+
+	return nil
+}
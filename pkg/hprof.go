@@ -0,0 +1,394 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	hprofUTF8            byte = 0x01
+	hprofLoadClass       byte = 0x02
+	hprofHeapDump        byte = 0x0c
+	hprofHeapDumpSegment byte = 0x1c
+
+	hprofRootUnknown     byte = 0xff
+	hprofRootJNIGlobal   byte = 0x01
+	hprofRootJNILocal    byte = 0x02
+	hprofRootJavaFrame   byte = 0x03
+	hprofRootNativeStack byte = 0x04
+	hprofRootStickyClass byte = 0x05
+	hprofRootThreadBlock byte = 0x06
+	hprofRootMonitorUsed byte = 0x07
+	hprofRootThreadObj   byte = 0x08
+	hprofClassDump       byte = 0x20
+	hprofInstanceDump    byte = 0x21
+	hprofObjArrayDump    byte = 0x22
+	hprofPrimArrayDump   byte = 0x23
+
+	hprofTypeObject byte = 2
+	hprofTypeByte   byte = 8
+)
+
+// hprofTypeSizes holds the size of hprof basic types, object ids excepted.
+var hprofTypeSizes = map[byte]int{4: 1, 5: 2, 6: 4, 7: 8, 8: 1, 9: 2, 10: 4, 11: 8}
+
+// HprofArray is a byte[] of a heap dump holding a serialized stream.
+type HprofArray struct {
+	ArrayID    uint64        `json:"arrayId"`
+	Offset     int           `json:"offset"` // offset of the array content in the heap dump
+	Length     int           `json:"length"`
+	OwnerID    uint64        `json:"ownerId,omitempty"`
+	OwnerClass string        `json:"ownerClass,omitempty"` // class of the object (or array) referencing the byte[]
+	OwnerField string        `json:"ownerField,omitempty"` // field of the owner holding the reference
+	Content    []interface{} `json:"content,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// hprofClass is the part of a CLASS DUMP needed to decode instance fields.
+type hprofClass struct {
+	super  uint64
+	fields []hprofField
+}
+
+type hprofField struct {
+	name     uint64
+	typeCode byte
+}
+
+// hprofScanner holds the state of a heap dump scan.
+type hprofScanner struct {
+	data       []byte
+	idSize     int
+	strings    map[uint64]string
+	classNames map[uint64]uint64 // class object id to name string id
+	classes    map[uint64]*hprofClass
+	arrays     map[uint64]*HprofArray
+	owners     []int // offsets of INSTANCE DUMP and OBJ ARRAY DUMP sub-records
+}
+
+// ScanHprof scans a Java heap dump (HPROF format) for byte[] instances holding a serialized stream, parses them and
+// resolves the class and field of the object referencing each array.
+func ScanHprof(data []byte, options ...Option) (_ []*HprofArray, err error) {
+	defer recoverPanic(&err, data, -1)
+
+	idx := bytes.IndexByte(data, 0)
+	if !bytes.HasPrefix(data, []byte("JAVA PROFILE")) || idx < 0 || len(data) < idx+13 {
+		return nil, errors.New("not a HPROF heap dump")
+	}
+
+	s := &hprofScanner{
+		data:       data,
+		idSize:     int(binary.BigEndian.Uint32(data[idx+1:])),
+		strings:    map[uint64]string{},
+		classNames: map[uint64]uint64{},
+		classes:    map[uint64]*hprofClass{},
+		arrays:     map[uint64]*HprofArray{},
+	}
+
+	if s.idSize != 4 && s.idSize != 8 {
+		return nil, errors.Errorf("unsupported HPROF identifier size %d", s.idSize)
+	}
+
+	// header: format name, identifier size (u4), timestamp (u8)
+	if err := s.records(idx + 13); err != nil {
+		return nil, err
+	}
+
+	for _, offset := range s.owners {
+		s.resolveOwner(offset)
+	}
+
+	arrays := make([]*HprofArray, 0, len(s.arrays))
+	for _, a := range s.arrays {
+		arrays = append(arrays, a)
+	}
+
+	sort.Slice(arrays, func(i, j int) bool { return arrays[i].Offset < arrays[j].Offset })
+
+	for _, a := range arrays {
+		var err error
+		if a.Content, err = ParseSerializedObject(data[a.Offset:a.Offset+a.Length], options...); err != nil {
+			a.Error = err.Error()
+		}
+	}
+
+	return arrays, nil
+}
+
+func (this *hprofScanner) id(offset int) uint64 {
+	if this.idSize == 4 {
+		return uint64(binary.BigEndian.Uint32(this.data[offset:]))
+	}
+
+	return binary.BigEndian.Uint64(this.data[offset:])
+}
+
+func (this *hprofScanner) u4(offset int) int {
+	return int(binary.BigEndian.Uint32(this.data[offset:]))
+}
+
+func (this *hprofScanner) u2(offset int) int {
+	return int(binary.BigEndian.Uint16(this.data[offset:]))
+}
+
+// valueSize returns the size of a value of the given basic type.
+func (this *hprofScanner) valueSize(typeCode byte) (int, error) {
+	if typeCode == hprofTypeObject {
+		return this.idSize, nil
+	}
+
+	if size, known := hprofTypeSizes[typeCode]; known {
+		return size, nil
+	}
+
+	return 0, errors.Errorf("unknown HPROF basic type %d", typeCode)
+}
+
+// className returns the name of a class object in Java notation.
+func (this *hprofScanner) className(classID uint64) string {
+	nameID, known := this.classNames[classID]
+	if !known {
+		return fmt.Sprintf("class@%#x", classID)
+	}
+
+	return strings.ReplaceAll(this.strings[nameID], "/", ".")
+}
+
+// records walks the top-level records: tag (u1), time (u4), length (u4), body.
+func (this *hprofScanner) records(offset int) error {
+	for offset+9 <= len(this.data) {
+		tag := this.data[offset]
+		length := this.u4(offset + 5)
+		body := offset + 9
+
+		if body+length > len(this.data) {
+			return errors.Errorf("truncated HPROF record %#x at offset %d", tag, offset)
+		}
+
+		switch tag {
+		case hprofUTF8:
+			if length < this.idSize {
+				return errors.Errorf("invalid HPROF string record at offset %d", offset)
+			}
+
+			this.strings[this.id(body)] = string(this.data[body+this.idSize : body+length])
+		case hprofLoadClass:
+			if length < 8+2*this.idSize {
+				return errors.Errorf("invalid HPROF load class record at offset %d", offset)
+			}
+
+			// serial (u4), class object id, stack serial (u4), name id
+			this.classNames[this.id(body+4)] = this.id(body + 8 + this.idSize)
+		case hprofHeapDump, hprofHeapDumpSegment:
+			if err := this.heapDump(body, body+length); err != nil {
+				return err
+			}
+		}
+
+		offset = body + length
+	}
+
+	return nil
+}
+
+// fixedSize returns the size of the fixed part of the heap dump sub-records which also carry a variable part.
+func (this *hprofScanner) fixedSize(tag byte) int {
+	switch tag {
+	case hprofClassDump:
+		return 7*this.idSize + 10
+	case hprofInstanceDump, hprofObjArrayDump:
+		return 2*this.idSize + 8
+	case hprofPrimArrayDump:
+		return this.idSize + 9
+	}
+
+	return 0
+}
+
+// heapDump walks the sub-records of a heap dump segment.
+func (this *hprofScanner) heapDump(offset, end int) error {
+	id := this.idSize
+
+	for offset < end {
+		tag := this.data[offset]
+		start := offset
+		offset++
+
+		if offset+this.fixedSize(tag) > end {
+			return errors.Errorf("truncated HPROF heap dump sub-record %#x at offset %d", tag, start)
+		}
+
+		switch tag {
+		case hprofRootUnknown, hprofRootStickyClass, hprofRootMonitorUsed:
+			offset += id
+		case hprofRootJNIGlobal:
+			offset += 2 * id
+		case hprofRootJNILocal, hprofRootJavaFrame, hprofRootThreadObj:
+			offset += id + 8
+		case hprofRootNativeStack, hprofRootThreadBlock:
+			offset += id + 4
+		case hprofClassDump:
+			var err error
+			if offset, err = this.classDump(offset, end); err != nil {
+				return err
+			}
+		case hprofInstanceDump:
+			this.owners = append(this.owners, start)
+			offset += 2*id + 4
+			offset += 4 + this.u4(offset)
+		case hprofObjArrayDump:
+			this.owners = append(this.owners, start)
+			offset += id + 4
+			offset += 4 + id + this.u4(offset)*id
+		case hprofPrimArrayDump:
+			arrayID := this.id(offset)
+			n := this.u4(offset + id + 4)
+			typeCode := this.data[offset+id+8]
+			offset += id + 9
+
+			size, err := this.valueSize(typeCode)
+			if err != nil {
+				return errors.Wrapf(err, "invalid primitive array at offset %d", start)
+			}
+
+			if typeCode == hprofTypeByte && n >= 4 && offset+n <= end &&
+				bytes.HasPrefix(this.data[offset:], []byte{STREAM_MAGIC1, STREAM_MAGIC2, 0x00, STREAM_VERSION}) {
+				this.arrays[arrayID] = &HprofArray{ArrayID: arrayID, Offset: offset, Length: n}
+			}
+
+			offset += n * size
+		default:
+			return errors.Errorf("unknown HPROF heap dump sub-record %#x at offset %d", tag, start)
+		}
+
+		if offset > end {
+			return errors.Errorf("truncated HPROF heap dump sub-record %#x at offset %d", tag, start)
+		}
+	}
+
+	return nil
+}
+
+// classDump reads a CLASS DUMP sub-record and returns the offset following it.
+func (this *hprofScanner) classDump(offset, end int) (int, error) {
+	id := this.idSize
+	cls := &hprofClass{super: this.id(offset + id + 4)}
+	classID := this.id(offset)
+
+	// class id, stack serial, super, loader, signers, protection domain, 2 reserved, instance size
+	offset += 7*id + 8
+
+	// constant pool: index (u2), type, value
+	count := this.u2(offset)
+	offset += 2
+
+	for i := 0; i < count; i++ {
+		if offset+3 > end {
+			return offset, errors.New("truncated HPROF class dump")
+		}
+
+		size, err := this.valueSize(this.data[offset+2])
+		if err != nil {
+			return offset, err
+		}
+
+		offset += 3 + size
+	}
+
+	// static fields: name id, type, value
+	if offset+2 > end {
+		return offset, errors.New("truncated HPROF class dump")
+	}
+
+	count = this.u2(offset)
+	offset += 2
+
+	for i := 0; i < count; i++ {
+		if offset+id+1 > end {
+			return offset, errors.New("truncated HPROF class dump")
+		}
+
+		size, err := this.valueSize(this.data[offset+id])
+		if err != nil {
+			return offset, err
+		}
+
+		offset += id + 1 + size
+	}
+
+	// instance fields: name id, type
+	if offset+2 > end {
+		return offset, errors.New("truncated HPROF class dump")
+	}
+
+	count = this.u2(offset)
+	offset += 2
+
+	if offset+count*(id+1) > end {
+		return offset, errors.New("truncated HPROF class dump")
+	}
+
+	for i := 0; i < count; i++ {
+		cls.fields = append(cls.fields, hprofField{name: this.id(offset), typeCode: this.data[offset+id]})
+		offset += id + 1
+	}
+
+	this.classes[classID] = cls
+
+	return offset, nil
+}
+
+// resolveOwner records the owner of every candidate array referenced by an INSTANCE DUMP or OBJ ARRAY DUMP.
+func (this *hprofScanner) resolveOwner(offset int) {
+	id := this.idSize
+	tag := this.data[offset]
+	ownerID := this.id(offset + 1)
+
+	if tag == hprofObjArrayDump {
+		n := this.u4(offset + 1 + id + 4)
+		classID := this.id(offset + 1 + id + 8)
+		elements := offset + 1 + 2*id + 8
+
+		for i := 0; i < n; i++ {
+			if a, isCandidate := this.arrays[this.id(elements+i*id)]; isCandidate && a.OwnerClass == "" {
+				a.OwnerID, a.OwnerClass, a.OwnerField = ownerID, this.className(classID), fmt.Sprintf("[%d]", i)
+			}
+		}
+
+		return
+	}
+
+	classID := this.id(offset + 1 + id + 4)
+	values := offset + 1 + 2*id + 8
+	end := values + this.u4(offset+1+2*id+4)
+
+	// field values are written for the class first, then for each super class
+	for cid := classID; cid != 0 && values < end; {
+		cls, known := this.classes[cid]
+		if !known {
+			return
+		}
+
+		for _, f := range cls.fields {
+			size, err := this.valueSize(f.typeCode)
+			if err != nil || values+size > end {
+				return
+			}
+
+			if f.typeCode == hprofTypeObject {
+				if a, isCandidate := this.arrays[this.id(values)]; isCandidate && a.OwnerClass == "" {
+					a.OwnerID, a.OwnerClass, a.OwnerField = ownerID, this.className(classID), this.strings[f.name]
+				}
+			}
+
+			values += size
+		}
+
+		cid = cls.super
+	}
+}
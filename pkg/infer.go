@@ -0,0 +1,313 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SchemaInference merges the objects of many streams of an application into a JSON Schema per class, telling how
+// stable the presence and type of each field is and the range of its values.
+type SchemaInference struct {
+	streams int
+	classes map[string]*inferredClass
+}
+
+// inferredClass is what the instances of a class have in common.
+type inferredClass struct {
+	uids      map[string]bool
+	instances int
+	streams   int
+	declared  map[string]string // java type of the declared fields
+	fields    map[string]*inferredField
+}
+
+// inferredField accumulates the values of a field.
+type inferredField struct {
+	present  int
+	types    map[string]int // JSON type -> occurrences
+	classes  map[string]bool
+	min, max float64 // numbers
+	minLen   int     // strings and arrays
+	maxLen   int
+	hasRange bool
+	hasLen   bool
+}
+
+// NewSchemaInference returns an empty SchemaInference.
+func NewSchemaInference() *SchemaInference {
+	return &SchemaInference{classes: map[string]*inferredClass{}}
+}
+
+// Add merges the objects of a parsed stream, as returned by ParseSerializedObject.
+func (this *SchemaInference) Add(content []interface{}) {
+	this.streams++
+
+	inStream := map[string]bool{}
+
+	walkClassFields(content, func(cls *clazz, fields map[string]interface{}) {
+		ic := this.class(cls)
+		ic.instances++
+
+		if !inStream[cls.name] {
+			inStream[cls.name] = true
+			ic.streams++
+		}
+
+		for name, v := range fields {
+			ic.field(name).add(v)
+		}
+	})
+}
+
+// walkClassFields calls fn for every class of the hierarchy of every parsed object, with the field values of the
+// class, annotations excepted.
+func walkClassFields(content []interface{}, fn func(cls *clazz, fields map[string]interface{})) {
+	walkObjects(content, func(obj map[string]interface{}) {
+		cls, isClazz := obj["class"].(*clazz)
+		extends, isObject := obj["extends"].(map[string]interface{})
+
+		if !isClazz || cls == nil || !isObject {
+			return
+		}
+
+		for c := cls; c != nil; c = c.super {
+			values, isMap := extends[c.name].(map[string]interface{})
+			if !isMap {
+				continue
+			}
+
+			fields := make(map[string]interface{}, len(values))
+			for name, v := range values {
+				if !strings.HasPrefix(name, "@") {
+					fields[name] = v
+				}
+			}
+
+			fn(c, fields)
+		}
+	})
+}
+
+// Streams returns the number of streams added.
+func (this *SchemaInference) Streams() int {
+	return this.streams
+}
+
+func (this *SchemaInference) class(cls *clazz) *inferredClass {
+	ic, exists := this.classes[cls.name]
+	if !exists {
+		ic = &inferredClass{uids: map[string]bool{}, declared: map[string]string{}, fields: map[string]*inferredField{}}
+		this.classes[cls.name] = ic
+	}
+
+	ic.uids[cls.serialVersionUID] = true
+
+	for _, f := range cls.fields {
+		if f == nil {
+			continue
+		}
+
+		javaType := f.typeName
+		if f.className != "" {
+			javaType = f.className
+		}
+
+		ic.declared[f.name] = javaType
+	}
+
+	return ic
+}
+
+func (this *inferredClass) field(name string) *inferredField {
+	f, exists := this.fields[name]
+	if !exists {
+		f = &inferredField{types: map[string]int{}, classes: map[string]bool{}}
+		this.fields[name] = f
+	}
+
+	return f
+}
+
+// add records a value of the field.
+func (this *inferredField) add(v interface{}) {
+	this.present++
+
+	kind := jsonKind(v)
+	this.types[kind]++
+
+	switch o := v.(type) {
+	case string:
+		this.length(len(o))
+	case []interface{}:
+		this.length(len(o))
+	case map[string]interface{}:
+		if name := objectClassName(o); name != "" {
+			this.classes[name] = true
+		}
+	}
+
+	if x, isNumber := numberValue(v); isNumber {
+		if !this.hasRange || x < this.min {
+			this.min = x
+		}
+
+		if !this.hasRange || x > this.max {
+			this.max = x
+		}
+
+		this.hasRange = true
+	}
+}
+
+func (this *inferredField) length(n int) {
+	if !this.hasLen || n < this.minLen {
+		this.minLen = n
+	}
+
+	if !this.hasLen || n > this.maxLen {
+		this.maxLen = n
+	}
+
+	this.hasLen = true
+}
+
+// jsonKind returns the JSON Schema type of a parsed value.
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64, int, uint:
+		return "integer"
+	case float32, float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	return "string"
+}
+
+// numberValue returns the value of a finite number.
+func numberValue(v interface{}) (float64, bool) {
+	var x float64
+
+	switch o := v.(type) {
+	case int8:
+		x = float64(o)
+	case int16:
+		x = float64(o)
+	case int32:
+		x = float64(o)
+	case int64:
+		x = float64(o)
+	case uint16:
+		x = float64(o)
+	case float32:
+		x = float64(o)
+	case float64:
+		x = o
+	default:
+		return 0, false
+	}
+
+	return x, !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+// JSONSchema returns the inferred schema: a definition per class under $defs, with the fields present in every
+// instance required, the JSON types and value ranges seen, and how often each field and type occurred under the
+// x-occurrences and x-types annotations.
+func (this *SchemaInference) JSONSchema() map[string]interface{} {
+	defs := map[string]interface{}{}
+
+	for name, ic := range this.classes {
+		properties := map[string]interface{}{}
+		required := []string{}
+
+		for fieldName, f := range ic.fields {
+			properties[fieldName] = f.schema(ic.declared[fieldName])
+
+			if f.present == ic.instances && f.types["null"] == 0 {
+				required = append(required, fieldName)
+			}
+		}
+
+		sort.Strings(required)
+
+		uids := make([]string, 0, len(ic.uids))
+		for uid := range ic.uids {
+			uids = append(uids, uid)
+		}
+
+		sort.Strings(uids)
+
+		defs[name] = map[string]interface{}{
+			"type":                "object",
+			"description":         fmt.Sprintf("%d instances in %d of %d streams", ic.instances, ic.streams, this.streams),
+			"properties":          properties,
+			"required":            required,
+			"x-serialVersionUIDs": uids,
+			"x-instances":         ic.instances,
+		}
+	}
+
+	return map[string]interface{}{
+		"$schema":     jsonSchemaDraft,
+		"title":       "go-pjs inferred class schemas",
+		"description": fmt.Sprintf("classes of %d streams, inferred from their parsed objects", this.streams),
+		"$defs":       defs,
+	}
+}
+
+// schema returns the schema of a field.
+func (this *inferredField) schema(javaType string) map[string]interface{} {
+	kinds := make([]string, 0, len(this.types))
+	for kind := range this.types {
+		kinds = append(kinds, kind)
+	}
+
+	sort.Strings(kinds)
+
+	s := map[string]interface{}{"x-occurrences": this.present, "x-types": this.types}
+
+	if len(kinds) == 1 {
+		s["type"] = kinds[0]
+	} else {
+		s["type"] = kinds
+	}
+
+	if javaType != "" {
+		s["x-javaType"] = javaType
+	}
+
+	if this.hasRange {
+		s["minimum"], s["maximum"] = this.min, this.max
+	}
+
+	if this.hasLen {
+		if this.types["string"] > 0 {
+			s["minLength"], s["maxLength"] = this.minLen, this.maxLen
+		}
+
+		if this.types["array"] > 0 {
+			s["minItems"], s["maxItems"] = this.minLen, this.maxLen
+		}
+	}
+
+	if len(this.classes) > 0 {
+		classes := make([]string, 0, len(this.classes))
+		for name := range this.classes {
+			classes = append(classes, name)
+		}
+
+		sort.Strings(classes)
+		s["x-classes"] = classes
+	}
+
+	return s
+}
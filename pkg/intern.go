@@ -0,0 +1,78 @@
+package pkg
+
+import "sync"
+
+// Interner deduplicates strings read from a stream so that identical class names, field names,
+// serialVersionUIDs and short string values share the same backing storage. Strings longer than maxInternedLength
+// are seldom repeated and are not interned, and the table stops growing at maxInternedStrings entries.
+// A single Interner may be shared by several parsers (see SetInterner), it is safe for concurrent use.
+type Interner struct {
+	mu      sync.Mutex
+	strings map[string]string
+	hits    int
+}
+
+const (
+	maxInternedLength  = 256     // bytes of the longest string interned
+	maxInternedStrings = 1 << 16 // strings held by an Interner at most
+)
+
+// NewInterner creates an empty Interner.
+func NewInterner() *Interner {
+	return &Interner{strings: make(map[string]string)}
+}
+
+// Intern returns the canonical instance of s, s itself when it is too long or the table is full.
+func (this *Interner) Intern(s string) string {
+	if len(s) > maxInternedLength {
+		return s
+	}
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if c, exists := this.strings[s]; exists {
+		this.hits++
+
+		return c
+	}
+
+	if len(this.strings) < maxInternedStrings {
+		this.strings[s] = s
+	}
+
+	return s
+}
+
+// Len returns the number of distinct strings held by the Interner.
+func (this *Interner) Len() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	return len(this.strings)
+}
+
+// Hits returns how many times an already known string was returned instead of a new copy.
+func (this *Interner) Hits() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	return this.hits
+}
+
+// SetInterner shares the given Interner with the parser, by default strings are not interned. Sharing one Interner
+// across many parses reduces memory when scanning large session stores or corpora.
+func SetInterner(in *Interner) Option {
+	return func(this *SerializedObjectParser) {
+		this.interner = in
+	}
+}
+
+// intern returns the canonical instance of s using the parser Interner, if any.
+func (this *SerializedObjectParser) intern(s string) string {
+	if this.interner == nil {
+		return s
+	}
+
+	return this.interner.Intern(s)
+}
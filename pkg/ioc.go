@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// IOC types.
+const (
+	IOCURL  = "url"
+	IOCHost = "host"
+	IOCIP   = "ip"
+	IOCPath = "path"
+)
+
+// IOC is an indicator of compromise (URL, host name, IP address or file path) found in the strings of a stream.
+type IOC struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+var (
+	iocURLPattern = regexp.MustCompile(`(?i)\b(?:https?|ldaps?|rmi|iiop|dns|ftp|jar|file)://[^\s"'<>]+`)
+	iocIPPattern  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+)
+
+// ExtractIOCs collects the URLs, hosts and IP addresses found in the strings of parsed content,
+// host fields of java.net.URL and java.net.InetAddress objects included. The paths of java.io.File and sun.nio.fs
+// objects and the java.net.URI strings are reported as well.
+func ExtractIOCs(content interface{}) []IOC {
+	found := map[IOC]bool{}
+
+	addHost := func(host string) {
+		if host == "" {
+			return
+		}
+
+		if net.ParseIP(host) != nil {
+			found[IOC{IOCIP, host}] = true
+		} else {
+			found[IOC{IOCHost, host}] = true
+		}
+	}
+
+	addURL := func(u string) {
+		found[IOC{IOCURL, u}] = true
+
+		if parsed, err := url.Parse(u); err == nil {
+			addHost(parsed.Hostname())
+		}
+	}
+
+	walkContent(content, func(v interface{}) {
+		switch o := v.(type) {
+		case string:
+			for _, u := range iocURLPattern.FindAllString(o, -1) {
+				addURL(u)
+			}
+
+			for _, ip := range iocIPPattern.FindAllString(o, -1) {
+				if net.ParseIP(ip) != nil {
+					found[IOC{IOCIP, ip}] = true
+				}
+			}
+		case map[string]interface{}:
+			className := objectClassName(o)
+
+			switch className {
+			case "java.net.URI":
+				if u, isString := o["value"].(string); isString && u != "" {
+					addURL(u)
+				}
+			case "java.io.File":
+				if path, isString := o["value"].(string); isString && path != "" {
+					found[IOC{IOCPath, path}] = true
+				}
+			case "java.net.URL":
+				if host, isString := o["host"].(string); isString {
+					addHost(host)
+				}
+			case "java.net.InetAddress", "java.net.Inet6Address", "java.net.InetSocketAddress":
+				if value, isMap := o["value"].(map[string]interface{}); isMap {
+					for _, k := range []string{"hostName", "address"} {
+						if host, isString := value[k].(string); isString {
+							addHost(strings.SplitN(host, "%", 2)[0])
+						}
+					}
+				}
+			default:
+				if strings.HasPrefix(className, "sun.nio.fs.") {
+					if path, isString := o["value"].(string); isString && path != "" {
+						found[IOC{IOCPath, path}] = true
+					}
+				}
+			}
+		}
+	})
+
+	iocs := make([]IOC, 0, len(found))
+	for ioc := range found {
+		iocs = append(iocs, ioc)
+	}
+
+	sort.Slice(iocs, func(i, j int) bool {
+		if iocs[i].Type != iocs[j].Type {
+			return iocs[i].Type < iocs[j].Type
+		}
+
+		return iocs[i].Value < iocs[j].Value
+	})
+
+	return iocs
+}
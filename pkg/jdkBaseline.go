@@ -0,0 +1,82 @@
+package pkg
+
+import "strings"
+
+// jdkClass is a class layout of the JDK baseline: name, serialVersionUID (hex), flags and fields as they appear in
+// the descriptors, "type name" or "type name className" separated by ';'.
+type jdkClass struct {
+	name, serialVersionUID string
+	flags                  uint8
+	fields                 string
+}
+
+// jdkClasses are the layouts of the JDK classes most found in streams, collections and boxed primitives, stable
+// across the JDK releases.
+var jdkClasses = []jdkClass{
+	{"java.lang.Boolean", "cd207280d59cfaee", 0x02, "Z value"},
+	{"java.lang.Byte", "9c4e6084ee50f51c", 0x02, "B value"},
+	{"java.lang.Character", "348b47d96b1a2678", 0x02, "C value"},
+	{"java.lang.Double", "80b3c24a296bfb04", 0x02, "D value"},
+	{"java.lang.Float", "daedc9a2db3cf0ec", 0x02, "F value"},
+	{"java.lang.Integer", "12e2a0a4f7818738", 0x02, "I value"},
+	{"java.lang.Long", "3b8be490cc8f23df", 0x02, "J value"},
+	{"java.lang.Number", "86ac951d0b94e08b", 0x02, ""},
+	{"java.lang.Short", "684d37133460da52", 0x02, "S value"},
+	{"java.util.ArrayList", "7881d21d99c7619d", 0x03, "I size"},
+	{"java.util.Date", "686a81014b597419", 0x03, ""},
+	{"java.util.HashMap", "0507dac1c31660d1", 0x03, "F loadFactor;I threshold"},
+	{"java.util.HashSet", "ba44859596b8b734", 0x03, ""},
+	{"java.util.Hashtable", "13bb0f25214ae4b8", 0x03, "F loadFactor;I threshold"},
+	{"java.util.LinkedHashMap", "34c04e5c106cc0fb", 0x02, "Z accessOrder"},
+	{"java.util.LinkedHashSet", "d86cd75a95dd2a1e", 0x02, ""},
+	{"java.util.LinkedList", "0c29535d4a608822", 0x03, ""},
+	{"java.util.TreeMap", "0cc1f63e2d256ae6", 0x03, "L comparator Ljava/util/Comparator;"},
+	{"java.util.Vector", "d9977d5b803baf01", 0x03,
+		"I capacityIncrement;I elementCount;[ elementData [Ljava/lang/Object;"},
+}
+
+// jdkLayout is the shared descriptor part and precompiled reader of a JDK baseline class.
+type jdkLayout struct {
+	fields []*field
+	plan   *decodePlan
+}
+
+// jdkBaseline maps the classLayoutKey of the JDK baseline classes to their layout.
+var jdkBaseline = map[string]*jdkLayout{}
+
+// jdkUIDs maps the names of the JDK baseline classes to their serialVersionUID.
+var jdkUIDs = map[string]string{}
+
+func init() {
+	for _, jc := range jdkClasses {
+		jdkUIDs[jc.name] = jc.serialVersionUID
+
+		cls := &clazz{name: jc.name, serialVersionUID: jc.serialVersionUID, flags: jc.flags}
+
+		for _, desc := range strings.Split(jc.fields, ";") {
+			if parts := strings.Fields(desc); len(parts) > 1 {
+				f := &field{typeName: parts[0], name: parts[1]}
+				if len(parts) > 2 {
+					f.className = parts[2]
+				}
+
+				cls.fields = append(cls.fields, f)
+			}
+		}
+
+		plan, err := compileDecodePlan(cls.fields, nil)
+		if err != nil {
+			panic(err)
+		}
+
+		jdkBaseline[classLayoutKey(cls)] = &jdkLayout{fields: cls.fields, plan: plan}
+	}
+}
+
+// knownClass switches a descriptor matching the JDK baseline exactly to the shared fields and precompiled reader of
+// its layout, the values of its instances being read without validating the field types again.
+func (this *SerializedObjectParser) knownClass(cls *clazz) {
+	if layout, known := jdkBaseline[classLayoutKey(cls)]; known {
+		cls.fields, cls.plan = layout.fields, layout.plan
+	}
+}
@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	jenkinsCapabilityPreamble   = "<===[JENKINS REMOTING CAPACITY]===>"
+	jenkinsTransmissionPreamble = "<===[HUDSON TRANSMISSION BEGINS]===>"
+
+	// jenkinsChunkedEncoding is hudson.remoting.Capability.MASK_CHUNKED_ENCODING.
+	jenkinsChunkedEncoding int64 = 1 << 5
+)
+
+// jenkinsCapabilityNames names the bits of hudson.remoting.Capability masks.
+var jenkinsCapabilityNames = []string{
+	"MULTI_CLASSLOADER",
+	"PIPE_THROTTLING",
+	"MIMIC_EXCEPTION",
+	"GREEDY_REMOTE_INPUTSTREAM",
+	"PROXY_WRITER_2_35",
+	"CHUNKED_ENCODING",
+	"PROXY_EXCEPTION_FALLBACK",
+}
+
+// JenkinsCapability is a hudson.remoting.Capability announced in a remoting preamble.
+type JenkinsCapability struct {
+	Offset  int           `json:"offset"` // offset of the preamble in the input
+	Mask    int64         `json:"mask"`
+	Flags   []string      `json:"flags"`
+	Content []interface{} `json:"content,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// JenkinsRemoting is what DecodeJenkinsRemoting extracted from a Jenkins CLI / remoting exchange.
+type JenkinsRemoting struct {
+	Capabilities       []*JenkinsCapability `json:"capabilities"`
+	TransmissionOffset int                  `json:"transmissionOffset"` // -1 when no transmission preamble was found
+	Chunked            bool                 `json:"chunked"`
+	Elements           []*Element           `json:"elements,omitempty"` // objects sent after the transmission preamble
+	Error              string               `json:"error,omitempty"`
+}
+
+// DecodeJenkinsRemoting decodes a captured Jenkins remoting preamble and capability exchange: every base64 encoded
+// Capability following a capacity preamble is parsed, as well as the serialized objects following the transmission
+// preamble (de-chunked when the capabilities announce chunked encoding).
+func DecodeJenkinsRemoting(data []byte, options ...Option) (_ *JenkinsRemoting, err error) {
+	defer recoverPanic(&err, data, -1)
+
+	res := &JenkinsRemoting{TransmissionOffset: -1}
+
+	for offset := 0; ; {
+		idx := bytes.Index(data[offset:], []byte(jenkinsCapabilityPreamble))
+		if idx < 0 {
+			break
+		}
+
+		capability := decodeJenkinsCapability(data[offset+idx+len(jenkinsCapabilityPreamble):], options)
+		capability.Offset = offset + idx
+		res.Capabilities = append(res.Capabilities, capability)
+		offset += idx + len(jenkinsCapabilityPreamble)
+
+		if capability.Mask&jenkinsChunkedEncoding != 0 {
+			res.Chunked = true
+		}
+	}
+
+	if idx := bytes.Index(data, []byte(jenkinsTransmissionPreamble)); idx >= 0 {
+		res.TransmissionOffset = idx
+		stream := data[idx+len(jenkinsTransmissionPreamble):]
+
+		if res.Chunked {
+			stream = dechunkJenkins(stream)
+		}
+
+		var err error
+		if res.Elements, _, err = ParseElements(stream, 0, options...); err != nil {
+			res.Error = err.Error()
+		}
+	}
+
+	if len(res.Capabilities) == 0 && res.TransmissionOffset < 0 {
+		return res, errors.New("no Jenkins remoting preamble found")
+	}
+
+	return res, nil
+}
+
+// decodeJenkinsCapability decodes the base64 serialized Capability following a capacity preamble.
+func decodeJenkinsCapability(data []byte, options []Option) *JenkinsCapability {
+	capability := &JenkinsCapability{}
+
+	end := 0
+	for end < len(data) && isBase64Char(data[end]) {
+		end++
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(string(data[:end]))
+	if err != nil {
+		capability.Error = errors.Wrap(err, "error decoding capability").Error()
+
+		return capability
+	}
+
+	if capability.Content, err = ParseSerializedObject(raw, options...); err != nil {
+		capability.Error = errors.Wrap(err, "error parsing capability").Error()
+	}
+
+	// hudson.remoting.Capability has a single long field: mask
+	for _, c := range capability.Content {
+		if obj, isMap := c.(map[string]interface{}); isMap {
+			if mask, isLong := obj["mask"].(int64); isLong {
+				capability.Mask = mask
+			}
+		}
+	}
+
+	for bit := 0; bit < 64; bit++ {
+		if capability.Mask&(1<<bit) == 0 {
+			continue
+		}
+
+		if bit < len(jenkinsCapabilityNames) {
+			capability.Flags = append(capability.Flags, jenkinsCapabilityNames[bit])
+		} else {
+			capability.Flags = append(capability.Flags, fmt.Sprintf("bit %d", bit))
+		}
+	}
+
+	return capability
+}
+
+// dechunkJenkins removes the 2-byte headers of hudson.remoting.ChunkedOutputStream,
+// the high bit of a header is set when more chunks of the same command follow.
+func dechunkJenkins(data []byte) []byte {
+	var out bytes.Buffer
+
+	for len(data) >= 2 {
+		length := int(binary.BigEndian.Uint16(data) & 0x7fff)
+		data = data[2:]
+
+		if length > len(data) {
+			length = len(data)
+		}
+
+		out.Write(data[:length])
+		data = data[length:]
+	}
+
+	return out.Bytes()
+}
+
+func isBase64Char(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '/' || c == '='
+}
@@ -0,0 +1,431 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	for i := range jmxMethods {
+		m := &jmxMethods[i]
+		jmxMethodsByHash[rmiMethodHash(m.name+m.descriptor)] = m
+	}
+}
+
+// Kinds of the messages of a JMXMessage.
+const (
+	JMXCall   = "call"   // client call of a remote method
+	JMXReturn = "return" // server return value or exception
+)
+
+const (
+	// rmiCallHeaderSize is the size of the header of a call: ObjID (object number and UID), operation and hash.
+	rmiCallHeaderSize = 34
+	// rmiReturnHeaderSize is the size of the header of a return: return type and UID.
+	rmiReturnHeaderSize = 15
+	// rmiExceptionalReturn is the return type of a thrown exception.
+	rmiExceptionalReturn byte = 2
+
+	// Interface hashes of the stubs calling methods by operation number instead of method hash.
+	registryInterfaceHash int64 = 4905912898345647071
+	dgcInterfaceHash      int64 = -669196253586618813
+)
+
+// Type descriptors shared by the remote methods.
+const (
+	descObjectName = "Ljavax/management/ObjectName;"
+	descString     = "Ljava/lang/String;"
+	descMarshalled = "Ljava/rmi/MarshalledObject;"
+	descSubject    = "Ljavax/security/auth/Subject;"
+	descInstance   = "Ljavax/management/ObjectInstance;"
+)
+
+// rmiMethod is a remote method, with the names of its parameters.
+type rmiMethod struct {
+	iface, name, descriptor string
+	params                  []string
+}
+
+// jmxMethods are the methods of the JMX connector interfaces, called by method hash.
+var jmxMethods = []rmiMethod{
+	{"javax.management.remote.rmi.RMIServer", "getVersion", "()" + descString, nil},
+	{"javax.management.remote.rmi.RMIServer", "newClient",
+		"(Ljava/lang/Object;)Ljavax/management/remote/rmi/RMIConnection;", []string{"credentials"}},
+	{"javax.management.remote.rmi.RMIConnection", "getConnectionId", "()" + descString, nil},
+	{"javax.management.remote.rmi.RMIConnection", "close", "()V", nil},
+	{"javax.management.remote.rmi.RMIConnection", "createMBean",
+		"(" + descString + descObjectName + descSubject + ")" + descInstance,
+		[]string{"className", "name", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "createMBean",
+		"(" + descString + descObjectName + descObjectName + descSubject + ")" + descInstance,
+		[]string{"className", "name", "loaderName", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "createMBean",
+		"(" + descString + descObjectName + descMarshalled + "[" + descString + descSubject + ")" + descInstance,
+		[]string{"className", "name", "params", "signature", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "createMBean",
+		"(" + descString + descObjectName + descObjectName + descMarshalled + "[" + descString + descSubject + ")" +
+			descInstance, []string{"className", "name", "loaderName", "params", "signature", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "unregisterMBean", "(" + descObjectName + descSubject + ")V",
+		[]string{"name", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "getObjectInstance",
+		"(" + descObjectName + descSubject + ")" + descInstance, []string{"name", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "queryMBeans",
+		"(" + descObjectName + descMarshalled + descSubject + ")Ljava/util/Set;",
+		[]string{"name", "query", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "queryNames",
+		"(" + descObjectName + descMarshalled + descSubject + ")Ljava/util/Set;",
+		[]string{"name", "query", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "isRegistered", "(" + descObjectName + descSubject + ")Z",
+		[]string{"name", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "getMBeanCount", "(" + descSubject + ")Ljava/lang/Integer;",
+		[]string{"delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "getAttribute",
+		"(" + descObjectName + descString + descSubject + ")Ljava/lang/Object;",
+		[]string{"name", "attribute", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "getAttributes",
+		"(" + descObjectName + "[" + descString + descSubject + ")Ljavax/management/AttributeList;",
+		[]string{"name", "attributes", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "setAttribute",
+		"(" + descObjectName + descMarshalled + descSubject + ")V", []string{"name", "attribute", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "setAttributes",
+		"(" + descObjectName + descMarshalled + descSubject + ")Ljavax/management/AttributeList;",
+		[]string{"name", "attributes", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "invoke",
+		"(" + descObjectName + descString + descMarshalled + "[" + descString + descSubject + ")Ljava/lang/Object;",
+		[]string{"name", "operationName", "params", "signature", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "getDefaultDomain", "(" + descSubject + ")" + descString,
+		[]string{"delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "getDomains", "(" + descSubject + ")[" + descString,
+		[]string{"delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "getMBeanInfo",
+		"(" + descObjectName + descSubject + ")Ljavax/management/MBeanInfo;", []string{"name", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "isInstanceOf",
+		"(" + descObjectName + descString + descSubject + ")Z", []string{"name", "className", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "addNotificationListener",
+		"(" + descObjectName + descObjectName + descMarshalled + descMarshalled + descSubject + ")V",
+		[]string{"name", "listener", "filter", "handback", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "removeNotificationListener",
+		"(" + descObjectName + descObjectName + descSubject + ")V", []string{"name", "listener", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "removeNotificationListener",
+		"(" + descObjectName + descObjectName + descMarshalled + descMarshalled + descSubject + ")V",
+		[]string{"name", "listener", "filter", "handback", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "addNotificationListeners",
+		"([" + descObjectName + "[" + descMarshalled + "[" + descSubject + ")[Ljava/lang/Integer;",
+		[]string{"names", "filters", "delegationSubjects"}},
+	{"javax.management.remote.rmi.RMIConnection", "removeNotificationListeners",
+		"(" + descObjectName + "[Ljava/lang/Integer;" + descSubject + ")V",
+		[]string{"name", "listenerIDs", "delegationSubject"}},
+	{"javax.management.remote.rmi.RMIConnection", "fetchNotifications",
+		"(JIJ)Ljavax/management/remote/NotificationResult;",
+		[]string{"clientSequenceNumber", "maxNotifications", "timeout"}},
+}
+
+// jmxMethodsByHash indexes jmxMethods by method hash.
+var jmxMethodsByHash = map[int64]*rmiMethod{}
+
+// registryMethods are the methods of java.rmi.registry.Registry, by operation number.
+var registryMethods = []rmiMethod{
+	{"java.rmi.registry.Registry", "bind", "(" + descString + "Ljava/rmi/Remote;)V", []string{"name", "obj"}},
+	{"java.rmi.registry.Registry", "list", "()[" + descString, nil},
+	{"java.rmi.registry.Registry", "lookup", "(" + descString + ")Ljava/rmi/Remote;", []string{"name"}},
+	{"java.rmi.registry.Registry", "rebind", "(" + descString + "Ljava/rmi/Remote;)V", []string{"name", "obj"}},
+	{"java.rmi.registry.Registry", "unbind", "(" + descString + ")V", []string{"name"}},
+}
+
+// dgcMethods are the methods of java.rmi.dgc.DGC, by operation number.
+var dgcMethods = []rmiMethod{
+	{"java.rmi.dgc.DGC", "clean", "([Ljava/rmi/server/ObjID;JLjava/rmi/dgc/VMID;Z)V",
+		[]string{"ids", "sequenceNum", "vmid", "strong"}},
+	{"java.rmi.dgc.DGC", "dirty", "([Ljava/rmi/server/ObjID;JLjava/rmi/dgc/Lease;)Ljava/rmi/dgc/Lease;",
+		[]string{"ids", "sequenceNum", "lease"}},
+}
+
+// rmiMethodHash computes the hash identifying a remote method in the calls: the first 8 bytes, little-endian, of
+// the SHA-1 of the method name and descriptor written by DataOutputStream.writeUTF.
+func rmiMethodHash(nameAndDescriptor string) int64 {
+	h := sha1.New()
+	_ = binary.Write(h, binary.BigEndian, uint16(len(nameAndDescriptor)))
+	h.Write([]byte(nameAndDescriptor))
+
+	return int64(binary.LittleEndian.Uint64(h.Sum(nil)))
+}
+
+// JMXArgument is an argument of a remote method call.
+type JMXArgument struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// JMXMessage is a JRMP call or return found in captured JMX traffic, described as the operation it performs.
+type JMXMessage struct {
+	Offset      int           `json:"offset"` // offset of the message type byte
+	Kind        string        `json:"kind"`   // JMXCall or JMXReturn
+	Object      int64         `json:"object"` // number of the remote object called: 0 registry, 2 DGC
+	Interface   string        `json:"interface,omitempty"`
+	Method      string        `json:"method,omitempty"` // empty when the method hash is unknown
+	Hash        int64         `json:"hash,omitempty"`   // method hash, or interface hash of operation numbers
+	Operation   int32         `json:"operation"`        // -1 when the method is called by hash
+	Arguments   []JMXArgument `json:"arguments,omitempty"`
+	Exception   bool          `json:"exception,omitempty"` // the return is an exception thrown by the method
+	Description string        `json:"description"`         // e.g. invoke(java.lang:type=Memory, "gc", [], [], null)
+	Content     []interface{} `json:"content,omitempty"`   // minimal representation of the serialized objects
+	Error       string        `json:"error,omitempty"`
+}
+
+// DecodeJMX finds the JRMP calls and returns of captured JMX-over-RMI traffic and describes them: the methods of the
+// JMX connector (RMIServer, RMIConnection), the registry and the DGC are recognized with their arguments, object
+// names and MarshalledObject parameters decoded. Calls of other methods keep their method hash.
+func DecodeJMX(data []byte, options ...Option) (_ []*JMXMessage, err error) {
+	defer recoverPanic(&err, data, -1)
+
+	var msgs []*JMXMessage
+
+	for offset := 1; offset < len(data); {
+		idx := bytes.Index(data[offset:], streamMagic)
+		if idx < 0 {
+			break
+		}
+
+		start := offset + idx - 1
+
+		if t := data[start]; t != RMI_Call && t != RMI_ReturnData {
+			offset += idx + 1
+
+			continue
+		}
+
+		msg, end := decodeJRMPMessage(data, start, options)
+		msgs = append(msgs, msg)
+		offset = end + 1
+	}
+
+	if len(msgs) == 0 {
+		return nil, errors.New("no JRMP call or return found")
+	}
+
+	return msgs, nil
+}
+
+// decodeJRMPMessage decodes the call or return whose type byte is at start, and returns the offset of its end.
+func decodeJRMPMessage(data []byte, start int, options []Option) (*JMXMessage, int) {
+	msg := &JMXMessage{Offset: start, Kind: JMXCall}
+	if data[start] == RMI_ReturnData {
+		msg.Kind = JMXReturn
+	}
+
+	stream := data[start+1:]
+	parser := NewSerializedObjectParser(bytes.NewReader(stream), bufferOptions(stream, options)...)
+
+	var (
+		blocks  []byte
+		objects []interface{}
+	)
+
+	for {
+		el, err := parser.NextElement()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			// the next message ends this one
+			if end := parser.parsedEnd; end >= int64(len(stream)) || !isJRMPMessageType(stream[end]) {
+				msg.Error = err.Error()
+			}
+
+			break
+		}
+
+		if tag := stream[el.Offset]; tag == TC_BLOCKDATA || tag == TC_BLOCKDATALONG {
+			blocks = append(blocks, el.Content.([]byte)...)
+		} else {
+			objects = append(objects, el.Content)
+		}
+	}
+
+	end := start + 1 + int(parser.parsedEnd)
+	msg.Content = jsonFriendlyArray(objects)
+
+	if msg.Kind == JMXCall {
+		describeJMXCall(msg, blocks, objects, options)
+	} else {
+		describeJMXReturn(msg, blocks, objects, options)
+	}
+
+	return msg, end
+}
+
+func isJRMPMessageType(b byte) bool {
+	return b >= RMI_Call && b <= RMI_DgcAck
+}
+
+// describeJMXCall decodes the call header and the arguments of the method called.
+func describeJMXCall(msg *JMXMessage, blocks []byte, objects []interface{}, options []Option) {
+	if len(blocks) < rmiCallHeaderSize {
+		msg.Description = "call with a truncated header"
+
+		return
+	}
+
+	msg.Object = int64(binary.BigEndian.Uint64(blocks))
+	msg.Operation = int32(binary.BigEndian.Uint32(blocks[22:]))
+	msg.Hash = int64(binary.BigEndian.Uint64(blocks[26:]))
+	primitives := blocks[rmiCallHeaderSize:]
+
+	var method *rmiMethod
+
+	switch {
+	case msg.Operation < 0:
+		method = jmxMethodsByHash[msg.Hash]
+	case msg.Hash == registryInterfaceHash && int(msg.Operation) < len(registryMethods):
+		method = &registryMethods[msg.Operation]
+	case msg.Hash == dgcInterfaceHash && int(msg.Operation) < len(dgcMethods):
+		method = &dgcMethods[msg.Operation]
+	}
+
+	if method == nil {
+		for i, obj := range objects {
+			msg.Arguments = append(msg.Arguments, JMXArgument{Name: fmt.Sprintf("arg%d", i),
+				Value: jmxValue(obj, options, 0)})
+		}
+
+		msg.Description = fmt.Sprintf("unknown method %#x%s of object %d", uint64(msg.Hash),
+			jmxArgumentList(msg.Arguments), msg.Object)
+
+		return
+	}
+
+	msg.Interface, msg.Method = method.iface, method.name
+
+	for i, typeCode := range descriptorTypeCodes(method.descriptor) {
+		arg := JMXArgument{Name: method.params[i], Value: "?"}
+
+		if size, isPrimitive := primitiveSizes[typeCode]; isPrimitive {
+			if len(primitives) >= size {
+				arg.Value = primitiveString(typeCode, primitives[:size])
+				primitives = primitives[size:]
+			}
+		} else if len(objects) > 0 {
+			arg.Value = jmxValue(objects[0], options, 0)
+			objects = objects[1:]
+		}
+
+		msg.Arguments = append(msg.Arguments, arg)
+	}
+
+	msg.Description = method.name + jmxArgumentList(msg.Arguments)
+}
+
+// describeJMXReturn decodes the return type and the value returned or the exception thrown.
+func describeJMXReturn(msg *JMXMessage, blocks []byte, objects []interface{}, options []Option) {
+	msg.Operation = -1
+
+	if len(blocks) < rmiReturnHeaderSize {
+		msg.Description = "return with a truncated header"
+
+		return
+	}
+
+	msg.Exception = blocks[0] == rmiExceptionalReturn
+
+	switch {
+	case msg.Exception && len(objects) > 0:
+		msg.Description = "throw " + jmxValue(objects[0], options, 0)
+	case msg.Exception:
+		msg.Description = "throw"
+	case len(objects) > 0:
+		msg.Description = "return " + jmxValue(objects[0], options, 0)
+	case len(blocks) > rmiReturnHeaderSize:
+		msg.Description = "return 0x" + fmt.Sprintf("%x", blocks[rmiReturnHeaderSize:])
+	default:
+		msg.Description = "return"
+	}
+}
+
+func jmxArgumentList(args []JMXArgument) string {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	return "(" + strings.Join(values, ", ") + ")"
+}
+
+// descriptorTypeCodes returns the type codes of the parameters of a method descriptor, '[' for every array.
+func descriptorTypeCodes(descriptor string) []byte {
+	var res []byte
+
+	for i := 1; i < len(descriptor) && descriptor[i] != ')'; i++ {
+		typeCode := descriptor[i]
+
+		for descriptor[i] == '[' {
+			i++
+		}
+
+		if descriptor[i] == 'L' {
+			i += strings.IndexByte(descriptor[i:], ';')
+		}
+
+		res = append(res, typeCode)
+	}
+
+	return res
+}
+
+// primitiveString formats a big-endian primitive value of type typeCode.
+func primitiveString(typeCode byte, b []byte) string {
+	values := appendPrimitives(nil, typeCode, b)
+	if len(values) != 1 {
+		return "?"
+	}
+
+	return fmt.Sprint(values[0])
+}
+
+// jmxValue renders an argument or a returned value: object names as their canonical name, MarshalledObject as the
+// object they hold, throwables as their class and message, the other values as minimal JSON.
+func jmxValue(v interface{}, options []Option, depth int) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case map[string]interface{}:
+		switch objectClassName(v) {
+		case "javax.management.ObjectName":
+			if name, isString := v["value"].(string); isString {
+				return name
+			}
+		case "java.rmi.MarshalledObject":
+			raw, _ := v["objBytes"].([]interface{})
+			if b := streamBytes(raw); b != nil && depth < maxEmbeddedDepth {
+				if content, err := ParseSerializedObject(b, options...); err == nil && len(content) == 1 {
+					return jmxValue(content[0], options, depth+1)
+				}
+			}
+		}
+
+		if isThrowable(v) {
+			if msg, isString := v["detailMessage"].(string); isString {
+				return objectClassName(v) + ": " + msg
+			}
+
+			return objectClassName(v)
+		}
+	}
+
+	b, err := json.Marshal(jsonFriendlyObject(v))
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,65 @@
+package pkg
+
+func init() {
+	// ObjectName is written in its 1.0 form, with serial fields, when jmx.serial.form=1.0
+	KnownPostProcs["javax.management.ObjectName@0f03a71beb6d15cf"] = objectNamePostProc
+	KnownPostProcs["javax.management.ObjectName@b41e7d55cfd55210"] = objectNamePostProc
+
+	objectPostProcs["javax.management.openmbean.CompositeDataSupport"] = compositeDataPostProc
+	objectPostProcs["javax.management.openmbean.TabularDataSupport"] = tabularDataPostProc
+}
+
+// objectNamePostProc sets the value of a javax.management.ObjectName to its canonical name, domain:key=value,...
+// written by writeObject after the (empty) serial fields, or held by the canonicalName field of the 1.0 form.
+func objectNamePostProc(fields map[string]interface{}, data []interface{}) (map[string]interface{}, error) {
+	if len(data) > 0 {
+		if name, isString := data[0].(string); isString {
+			fields["value"] = name
+		}
+	} else if name, isString := fields["canonicalName"].(string); isString {
+		fields["value"] = name
+	}
+
+	return fields, nil
+}
+
+// compositeDataPostProc decodes a javax.management.openmbean.CompositeDataSupport to the map of its items, held by
+// the TreeMap of its contents field. Its fields stay available in "extends".
+func compositeDataPostProc(obj map[string]interface{}) {
+	contents, isMap := obj["contents"].(map[string]interface{})
+	if !isMap {
+		return
+	}
+
+	if items, isMap := contents["value"].(map[string]interface{}); isMap {
+		obj["value"] = items
+		delete(obj, "contents")
+		delete(obj, "compositeType")
+	}
+}
+
+// tabularDataPostProc decodes a javax.management.openmbean.TabularDataSupport to the list of its rows, the
+// CompositeData values of the HashMap of its dataMap field, keyed by lists of the index items. Its fields stay
+// available in "extends".
+func tabularDataPostProc(obj map[string]interface{}) {
+	dataMap, isMap := obj["dataMap"].(map[string]interface{})
+	if !isMap {
+		return
+	}
+
+	data, _ := dataMap["@"].([]interface{})
+
+	size, err := postProcSize(data, 4)
+	if err != nil || size*2+1 > len(data) {
+		return
+	}
+
+	rows := make([]interface{}, 0, size)
+	for i := 0; i < size; i++ {
+		rows = append(rows, data[2*i+2])
+	}
+
+	obj["value"] = rows
+	delete(obj, "dataMap")
+	delete(obj, "tabularType")
+}
@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// jvmHelperSource is the helper run by StartJVM, launched from its source.
+//
+//go:embed jvm/PjsDeserialize.java
+var jvmHelperSource []byte
+
+// JVMResult is what ObjectInputStream made of a stream in the helper JVM. The helper resolves no class but the
+// primitive arrays: ObjectInputStream reads the whole stream anyway, skipping the data of the objects it cannot
+// create, and no code of the stream runs.
+type JVMResult struct {
+	Elements int      `json:"elements"`        // top-level elements read
+	Classes  []string `json:"classes"`         // names of the class descriptors read, proxies left out
+	Error    string   `json:"error,omitempty"` // exception which stopped the read
+}
+
+// JVM is a helper JVM reading streams with ObjectInputStream, to catch the divergences of the parser.
+type JVM struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	dir    string
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// StartJVM starts the helper JVM with a java command of Java 11 or later, which runs the bundled source directly.
+func StartJVM(java string) (*JVM, error) {
+	dir, err := ioutil.TempDir("", "go-pjs-jvm")
+	if err != nil {
+		return nil, err
+	}
+
+	source := filepath.Join(dir, "PjsDeserialize.java")
+	if err = ioutil.WriteFile(source, jvmHelperSource, 0644); err != nil {
+		_ = os.RemoveAll(dir)
+
+		return nil, err
+	}
+
+	this := &JVM{cmd: exec.Command(java, source), dir: dir}
+	this.cmd.Stderr = os.Stderr
+
+	stdin, err := this.cmd.StdinPipe()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+
+		return nil, errors.Wrap(err, "error opening jvm stdin")
+	}
+
+	stdout, err := this.cmd.StdoutPipe()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+
+		return nil, errors.Wrap(err, "error opening jvm stdout")
+	}
+
+	this.stdin, this.stdout = stdin, bufio.NewReader(stdout)
+
+	if err = this.cmd.Start(); err != nil {
+		_ = os.RemoveAll(dir)
+
+		return nil, errors.Wrapf(err, "error starting jvm %s", java)
+	}
+
+	return this, nil
+}
+
+// Deserialize reads a stream with ObjectInputStream.
+func (this *JVM) Deserialize(data []byte) (*JVMResult, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if _, err := io.WriteString(this.stdin, base64.StdEncoding.EncodeToString(data)+"\n"); err != nil {
+		return nil, errors.Wrap(err, "error writing jvm request")
+	}
+
+	line, err := this.stdout.ReadBytes('\n')
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading jvm response")
+	}
+
+	res := &JVMResult{}
+
+	return res, errors.Wrap(json.Unmarshal(line, res), "error decoding jvm response")
+}
+
+// Close stops the helper JVM.
+func (this *JVM) Close() error {
+	_ = this.stdin.Close()
+	err := this.cmd.Wait()
+	_ = os.RemoveAll(this.dir)
+
+	return err
+}
+
+// JVMDivergences compares the read of a stream by ObjectInputStream with its parse and compatibility check: one
+// failing while the other reads the stream, or class descriptors found by one only when both read it. Streams whose
+// compatibility is unknown are only compared when both read them.
+func JVMDivergences(data []byte, res *JVMResult, options ...Option) []string {
+	var divergences []string
+
+	analysis := Analyze(data, options...)
+	compat := CheckCompatibility(data)
+
+	switch {
+	case res.Error != "" && analysis.Error == "" && compat.Verdict == CompatAccepted:
+		divergences = append(divergences, "ObjectInputStream fails with "+res.Error+", the stream parses and is compatible")
+	case res.Error == "" && analysis.Error != "":
+		divergences = append(divergences, "ObjectInputStream reads the stream, the parse fails with "+analysis.Error)
+	case res.Error == "" && compat.Verdict == CompatRejected && len(compat.Reasons) > 0:
+		divergences = append(divergences, "ObjectInputStream reads the stream, the compatibility check rejects it: "+
+			compat.Reasons[0].Detail)
+	}
+
+	if res.Error != "" || analysis.Error != "" {
+		return divergences
+	}
+
+	classes := map[string]int{}
+
+	for _, name := range res.Classes {
+		classes[name] |= 1
+	}
+
+	for _, cls := range analysis.Classes {
+		if cls.Name != proxyClassName {
+			classes[cls.Name] |= 2
+		}
+	}
+
+	names := make([]string, 0, len(classes))
+	for name := range classes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch classes[name] {
+		case 1:
+			divergences = append(divergences, "class "+name+" read by ObjectInputStream only")
+		case 2:
+			divergences = append(divergences, "class "+name+" found by the parser only")
+		}
+	}
+
+	return divergences
+}
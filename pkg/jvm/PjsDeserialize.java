@@ -0,0 +1,106 @@
+import java.io.BufferedReader;
+import java.io.ByteArrayInputStream;
+import java.io.FileDescriptor;
+import java.io.FileOutputStream;
+import java.io.IOException;
+import java.io.InputStreamReader;
+import java.io.ObjectInputStream;
+import java.io.ObjectStreamClass;
+import java.io.OptionalDataException;
+import java.io.PrintStream;
+import java.io.WriteAbortedException;
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.Base64;
+import java.util.List;
+
+/**
+ * Helper of pkg.StartJVM: reads base64 encoded streams from stdin, one per line, and writes what ObjectInputStream
+ * made of each to stdout as a JSON line. No class but the primitive arrays is resolved, ObjectInputStream then skips
+ * the data of the objects it cannot create and no code of the stream ever runs.
+ */
+public class PjsDeserialize {
+    public static void main(String[] args) throws IOException {
+        BufferedReader in = new BufferedReader(new InputStreamReader(System.in, StandardCharsets.US_ASCII));
+        PrintStream out = new PrintStream(new FileOutputStream(FileDescriptor.out), false, "UTF-8");
+
+        for (String line; (line = in.readLine()) != null; ) {
+            out.println(read(Base64.getDecoder().decode(line.trim())));
+            out.flush();
+        }
+    }
+
+    static String read(byte[] data) {
+        final List<String> classes = new ArrayList<>();
+        ByteArrayInputStream bis = new ByteArrayInputStream(data);
+        int elements = 0;
+        String error = null;
+
+        try (ObjectInputStream ois = new ObjectInputStream(bis) {
+            @Override
+            protected Class<?> resolveClass(ObjectStreamClass desc) throws IOException, ClassNotFoundException {
+                classes.add(desc.getName());
+
+                // the elements of the arrays of unresolved classes are read as objects
+                if (desc.getName().length() == 2 && desc.getName().charAt(0) == '[') {
+                    return super.resolveClass(desc);
+                }
+
+                throw new ClassNotFoundException(desc.getName());
+            }
+
+            @Override
+            protected Class<?> resolveProxyClass(String[] interfaces) throws ClassNotFoundException {
+                throw new ClassNotFoundException(String.join(",", interfaces));
+            }
+        }) {
+            while (bis.available() > 0) {
+                try {
+                    ois.readObject();
+                } catch (ClassNotFoundException | WriteAbortedException e) {
+                    // the element was read entirely
+                } catch (OptionalDataException e) {
+                    if (e.eof || e.length == 0) {
+                        throw e;
+                    }
+
+                    ois.skipBytes(e.length);
+                }
+
+                elements++;
+            }
+        } catch (IOException e) {
+            error = e.toString();
+        }
+
+        StringBuilder json = new StringBuilder("{\"elements\":").append(elements).append(",\"classes\":[");
+
+        for (int i = 0; i < classes.size(); i++) {
+            json.append(i > 0 ? "," : "").append(quote(classes.get(i)));
+        }
+
+        json.append(']');
+
+        if (error != null) {
+            json.append(",\"error\":").append(quote(error));
+        }
+
+        return json.append('}').toString();
+    }
+
+    static String quote(String s) {
+        StringBuilder res = new StringBuilder("\"");
+
+        for (char c : s.toCharArray()) {
+            if (c == '"' || c == '\\') {
+                res.append('\\').append(c);
+            } else if (c < 0x20 || c > 0x7e) {
+                res.append(String.format("\\u%04x", (int) c));
+            } else {
+                res.append(c);
+            }
+        }
+
+        return res.append('"').toString();
+    }
+}
@@ -0,0 +1,202 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// KeyTrialProgress describes the progress of a KeyTrial.
+type KeyTrialProgress struct {
+	Tried   int     `json:"tried"`
+	Total   int     `json:"total"`
+	Seconds float64 `json:"seconds"`
+	Rate    float64 `json:"rate"` // keys tried per second
+}
+
+// KeyTrialResult is the outcome of a KeyTrial.
+type KeyTrialResult struct {
+	KeyTrialProgress
+	Found *KeyResult `json:"found,omitempty"` // first profile found decrypting the payload, nil when none does
+}
+
+// KeyTrial tries long key lists, such as the published Shiro keys, on a payload in parallel, stopping at the first
+// key decrypting it to a serialized stream. Zero values select the defaults.
+type KeyTrial struct {
+	Workers  int                    // runtime.NumCPU by default
+	Progress func(KeyTrialProgress) // called every Interval while keys are tried, and once at the end
+	Interval time.Duration          // one second by default
+}
+
+// LoadKeyList reads a key list file, one base64 or hex: prefixed key per line, blank lines and lines starting with #
+// ignored, as profiles of an algorithm named after their keys.
+func LoadKeyList(path, algorithm string) ([]*KeyProfile, error) {
+	if algorithm != KeyAESCBC && algorithm != KeyAESGCM {
+		return nil, errors.Errorf("unknown algorithm '%s', want %s or %s", algorithm, KeyAESCBC, KeyAESGCM)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var res []*KeyProfile
+
+	scanner := bufio.NewScanner(f)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		key, err := decodeKey(text)
+		if err == nil {
+			_, err = aes.NewCipher(key)
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s:%d: invalid key", path, line)
+		}
+
+		res = append(res, &KeyProfile{Name: text, Algorithm: algorithm, Key: key})
+	}
+
+	return res, errors.Wrapf(scanner.Err(), "error reading key list %s", path)
+}
+
+// Run tries the profiles on a payload, raw or base64 encoded, with Workers goroutines. CBC profiles are first checked
+// on the first block only, which must start with the stream magic 0xACED0005, so most wrong keys cost a single block
+// decryption. It returns the context error when ctx is done before a key is found.
+func (this *KeyTrial) Run(ctx context.Context, data []byte, profiles []*KeyProfile) (*KeyTrialResult, error) {
+	workers, interval := this.Workers, this.Interval
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	data = decodeCiphertext(data)
+	start := time.Now()
+	res := &KeyTrialResult{}
+
+	var (
+		next, tried int64
+		once        sync.Once
+		wg          sync.WaitGroup
+	)
+
+	progress := func() KeyTrialProgress {
+		p := KeyTrialProgress{Tried: int(atomic.LoadInt64(&tried)), Total: len(profiles)}
+		p.Seconds = time.Since(start).Seconds()
+
+		if p.Seconds > 0 {
+			p.Rate = float64(p.Tried) / p.Seconds
+		}
+
+		return p
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for ctx.Err() == nil {
+				idx := int(atomic.AddInt64(&next, 1)) - 1
+				if idx >= len(profiles) {
+					return
+				}
+
+				plain, err := profiles[idx].trial(data)
+				atomic.AddInt64(&tried, 1)
+
+				if err == nil {
+					once.Do(func() {
+						res.Found = &KeyResult{Profile: profiles[idx].Name, OK: true, Data: plain}
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-ticker.C:
+			if this.Progress != nil {
+				this.Progress(progress())
+			}
+		}
+	}
+
+	res.KeyTrialProgress = progress()
+
+	if this.Progress != nil {
+		this.Progress(res.KeyTrialProgress)
+	}
+
+	if res.Found == nil && ctx.Err() != nil {
+		// the parent context, the trial only cancelling its own once a key is found
+		return res, errors.Wrap(ctx.Err(), "key trial interrupted")
+	}
+
+	return res, nil
+}
+
+// trial decrypts a ciphertext to a serialized stream, rejecting the wrong CBC keys on the first block.
+func (this *KeyProfile) trial(data []byte) ([]byte, error) {
+	if this.Algorithm == KeyAESCBC && len(data) >= this.MAC+2*aes.BlockSize {
+		block, err := aes.NewCipher(this.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		first := make([]byte, aes.BlockSize)
+		block.Decrypt(first, data[aes.BlockSize:2*aes.BlockSize])
+
+		for i := range first {
+			first[i] ^= data[i]
+		}
+
+		if !bytes.HasPrefix(first, streamMagic) {
+			return nil, errors.New("not a serialized stream")
+		}
+	}
+
+	plain, err := this.decrypt(data)
+	if err == nil && !bytes.HasPrefix(plain, streamMagic) {
+		err = errors.New("not a serialized stream")
+	}
+
+	return plain, err
+}
@@ -0,0 +1,211 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Key profile algorithms, see ParseKeyVault.
+const (
+	KeyAESCBC = "aes-cbc" // 16 byte IV prefix, PKCS#5 padding: Shiro rememberMe up to 1.4.1, custom AES
+	KeyAESGCM = "aes-gcm" // 16 byte nonce prefix, 16 byte tag: Shiro rememberMe from 1.4.2
+)
+
+// gcmNonceSize is the nonce size of the aes-gcm profiles, the one of Shiro.
+const gcmNonceSize = 16
+
+// KeyProfile is a named key of a KeyVault.
+type KeyProfile struct {
+	Name      string
+	Algorithm string
+	Key       []byte
+	MAC       int // bytes of MAC appended to the ciphertext, such as the HMAC of a JSF ViewState, stripped unchecked
+}
+
+// KeyVault holds the keys of the encrypted payloads: Shiro rememberMe cookies, JSF ViewStates, custom AES.
+type KeyVault struct {
+	Profiles []*KeyProfile
+}
+
+// KeyResult is the outcome of decrypting a payload with a profile, see KeyVault.TryKeys.
+type KeyResult struct {
+	Profile string `json:"profile"`
+	OK      bool   `json:"ok"`              // the payload decrypts to a serialized stream
+	Error   string `json:"error,omitempty"` // why it does not
+	Data    []byte `json:"-"`               // decrypted stream
+}
+
+// LoadKeyVault reads a YAML key vault file, see ParseKeyVault.
+func LoadKeyVault(path string) (*KeyVault, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vault, err := ParseKeyVault(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading key vault %s", path)
+	}
+
+	return vault, nil
+}
+
+// ParseKeyVault parses YAML key profiles, their keys base64 or, prefixed with hex:, hex encoded:
+//
+//	profiles:
+//	  - name: shiro-1.2.4
+//	    algorithm: aes-cbc
+//	    key: kPH+bIxk5D2deZiIxcaaaA==
+//	  - name: viewstate
+//	    algorithm: aes-cbc
+//	    key: hex:000102030405060708090a0b0c0d0e0f
+//	    mac: 32
+func ParseKeyVault(b []byte) (*KeyVault, error) {
+	var doc struct {
+		Profiles []struct {
+			Name      string `yaml:"name"`
+			Algorithm string `yaml:"algorithm"`
+			Key       string `yaml:"key"`
+			MAC       int    `yaml:"mac"`
+		} `yaml:"profiles"`
+	}
+
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	vault := &KeyVault{}
+	names := map[string]bool{}
+
+	for i, p := range doc.Profiles {
+		profile := &KeyProfile{Name: p.Name, Algorithm: strings.ToLower(p.Algorithm), MAC: p.MAC}
+
+		var err error
+
+		profile.Key, err = decodeKey(p.Key)
+
+		switch {
+		case profile.Name == "" || names[profile.Name]:
+			return nil, errors.Errorf("profile %d: missing or duplicate name '%s'", i+1, profile.Name)
+		case err != nil:
+			return nil, errors.Wrapf(err, "profile %s: invalid key", profile.Name)
+		case profile.Algorithm != KeyAESCBC && profile.Algorithm != KeyAESGCM:
+			return nil, errors.Errorf("profile %s: unknown algorithm '%s', want %s or %s", profile.Name, p.Algorithm,
+				KeyAESCBC, KeyAESGCM)
+		case profile.MAC < 0:
+			return nil, errors.Errorf("profile %s: negative mac size", profile.Name)
+		}
+
+		if _, err = aes.NewCipher(profile.Key); err != nil {
+			return nil, errors.Wrapf(err, "profile %s", profile.Name)
+		}
+
+		names[profile.Name] = true
+		vault.Profiles = append(vault.Profiles, profile)
+	}
+
+	return vault, nil
+}
+
+// decodeKey decodes a base64 or, prefixed with hex:, hex encoded key.
+func decodeKey(key string) ([]byte, error) {
+	if strings.HasPrefix(key, "hex:") {
+		return hex.DecodeString(strings.TrimPrefix(key, "hex:"))
+	}
+
+	return base64.StdEncoding.DecodeString(key)
+}
+
+// TryKeys decrypts a payload, raw or base64 encoded like a cookie, with every profile of the vault, in order, and
+// tells which profiles decrypt it to a serialized stream.
+func (this *KeyVault) TryKeys(data []byte) []KeyResult {
+	data = decodeCiphertext(data)
+	res := make([]KeyResult, 0, len(this.Profiles))
+
+	for _, profile := range this.Profiles {
+		plain, err := profile.trial(data)
+		if err != nil {
+			res = append(res, KeyResult{Profile: profile.Name, Error: err.Error()})
+		} else {
+			res = append(res, KeyResult{Profile: profile.Name, OK: true, Data: plain})
+		}
+	}
+
+	return res
+}
+
+// Decrypt returns the serialized stream of a payload decrypted with the first profile of the vault which succeeds,
+// see TryKeys.
+func (this *KeyVault) Decrypt(data []byte) ([]byte, string, error) {
+	for _, res := range this.TryKeys(data) {
+		if res.OK {
+			return res.Data, res.Profile, nil
+		}
+	}
+
+	return nil, "", errors.Errorf("no key of the %d profiles decrypts the payload", len(this.Profiles))
+}
+
+// decodeCiphertext decodes a base64 payload, standard or URL-safe, returning other payloads as they are.
+func decodeCiphertext(data []byte) []byte {
+	text := strings.TrimSpace(string(data))
+
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding,
+		base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(text); err == nil {
+			return b
+		}
+	}
+
+	return data
+}
+
+// decrypt decrypts a ciphertext, its IV or nonce first.
+func (this *KeyProfile) decrypt(data []byte) ([]byte, error) {
+	if len(data) < this.MAC {
+		return nil, errors.New("payload shorter than its mac")
+	}
+
+	data = data[:len(data)-this.MAC]
+
+	block, err := aes.NewCipher(this.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if this.Algorithm == KeyAESGCM {
+		gcm, err := cipher.NewGCMWithNonceSize(block, gcmNonceSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) < gcmNonceSize+gcm.Overhead() {
+			return nil, errors.New("payload shorter than a nonce and a tag")
+		}
+
+		return gcm.Open(nil, data[:gcmNonceSize], data[gcmNonceSize:], nil)
+	}
+
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, errors.Errorf("payload of %d bytes is not an IV and whole blocks", len(data))
+	}
+
+	plain := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(plain, data[aes.BlockSize:])
+
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(plain[len(plain)-padding:],
+		bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errors.New("invalid padding")
+	}
+
+	return plain[:len(plain)-padding], nil
+}
@@ -0,0 +1,227 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	mdExcerptSize = 64 // bytes shown per hexdump excerpt
+	mdMaxExcerpts = 5
+)
+
+// markdownOutput renders a human-readable Markdown report of an analysis, for inclusion in pentest notes.
+func markdownOutput(w io.Writer, name string, analysis *Analysis) error {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "# go-pjs report: %s\n\n", mdEscape(name))
+
+	sb.WriteString("## Overview\n\n| | |\n|---|---|\n")
+	fmt.Fprintf(&sb, "| Verdict | **%s** |\n", analysis.Verdict())
+	fmt.Fprintf(&sb, "| Size | %d bytes |\n", analysis.Size)
+	fmt.Fprintf(&sb, "| SHA-256 | `%s` |\n", analysis.SHA256)
+	fmt.Fprintf(&sb, "| Fingerprint | `%s` |\n", analysis.Fingerprint)
+	fmt.Fprintf(&sb, "| Content hash | `%s` |\n", analysis.ContentHash)
+	fmt.Fprintf(&sb, "| Top level elements | %d |\n", analysis.Elements)
+
+	if analysis.Error != "" {
+		fmt.Fprintf(&sb, "| Parse error | %s |\n", mdEscape(analysis.Error))
+	}
+
+	if trailing := analysis.Trailing; trailing != nil {
+		fmt.Fprintf(&sb, "| Trailing data | %d bytes at offset %d |\n", trailing.Length, trailing.Offset)
+
+		if meta := trailing.Metadata; meta != nil {
+			fmt.Fprintf(&sb, "| Sample metadata | %s by %s with go-pjs %s %s |\n", meta.Timestamp.Format(time.RFC3339),
+				mdEscape(meta.Analyst), mdEscape(meta.Version), mdEscape(meta.Tool))
+		}
+	}
+
+	sb.WriteString("\n## Classes\n\n")
+
+	if len(analysis.Classes) == 0 {
+		sb.WriteString("No class descriptor.\n")
+	} else if !hasClassPath(analysis) {
+		sb.WriteString("| Class | serialVersionUID | Instances |\n|---|---|---:|\n")
+
+		for _, cls := range analysis.Classes {
+			fmt.Fprintf(&sb, "| `%s` | `%s` | %d |\n", cls.Name, cls.SerialVersionUID, cls.Instances)
+		}
+	} else {
+		sb.WriteString("| Class | serialVersionUID | Instances | Class path |\n|---|---|---:|---|\n")
+
+		for _, cls := range analysis.Classes {
+			fmt.Fprintf(&sb, "| `%s` | `%s` | %d | %s |\n", cls.Name, cls.SerialVersionUID, cls.Instances, cls.ClassPath)
+		}
+	}
+
+	if len(analysis.Libraries) > 0 {
+		sb.WriteString("\n## Libraries\n\n")
+
+		for _, lib := range analysis.Libraries {
+			names := make([]string, len(lib.Classes))
+			for i, name := range lib.Classes {
+				names[i] = simpleClassName(name)
+			}
+
+			fmt.Fprintf(&sb, "- `%s`: %s\n", lib.String(), strings.Join(names, ", "))
+		}
+	}
+
+	sb.WriteString("\n## Findings\n\n")
+
+	if len(analysis.Findings) == 0 {
+		sb.WriteString("No suspicious finding.\n")
+	} else {
+		sb.WriteString("| Severity | Rule | Title | Class |\n|---|---|---|---|\n")
+
+		for _, f := range analysis.Findings {
+			fmt.Fprintf(&sb, "| %s | %s | %s | %s |\n", f.Severity, f.RuleID, mdEscape(f.Title), mdCode(f.Class))
+		}
+	}
+
+	if len(analysis.GadgetPaths) > 0 {
+		sb.WriteString("\n## Gadget chains\n\n")
+
+		for _, path := range analysis.GadgetPaths {
+			fmt.Fprintf(&sb, "- %s: `%s`\n", mdEscape(path.Chain), strings.ReplaceAll(path.Path, "`", "'"))
+		}
+	}
+
+	sb.WriteString("\n## Indicators\n\n")
+
+	if len(analysis.IOCs) == 0 && len(analysis.Strings) == 0 {
+		sb.WriteString("No indicator extracted.\n")
+	}
+
+	for _, ioc := range analysis.IOCs {
+		fmt.Fprintf(&sb, "- %s: `%s`\n", ioc.Type, ioc.Value)
+	}
+
+	for _, s := range analysis.Strings {
+		fmt.Fprintf(&sb, "- string: `%s`\n", strings.ReplaceAll(s, "`", "'"))
+	}
+
+	if len(analysis.raw) > 0 {
+		sb.WriteString("\n## Hexdump excerpts\n")
+		mdExcerpt(&sb, "Stream header", analysis.raw, 0)
+
+		excerpts := 0
+
+		for _, f := range analysis.Findings {
+			if f.Class == "" || excerpts >= mdMaxExcerpts {
+				continue
+			}
+
+			if idx := bytes.Index(analysis.raw, []byte(f.Class)); idx >= 0 {
+				mdExcerpt(&sb, "`"+f.Class+"`", analysis.raw, idx-2) // include the name length
+				excerpts++
+			}
+		}
+
+		if trailing := analysis.Trailing; trailing != nil {
+			mdExcerpt(&sb, "Trailing data", analysis.raw, int(trailing.Offset))
+		}
+	}
+
+	if p := analysis.Provenance; p != nil {
+		sb.WriteString("\n## Provenance\n\n| | |\n|---|---|\n")
+		fmt.Fprintf(&sb, "| go-pjs version | `%s` |\n", p.Version)
+		fmt.Fprintf(&sb, "| Rules hash | `%s` |\n", p.RulesHash)
+		fmt.Fprintf(&sb, "| Packs | %s |\n", mdEscape(strings.Join(p.Packs, ", ")))
+
+		names := make([]string, 0, len(p.Options))
+		for name := range p.Options {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(&sb, "| Option %s | `%s` |\n", name, mdEscape(p.Options[name]))
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+
+	return err
+}
+
+// mdExcerpt writes a hexdump of the bytes starting at offset.
+func mdExcerpt(sb *strings.Builder, title string, data []byte, offset int) {
+	if offset < 0 {
+		offset = 0
+	}
+
+	end := offset + mdExcerptSize
+	if end > len(data) {
+		end = len(data)
+	}
+
+	fmt.Fprintf(sb, "\n%s at offset %#x:\n\n```\n", title, offset)
+	sb.WriteString(hexDump(data[offset:end], offset))
+	sb.WriteString("```\n")
+}
+
+// hexDump formats data like hexdump -C, offsets starting at base.
+func hexDump(data []byte, base int) string {
+	var sb strings.Builder
+
+	for line := 0; line < len(data); line += 16 {
+		fmt.Fprintf(&sb, "%08x  ", base+line)
+
+		for i := line; i < line+16; i++ {
+			if i < len(data) {
+				fmt.Fprintf(&sb, "%02x ", data[i])
+			} else {
+				sb.WriteString("   ")
+			}
+
+			if i == line+7 {
+				sb.WriteString(" ")
+			}
+		}
+
+		sb.WriteString(" |")
+
+		for i := line; i < line+16 && i < len(data); i++ {
+			if data[i] >= 0x20 && data[i] < 0x7f {
+				sb.WriteByte(data[i])
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+
+		sb.WriteString("|\n")
+	}
+
+	return sb.String()
+}
+
+// mdEscape escapes the characters which would break a Markdown table cell.
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "\r", "").Replace(s)
+}
+
+func mdCode(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	return "`" + s + "`"
+}
+
+// hasClassPath tells whether the classes of an analysis were checked against a class path, see SetClassPath.
+func hasClassPath(analysis *Analysis) bool {
+	for _, cls := range analysis.Classes {
+		if cls.ClassPath != "" {
+			return true
+		}
+	}
+
+	return false
+}
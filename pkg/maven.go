@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"sort"
+	"strings"
+)
+
+// MavenArtifact is the probable Maven artifact of a library class.
+type MavenArtifact struct {
+	GroupID    string `json:"groupId"`
+	ArtifactID string `json:"artifactId"`
+	Versions   string `json:"versions,omitempty"` // probable versions, e.g. 3.x
+}
+
+// String returns the coordinates of the artifact, groupId:artifactId:versions.
+func (this *MavenArtifact) String() string {
+	if this.Versions == "" {
+		return this.GroupID + ":" + this.ArtifactID
+	}
+
+	return this.GroupID + ":" + this.ArtifactID + ":" + this.Versions
+}
+
+// mavenPackage maps a package prefix, ending with '.', to the artifact shipping its classes.
+type mavenPackage struct {
+	prefix   string
+	artifact MavenArtifact
+}
+
+// mavenIndex is the bundled index of the libraries most found in streams, by package. The versions are those the
+// package name tells apart, such as commons-collections 3.x from commons-collections4.
+var mavenIndex = []mavenPackage{
+	{"org.apache.commons.collections.", MavenArtifact{"commons-collections", "commons-collections", "3.x"}},
+	{"org.apache.commons.collections4.", MavenArtifact{"org.apache.commons", "commons-collections4", "4.x"}},
+	{"org.apache.commons.beanutils.", MavenArtifact{"commons-beanutils", "commons-beanutils", ""}},
+	{"org.apache.commons.fileupload.", MavenArtifact{"commons-fileupload", "commons-fileupload", ""}},
+	{"org.apache.commons.io.", MavenArtifact{"commons-io", "commons-io", ""}},
+	{"org.apache.commons.lang.", MavenArtifact{"commons-lang", "commons-lang", "2.x"}},
+	{"org.apache.commons.lang3.", MavenArtifact{"org.apache.commons", "commons-lang3", "3.x"}},
+	{"org.apache.commons.logging.", MavenArtifact{"commons-logging", "commons-logging", ""}},
+	{"org.apache.log4j.", MavenArtifact{"log4j", "log4j", "1.x"}},
+	{"org.apache.logging.log4j.", MavenArtifact{"org.apache.logging.log4j", "log4j-core", "2.x"}},
+	{"org.apache.xalan.", MavenArtifact{"xalan", "xalan", ""}},
+	{"org.apache.wicket.util.", MavenArtifact{"org.apache.wicket", "wicket-util", ""}},
+	{"org.apache.myfaces.", MavenArtifact{"org.apache.myfaces.core", "myfaces-impl", ""}},
+	{"org.apache.click.", MavenArtifact{"org.apache.click", "click-nodeps", ""}},
+	{"org.apache.shiro.", MavenArtifact{"org.apache.shiro", "shiro-core", ""}},
+	{"org.springframework.aop.", MavenArtifact{"org.springframework", "spring-aop", ""}},
+	{"org.springframework.beans.", MavenArtifact{"org.springframework", "spring-beans", ""}},
+	{"org.springframework.core.", MavenArtifact{"org.springframework", "spring-core", ""}},
+	{"org.springframework.transaction.", MavenArtifact{"org.springframework", "spring-tx", ""}},
+	{"org.codehaus.groovy.", MavenArtifact{"org.codehaus.groovy", "groovy", ""}},
+	{"com.mchange.v2.c3p0.", MavenArtifact{"com.mchange", "c3p0", ""}},
+	{"org.hibernate.", MavenArtifact{"org.hibernate", "hibernate-core", ""}},
+	{"org.mozilla.javascript.", MavenArtifact{"org.mozilla", "rhino", ""}},
+	{"bsh.", MavenArtifact{"org.beanshell", "bsh", ""}},
+	{"clojure.", MavenArtifact{"org.clojure", "clojure", ""}},
+	{"org.python.", MavenArtifact{"org.python", "jython-standalone", ""}},
+	{"com.vaadin.", MavenArtifact{"com.vaadin", "vaadin-server", ""}},
+	{"net.sf.json.", MavenArtifact{"net.sf.json-lib", "json-lib", ""}},
+	{"org.jboss.interceptor.", MavenArtifact{"org.jboss.interceptor", "jboss-interceptor-core", ""}},
+	{"org.jboss.weld.", MavenArtifact{"org.jboss.weld", "weld-core", ""}},
+	{"com.sun.syndication.", MavenArtifact{"rome", "rome", "≤1.0"}},
+	{"com.rometools.rome.", MavenArtifact{"com.rometools", "rome", ""}},
+	{"com.alibaba.fastjson.", MavenArtifact{"com.alibaba", "fastjson", ""}},
+	{"com.fasterxml.jackson.databind.", MavenArtifact{"com.fasterxml.jackson.core", "jackson-databind", ""}},
+	{"com.google.common.", MavenArtifact{"com.google.guava", "guava", ""}},
+	{"javassist.", MavenArtifact{"org.javassist", "javassist", ""}},
+	{"org.aspectj.", MavenArtifact{"org.aspectj", "aspectjweaver", ""}},
+	{"ch.qos.logback.classic.", MavenArtifact{"ch.qos.logback", "logback-classic", ""}},
+	{"ch.qos.logback.core.", MavenArtifact{"ch.qos.logback", "logback-core", ""}},
+}
+
+func init() {
+	// longest prefix first, org.apache.commons.collections4. before org.apache.commons.collections.
+	sort.SliceStable(mavenIndex, func(i, j int) bool { return len(mavenIndex[i].prefix) > len(mavenIndex[j].prefix) })
+}
+
+// LookupArtifact returns the probable Maven artifact of a class from the bundled index, nil for the JDK classes and
+// the classes of unknown libraries.
+func LookupArtifact(className string) *MavenArtifact {
+	for i := range mavenIndex {
+		if strings.HasPrefix(className, mavenIndex[i].prefix) {
+			artifact := mavenIndex[i].artifact
+
+			return &artifact
+		}
+	}
+
+	return nil
+}
+
+// Library is a library whose classes a stream holds, see LookupArtifact.
+type Library struct {
+	MavenArtifact
+	Classes []string `json:"classes"`
+}
+
+// libraries groups the classes of an analysis by probable artifact, in coordinates order.
+func libraries(classes []ClassSummary) []Library {
+	var res []Library
+
+	byArtifact := map[string]int{}
+
+	for _, cls := range classes {
+		artifact := LookupArtifact(cls.Name)
+		if artifact == nil {
+			continue
+		}
+
+		idx, exists := byArtifact[artifact.String()]
+		if !exists {
+			idx = len(res)
+			byArtifact[artifact.String()] = idx
+			res = append(res, Library{MavenArtifact: *artifact})
+		}
+
+		res[idx].Classes = append(res[idx].Classes, cls.Name)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].String() < res[j].String() })
+
+	return res
+}
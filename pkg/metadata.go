@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// metadataMarker starts the metadata block appended to the samples written by go-pjs. Its first byte is no stream
+// element tag: top-level parsing stops at it.
+var metadataMarker = []byte("\x00go-pjs-metadata\x00")
+
+// SampleMetadata is the provenance of a sample written by go-pjs (defused, minimized...), stored in a block appended
+// after the stream: ObjectInputStream never reads it, the parser surfaces it with the trailing data.
+type SampleMetadata struct {
+	Analyst   string    `json:"analyst,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Version   string    `json:"version"`        // go-pjs version which wrote the sample
+	Tool      string    `json:"tool,omitempty"` // command which wrote the sample
+}
+
+// NewSampleMetadata describes a sample written now by the running go-pjs.
+func NewSampleMetadata(analyst, tool string) *SampleMetadata {
+	return &SampleMetadata{Analyst: analyst, Timestamp: time.Now().UTC(), Version: CurrentVersion(), Tool: tool}
+}
+
+// AppendMetadata appends a metadata block to a sample, replacing the block it already holds.
+func AppendMetadata(data []byte, meta *SampleMetadata) ([]byte, error) {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding sample metadata")
+	}
+
+	if i := bytes.LastIndex(data, metadataMarker); i >= 0 && ReadMetadata(data[i:]) != nil {
+		data = data[:i]
+	}
+
+	res := make([]byte, 0, len(data)+len(metadataMarker)+len(b))
+	res = append(res, data...)
+	res = append(res, metadataMarker...)
+
+	return append(res, b...), nil
+}
+
+// ReadMetadata decodes a metadata block, nil when block is none.
+func ReadMetadata(block []byte) *SampleMetadata {
+	if !bytes.HasPrefix(block, metadataMarker) {
+		return nil
+	}
+
+	meta := &SampleMetadata{}
+	if err := json.Unmarshal(block[len(metadataMarker):], meta); err != nil {
+		return nil
+	}
+
+	return meta
+}
+
+// atMetadata tells whether the next bytes are a metadata block.
+func (this *SerializedObjectParser) atMetadata() bool {
+	b, _ := this.rd.Peek(len(metadataMarker))
+
+	return bytes.Equal(b, metadataMarker)
+}
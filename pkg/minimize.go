@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrNotFailing is returned by Minimize and SameParseFailure when the input to minimize does not fail.
+var ErrNotFailing = errors.New("input does not fail")
+
+// Minimize reduces a failing input to a small reproducer which still fails, fails telling whether an input shows the
+// failure. It first truncates the input as much as possible, then removes chunks of decreasing size (delta
+// debugging) until no single byte can be removed anymore.
+func Minimize(data []byte, fails func([]byte) bool) ([]byte, error) {
+	if !fails(data) {
+		return nil, ErrNotFailing
+	}
+
+	for step := len(data) / 2; step > 0; step /= 2 {
+		for len(data) > step && fails(data[:len(data)-step]) {
+			data = data[:len(data)-step]
+		}
+	}
+
+	for chunks := 2; len(data) > 1; {
+		if chunks > len(data) {
+			chunks = len(data)
+		}
+
+		size := (len(data) + chunks - 1) / chunks
+		reduced := false
+
+		for start := 0; start < len(data); start += size {
+			end := start + size
+			if end > len(data) {
+				end = len(data)
+			}
+
+			candidate := append(append(make([]byte, 0, len(data)-(end-start)), data[:start]...), data[end:]...)
+			if fails(candidate) {
+				data, reduced = candidate, true
+
+				break
+			}
+		}
+
+		switch {
+		case reduced:
+			if chunks > 2 {
+				chunks--
+			}
+		case chunks == len(data):
+			return data, nil
+		default:
+			chunks *= 2
+		}
+	}
+
+	return data, nil
+}
+
+// SameParseFailure returns the Minimize predicate of inputs failing to parse like data: a panic with the same value,
+// or else an error with the same cause message. Messages holding offsets or lengths rarely survive the reduction,
+// such failures are better matched on a part of their message.
+func SameParseFailure(data []byte, options ...Option) (func([]byte) bool, error) {
+	want := parseFailure(data, options)
+	if want == "" {
+		return nil, ErrNotFailing
+	}
+
+	return func(candidate []byte) bool {
+		return parseFailure(candidate, options) == want
+	}, nil
+}
+
+// parseFailure describes how data fails to parse, empty when it parses.
+func parseFailure(data []byte, options []Option) string {
+	_, err := ParseSerializedObject(data, options...)
+	if err == nil {
+		return ""
+	}
+
+	if pe, isPanic := err.(*PanicError); isPanic {
+		return "panic: " + pe.Value
+	}
+
+	return errors.Cause(err).Error()
+}
@@ -0,0 +1,174 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MISPClient creates MISP events from analyses through the MISP REST API.
+type MISPClient struct {
+	Endpoint     string // base URL of the MISP instance
+	APIKey       string
+	Distribution int // event distribution, 0 (organisation only) by default
+	Tags         []string
+	Client       *http.Client // http.DefaultClient when nil
+}
+
+// MISPAttribute is an attribute of a MISP event.
+type MISPAttribute struct {
+	Type     string `json:"type"`
+	Category string `json:"category"`
+	Value    string `json:"value"`
+	ToIDS    bool   `json:"to_ids"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type mispTag struct {
+	Name string `json:"name"`
+}
+
+type mispEvent struct {
+	ID            string          `json:"id,omitempty"`
+	UUID          string          `json:"uuid,omitempty"`
+	Info          string          `json:"info"`
+	Distribution  string          `json:"distribution"`
+	ThreatLevelID string          `json:"threat_level_id"`
+	Analysis      string          `json:"analysis"`
+	Attribute     []MISPAttribute `json:"Attribute,omitempty"`
+	Tag           []mispTag       `json:"Tag,omitempty"`
+}
+
+// NewMISPClient creates a client of the MISP instance at endpoint. When insecure is set the TLS certificate of the
+// instance is not verified, which is common for self-hosted instances.
+func NewMISPClient(endpoint, apiKey string, insecure bool) *MISPClient {
+	client := &MISPClient{Endpoint: strings.TrimRight(endpoint, "/"), APIKey: apiKey}
+
+	if insecure {
+		client.Client = &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+		}}
+	}
+
+	return client
+}
+
+// MISPAttributes derives the event attributes of an analysis: payload hash, gadget chains, class names of findings,
+// URLs, hosts and IP addresses found in the stream and a YARA rule matching it.
+func MISPAttributes(name string, analysis *Analysis) []MISPAttribute {
+	attrs := []MISPAttribute{
+		{Type: "sha256", Category: "Payload delivery", Value: analysis.SHA256, ToIDS: true, Comment: name},
+	}
+
+	chains := map[string]bool{}
+
+	for _, f := range analysis.Findings {
+		if f.Chain != "" && !chains[f.Chain] {
+			chains[f.Chain] = true
+			attrs = append(attrs, MISPAttribute{Type: "text", Category: "Other", Value: f.Chain, Comment: "gadget chain"})
+		}
+
+		if f.Class != "" {
+			attrs = append(attrs, MISPAttribute{Type: "text", Category: "Payload delivery", Value: f.Class, Comment: f.RuleID})
+		}
+	}
+
+	for _, ioc := range analysis.IOCs {
+		attr := MISPAttribute{Category: "Network activity", Value: ioc.Value, ToIDS: true}
+
+		switch ioc.Type {
+		case IOCURL:
+			attr.Type = "url"
+		case IOCHost:
+			attr.Type = "hostname"
+		case IOCIP:
+			attr.Type = "ip-dst"
+		case IOCPath:
+			attr.Type, attr.Category, attr.ToIDS = "filename", "Artifacts dropped", false
+		}
+
+		attrs = append(attrs, attr)
+	}
+
+	attrs = append(attrs, MISPAttribute{
+		Type: "yara", Category: "Payload installation", Value: GenerateYaraRule(name, analysis), ToIDS: true,
+	})
+
+	return attrs
+}
+
+// mispThreatLevel maps the verdict of an analysis to a MISP threat level (1 high, 2 medium, 3 low, 4 undefined).
+func mispThreatLevel(analysis *Analysis) string {
+	switch analysis.Verdict() {
+	case "malicious":
+		return "1"
+	case "suspicious":
+		return "2"
+	}
+
+	return "3"
+}
+
+// SubmitEvent creates a MISP event for the analysis of a sample and returns the id of the created event.
+func (this *MISPClient) SubmitEvent(ctx context.Context, name string, analysis *Analysis) (string, error) {
+	event := mispEvent{
+		Info:          "Java serialized payload " + name,
+		Distribution:  strconv.Itoa(this.Distribution),
+		ThreatLevelID: mispThreatLevel(analysis),
+		Analysis:      "2", // completed
+		Attribute:     MISPAttributes(name, analysis),
+	}
+
+	for _, tag := range this.Tags {
+		event.Tag = append(event.Tag, mispTag{Name: tag})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"Event": event})
+	if err != nil {
+		return "", errors.Wrap(err, "encode event")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, this.Endpoint+"/events/add", bytes.NewReader(body))
+	if err != nil {
+		return "", errors.Wrap(err, "create request")
+	}
+
+	req.Header.Set("Authorization", this.APIKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := this.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "submit event")
+	}
+
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode >= 300 {
+		return "", errors.Errorf("submit event: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var created struct {
+		Event mispEvent `json:"Event"`
+	}
+
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return "", errors.Wrap(err, "decode created event")
+	}
+
+	return created.Event.ID, nil
+}
@@ -3,6 +3,10 @@ package pkg
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"io"
+
+	"golang.org/x/text/encoding"
 )
 
 // 流中子对象
@@ -25,6 +29,7 @@ type Smooth struct {
 // see: https://docs.oracle.com/javase/8/docs/platform/serialization/spec/protocol.html
 type SerializedObjectParser struct {
 	buf                    bytes.Buffer
+	src                    *countingReader
 	rd                     *bufio.Reader
 	handles                []interface{}
 	maxDataBlockSize       int
@@ -32,7 +37,44 @@ type SerializedObjectParser struct {
 	_indent                string
 	_classDataDescriptions []*ClassDataDesc
 	_data                  Smooth
-	so                     *SerObject // 序列化对象
+	so                     *SerObject                   // 序列化对象
+	interner               *Interner                    // shared string table, see SetInterner
+	classCache             *ClassCache                  // shared class descriptor cache, see SetClassCache
+	classCacheSeen         map[string]bool              // class layouts already counted for this stream
+	stopAfterFirst         bool                         // see StopAfterFirstObject
+	baseOffset             int64                        // see ResumeAt
+	headerRead             bool                         // the stream header has been consumed
+	parsedEnd              int64                        // offset after the last complete top-level element
+	handleFloor            int                          // handles assigned before the last TC_RESET
+	splitOnReset           bool                         // see SplitOnReset
+	segments               []*Segment                   // see Segments
+	segmentOpen            bool                         // the last segment has had no TC_RESET since
+	unknownClasses         []string                     // see UnknownClasses
+	skipCustomData         bool                         // the object being decoded is of an unknown class
+	charset                encoding.Encoding            // see SetCharset
+	classCharsets          map[string]encoding.Encoding // see SetClassCharset
+	annotationClasses      []string                     // classes whose annotations are being dumped
+	relocations            *Relocations                 // see SetRelocations
+	proguard               *ProGuardMapping             // see SetProGuardMapping
+	classPath              *ClassPath                   // see SetClassPath
+	protocol               ProtocolInfo                 // see Protocol
+	memoryCap              int64                        // see SetMemoryCap
+	ctx                    context.Context              // see SetContext
+	input                  []byte                       // whole input when parsing a buffer, see PanicError
+	dumpOut                io.Writer                    // see SetDumpWriter
+	maxDumpBytes           int                          // see SetMaxDumpBytes
+	explain                bool                         // see SetDumpExplain
+	bufferSize             int                          // see SetBufferSize
+	handleBase             int                          // see SetInitialHandle
+	indentUnit             string                       // see SetDumpIndent
+	strict                 bool                         // see Strict
+	postProcs              map[string]PostProc          // see SetPostProcs
+	objectPostProcsOff     bool                         // see SetPostProcs
+	primitiveOverrides     map[string]primitiveHandler  // see SetPrimitiveHandler
+	warnings               []Warning                    // see Warnings
+	warningHandler         func(w Warning)              // see WithWarningHandler
+	classUIDs              map[string]string            // serialVersionUID of the first descriptor of each class
+	classHooks             map[string]ClassHooks        // see WithClassHooks
 }
 
 const bufferSize = 1024
@@ -44,7 +86,7 @@ func NewSerializationDumper() *SerializedObjectParser {
 
 		_data:                  Smooth{data: []byte{}},
 		_classDataDescriptions: []*ClassDataDesc{},
-		_handleValue:           0x7e0000,
+		_handleValue:           baseWireHandle,
 		so:                     &SerObject{},
 	}
 	sop._data._p = sop
@@ -0,0 +1,250 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Mutation kinds of a Mutator.
+const (
+	MutateFlags     = "flags"     // flip a flag of a class descriptor
+	MutateUID       = "uid"       // swap the serialVersionUID of a class descriptor with another, or randomize it
+	MutateArray     = "array"     // resize a primitive array, its elements cut or extended with random values
+	MutateBlockData = "blockdata" // truncate a block data segment
+)
+
+// maxArrayGrowth bounds the elements added to an array by a mutation.
+const maxArrayGrowth = 4096
+
+// mutationFlags are the class descriptor flags flipped by MutateFlags.
+var mutationFlags = []struct {
+	flag byte
+	name string
+}{
+	{SC_WRITE_METHOD, "SC_WRITE_METHOD"},
+	{SC_SERIALIZABLE, "SC_SERIALIZABLE"},
+	{SC_EXTERNALIZABLE, "SC_EXTERNALIZABLE"},
+	{SC_BLOCK_DATA, "SC_BLOCK_DATA"},
+	{SC_ENUM, "SC_ENUM"},
+}
+
+// Mutation describes a variant produced by a Mutator, or an edit of Defuse.
+type Mutation struct {
+	Kind   string `json:"kind"`
+	Offset int    `json:"offset"` // offset of the mutated structure in the original stream
+	Detail string `json:"detail"`
+}
+
+func (m Mutation) String() string {
+	return fmt.Sprintf("%s at offset %d: %s", m.Kind, m.Offset, m.Detail)
+}
+
+// mutationSites records the structures of a stream a Mutator can change while an estimator walks it.
+type mutationSites struct {
+	classes []classSite
+	arrays  []arraySite
+	blocks  []blockSite
+}
+
+type classSite struct {
+	name       string
+	uid, flags int // offsets of the serialVersionUID and flags
+}
+
+type arraySite struct {
+	name     string
+	size     int // offset of the size
+	elemSize int
+	n        int
+}
+
+type blockSite struct {
+	start int // offset of the TC_BLOCKDATA or TC_BLOCKDATALONG byte
+	long  bool
+	n     int
+}
+
+func (this *mutationSites) class(name string, uid, flags int) {
+	this.classes = append(this.classes, classSite{name: name, uid: uid, flags: flags})
+}
+
+func (this *mutationSites) array(name string, size, elemSize, n int) {
+	this.arrays = append(this.arrays, arraySite{name: name, size: size, elemSize: elemSize, n: n})
+}
+
+func (this *mutationSites) blockData(start int, long bool, n int) {
+	if n > 0 {
+		this.blocks = append(this.blocks, blockSite{start: start, long: long, n: n})
+	}
+}
+
+// Mutator generates structure-aware variants of a valid stream for robustness testing: each variant changes a
+// single class descriptor, primitive array or block data segment, keeping the stream mostly well-formed.
+type Mutator struct {
+	data  []byte
+	sites *mutationSites
+	kinds []string
+	rnd   *rand.Rand
+}
+
+// MutationKinds returns the mutation kinds.
+func MutationKinds() []string {
+	return []string{MutateFlags, MutateUID, MutateArray, MutateBlockData}
+}
+
+// NewMutator walks a stream for the structures to mutate, with the given kinds (all when none) and a seed making the
+// variants reproducible. Streams which Estimate cannot walk completely are refused.
+func NewMutator(data []byte, seed int64, kinds ...string) (*Mutator, error) {
+	sites := &mutationSites{}
+	walker := &estimator{b: data, res: &SizeEstimate{}, sites: sites}
+
+	if err := walker.walk(); err != nil {
+		return nil, err
+	}
+
+	if len(kinds) == 0 {
+		kinds = MutationKinds()
+	}
+
+	this := &Mutator{data: data, sites: sites, rnd: rand.New(rand.NewSource(seed))}
+
+	for _, kind := range kinds {
+		var count int
+
+		switch kind {
+		case MutateFlags, MutateUID:
+			count = len(sites.classes)
+		case MutateArray:
+			count = len(sites.arrays)
+		case MutateBlockData:
+			count = len(sites.blocks)
+		default:
+			return nil, errors.Errorf("unknown mutation kind '%s', want one of %s", kind,
+				strings.Join(MutationKinds(), ", "))
+		}
+
+		if count > 0 {
+			this.kinds = append(this.kinds, kind)
+		}
+	}
+
+	if len(this.kinds) == 0 {
+		return nil, errors.New("nothing to mutate in the stream")
+	}
+
+	return this, nil
+}
+
+// Mutate returns a new variant of the stream.
+func (this *Mutator) Mutate() ([]byte, Mutation) {
+	switch this.kinds[this.rnd.Intn(len(this.kinds))] {
+	case MutateFlags:
+		return this.flags()
+	case MutateUID:
+		return this.uid()
+	case MutateArray:
+		return this.array()
+	default:
+		return this.blockData()
+	}
+}
+
+func (this *Mutator) flags() ([]byte, Mutation) {
+	site := this.sites.classes[this.rnd.Intn(len(this.sites.classes))]
+
+	flag := mutationFlags[this.rnd.Intn(len(mutationFlags))]
+	res := append([]byte(nil), this.data...)
+	res[site.flags] ^= flag.flag
+
+	return res, Mutation{Kind: MutateFlags, Offset: site.flags,
+		Detail: fmt.Sprintf("%s flags %#02x -> %#02x (%s flipped)", site.name, this.data[site.flags],
+			res[site.flags], flag.name)}
+}
+
+func (this *Mutator) uid() ([]byte, Mutation) {
+	classes := this.sites.classes
+	site := classes[this.rnd.Intn(len(classes))]
+	res := append([]byte(nil), this.data...)
+	uid := res[site.uid : site.uid+8]
+
+	var detail string
+
+	if other := classes[this.rnd.Intn(len(classes))]; other.name != site.name &&
+		string(this.data[other.uid:other.uid+8]) != string(uid) {
+		copy(uid, this.data[other.uid:other.uid+8])
+		copy(res[other.uid:other.uid+8], this.data[site.uid:site.uid+8])
+		detail = fmt.Sprintf("serialVersionUID of %s swapped with %s", site.name, other.name)
+	} else {
+		this.rnd.Read(uid)
+		detail = fmt.Sprintf("serialVersionUID of %s set to %x", site.name, uid)
+	}
+
+	return res, Mutation{Kind: MutateUID, Offset: site.uid, Detail: detail}
+}
+
+func (this *Mutator) array() ([]byte, Mutation) {
+	site := this.sites.arrays[this.rnd.Intn(len(this.sites.arrays))]
+
+	var n int
+
+	switch this.rnd.Intn(4) {
+	case 0:
+		n = 0
+	case 1:
+		n = site.n / 2
+	case 2:
+		n = site.n + 1
+	default:
+		n = site.n + 1 + this.rnd.Intn(maxArrayGrowth)
+	}
+
+	data := site.size + 4
+	end := data + site.n*site.elemSize
+
+	res := make([]byte, 0, len(this.data)-site.n*site.elemSize+n*site.elemSize)
+	res = append(res, this.data[:site.size]...)
+	res = append(res, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(res[site.size:], uint32(n))
+
+	if n <= site.n {
+		res = append(res, this.data[data:data+n*site.elemSize]...)
+	} else {
+		res = append(res, this.data[data:end]...)
+		grown := make([]byte, (n-site.n)*site.elemSize)
+		this.rnd.Read(grown)
+		res = append(res, grown...)
+	}
+
+	res = append(res, this.data[end:]...)
+
+	return res, Mutation{Kind: MutateArray, Offset: site.size,
+		Detail: fmt.Sprintf("%s resized from %d to %d elements", site.name, site.n, n)}
+}
+
+func (this *Mutator) blockData() ([]byte, Mutation) {
+	site := this.sites.blocks[this.rnd.Intn(len(this.sites.blocks))]
+	n := this.rnd.Intn(site.n)
+
+	header := 2
+	if site.long {
+		header = 5
+	}
+
+	res := make([]byte, 0, len(this.data)-(site.n-n))
+	res = append(res, this.data[:site.start+header+n]...)
+
+	if site.long {
+		binary.BigEndian.PutUint32(res[site.start+1:], uint32(n))
+	} else {
+		res[site.start+1] = byte(n)
+	}
+
+	res = append(res, this.data[site.start+header+site.n:]...)
+
+	return res, Mutation{Kind: MutateBlockData, Offset: site.start,
+		Detail: fmt.Sprintf("block data truncated from %d to %d bytes", site.n, n)}
+}
@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+)
+
+func init() {
+	// Inet4Address is written as a plain InetAddress by its writeReplace method
+	KnownPostProcs["java.net.InetAddress@2d9b57af9fe3ebdb"] = inetAddressPostProc
+	KnownPostProcs["java.net.InetSocketAddress@467194616ff9aa45"] = inetSocketAddressPostProc
+	objectPostProcs["java.net.Inet6Address@5f7c2081522c8021"] = inet6AddressPostProc
+}
+
+// inetAddress builds the value of an InetAddress, the host name being left out when it was not resolved.
+func inetAddress(hostName interface{}, ip net.IP) map[string]interface{} {
+	value := map[string]interface{}{}
+
+	if ip != nil {
+		value["address"] = ip.String()
+	}
+
+	if name, isString := hostName.(string); isString && name != "" {
+		value["hostName"] = name
+	}
+
+	return value
+}
+
+// inetAddressPostProc decodes the serial fields of java.net.InetAddress (hostName, address, family) written from
+// its holder by writeObject. The address int only holds IPv4 addresses, Inet6Address fills its own fields.
+func inetAddressPostProc(fields map[string]interface{}, _ []interface{}) (map[string]interface{}, error) {
+	var ip net.IP
+
+	if address, isInt := fields["address"].(int32); isInt && fields["family"] != int32(2) {
+		ip = make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, uint32(address))
+	}
+
+	fields["value"] = inetAddress(fields["hostName"], ip)
+
+	return fields, nil
+}
+
+// inet6AddressPostProc decodes the ipaddress bytes and scope of a java.net.Inet6Address.
+func inet6AddressPostProc(obj map[string]interface{}) {
+	raw, isArray := obj["ipaddress"].([]interface{})
+	if !isArray || len(raw) != net.IPv6len {
+		return
+	}
+
+	ip := make(net.IP, net.IPv6len)
+
+	for i, b := range raw {
+		if v, isByte := b.(int8); isByte {
+			ip[i] = byte(v)
+		}
+	}
+
+	value := inetAddress(obj["hostName"], ip)
+
+	if ifname, isString := obj["ifname"].(string); isString && ifname != "" {
+		value["address"] = ip.String() + "%" + ifname
+	} else if set, _ := obj["scope_id_set"].(bool); set {
+		if id, isInt := obj["scope_id"].(int32); isInt {
+			value["address"] = ip.String() + "%" + strconv.Itoa(int(id))
+		}
+	}
+
+	obj["value"] = value
+}
+
+// inetSocketAddressPostProc decodes the serial fields of java.net.InetSocketAddress (hostname, addr, port).
+func inetSocketAddressPostProc(fields map[string]interface{}, _ []interface{}) (map[string]interface{}, error) {
+	value := map[string]interface{}{}
+
+	if addr, isMap := fields["addr"].(map[string]interface{}); isMap {
+		if inet, isInet := addr["value"].(map[string]interface{}); isInet {
+			for k, v := range inet {
+				value[k] = v
+			}
+		}
+	}
+
+	if hostName, isString := fields["hostname"].(string); isString && hostName != "" {
+		value["hostName"] = hostName
+	}
+
+	if port, isInt := fields["port"].(int32); isInt {
+		value["port"] = port
+	}
+
+	fields["value"] = value
+
+	return fields, nil
+}
@@ -0,0 +1,203 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	openWireWireFormatInfo byte = 1
+	openWireObjectMessage  byte = 26 // ActiveMQObjectMessage data structure type
+
+	// maxOpenWireFrame bounds frame sizes accepted while walking size-prefixed OpenWire traffic.
+	maxOpenWireFrame = 64 << 20
+)
+
+// openWireDestinationTypes maps ActiveMQDestination data structure types to their URI scheme.
+var openWireDestinationTypes = map[byte]string{
+	100: "queue",
+	101: "topic",
+	102: "temp-queue",
+	103: "temp-topic",
+}
+
+// OpenWireMessage is an ObjectMessage body found in OpenWire traffic or in a broker journal.
+// Metadata is recovered on a best effort basis, an empty field means it could not be located.
+type OpenWireMessage struct {
+	Offset      int           `json:"offset"`         // offset of the frame (or of the body when no framing was found)
+	Size        int           `json:"size,omitempty"` // frame size
+	Destination string        `json:"destination,omitempty"`
+	Timestamp   *time.Time    `json:"timestamp,omitempty"`
+	Compressed  bool          `json:"compressed"`
+	Content     []interface{} `json:"content,omitempty"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// ExtractOpenWireObjectMessages pulls ObjectMessage bodies out of captured ActiveMQ OpenWire traffic (size-prefixed
+// commands starting with a WireFormatInfo) and parses them. Input which is not OpenWire framed, such as KahaDB journal
+// files, is scanned for serialized bodies instead, using the bytes preceding a body for metadata.
+func ExtractOpenWireObjectMessages(data []byte, options ...Option) (_ []*OpenWireMessage, err error) {
+	defer recoverPanic(&err, data, -1)
+
+	if isOpenWireFramed(data) {
+		return extractOpenWireFrames(data, options)
+	}
+
+	var msgs []*OpenWireMessage
+
+	magic := []byte{STREAM_MAGIC1, STREAM_MAGIC2, 0x00, STREAM_VERSION}
+
+	for offset := 0; ; {
+		idx := bytes.Index(data[offset:], magic)
+		if idx < 0 {
+			break
+		}
+
+		start := offset + idx
+		headerStart := start - 1024
+
+		if headerStart < 0 {
+			headerStart = 0
+		}
+
+		msg := &OpenWireMessage{Offset: start}
+		openWireMetadata(msg, data[headerStart:start])
+
+		content, n, err := ParseFirst(data[start:], options...)
+		if err != nil {
+			msg.Error = err.Error()
+		}
+
+		if content != nil {
+			msg.Content = []interface{}{content}
+		}
+
+		msgs = append(msgs, msg)
+
+		if n < len(magic) {
+			n = len(magic)
+		}
+
+		offset = start + n
+	}
+
+	if len(msgs) == 0 {
+		return nil, errors.New("no OpenWire object message found")
+	}
+
+	return msgs, nil
+}
+
+// isOpenWireFramed checks whether data starts with a size-prefixed WireFormatInfo command.
+func isOpenWireFramed(data []byte) bool {
+	return len(data) > 13 && data[4] == openWireWireFormatInfo && bytes.Equal(data[5:13], []byte("ActiveMQ"))
+}
+
+// extractOpenWireFrames walks size-prefixed OpenWire commands and extracts every ObjectMessage.
+func extractOpenWireFrames(data []byte, options []Option) (msgs []*OpenWireMessage, err error) {
+	for offset := 0; offset+5 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[offset:]))
+		if size <= 0 || size > maxOpenWireFrame || offset+4+size > len(data) {
+			return msgs, errors.Errorf("invalid OpenWire frame size %d at offset %d", size, offset)
+		}
+
+		frame := data[offset+4 : offset+4+size]
+
+		if frame[0] == openWireObjectMessage {
+			msg := &OpenWireMessage{Offset: offset, Size: size}
+			body := openWireBody(msg, frame[1:])
+			openWireMetadata(msg, frame[1:])
+
+			if body == nil {
+				msg.Error = "no serialized body in object message"
+			} else if msg.Content, err = ParseSerializedObject(body, options...); err != nil {
+				msg.Error = err.Error()
+				err = nil
+			}
+
+			msgs = append(msgs, msg)
+		}
+
+		offset += 4 + size
+	}
+
+	return msgs, nil
+}
+
+// openWireBody locates the serialized body of an ObjectMessage, inflating it when the message is compressed.
+func openWireBody(msg *OpenWireMessage, frame []byte) []byte {
+	if idx := bytes.Index(frame, []byte{STREAM_MAGIC1, STREAM_MAGIC2, 0x00, STREAM_VERSION}); idx >= 0 {
+		return frame[idx:]
+	}
+
+	// compressed bodies are zlib streams
+	for idx := 0; idx+2 <= len(frame); idx++ {
+		if frame[idx] != 0x78 || (uint16(frame[idx])<<8|uint16(frame[idx+1]))%31 != 0 {
+			continue
+		}
+
+		zr, err := zlib.NewReader(bytes.NewReader(frame[idx:]))
+		if err != nil {
+			continue
+		}
+
+		body, err := io.ReadAll(io.LimitReader(zr, maxOpenWireFrame))
+		if (err == nil || err == io.ErrUnexpectedEOF) && bytes.HasPrefix(body, []byte{STREAM_MAGIC1, STREAM_MAGIC2}) {
+			msg.Compressed = true
+
+			return body
+		}
+	}
+
+	return nil
+}
+
+// openWireMetadata recovers the destination and timestamp of a message from its marshalled header fields:
+// a destination is a present flag, a destination type and a present flag followed by a UTF string,
+// the first big-endian long after the destination holding a plausible epoch in milliseconds is taken as the timestamp.
+func openWireMetadata(msg *OpenWireMessage, header []byte) {
+	tsStart := 0
+
+	for i := 0; i+5 <= len(header) && msg.Destination == ""; i++ {
+		scheme, isDest := openWireDestinationTypes[header[i+1]]
+		if header[i] != 1 || !isDest || header[i+2] != 1 {
+			continue
+		}
+
+		l := int(binary.BigEndian.Uint16(header[i+3:]))
+		if l == 0 || i+5+l > len(header) || !isPrintable(header[i+5:i+5+l]) {
+			continue
+		}
+
+		msg.Destination = scheme + "://" + string(header[i+5:i+5+l])
+		tsStart = i + 5 + l
+	}
+
+	minTs := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+	maxTs := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+
+	for i := tsStart; i+8 <= len(header); i++ {
+		if ts := int64(binary.BigEndian.Uint64(header[i:])); ts > minTs && ts < maxTs {
+			t := time.Unix(0, ts*int64(time.Millisecond)).UTC()
+			msg.Timestamp = &t
+
+			return
+		}
+	}
+}
+
+// isPrintable checks whether b only holds printable ASCII characters.
+func isPrintable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c >= 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
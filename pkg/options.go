@@ -0,0 +1,180 @@
+package pkg
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultIndent is the indentation unit of the dump.
+const defaultIndent = "  "
+
+// SetBufferSize sets the size of the read buffer, which is also the default SetMaxDataBlockSize of the parsers not
+// created from a buffer.
+func SetBufferSize(size int) Option {
+	return func(this *SerializedObjectParser) {
+		this.bufferSize = size
+	}
+}
+
+// SetInitialHandle sets the first wire handle, 0x7E0000 in every stream written by ObjectOutputStream. Streams of
+// patched or alternative serializers assign another range.
+func SetInitialHandle(handle int) Option {
+	return func(this *SerializedObjectParser) {
+		this.handleBase = handle
+		this._handleValue = handle
+	}
+}
+
+// SetDumpIndent sets the indentation unit of DumpSerializedObject, two spaces by default.
+func SetDumpIndent(indent string) Option {
+	return func(this *SerializedObjectParser) {
+		this.indentUnit = indent
+	}
+}
+
+// Strict fails on the anomalies the parser otherwise tolerates: a reference to a handle not yet assigned is an error
+// instead of a null value.
+func Strict() Option {
+	return func(this *SerializedObjectParser) {
+		this.strict = true
+	}
+}
+
+// SetPostProcs replaces KnownPostProcs by procs for the parser and disables the built-in object post-processors, an
+// empty map leaving the parsed values as they are in the stream.
+func SetPostProcs(procs map[string]PostProc) Option {
+	return func(this *SerializedObjectParser) {
+		this.postProcs = make(map[string]PostProc, len(procs))
+		for signature, proc := range procs {
+			this.postProcs[signature] = proc
+		}
+
+		this.objectPostProcsOff = true
+	}
+}
+
+// SetPrimitiveHandler overrides the reader of the field values and array elements of a type code ('I', 'L'...), read
+// reads the value at the position of the parser. The overrides are not shared through a ClassCache.
+func SetPrimitiveHandler(typeCode byte, read func(sop *SerializedObjectParser) (interface{}, error)) Option {
+	return func(this *SerializedObjectParser) {
+		if this.primitiveOverrides == nil {
+			this.primitiveOverrides = map[string]primitiveHandler{}
+		}
+
+		this.primitiveOverrides[string(typeCode)] = read
+	}
+}
+
+// OptionValues is a snapshot of the settings of a parser.
+type OptionValues struct {
+	BufferSize           int      `json:"bufferSize"`
+	MaxDataBlockSize     int      `json:"maxDataBlockSize"`
+	InitialHandle        int      `json:"initialHandle"`
+	DumpIndent           string   `json:"dumpIndent"`
+	MaxDumpBytes         int      `json:"maxDumpBytes"`
+	Strict               bool     `json:"strict"`
+	MemoryCap            int64    `json:"memoryCap"`
+	StopAfterFirstObject bool     `json:"stopAfterFirstObject"`
+	SplitOnReset         bool     `json:"splitOnReset"`
+	KeepAnnotationBytes  bool     `json:"keepAnnotationBytes"`
+	PostProcs            []string `json:"postProcs"`          // signatures of the annotation post-processors
+	ObjectPostProcs      bool     `json:"objectPostProcs"`    // the built-in object post-processors run
+	PrimitiveOverrides   []string `json:"primitiveOverrides"` // type codes read by SetPrimitiveHandler overrides
+	UnknownClasses       []string `json:"unknownClasses"`     // see UnknownClasses
+}
+
+// DefaultOptions returns the settings of a parser created without options.
+func DefaultOptions() OptionValues {
+	return NewSerializedObjectParser(nil).Options()
+}
+
+// Options returns the settings of the parser.
+func (this *SerializedObjectParser) Options() OptionValues {
+	res := OptionValues{
+		BufferSize:           this.bufferSize,
+		MaxDataBlockSize:     this.maxDataBlockSize,
+		InitialHandle:        this.handleBase,
+		DumpIndent:           this.indent(),
+		MaxDumpBytes:         this.maxDumpBytes,
+		Strict:               this.strict,
+		MemoryCap:            this.memoryCap,
+		StopAfterFirstObject: this.stopAfterFirst,
+		SplitOnReset:         this.splitOnReset,
+		KeepAnnotationBytes:  this.src != nil && this.src.recording,
+		PostProcs:            []string{},
+		ObjectPostProcs:      !this.objectPostProcsOff,
+		PrimitiveOverrides:   []string{},
+		UnknownClasses:       append([]string{}, this.unknownClasses...),
+	}
+
+	procs := this.postProcs
+	if procs == nil {
+		pluginsMu.Lock()
+		defer pluginsMu.Unlock()
+
+		procs = KnownPostProcs
+	}
+
+	for signature := range procs {
+		res.PostProcs = append(res.PostProcs, signature)
+	}
+
+	for typeCode := range this.primitiveOverrides {
+		res.PrimitiveOverrides = append(res.PrimitiveOverrides, typeCode)
+	}
+
+	sort.Strings(res.PostProcs)
+	sort.Strings(res.PrimitiveOverrides)
+
+	return res
+}
+
+func (this *SerializedObjectParser) indent() string {
+	if this.indentUnit == "" {
+		return defaultIndent
+	}
+
+	return this.indentUnit
+}
+
+// postProc returns the annotation post-processor of a class signature, see SetPostProcs.
+func (this *SerializedObjectParser) postProc(signature string) (PostProc, bool) {
+	if this.postProcs != nil {
+		proc, exists := this.postProcs[signature]
+
+		return proc, exists
+	}
+
+	proc, exists := KnownPostProcs[signature]
+
+	return proc, exists
+}
+
+// objectPostProc returns the built-in object post-processor of a class, see SetPostProcs.
+func (this *SerializedObjectParser) objectPostProc(cls *clazz) (func(obj map[string]interface{}), bool) {
+	if this.objectPostProcsOff {
+		return nil, false
+	}
+
+	if proc, exists := objectPostProcs[cls.name+"@"+cls.serialVersionUID]; exists {
+		return proc, true
+	}
+
+	proc, exists := objectPostProcs[cls.name]
+	if !exists && strings.HasSuffix(cls.name, rmiStubSuffix) {
+		proc, exists = objectPostProcs["*"+rmiStubSuffix]
+	}
+
+	return proc, exists
+}
+
+// primitiveHandler returns the reader of a type code, see SetPrimitiveHandler.
+func (this *SerializedObjectParser) primitiveHandler(typeName string) (primitiveHandler, bool) {
+	if handler, exists := this.primitiveOverrides[typeName]; exists {
+		return handler, true
+	}
+
+	handler, exists := primitiveHandlers[typeName]
+
+	return handler, exists
+}
@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// Pager is an io.WriteCloser cutting an output into numbered pages of at most MaxBytes bytes, for the storage
+// systems with object-size limits. Pages end on a line boundary, only lines longer than MaxBytes being cut; Close
+// hands the last page. A MaxBytes of 0 makes a single page.
+type Pager struct {
+	MaxBytes int
+	Page     func(n int, page []byte) error // called with the pages in order, numbered from 1
+
+	buf []byte
+	n   int
+	err error
+}
+
+// NewFileSplitter returns a Pager writing each page to a file named after pattern, a format holding the page
+// number such as "dump.%03d.txt".
+func NewFileSplitter(pattern string, maxBytes int) *Pager {
+	return &Pager{MaxBytes: maxBytes, Page: SplitFiles(pattern)}
+}
+
+// SplitFiles returns a page func writing each page to a file named after pattern, see NewFileSplitter.
+func SplitFiles(pattern string) func(n int, page []byte) error {
+	return func(n int, page []byte) error {
+		name := fmt.Sprintf(pattern, n)
+
+		return errors.Wrapf(ioutil.WriteFile(name, page, 0o644), "error writing page %s", name)
+	}
+}
+
+// Write buffers p and hands the full pages.
+func (this *Pager) Write(p []byte) (int, error) {
+	if this.err != nil {
+		return 0, this.err
+	}
+
+	this.buf = append(this.buf, p...)
+
+	for this.MaxBytes > 0 && len(this.buf) > this.MaxBytes && this.err == nil {
+		cut := bytes.LastIndexByte(this.buf[:this.MaxBytes], '\n') + 1
+		if cut == 0 {
+			cut = this.MaxBytes
+		}
+
+		this.emit(this.buf[:cut])
+		this.buf = append(this.buf[:0], this.buf[cut:]...)
+	}
+
+	return len(p), this.err
+}
+
+// Close hands the last page.
+func (this *Pager) Close() error {
+	if len(this.buf) > 0 && this.err == nil {
+		this.emit(this.buf)
+		this.buf = nil
+	}
+
+	return this.err
+}
+
+func (this *Pager) emit(page []byte) {
+	this.n++
+	this.err = this.Page(this.n, page)
+}
+
+// MarshalMinimalPages is MarshalMinimal cut into pages of at most maxBytes bytes which are valid JSON arrays on their
+// own, holding whole top-level elements: concatenating the arrays of the pages gives the content. A single element
+// larger than maxBytes makes a larger page.
+func MarshalMinimalPages(content []interface{}, maxBytes int, page func(n int, page []byte) error,
+	options ...JSONOption) error {
+	var buf bytes.Buffer
+
+	n := 0
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+
+		buf.WriteByte(']')
+		n++
+
+		err := page(n, buf.Bytes())
+		buf.Reset()
+
+		return err
+	}
+
+	for _, v := range content {
+		b, err := MarshalMinimal([]interface{}{v}, options...)
+		if err != nil {
+			return err
+		}
+
+		elem := b[1 : len(b)-1]
+
+		if buf.Len() > 0 && buf.Len()+len(elem)+2 > maxBytes {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+
+		if buf.Len() == 0 {
+			buf.WriteByte('[')
+		} else {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(elem)
+	}
+
+	if len(content) == 0 {
+		buf.WriteByte('[')
+	}
+
+	return flush()
+}
@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"runtime/debug"
+
+	"github.com/pkg/errors"
+)
+
+// ErrParserPanic is the cause of the errors returned when a public entry point recovers from a panic, which is
+// always a go-pjs bug: please attach the PanicError details, printed with %+v, to the bug report.
+var ErrParserPanic = errors.New("parser panic")
+
+// panicSnippetSize is the number of input bytes kept on each side of the panic offset.
+const panicSnippetSize = 32
+
+// PanicError is a panic recovered by a public entry point.
+type PanicError struct {
+	Value   string `json:"value"`             // recovered value
+	Offset  int64  `json:"offset"`            // input offset reached when the panic occurred, -1 when unknown
+	Snippet string `json:"snippet,omitempty"` // hex of the input around Offset, see redactSnippet
+	Stack   string `json:"stack"`
+}
+
+// Error returns the panic value, its offset and snippet, the stack is printed with %+v.
+func (this *PanicError) Error() string {
+	return fmt.Sprintf("parser panic at offset %d: %s [%s]", this.Offset, this.Value, this.Snippet)
+}
+
+// Cause returns ErrParserPanic.
+func (this *PanicError) Cause() error {
+	return ErrParserPanic
+}
+
+// Format prints the stack along with the message for %+v, like the errors of github.com/pkg/errors.
+func (this *PanicError) Format(s fmt.State, verb rune) {
+	_, _ = io.WriteString(s, this.Error())
+
+	if verb == 'v' && s.Flag('+') {
+		_, _ = io.WriteString(s, "\n"+this.Stack)
+	}
+}
+
+func newPanicError(value interface{}, input []byte, offset int64) *PanicError {
+	return &PanicError{
+		Value:   fmt.Sprint(value),
+		Offset:  offset,
+		Snippet: redactSnippet(input, offset),
+		Stack:   string(debug.Stack()),
+	}
+}
+
+// recoverPanic stores the recovered panic in *err as a PanicError, it must be deferred by the entry point itself.
+// offset is -1 when the position reached is unknown, the snippet then shows the start of the input.
+func recoverPanic(err *error, input []byte, offset int64) {
+	if r := recover(); r != nil {
+		*err = newPanicError(r, input, offset)
+	}
+}
+
+// recoverPanic is the parser counterpart of recoverPanic, using the parser input and offset.
+func (this *SerializedObjectParser) recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = newPanicError(r, this.input, this.Offset())
+	}
+}
+
+// withInput keeps the whole input of a parser reading from a buffer, for the PanicError snippets.
+func withInput(buf []byte) Option {
+	return func(this *SerializedObjectParser) {
+		this.input = buf
+	}
+}
+
+// redactSnippet returns the hex of the input bytes around offset with every run of 4 or more printable characters
+// masked by '*', so that the structure of the stream is kept but not the strings it may hold, such as credentials.
+func redactSnippet(input []byte, offset int64) string {
+	start, end := offset-panicSnippetSize, offset+panicSnippetSize
+	if offset < 0 {
+		start, end = 0, 2*panicSnippetSize
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	if end > int64(len(input)) {
+		end = int64(len(input))
+	}
+
+	if start >= end {
+		return ""
+	}
+
+	snippet := append([]byte(nil), input[start:end]...)
+
+	for i := 0; i < len(snippet); {
+		j := i
+		for j < len(snippet) && snippet[j] >= 0x20 && snippet[j] < 0x7f {
+			j++
+		}
+
+		if j-i >= 4 {
+			for k := i; k < j; k++ {
+				snippet[k] = '*'
+			}
+		}
+
+		i = j + 1
+	}
+
+	return hex.EncodeToString(snippet)
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	parcelValSerializable int32 = 21         // Parcel.VAL_SERIALIZABLE
+	bundleMagic           int32 = 0x4c444e42 // 'BNDL'
+	maxParcelString             = 1024       // longest key / class name looked for
+)
+
+// ParcelSerializable is a java.io.Serializable extra found in an Android Parcel or Bundle blob.
+type ParcelSerializable struct {
+	Offset    int           `json:"offset"` // offset of the serialized stream
+	Length    int           `json:"length"`
+	Key       string        `json:"key,omitempty"`       // Bundle key, when the extra belongs to a Bundle
+	ClassName string        `json:"className,omitempty"` // class name written by Parcel.writeSerializable
+	InBundle  bool          `json:"inBundle"`            // a Bundle header was found before the extra
+	Content   []interface{} `json:"content,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// ExtractParcelSerializables scans binder transaction dumps or Bundle blobs (little-endian Parcel data) for
+// Serializable extras and parses them. Parcel.writeSerializable writes the class name as a String16 followed by the
+// serialized bytes as a byte array, a Bundle entry prefixes it with its key and VAL_SERIALIZABLE, both are recovered
+// by walking back from the serialized stream so unknown values around the extra do not matter.
+func ExtractParcelSerializables(data []byte, options ...Option) (_ []*ParcelSerializable, err error) {
+	defer recoverPanic(&err, data, -1)
+
+	var extras []*ParcelSerializable
+
+	magic := []byte{STREAM_MAGIC1, STREAM_MAGIC2, 0x00, STREAM_VERSION}
+	bundle := make([]byte, 4)
+	binary.LittleEndian.PutUint32(bundle, uint32(bundleMagic))
+
+	for offset := 0; ; {
+		idx := bytes.Index(data[offset:], magic)
+		if idx < 0 {
+			break
+		}
+
+		extra := &ParcelSerializable{Offset: offset + idx, Length: len(data) - offset - idx}
+		offset = extra.Offset + len(magic)
+
+		// byte array length
+		if extra.Offset >= 4 {
+			if n := int(int32(binary.LittleEndian.Uint32(data[extra.Offset-4:]))); n >= len(magic) && n <= extra.Length {
+				extra.Length = n
+				offset = extra.Offset + n
+
+				if name, start, found := parcelString16Before(data, extra.Offset-4); found {
+					extra.ClassName = name
+
+					if start >= 4 && int32(binary.LittleEndian.Uint32(data[start-4:])) == parcelValSerializable {
+						extra.Key, _, _ = parcelString16Before(data, start-4)
+					}
+				}
+			}
+		}
+
+		extra.InBundle = bytes.Contains(data[:extra.Offset], bundle)
+
+		var err error
+		if extra.Content, err = ParseSerializedObject(data[extra.Offset:extra.Offset+extra.Length], options...); err != nil {
+			extra.Error = err.Error()
+		}
+
+		extras = append(extras, extra)
+	}
+
+	if len(extras) == 0 {
+		return nil, errors.New("no serializable extra found")
+	}
+
+	return extras, nil
+}
+
+// parcelString16Before decodes the Parcel String16 ending (padding included) at end: an int32 char count followed by
+// the UTF-16LE characters, a NUL terminator and padding to a multiple of 4 bytes. It returns the string and the offset
+// of its length prefix.
+func parcelString16Before(data []byte, end int) (s string, start int, found bool) {
+	for n := 1; n <= maxParcelString; n++ {
+		size := (n + 1) * 2
+		size += (4 - size%4) % 4
+		start = end - size - 4
+
+		if start < 0 {
+			return "", 0, false
+		}
+
+		if int(int32(binary.LittleEndian.Uint32(data[start:]))) != n {
+			continue
+		}
+
+		chars := make([]uint16, n)
+		printable := true
+
+		for i := range chars {
+			chars[i] = binary.LittleEndian.Uint16(data[start+4+2*i:])
+			if chars[i] < 0x20 || chars[i] == 0x7f {
+				printable = false
+
+				break
+			}
+		}
+
+		if printable && binary.LittleEndian.Uint16(data[start+4+2*n:]) == 0 {
+			return string(utf16.Decode(chars)), start, true
+		}
+	}
+
+	return "", 0, false
+}
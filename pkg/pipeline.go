@@ -0,0 +1,464 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPipelineBusy is returned by Pipeline.Submit when accepting a payload would exceed the queue or in-flight
+// limits, callers should retry later (HTTP 503).
+var ErrPipelineBusy = errors.New("pipeline busy")
+
+// ErrPipelineClosed is returned by Pipeline.Submit once the pipeline is closed.
+var ErrPipelineClosed = errors.New("pipeline closed")
+
+// ErrPayloadTooLarge is returned by Pipeline.Submit for payloads above PipelineConfig.MaxPayloadSize.
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// ErrReportTooLarge is returned by Pipeline.Submit when a report exceeds PipelineConfig.MaxReportSize.
+var ErrReportTooLarge = errors.New("report too large")
+
+// RequestError is the structured error returned to the clients of a service built on a Pipeline.
+type RequestError struct {
+	Status  int    `json:"status"` // HTTP status
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error returns the error message.
+func (this *RequestError) Error() string {
+	return this.Message
+}
+
+// AsRequestError maps an error of Pipeline.Submit to its HTTP status and a stable code: 413 for payloads over the
+// size or memory limits, 422 for payloads which cannot be analyzed within the limits, 503 when the pipeline is busy.
+func AsRequestError(err error) *RequestError {
+	if re, isRequestError := err.(*RequestError); isRequestError {
+		return re
+	}
+
+	res := &RequestError{Status: http.StatusUnprocessableEntity, Code: "analysis_failed", Message: err.Error()}
+
+	switch errors.Cause(err) {
+	case ErrPipelineBusy, ErrPipelineClosed:
+		res.Status, res.Code = http.StatusServiceUnavailable, "busy"
+	case ErrPayloadTooLarge:
+		res.Status, res.Code = http.StatusRequestEntityTooLarge, "payload_too_large"
+	case ErrMemoryCap:
+		res.Status, res.Code = http.StatusRequestEntityTooLarge, "memory_cap_exceeded"
+	case ErrReportTooLarge:
+		res.Code = "report_too_large"
+	case ErrSandboxCrashed:
+		res.Code = "sandbox_crashed"
+	case ErrParserPanic:
+		res.Code = "parser_panic"
+	case context.DeadlineExceeded:
+		res.Code = "timeout"
+	case context.Canceled:
+		res.Code = "canceled"
+	}
+
+	return res
+}
+
+// Pipeline stage names.
+const (
+	StageDecode  = "decode"
+	StageParse   = "parse"
+	StageAnalyze = "analyze"
+	StageReport  = "report"
+)
+
+// PipelineConfig configures the stages of a Pipeline, zero values select the defaults.
+type PipelineConfig struct {
+	Workers          map[string]int // concurrency per stage name, runtime.NumCPU by default
+	QueueSize        int            // jobs waiting in front of each stage, 16 by default
+	MaxInFlightBytes int64          // payload bytes accepted and not yet reported, 256 MiB by default
+	Format           OutputFormat   // report format, JSON by default
+	Options          []Option       // parser options, SetMemoryCap bounds the memory of each payload
+	Timeout          time.Duration  // time allowed to each payload, unlimited when zero
+	MaxPayloadSize   int            // size of the submitted payloads, unlimited when zero
+	MaxReportSize    int            // size of the reports, unlimited when zero
+	Sandbox          *Sandbox       // parse and analyze in child processes when set, see Sandbox
+	KeyVault         *KeyVault      // keys decrypting the payloads which are not streams once decoded, see KeyVault
+}
+
+// PipelineResult is the outcome of a submitted payload.
+type PipelineResult struct {
+	Analysis *Analysis
+	Report   []byte
+}
+
+// StageMetrics describes the load of a pipeline stage.
+type StageMetrics struct {
+	Name      string `json:"name"`
+	Workers   int    `json:"workers"`
+	Busy      int32  `json:"busy"`     // workers processing a job
+	Queued    int    `json:"queued"`   // jobs waiting for a worker
+	Capacity  int    `json:"capacity"` // queue capacity
+	Processed int64  `json:"processed"`
+	Failed    int64  `json:"failed"`
+}
+
+// PipelineMetrics describes the load of a Pipeline.
+type PipelineMetrics struct {
+	Stages        []StageMetrics `json:"stages"`
+	InFlight      int64          `json:"inFlight"`      // jobs accepted and not yet completed
+	InFlightBytes int64          `json:"inFlightBytes"` // payload bytes of the in-flight jobs
+	Accepted      int64          `json:"accepted"`
+	Rejected      int64          `json:"rejected"` // payloads refused with ErrPipelineBusy
+}
+
+// pipelineJob is a payload travelling through the stages.
+type pipelineJob struct {
+	ctx     context.Context
+	name    string
+	data    []byte
+	parsed  *parsedStream
+	result  *PipelineResult
+	err     error
+	done    chan struct{}
+	release func()
+}
+
+// pipelineStage runs a step of every job with a bounded queue and a fixed number of workers.
+type pipelineStage struct {
+	name      string
+	in        chan *pipelineJob
+	workers   int
+	run       func(*pipelineJob) error
+	next      *pipelineStage
+	wg        sync.WaitGroup
+	busy      int32
+	processed int64
+	failed    int64
+}
+
+// Pipeline decodes, parses, analyzes and reports payloads in bounded concurrent stages: each stage has its own
+// workers and queue, a full queue blocks the previous stage, and payloads are refused up front once the first queue
+// or the in-flight byte budget is full, so a burst of huge payloads cannot exhaust memory.
+type Pipeline struct {
+	config   PipelineConfig
+	stages   []*pipelineStage
+	mu       sync.RWMutex
+	closed   bool
+	inFlight int64
+	bytes    int64
+	accepted int64
+	rejected int64
+}
+
+// NewPipeline starts the workers of a pipeline, Close stops them.
+func NewPipeline(config PipelineConfig) *Pipeline {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 16
+	}
+
+	if config.MaxInFlightBytes <= 0 {
+		config.MaxInFlightBytes = 256 << 20
+	}
+
+	if config.Format == nil {
+		config.Format = jsonOutput
+	}
+
+	this := &Pipeline{config: config}
+	runs := []func(*pipelineJob) error{this.decode, this.parse, this.analyze, this.report}
+
+	for i, name := range []string{StageDecode, StageParse, StageAnalyze, StageReport} {
+		workers := config.Workers[name]
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+
+		stage := &pipelineStage{name: name, in: make(chan *pipelineJob, config.QueueSize), workers: workers, run: runs[i]}
+		if i > 0 {
+			this.stages[i-1].next = stage
+		}
+
+		this.stages = append(this.stages, stage)
+	}
+
+	for _, stage := range this.stages {
+		for i := 0; i < stage.workers; i++ {
+			stage.wg.Add(1)
+
+			go stage.work()
+		}
+	}
+
+	return this
+}
+
+func (this *pipelineStage) work() {
+	defer this.wg.Done()
+
+	for job := range this.in {
+		err := job.ctx.Err()
+		if err == nil {
+			atomic.AddInt32(&this.busy, 1)
+			err = this.runJob(job)
+			atomic.AddInt32(&this.busy, -1)
+		}
+
+		if err != nil {
+			atomic.AddInt64(&this.failed, 1)
+			job.err = err
+			job.finish()
+
+			continue
+		}
+
+		atomic.AddInt64(&this.processed, 1)
+
+		if this.next == nil {
+			job.finish()
+		} else {
+			this.next.in <- job
+		}
+	}
+}
+
+// runJob runs the step of the stage on job, a panic failing the job instead of the whole pipeline.
+func (this *pipelineStage) runJob(job *pipelineJob) (err error) {
+	defer recoverPanic(&err, job.data, -1)
+
+	return this.run(job)
+}
+
+func (this *pipelineJob) finish() {
+	this.release()
+	close(this.done)
+}
+
+// Submit queues a payload and waits for its result. It returns ErrPipelineBusy at once when the pipeline is full,
+// and the context error when ctx is done or the timeout elapses first, the parse being aborted and the job dropped
+// by the next stage it reaches. See AsRequestError for the mapping of the errors to HTTP statuses.
+func (this *Pipeline) Submit(ctx context.Context, name string, payload []byte) (*PipelineResult, error) {
+	size := int64(len(payload))
+
+	if this.config.MaxPayloadSize > 0 && len(payload) > this.config.MaxPayloadSize {
+		return nil, errors.Wrapf(ErrPayloadTooLarge, "%d bytes exceed the limit of %d", size, this.config.MaxPayloadSize)
+	}
+
+	if this.config.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, this.config.Timeout)
+		defer cancel()
+	}
+
+	// a payload larger than the budget is still accepted by an idle pipeline
+	if n := atomic.AddInt64(&this.bytes, size); n > this.config.MaxInFlightBytes && n != size {
+		atomic.AddInt64(&this.bytes, -size)
+		atomic.AddInt64(&this.rejected, 1)
+
+		return nil, ErrPipelineBusy
+	}
+
+	job := &pipelineJob{ctx: ctx, name: name, data: payload, done: make(chan struct{})}
+	job.release = func() {
+		atomic.AddInt64(&this.bytes, -size)
+		atomic.AddInt64(&this.inFlight, -1)
+	}
+
+	atomic.AddInt64(&this.inFlight, 1)
+
+	if err := this.enqueue(job); err != nil {
+		job.release()
+
+		return nil, err
+	}
+
+	select {
+	case <-job.done:
+		return job.result, job.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (this *Pipeline) enqueue(job *pipelineJob) error {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	if this.closed {
+		return ErrPipelineClosed
+	}
+
+	select {
+	case this.stages[0].in <- job:
+		atomic.AddInt64(&this.accepted, 1)
+
+		return nil
+	default:
+		atomic.AddInt64(&this.rejected, 1)
+
+		return ErrPipelineBusy
+	}
+}
+
+// Metrics returns the current load of the pipeline.
+func (this *Pipeline) Metrics() *PipelineMetrics {
+	res := &PipelineMetrics{
+		InFlight:      atomic.LoadInt64(&this.inFlight),
+		InFlightBytes: atomic.LoadInt64(&this.bytes),
+		Accepted:      atomic.LoadInt64(&this.accepted),
+		Rejected:      atomic.LoadInt64(&this.rejected),
+	}
+
+	for _, stage := range this.stages {
+		res.Stages = append(res.Stages, StageMetrics{
+			Name:      stage.name,
+			Workers:   stage.workers,
+			Busy:      atomic.LoadInt32(&stage.busy),
+			Queued:    len(stage.in),
+			Capacity:  cap(stage.in),
+			Processed: atomic.LoadInt64(&stage.processed),
+			Failed:    atomic.LoadInt64(&stage.failed),
+		})
+	}
+
+	return res
+}
+
+// Close refuses new payloads, lets the queued ones complete and stops the workers.
+func (this *Pipeline) Close() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if this.closed {
+		return
+	}
+
+	this.closed = true
+
+	for _, stage := range this.stages {
+		close(stage.in)
+		stage.wg.Wait()
+	}
+}
+
+func (this *Pipeline) decode(job *pipelineJob) (err error) {
+	if job.data, err = DecodePayload(job.data); err != nil {
+		return &RequestError{Status: http.StatusUnprocessableEntity, Code: "undecodable_payload", Message: err.Error()}
+	}
+
+	// encrypted payloads, such as rememberMe cookies, the parse stage reporting those no key decrypts
+	if this.config.KeyVault != nil && !bytes.HasPrefix(job.data, streamMagic) {
+		if plain, _, err := this.config.KeyVault.Decrypt(job.data); err == nil {
+			job.data = plain
+		}
+	}
+
+	return nil
+}
+
+func (this *Pipeline) parse(job *pipelineJob) error {
+	if this.config.Sandbox != nil {
+		analysis, err := this.config.Sandbox.Analyze(job.ctx, job.data)
+		if err != nil {
+			return err
+		}
+
+		job.result = &PipelineResult{Analysis: analysis}
+		job.data = nil
+
+		return nil
+	}
+
+	options := append([]Option{SetContext(job.ctx)}, this.config.Options...)
+
+	job.parsed = parseForAnalysis(job.data, options)
+	job.data = nil
+
+	if isAbortingParseError(job.parsed.err) {
+		return job.parsed.err
+	}
+
+	return nil
+}
+
+// isAbortingParseError tells whether a parse error fails the analysis rather than being reported in it.
+func isAbortingParseError(err error) bool {
+	switch errors.Cause(err) {
+	case ErrMemoryCap, context.DeadlineExceeded, context.Canceled:
+		return true
+	}
+
+	return false
+}
+
+func (this *Pipeline) analyze(job *pipelineJob) error {
+	// already analyzed by the sandbox
+	if job.parsed == nil {
+		return nil
+	}
+
+	if err := job.parsed.analyze(); err != nil {
+		return err
+	}
+
+	job.result = &PipelineResult{Analysis: job.parsed.res}
+	job.parsed = nil
+
+	return nil
+}
+
+func (this *Pipeline) report(job *pipelineJob) error {
+	var buf bytes.Buffer
+
+	if err := this.config.Format(&buf, job.name, job.result.Analysis); err != nil {
+		return err
+	}
+
+	if this.config.MaxReportSize > 0 && buf.Len() > this.config.MaxReportSize {
+		return errors.Wrapf(ErrReportTooLarge, "%d bytes exceed the limit of %d", buf.Len(), this.config.MaxReportSize)
+	}
+
+	job.result.Report = buf.Bytes()
+
+	return nil
+}
+
+// DecodePayload returns the serialized stream of a payload submitted raw, gzipped, base64 (rO0AB...) or hex
+// (aced0005...) encoded, surrounding whitespace ignored.
+func DecodePayload(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "error decoding gzip payload")
+		}
+
+		if data, err = ioutil.ReadAll(io.LimitReader(gz, maxArchiveEntrySize)); err != nil {
+			return nil, errors.Wrap(err, "error decoding gzip payload")
+		}
+	}
+
+	text := bytes.TrimSpace(data)
+
+	switch {
+	case bytes.HasPrefix(text, []byte("rO0AB")):
+		b, err := base64.StdEncoding.DecodeString(string(text))
+
+		return b, errors.Wrap(err, "error decoding base64 payload")
+	case bytes.HasPrefix(bytes.ToLower(text), []byte("aced0005")):
+		b, err := hex.DecodeString(string(text))
+
+		return b, errors.Wrap(err, "error decoding hex payload")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,150 @@
+// Package pjstest lets the projects embedding go-pjs regression-test their own payload corpora against parser
+// upgrades: each payload of a corpus is parsed to a golden file which later runs compare against.
+package pjstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hktalent/go-pjs/pkg"
+)
+
+// UpdateEnv is the environment variable which, set to a non-empty value, makes CompareGolden rewrite the golden
+// files instead of comparing them, e.g. PJS_UPDATE_GOLDEN=1 go test ./...
+const UpdateEnv = "PJS_UPDATE_GOLDEN"
+
+// GoldenExt is the extension of the golden files.
+const GoldenExt = ".golden"
+
+// golden is the content of a golden file.
+type golden struct {
+	Content json.RawMessage `json:"content"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// ParseToGolden parses a payload to its golden output: the byte-stable minimal JSON of the parsed content (see
+// pkg.MarshalMinimal) and the parse error, if any, indented for readable diffs.
+func ParseToGolden(data []byte, options ...pkg.Option) ([]byte, error) {
+	var res golden
+
+	content, err := pkg.ParseSerializedObject(data, options...)
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	if res.Content, err = pkg.MarshalMinimal(content); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	if err = enc.Encode(&res); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// CompareGolden parses every payload of corpusDir and compares its golden output with the file of the same name plus
+// GoldenExt in goldenDir, corpusDir when empty. Every payload runs as a subtest failing on the first differing
+// line. With UpdateEnv set the golden files are written instead.
+func CompareGolden(t testing.TB, corpusDir, goldenDir string, options ...pkg.Option) {
+	t.Helper()
+
+	if goldenDir == "" {
+		goldenDir = corpusDir
+	}
+
+	files, err := ioutil.ReadDir(corpusDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := os.Getenv(UpdateEnv) != ""
+
+	for _, fi := range files {
+		if fi.IsDir() || strings.HasSuffix(fi.Name(), GoldenExt) {
+			continue
+		}
+
+		name := fi.Name()
+
+		run(t, name, func(t testing.TB) {
+			data, err := ioutil.ReadFile(filepath.Join(corpusDir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ParseToGolden(data, options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(goldenDir, name+GoldenExt)
+
+			if update {
+				if err = ioutil.WriteFile(path, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			want, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v, run with %s=1 to create it", err, UpdateEnv)
+			}
+
+			if diff := firstDiff(want, got); diff != "" {
+				t.Errorf("%s differs from %s: %s", name, path, diff)
+			}
+		})
+	}
+}
+
+// run runs fn as a subtest when t supports them.
+func run(t testing.TB, name string, fn func(t testing.TB)) {
+	switch tt := t.(type) {
+	case *testing.T:
+		tt.Run(name, func(t *testing.T) { fn(t) })
+	case *testing.B:
+		tt.Run(name, func(b *testing.B) { fn(b) })
+	default:
+		fn(t)
+	}
+}
+
+// firstDiff describes the first differing line of two golden outputs, empty when they are equal.
+func firstDiff(want, got []byte) string {
+	if bytes.Equal(want, got) {
+		return ""
+	}
+
+	wantLines, gotLines := strings.Split(string(want), "\n"), strings.Split(string(got), "\n")
+
+	for i := 0; ; i++ {
+		var w, g string
+
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+
+		if w != g || i >= len(wantLines) || i >= len(gotLines) {
+			return fmt.Sprintf("line %d: want %q, got %q", i+1, w, g)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package pjstest
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hktalent/go-pjs/pkg"
+)
+
+// JavaEnv is the environment variable naming the java command of CompareJVM, java from the PATH by default.
+const JavaEnv = "PJS_JAVA"
+
+// CompareJVM reads every payload of corpusDir with ObjectInputStream in a helper JVM (see pkg.StartJVM) and compares
+// the result with the parse of go-pjs, every payload running as a subtest failing on its divergences (see
+// pkg.JVMDivergences). It skips when no java command is found.
+func CompareJVM(t testing.TB, corpusDir string, options ...pkg.Option) {
+	t.Helper()
+
+	java := os.Getenv(JavaEnv)
+	if java == "" {
+		var err error
+
+		if java, err = exec.LookPath("java"); err != nil {
+			t.Skipf("no java command, set %s to run the JVM comparison", JavaEnv)
+		}
+	}
+
+	files, err := ioutil.ReadDir(corpusDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jvm, err := pkg.StartJVM(java)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = jvm.Close() }()
+
+	for _, fi := range files {
+		if fi.IsDir() || strings.HasSuffix(fi.Name(), GoldenExt) {
+			continue
+		}
+
+		name := fi.Name()
+
+		run(t, name, func(t testing.TB) {
+			data, err := ioutil.ReadFile(filepath.Join(corpusDir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := jvm.Deserialize(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, divergence := range pkg.JVMDivergences(data, res, options...) {
+				t.Errorf("%s: %s", name, divergence)
+			}
+		})
+	}
+}
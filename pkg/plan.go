@@ -0,0 +1,64 @@
+package pkg
+
+import "github.com/pkg/errors"
+
+// decodePlan reads the field values of a class layout with the primitive handlers resolved once, instead of looking
+// them up for every field of every instance. A plan is compiled the first time a layout is met, and reused by the
+// instances of the descriptor through the handles of the stream and by the other parsers through the ClassCache.
+type decodePlan struct {
+	names    []string
+	handlers []primitiveHandler
+}
+
+// compileDecodePlan resolves the handlers of fields, overrides taking precedence over primitiveHandlers. It fails on
+// an unknown field type.
+func compileDecodePlan(fields []*field, overrides map[string]primitiveHandler) (*decodePlan, error) {
+	plan := &decodePlan{}
+
+	for _, f := range fields {
+		if f == nil {
+			continue
+		}
+
+		handler, exists := overrides[f.typeName]
+		if !exists {
+			handler, exists = primitiveHandlers[f.typeName]
+		}
+
+		if !exists {
+			return nil, errors.Errorf("unknown field type '%s'", f.typeName)
+		}
+
+		plan.names = append(plan.names, f.name)
+		plan.handlers = append(plan.handlers, handler)
+	}
+
+	return plan, nil
+}
+
+// classPlan sets the decode plan of a descriptor: the precompiled plan of the JDK baseline, of the shared cached
+// layout, or else a new one, always a new one with primitive handler overrides. Descriptors with an unknown field
+// type get none, values then reports the type.
+func (this *SerializedObjectParser) classPlan(cls *clazz) {
+	this.knownClass(cls)
+	this.cachedClass(cls)
+
+	if cls.plan == nil || this.primitiveOverrides != nil {
+		cls.plan, _ = compileDecodePlan(cls.fields, this.primitiveOverrides)
+	}
+}
+
+// read reads the field values of an instance.
+func (this *decodePlan) read(sop *SerializedObjectParser) (vals map[string]interface{}, err error) {
+	vals = make(map[string]interface{}, len(this.names))
+
+	for i, handler := range this.handlers {
+		if vals[this.names[i]], err = handler(sop); err != nil {
+			err = errors.Wrap(err, "error reading primitive field value")
+
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,321 @@
+package pkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"plugin"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Detector inspects a parsed stream and returns the findings it raises.
+type Detector func(analysis *Analysis, content []interface{}) []Finding
+
+// OutputFormat renders the analysis of a sample.
+type OutputFormat func(w io.Writer, name string, analysis *Analysis) error
+
+// Detectors maps detector names to the Detector implementations run by Analyze.
+var Detectors = map[string]Detector{}
+
+// OutputFormats maps output format names to their implementation.
+var OutputFormats = map[string]OutputFormat{
+	"json": jsonOutput,
+	"csv":  csvOutput,
+	"md":   markdownOutput,
+}
+
+var pluginsMu sync.Mutex
+
+// externalPlugins holds the running external plugins.
+var externalPlugins = map[*ExternalPlugin]bool{}
+
+// RegisterDetector adds a detector run by Analyze.
+func RegisterDetector(name string, detector Detector) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	Detectors[name] = detector
+}
+
+// RegisterOutputFormat adds an output format.
+func RegisterOutputFormat(name string, format OutputFormat) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	OutputFormats[name] = format
+}
+
+// RegisterPostProc adds a post-processor for objects of the given class signature (name@serialVersionUID).
+func RegisterPostProc(signature string, postProc PostProc) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	KnownPostProcs[signature] = postProc
+}
+
+// sortedDetectors returns the registered detectors ordered by name.
+func sortedDetectors() []Detector {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	names := make([]string, 0, len(Detectors))
+	for name := range Detectors {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	detectors := make([]Detector, len(names))
+	for i, name := range names {
+		detectors[i] = Detectors[name]
+	}
+
+	return detectors
+}
+
+func jsonOutput(w io.Writer, _ string, analysis *Analysis) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(analysis)
+}
+
+// LoadGoPlugin opens a Go plugin (built with -buildmode=plugin against the same go-pjs version) and calls its
+// exported `Register func()` which registers detectors, post-processors and output formats.
+func LoadGoPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "open plugin %s", path)
+	}
+
+	sym, err := p.Lookup("Register")
+	if err != nil {
+		return errors.Wrapf(err, "plugin %s", path)
+	}
+
+	register, isFunc := sym.(func())
+	if !isFunc {
+		return errors.Errorf("plugin %s: Register is not a func()", path)
+	}
+
+	register()
+
+	return nil
+}
+
+// ExternalPlugin is a plugin running as a subprocess. go-pjs writes one JSON request per line on its stdin and reads
+// one JSON response per line from its stdout:
+//
+//	request:  {"id": 1, "method": "describe" | "detect" | "postproc" | "format", "params": {...}}
+//	response: {"id": 1, "result": ..., "error": "..."}
+//
+// "describe" returns {"name": ..., "detectors": [...], "postProcs": ["class@uid", ...], "formats": [...]},
+// "detect" receives {"detector", "analysis", "content"} and returns a list of findings,
+// "postproc" receives {"signature", "fields", "annotations"} and returns the fields to set on the object,
+// "format" receives {"format", "name", "analysis"} and returns the rendered output as a string.
+type ExternalPlugin struct {
+	Name      string   `json:"name"`
+	Detectors []string `json:"detectors"`
+	PostProcs []string `json:"postProcs"`
+	Formats   []string `json:"formats"`
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	nextID int
+}
+
+type pluginRequest struct {
+	ID     int         `json:"id"`
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+type pluginResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+// maxPluginResponse bounds the size of a single plugin response line.
+const maxPluginResponse = 64 << 20
+
+// StartExternalPlugin starts a subprocess plugin, asks it to describe itself and registers its extensions.
+func StartExternalPlugin(path string, args ...string) (*ExternalPlugin, error) {
+	this := &ExternalPlugin{cmd: exec.Command(path, args...)}
+
+	stdin, err := this.cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "plugin stdin")
+	}
+
+	stdout, err := this.cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "plugin stdout")
+	}
+
+	this.stdin, this.stdout = stdin, bufio.NewReader(stdout)
+
+	if err := this.cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "start plugin %s", path)
+	}
+
+	if err := this.call("describe", nil, this); err != nil {
+		_ = this.Close()
+
+		return nil, errors.Wrapf(err, "describe plugin %s", path)
+	}
+
+	for _, name := range this.Detectors {
+		RegisterDetector(name, this.detector(name))
+	}
+
+	for _, signature := range this.PostProcs {
+		RegisterPostProc(signature, this.postProc(signature))
+	}
+
+	for _, format := range this.Formats {
+		RegisterOutputFormat(format, this.format(format))
+	}
+
+	pluginsMu.Lock()
+	externalPlugins[this] = true
+	pluginsMu.Unlock()
+
+	return this, nil
+}
+
+// call sends a request and decodes the result of its response into result.
+func (this *ExternalPlugin) call(method string, params, result interface{}) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.nextID++
+
+	req, err := json.Marshal(&pluginRequest{ID: this.nextID, Method: method, Params: params})
+	if err != nil {
+		return errors.Wrap(err, "encode plugin request")
+	}
+
+	if _, err := this.stdin.Write(append(req, '\n')); err != nil {
+		return errors.Wrap(err, "write plugin request")
+	}
+
+	var line []byte
+
+	for {
+		chunk, isPrefix, err := this.stdout.ReadLine()
+		if err != nil {
+			return errors.Wrap(err, "read plugin response")
+		}
+
+		line = append(line, chunk...)
+		if len(line) > maxPluginResponse {
+			return errors.New("plugin response too large")
+		}
+
+		if !isPrefix {
+			break
+		}
+	}
+
+	var resp pluginResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return errors.Wrap(err, "decode plugin response")
+	}
+
+	if resp.ID != this.nextID {
+		return errors.Errorf("plugin response id %d does not match request id %d", resp.ID, this.nextID)
+	}
+
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+
+	return errors.Wrap(json.Unmarshal(resp.Result, result), "decode plugin result")
+}
+
+func (this *ExternalPlugin) detector(name string) Detector {
+	return func(analysis *Analysis, content []interface{}) []Finding {
+		var findings []Finding
+
+		params := map[string]interface{}{"detector": name, "analysis": analysis, "content": jsonFriendlyArray(content)}
+		if err := this.call("detect", params, &findings); err != nil {
+			return []Finding{{
+				RuleID: "PLUGIN-ERROR", Severity: SeverityInfo, Title: "detector " + name + " failed", Detail: err.Error(),
+			}}
+		}
+
+		return findings
+	}
+}
+
+func (this *ExternalPlugin) postProc(signature string) PostProc {
+	return func(fields map[string]interface{}, anns []interface{}) (map[string]interface{}, error) {
+		var values map[string]interface{}
+
+		params := map[string]interface{}{
+			"signature": signature, "fields": jsonFriendlyMap(fields), "annotations": jsonFriendlyArray(anns),
+		}
+		if err := this.call("postproc", params, &values); err != nil {
+			return nil, errors.Wrapf(err, "plugin post-processor %s", signature)
+		}
+
+		for k, v := range values {
+			fields[k] = v
+		}
+
+		return fields, nil
+	}
+}
+
+func (this *ExternalPlugin) format(format string) OutputFormat {
+	return func(w io.Writer, name string, analysis *Analysis) error {
+		var out string
+
+		params := map[string]interface{}{"format": format, "name": name, "analysis": analysis}
+		if err := this.call("format", params, &out); err != nil {
+			return errors.Wrapf(err, "plugin output format %s", format)
+		}
+
+		_, err := io.WriteString(w, out)
+
+		return err
+	}
+}
+
+// CloseExternalPlugins stops every running external plugin.
+func CloseExternalPlugins() {
+	pluginsMu.Lock()
+	plugins := make([]*ExternalPlugin, 0, len(externalPlugins))
+
+	for p := range externalPlugins {
+		plugins = append(plugins, p)
+	}
+
+	pluginsMu.Unlock()
+
+	for _, p := range plugins {
+		_ = p.Close()
+	}
+}
+
+// Close stops the plugin process.
+func (this *ExternalPlugin) Close() error {
+	pluginsMu.Lock()
+	delete(externalPlugins, this)
+	pluginsMu.Unlock()
+
+	_ = this.stdin.Close()
+
+	return this.cmd.Wait()
+}
@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// primitiveArrayChunk is the number of bytes of a primitive array decoded at once, a multiple of every primitive
+// size. Chunks keep the memory of a bogus array size proportional to the input actually read.
+const primitiveArrayChunk = 4096
+
+// readPrimitiveArray reads the size values of a primitive array in bulk: the array region is read chunk by chunk and
+// converted with unrolled loops, instead of a binary.Read per element. The values are those of primitiveHandlers.
+func (this *SerializedObjectParser) readPrimitiveArray(typeCode byte, size int) (array []interface{}, err error) {
+	elemSize := primitiveSizes[typeCode]
+	if size <= 0 {
+		return nil, nil
+	}
+
+	chunkElems := primitiveArrayChunk / elemSize
+	if size < chunkElems {
+		chunkElems = size
+	}
+
+	array = make([]interface{}, 0, chunkElems)
+	buf := make([]byte, chunkElems*elemSize)
+
+	for remaining := size; remaining > 0; remaining -= chunkElems {
+		if remaining < chunkElems {
+			chunkElems = remaining
+		}
+
+		b := buf[:chunkElems*elemSize]
+		if _, err = io.ReadFull(this.rd, b); err != nil {
+			return nil, errors.Wrap(err, "error reading primitive array member")
+		}
+
+		array = appendPrimitives(array, typeCode, b)
+	}
+
+	return array, nil
+}
+
+// appendPrimitives appends the big-endian values of type typeCode held by b.
+func appendPrimitives(array []interface{}, typeCode byte, b []byte) []interface{} {
+	be := binary.BigEndian
+	i := 0
+
+	switch typeCode {
+	case 'B':
+		for ; i+4 <= len(b); i += 4 {
+			array = append(array, int8(b[i]), int8(b[i+1]), int8(b[i+2]), int8(b[i+3]))
+		}
+
+		for ; i < len(b); i++ {
+			array = append(array, int8(b[i]))
+		}
+	case 'Z':
+		for ; i+4 <= len(b); i += 4 {
+			array = append(array, b[i] != 0, b[i+1] != 0, b[i+2] != 0, b[i+3] != 0)
+		}
+
+		for ; i < len(b); i++ {
+			array = append(array, b[i] != 0)
+		}
+	case 'C':
+		for ; i+8 <= len(b); i += 8 {
+			array = append(array, string(rune(be.Uint16(b[i:]))), string(rune(be.Uint16(b[i+2:]))),
+				string(rune(be.Uint16(b[i+4:]))), string(rune(be.Uint16(b[i+6:]))))
+		}
+
+		for ; i < len(b); i += 2 {
+			array = append(array, string(rune(be.Uint16(b[i:]))))
+		}
+	case 'S':
+		for ; i+8 <= len(b); i += 8 {
+			array = append(array, int16(be.Uint16(b[i:])), int16(be.Uint16(b[i+2:])), int16(be.Uint16(b[i+4:])),
+				int16(be.Uint16(b[i+6:])))
+		}
+
+		for ; i < len(b); i += 2 {
+			array = append(array, int16(be.Uint16(b[i:])))
+		}
+	case 'I':
+		for ; i+16 <= len(b); i += 16 {
+			array = append(array, int32(be.Uint32(b[i:])), int32(be.Uint32(b[i+4:])), int32(be.Uint32(b[i+8:])),
+				int32(be.Uint32(b[i+12:])))
+		}
+
+		for ; i < len(b); i += 4 {
+			array = append(array, int32(be.Uint32(b[i:])))
+		}
+	case 'F':
+		for ; i+16 <= len(b); i += 16 {
+			array = append(array, math.Float32frombits(be.Uint32(b[i:])), math.Float32frombits(be.Uint32(b[i+4:])),
+				math.Float32frombits(be.Uint32(b[i+8:])), math.Float32frombits(be.Uint32(b[i+12:])))
+		}
+
+		for ; i < len(b); i += 4 {
+			array = append(array, math.Float32frombits(be.Uint32(b[i:])))
+		}
+	case 'J':
+		for ; i+32 <= len(b); i += 32 {
+			array = append(array, int64(be.Uint64(b[i:])), int64(be.Uint64(b[i+8:])), int64(be.Uint64(b[i+16:])),
+				int64(be.Uint64(b[i+24:])))
+		}
+
+		for ; i < len(b); i += 8 {
+			array = append(array, int64(be.Uint64(b[i:])))
+		}
+	case 'D':
+		for ; i+32 <= len(b); i += 32 {
+			array = append(array, math.Float64frombits(be.Uint64(b[i:])), math.Float64frombits(be.Uint64(b[i+8:])),
+				math.Float64frombits(be.Uint64(b[i+16:])), math.Float64frombits(be.Uint64(b[i+24:])))
+		}
+
+		for ; i < len(b); i += 8 {
+			array = append(array, math.Float64frombits(be.Uint64(b[i:])))
+		}
+	}
+
+	return array
+}
@@ -0,0 +1,139 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// primitiveTypeCodes are the element types of the primitive arrays.
+var primitiveTypeCodes = []byte{'B', 'Z', 'C', 'S', 'I', 'F', 'J', 'D'}
+
+// primitiveArrayData returns size random values of type typeCode.
+func primitiveArrayData(typeCode byte, size int) []byte {
+	b := make([]byte, size*primitiveSizes[typeCode])
+	rand.New(rand.NewSource(int64(typeCode))).Read(b)
+
+	return b
+}
+
+// readPrimitivesPerElement reads size values of type typeCode one binary.Read at a time, through primitiveHandlers.
+func readPrimitivesPerElement(data []byte, typeCode byte, size int) ([]interface{}, error) {
+	sop := NewSerializedObjectParser(bytes.NewReader(data))
+	handler := primitiveHandlers[string(typeCode)]
+
+	var res []interface{}
+
+	for i := 0; i < size; i++ {
+		v, err := handler(sop)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, v)
+	}
+
+	return res, nil
+}
+
+// samePrimitive compares two values bit for bit, so that NaNs compare equal.
+func samePrimitive(a, b interface{}) bool {
+	switch x := a.(type) {
+	case float32:
+		y, ok := b.(float32)
+
+		return ok && math.Float32bits(x) == math.Float32bits(y)
+	case float64:
+		y, ok := b.(float64)
+
+		return ok && math.Float64bits(x) == math.Float64bits(y)
+	}
+
+	return a == b
+}
+
+func TestReadPrimitiveArray(t *testing.T) {
+	for _, typeCode := range primitiveTypeCodes {
+		chunkElems := primitiveArrayChunk / primitiveSizes[typeCode]
+
+		for _, size := range []int{0, 1, 3, 4, 5, 7, 8, 9, chunkElems - 1, chunkElems, chunkElems + 1, 2*chunkElems + 3} {
+			t.Run(fmt.Sprintf("%c/%d", typeCode, size), func(t *testing.T) {
+				data := primitiveArrayData(typeCode, size)
+
+				want, err := readPrimitivesPerElement(data, typeCode, size)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := NewSerializedObjectParser(bytes.NewReader(data)).readPrimitiveArray(typeCode, size)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(got) != len(want) {
+					t.Fatalf("got %d values, want %d", len(got), len(want))
+				}
+
+				for i := range want {
+					if !samePrimitive(got[i], want[i]) {
+						t.Fatalf("value %d: got %#v, want %#v", i, got[i], want[i])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestReadPrimitiveArrayTruncated(t *testing.T) {
+	for _, typeCode := range primitiveTypeCodes {
+		chunkElems := primitiveArrayChunk / primitiveSizes[typeCode]
+
+		for _, size := range []int{1, 5, chunkElems + 1} {
+			data := primitiveArrayData(typeCode, size)
+			data = data[:len(data)-1]
+
+			if _, err := NewSerializedObjectParser(bytes.NewReader(data)).readPrimitiveArray(typeCode,
+				size); err == nil {
+				t.Errorf("%c/%d: no error reading a truncated array", typeCode, size)
+			}
+		}
+	}
+}
+
+// benchmarkArraySize is the number of elements of the arrays of the benchmarks.
+const benchmarkArraySize = 10000
+
+func BenchmarkReadPrimitiveArray(b *testing.B) {
+	for _, typeCode := range primitiveTypeCodes {
+		data := primitiveArrayData(typeCode, benchmarkArraySize)
+
+		b.Run(string(typeCode), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+
+			for i := 0; i < b.N; i++ {
+				if _, err := NewSerializedObjectParser(bytes.NewReader(data)).readPrimitiveArray(typeCode,
+					benchmarkArraySize); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReadPrimitivesPerElement(b *testing.B) {
+	for _, typeCode := range primitiveTypeCodes {
+		data := primitiveArrayData(typeCode, benchmarkArraySize)
+
+		b.Run(string(typeCode), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+
+			for i := 0; i < b.N; i++ {
+				if _, err := readPrimitivesPerElement(data, typeCode, benchmarkArraySize); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
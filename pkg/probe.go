@@ -0,0 +1,301 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ProbeProtocol selects the handshake performed by Probe before sending the probe object.
+type ProbeProtocol int
+
+const (
+	ProbeRaw  ProbeProtocol = iota // send the serialized probe as is
+	ProbeJRMP                      // Java RMI transport: JRMI handshake then a DGC call carrying the probe
+	ProbeT3                        // WebLogic T3: handshake only, the server banner is the probe result
+)
+
+// String returns the protocol name.
+func (this ProbeProtocol) String() string {
+	switch this {
+	case ProbeJRMP:
+		return "jrmp"
+	case ProbeT3:
+		return "t3"
+	default:
+		return "raw"
+	}
+}
+
+const (
+	defaultProbeTimeout   = 10 * time.Second
+	defaultProbeReadLimit = 1 << 20
+	probeIdleTimeout      = 2 * time.Second
+
+	jrmpMagic          uint32 = 0x4a524d49 // "JRMI"
+	jrmpVersion        uint16 = 2
+	jrmpStreamProtocol byte   = 0x4b
+	jrmpProtocolAck    byte   = 0x4e
+
+	// dgcDirtyOperation is the operation number of java.rmi.dgc.DGC.dirty, see dgcMethods.
+	dgcDirtyOperation int32 = 1
+)
+
+// ProbeConfig describes a single probe of a serialization endpoint.
+type ProbeConfig struct {
+	Address   string        // host:port
+	Protocol  ProbeProtocol // handshake to perform
+	Timeout   time.Duration // overall timeout, defaults to 10s
+	Payload   []byte        // serialized probe object (with stream header), defaults to a serialized java.lang.String
+	ReadLimit int           // maximum number of response bytes kept, defaults to 1MB
+	Options   []Option      // parser options used for the response
+}
+
+// ProbeResult holds what was exchanged with the endpoint.
+type ProbeResult struct {
+	Address    string        `json:"address"`
+	Protocol   string        `json:"protocol"`
+	Handshake  string        `json:"handshake,omitempty"` // server side of the handshake (JRMP endpoint, T3 HELO line)
+	Sent       int           `json:"sent"`
+	Response   []byte        `json:"response,omitempty"`
+	Content    []interface{} `json:"content,omitempty"` // parsed serialized response, if any
+	ParseError string        `json:"parseError,omitempty"`
+	Verdict    *ProbeVerdict `json:"verdict,omitempty"`
+	Elapsed    time.Duration `json:"elapsed"`
+}
+
+// defaultProbePayload is a serialized java.lang.String, harmless for any endpoint.
+var defaultProbePayload = []byte{
+	STREAM_MAGIC1, STREAM_MAGIC2, 0x00, STREAM_VERSION,
+	TC_STRING, 0x00, 0x06, 'g', 'o', '-', 'p', 'j', 's',
+}
+
+// Probe connects to an endpoint, performs the optional handshake, sends a benign serialized probe object and parses
+// whatever serialized response comes back. Network errors are returned, a response which cannot be parsed is not an
+// error and is reported in ProbeResult.ParseError.
+func Probe(ctx context.Context, cfg *ProbeConfig) (res *ProbeResult, err error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+
+	payload := cfg.Payload
+	if len(payload) == 0 {
+		payload = defaultProbePayload
+	}
+
+	readLimit := cfg.ReadLimit
+	if readLimit <= 0 {
+		readLimit = defaultProbeReadLimit
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	res = &ProbeResult{Address: cfg.Address, Protocol: cfg.Protocol.String()}
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", cfg.Address)
+	if err != nil {
+		return res, errors.Wrapf(err, "error connecting to %s", cfg.Address)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	var msg []byte
+
+	switch cfg.Protocol {
+	case ProbeJRMP:
+		if res.Handshake, err = jrmpHandshake(conn); err != nil {
+			return res, err
+		}
+
+		msg = jrmpDgcCall(payload)
+	case ProbeT3:
+		if res.Handshake, err = t3Handshake(conn, deadline); err != nil {
+			return res, err
+		}
+	default:
+		msg = payload
+	}
+
+	if len(msg) > 0 {
+		if res.Sent, err = conn.Write(msg); err != nil {
+			return res, errors.Wrap(err, "error sending probe")
+		}
+
+		if res.Response, err = readResponse(conn, readLimit, deadline); err != nil {
+			return res, err
+		}
+
+		res.Content, err = parseProbeResponse(res.Response, cfg.Options)
+		if err != nil {
+			res.ParseError = err.Error()
+			err = nil
+		}
+
+		res.Verdict = ClassifyResponse(res.Response, payload, cfg.Options...)
+	}
+
+	res.Elapsed = time.Since(start)
+
+	return res, nil
+}
+
+// jrmpHandshake performs the JRMP stream protocol handshake and returns the endpoint echoed by the server.
+func jrmpHandshake(conn net.Conn) (string, error) {
+	var out bytes.Buffer
+
+	_ = binary.Write(&out, binary.BigEndian, jrmpMagic)
+	_ = binary.Write(&out, binary.BigEndian, jrmpVersion)
+	out.WriteByte(jrmpStreamProtocol)
+
+	if _, err := conn.Write(out.Bytes()); err != nil {
+		return "", errors.Wrap(err, "error sending JRMP handshake")
+	}
+
+	var ack byte
+
+	if err := binary.Read(conn, binary.BigEndian, &ack); err != nil {
+		return "", errors.Wrap(err, "error reading JRMP protocol ack")
+	}
+
+	if ack != jrmpProtocolAck {
+		return "", errors.Errorf("unexpected JRMP protocol ack %#x", ack)
+	}
+
+	var hostLen uint16
+
+	if err := binary.Read(conn, binary.BigEndian, &hostLen); err != nil {
+		return "", errors.Wrap(err, "error reading JRMP endpoint host")
+	}
+
+	host := make([]byte, hostLen)
+
+	var port int32
+
+	if _, err := io.ReadFull(conn, host); err != nil {
+		return "", errors.Wrap(err, "error reading JRMP endpoint host")
+	}
+
+	if err := binary.Read(conn, binary.BigEndian, &port); err != nil {
+		return "", errors.Wrap(err, "error reading JRMP endpoint port")
+	}
+
+	// client endpoint: empty host, port 0
+	if _, err := conn.Write([]byte{0, 0, 0, 0, 0, 0}); err != nil {
+		return "", errors.Wrap(err, "error sending JRMP client endpoint")
+	}
+
+	return net.JoinHostPort(string(host), strconv.Itoa(int(port))), nil
+}
+
+// jrmpDgcCall wraps a serialized object into a JRMP call of DGC.dirty, the object being the first argument.
+func jrmpDgcCall(payload []byte) []byte {
+	var out bytes.Buffer
+
+	out.WriteByte(RMI_Call)
+	out.Write([]byte{STREAM_MAGIC1, STREAM_MAGIC2, 0x00, STREAM_VERSION})
+
+	// ObjID of the DGC (objNum 2, zero UID), the operation of dirty and the DGC interface hash, as 1.1 stubs call
+	var call bytes.Buffer
+
+	_ = binary.Write(&call, binary.BigEndian, int64(2))
+	_ = binary.Write(&call, binary.BigEndian, int32(0))
+	_ = binary.Write(&call, binary.BigEndian, int64(0))
+	_ = binary.Write(&call, binary.BigEndian, int16(0))
+	_ = binary.Write(&call, binary.BigEndian, dgcDirtyOperation)
+	_ = binary.Write(&call, binary.BigEndian, dgcInterfaceHash)
+
+	out.WriteByte(TC_BLOCKDATA)
+	out.WriteByte(byte(call.Len()))
+	out.Write(call.Bytes())
+
+	if len(payload) >= 4 && payload[0] == STREAM_MAGIC1 && payload[1] == STREAM_MAGIC2 {
+		payload = payload[4:]
+	}
+
+	out.Write(payload)
+
+	return out.Bytes()
+}
+
+// t3Handshake performs the WebLogic T3 handshake and returns the HELO line of the server.
+func t3Handshake(conn net.Conn, deadline time.Time) (string, error) {
+	if _, err := conn.Write([]byte("t3 12.2.1\nAS:255\nHL:19\nMS:10000000\n\n")); err != nil {
+		return "", errors.Wrap(err, "error sending T3 handshake")
+	}
+
+	resp, err := readResponse(conn, 4096, deadline)
+	if err != nil {
+		return "", err
+	}
+
+	line := strings.SplitN(string(resp), "\n", 2)[0]
+	if !strings.HasPrefix(line, "HELO") {
+		return line, errors.Errorf("unexpected T3 handshake response %q", line)
+	}
+
+	return line, nil
+}
+
+// readResponse reads until the peer closes the connection, limit bytes were read, the connection deadline expires or
+// the peer stays silent for probeIdleTimeout after having sent something.
+func readResponse(conn net.Conn, limit int, deadline time.Time) ([]byte, error) {
+	var resp bytes.Buffer
+
+	buf := make([]byte, 4096)
+
+	for resp.Len() < limit {
+		if idle := time.Now().Add(probeIdleTimeout); resp.Len() > 0 && idle.Before(deadline) {
+			_ = conn.SetReadDeadline(idle)
+		} else {
+			_ = conn.SetReadDeadline(deadline)
+		}
+
+		n, err := conn.Read(buf)
+		resp.Write(buf[:n])
+
+		if err != nil {
+			if ne, isNetErr := err.(net.Error); err == io.EOF || isNetErr && ne.Timeout() && resp.Len() > 0 {
+				break
+			}
+
+			return resp.Bytes(), errors.Wrap(err, "error reading response")
+		}
+	}
+
+	if resp.Len() > limit {
+		resp.Truncate(limit)
+	}
+
+	return resp.Bytes(), nil
+}
+
+// parseProbeResponse parses the first serialized stream found in a response (e.g. after a JRMP ReturnData byte).
+func parseProbeResponse(resp []byte, options []Option) ([]interface{}, error) {
+	idx := bytes.Index(resp, []byte{STREAM_MAGIC1, STREAM_MAGIC2})
+	if idx < 0 {
+		return nil, errors.New("no serialized stream in response")
+	}
+
+	elements, _, err := ParseElements(resp[idx:], 0, options...)
+
+	content := make([]interface{}, 0, len(elements))
+	for _, el := range elements {
+		content = append(content, el.Content)
+	}
+
+	return content, err
+}
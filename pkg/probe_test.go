@@ -0,0 +1,23 @@
+package pkg
+
+import "testing"
+
+func TestJRMPDgcCall(t *testing.T) {
+	payload, err := StringPayload("probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := DecodeJMX(jrmpDgcCall(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 1 || msgs[0].Interface != "java.rmi.dgc.DGC" || msgs[0].Method != "dirty" {
+		t.Fatalf("got %+v, want a call of DGC.dirty", msgs)
+	}
+
+	if args := msgs[0].Arguments; len(args) == 0 || args[0].Name != "ids" || args[0].Value != `"probe"` {
+		t.Errorf("got arguments %+v, want the probe as ids", args)
+	}
+}
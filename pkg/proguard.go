@@ -0,0 +1,149 @@
+package pkg
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProGuardMapping maps the class and field names of an application obfuscated by ProGuard or R8 back to their
+// original names, from the mapping file written along with the application (-printmapping, mapping.txt).
+type ProGuardMapping struct {
+	classes map[string]string            // obfuscated class name to original
+	fields  map[string]map[string]string // original class name to obfuscated field name to original
+}
+
+// ParseProGuardMapping reads a ProGuard or R8 mapping file: a "original -> obfuscated:" line per class followed by
+// its indented members, of which the fields ("type original -> obfuscated") are kept and the methods skipped. Lines
+// starting with '#' are ignored.
+//
+//	com.vendor.Session -> a.b:
+//	    java.lang.String user -> a
+//	    1:3:void refresh() -> b
+func ParseProGuardMapping(rd io.Reader) (*ProGuardMapping, error) {
+	res := &ProGuardMapping{classes: map[string]string{}, fields: map[string]map[string]string{}}
+	sc := bufio.NewScanner(rd)
+
+	var class string
+
+	for line := 1; sc.Scan(); line++ {
+		raw := sc.Text()
+		text := strings.TrimSpace(raw)
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(text, " -> ", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("line %d: invalid mapping '%s', want original -> obfuscated", line, text)
+		}
+
+		original, obfuscated := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+		if raw[0] != ' ' && raw[0] != '\t' {
+			if !strings.HasSuffix(obfuscated, ":") {
+				return nil, errors.Errorf("line %d: invalid class mapping '%s', want original -> obfuscated:", line,
+					text)
+			}
+
+			class = original
+			res.classes[strings.TrimSuffix(obfuscated, ":")] = class
+
+			continue
+		}
+
+		// methods, with their parameters and optional line numbers
+		if class == "" || strings.Contains(original, "(") {
+			continue
+		}
+
+		if i := strings.LastIndex(original, " "); i >= 0 {
+			original = original[i+1:]
+		}
+
+		if original != obfuscated {
+			if res.fields[class] == nil {
+				res.fields[class] = map[string]string{}
+			}
+
+			res.fields[class][obfuscated] = original
+		}
+	}
+
+	return res, errors.Wrap(sc.Err(), "error reading mapping")
+}
+
+// LoadProGuardMapping reads a mapping file, see ParseProGuardMapping.
+func LoadProGuardMapping(path string) (*ProGuardMapping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening mapping")
+	}
+	defer f.Close()
+
+	return ParseProGuardMapping(f)
+}
+
+// Class returns the original name of a class, array class names ([La.b;) included.
+func (this *ProGuardMapping) Class(name string) string {
+	if this == nil {
+		return name
+	}
+
+	dims := strings.LastIndex(name, "[") + 1
+	if dims > 0 {
+		if !strings.HasPrefix(name[dims:], "L") || !strings.HasSuffix(name, ";") {
+			return name
+		}
+
+		return name[:dims+1] + this.Class(name[dims+1:len(name)-1]) + ";"
+	}
+
+	if original, exists := this.classes[name]; exists {
+		return original
+	}
+
+	return name
+}
+
+// Field returns the original name of a field of a class, given its original name.
+func (this *ProGuardMapping) Field(class, name string) string {
+	if this == nil {
+		return name
+	}
+
+	if original, exists := this.fields[class][name]; exists {
+		return original
+	}
+
+	return name
+}
+
+// Signature returns the original JVM type signature of an object field, e.g. Lcom/vendor/Session; for La/b;.
+func (this *ProGuardMapping) Signature(signature string) string {
+	if this == nil {
+		return signature
+	}
+
+	dims := strings.LastIndex(signature, "[") + 1
+	if !strings.HasPrefix(signature[dims:], "L") || !strings.HasSuffix(signature, ";") {
+		return signature
+	}
+
+	name := strings.ReplaceAll(signature[dims+1:len(signature)-1], "/", ".")
+
+	return signature[:dims+1] + strings.ReplaceAll(this.Class(name), ".", "/") + ";"
+}
+
+// SetProGuardMapping maps the obfuscated class and field names read from the stream back to their original names,
+// before relocations: the parsed objects, class cache statistics and analysis see the original names, the dump
+// prints them next to the obfuscated ones.
+func SetProGuardMapping(mapping *ProGuardMapping) Option {
+	return func(this *SerializedObjectParser) {
+		this.proguard = mapping
+	}
+}
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	objectPostProcs["java.util.Properties@3912d07a70363e98"] = propertiesPostProc
+}
+
+// propertiesPostProc resolves a java.util.Properties to a map[string]string the way getProperty sees it: the entries
+// of the Hashtable part over those of the defaults chain. The defaults stay available under "defaults".
+func propertiesPostProc(obj map[string]interface{}) {
+	value := map[string]string{}
+
+	if defaults, isMap := obj["defaults"].(map[string]interface{}); isMap {
+		if resolved, isResolved := defaults["value"].(map[string]string); isResolved {
+			for k, v := range resolved {
+				value[k] = v
+			}
+		}
+	}
+
+	if entries, isMap := obj["value"].(map[string]interface{}); isMap {
+		for k, v := range entries {
+			value[k] = propertyString(v)
+		}
+	}
+
+	obj["value"] = value
+}
+
+// propertyString renders a property value, values put through the Hashtable API are not necessarily strings.
+func propertyString(v interface{}) string {
+	switch o := v.(type) {
+	case string:
+		return o
+	case map[string]interface{}:
+		if value, exists := o["value"]; exists {
+			return propertyString(value)
+		}
+	}
+
+	return fmt.Sprint(v)
+}
+
+// preferencesNode is a node of the XML export of java.util.prefs.Preferences (exportNode / exportSubtree).
+type preferencesNode struct {
+	Name     string             `xml:"name,attr"`
+	Entries  []preferencesEntry `xml:"map>entry"`
+	Children []preferencesNode  `xml:"node"`
+}
+
+type preferencesEntry struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// preferencesExport is the preferences document of the export.
+type preferencesExport struct {
+	Root struct {
+		Type string `xml:"type,attr"`
+		preferencesNode
+	} `xml:"root"`
+}
+
+// PreferencesTree decodes the XML export of java.util.prefs.Preferences, found in strings or block data, into
+// nested maps: the entries of a node are strings and its child nodes maps. The root node holds "@type" (user or
+// system).
+func PreferencesTree(doc string) (map[string]interface{}, error) {
+	var export preferencesExport
+
+	dec := xml.NewDecoder(strings.NewReader(doc))
+	dec.Strict = false
+
+	if err := dec.Decode(&export); err != nil {
+		return nil, errors.Wrap(err, "error decoding preferences export")
+	}
+
+	tree := export.Root.preferencesNode.tree()
+	tree["@type"] = export.Root.Type
+
+	return tree, nil
+}
+
+func (this preferencesNode) tree() map[string]interface{} {
+	m := make(map[string]interface{}, len(this.Entries)+len(this.Children))
+
+	for _, e := range this.Entries {
+		m[e.Key] = e.Value
+	}
+
+	for _, child := range this.Children {
+		m[child.Name] = child.tree()
+	}
+
+	return m
+}
@@ -0,0 +1,62 @@
+package pkg
+
+import "github.com/pkg/errors"
+
+// ObjectOutputStream protocol versions, see ObjectOutputStream.useProtocolVersion.
+const (
+	ProtocolVersion1 = 1 // externalizable data written as is (JDK 1.1)
+	ProtocolVersion2 = 2 // externalizable data written in block data mode, the default since JDK 1.2
+)
+
+// ProtocolInfo reports how a parsed stream was written.
+type ProtocolInfo struct {
+	StreamVersion   int  `json:"streamVersion"`
+	ProtocolVersion int  `json:"protocolVersion"` // 1 when externalizable data was written without block data
+	BlockData       bool `json:"blockData"`       // block data segments were read
+	ExternalV1      int  `json:"externalV1,omitempty"`
+	ExternalV2      int  `json:"externalV2,omitempty"`
+	Resets          int  `json:"resets,omitempty"` // TC_RESET elements read
+}
+
+// externalReaders maps class names to the readers of their version 1 external data, see RegisterExternalReader.
+var externalReaders = map[string]TagHandler{}
+
+// RegisterExternalReader registers the reader of the external data of a class written with protocol version 1.
+// Unlike version 2 data, which is framed by block data, version 1 data is written as is by writeExternal and can
+// only be read by code knowing its layout. The value returned by fn becomes the "value" of the object.
+func RegisterExternalReader(className string, fn TagHandler) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	externalReaders[className] = fn
+}
+
+// Protocol returns the protocol details of the stream read so far.
+func (this *SerializedObjectParser) Protocol() *ProtocolInfo {
+	info := this.protocol
+
+	info.ProtocolVersion = ProtocolVersion2
+	if info.ExternalV1 > 0 {
+		info.ProtocolVersion = ProtocolVersion1
+	}
+
+	return &info
+}
+
+// externalV1Data reads the version 1 external data of a class with its registered reader.
+func (this *SerializedObjectParser) externalV1Data(cls *clazz) (data map[string]interface{}, err error) {
+	this.protocol.ExternalV1++
+
+	reader, exists := externalReaders[cls.name]
+	if !exists {
+		return nil, errors.Errorf("unable to parse version 1 external content of %s, see RegisterExternalReader",
+			cls.name)
+	}
+
+	value, err := reader(&TagReader{p: this})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading version 1 external content of %s", cls.name)
+	}
+
+	return map[string]interface{}{"value": value}, nil
+}
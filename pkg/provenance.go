@@ -0,0 +1,204 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+)
+
+// modulePath is the path of the go-pjs module, looked up in the build info.
+const modulePath = "github.com/hktalent/go-pjs"
+
+// Version is the go-pjs version stamped on the outputs, set at build time with
+// -ldflags "-X github.com/hktalent/go-pjs/pkg.Version=v1.2.3". See CurrentVersion.
+var Version = ""
+
+// Provenance describes how an output was produced, so that results stored long-term remain interpretable and can
+// be reproduced with the same version, rules and options.
+type Provenance struct {
+	Version   string            `json:"version"`
+	Packs     []string          `json:"packs"`             // enabled detectors and external plugins
+	RulesHash string            `json:"rulesHash"`         // see RulesHash
+	Options   map[string]string `json:"options,omitempty"` // parse options and finding filters in effect
+}
+
+// CurrentVersion returns Version, or else the go-pjs version recorded in the build info: the module version, or devel
+// plus the VCS revision when the toolchain records none for a checkout.
+func CurrentVersion() string {
+	if Version != "" {
+		return Version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+
+	if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return "devel+" + setting.Value[:12]
+		}
+	}
+
+	return "devel"
+}
+
+// NewProvenance describes the running go-pjs and the given parser options.
+func NewProvenance(options ...Option) *Provenance {
+	res := &Provenance{Version: CurrentVersion(), RulesHash: RulesHash(), Options: describeOptions(options)}
+
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	res.Packs = []string{}
+
+	for name := range Detectors {
+		res.Packs = append(res.Packs, "detector:"+name)
+	}
+
+	for p := range externalPlugins {
+		res.Packs = append(res.Packs, "plugin:"+p.Name)
+	}
+
+	sort.Strings(res.Packs)
+
+	return res
+}
+
+// RulesHash is the SHA-256 (hex) of everything deciding the findings and parsed values besides the input: the gadget
+// rules, the names of the detectors and external plugins, the post-processor signatures, the external data readers
+// and the vendor-specific content elements. Two outputs with the same hash were produced by the same rules.
+func RulesHash() string {
+	var lines []string
+
+	for name, rule := range KnownGadgetClasses {
+		lines = append(lines, fmt.Sprintf("gadget %s %s %s %s", name, rule.ID, rule.Severity, rule.Chain))
+	}
+
+	pluginsMu.Lock()
+
+	for name := range Detectors {
+		lines = append(lines, "detector "+name)
+	}
+
+	for p := range externalPlugins {
+		lines = append(lines, "plugin "+p.Name)
+	}
+
+	for signature := range KnownPostProcs {
+		lines = append(lines, "postproc "+signature)
+	}
+
+	for signature := range objectPostProcs {
+		lines = append(lines, "postproc "+signature)
+	}
+
+	for name := range externalReaders {
+		lines = append(lines, "external "+name)
+	}
+
+	for tc, tag := range contentTags {
+		if tag.handler != nil {
+			lines = append(lines, fmt.Sprintf("tag %#02x", tc))
+		}
+	}
+
+	pluginsMu.Unlock()
+
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// describeOptions lists the parser options changing the output, the per-payload and resource options excluded.
+func describeOptions(options []Option) map[string]string {
+	this := NewSerializedObjectParser(bytes.NewReader(nil), options...)
+	res := map[string]string{}
+
+	if this.memoryCap > 0 {
+		res["memoryCap"] = strconv.FormatInt(this.memoryCap, 10)
+	}
+
+	if this.charset != nil {
+		res["charset"] = charsetName(this.charset)
+	}
+
+	if len(this.classCharsets) > 0 {
+		var classes []string
+
+		for name, enc := range this.classCharsets {
+			classes = append(classes, name+"="+charsetName(enc))
+		}
+
+		sort.Strings(classes)
+		res["classCharsets"] = strings.Join(classes, ",")
+	}
+
+	if this.relocations != nil {
+		var rules []string
+
+		for _, rule := range this.relocations.rules {
+			rules = append(rules, rule.from+" -> "+rule.to)
+		}
+
+		sum := sha256.Sum256([]byte(strings.Join(rules, "\n")))
+		res["relocations"] = fmt.Sprintf("%d rules, sha256 %s", len(rules), hex.EncodeToString(sum[:]))
+	}
+
+	if this.proguard != nil {
+		fields := 0
+
+		for _, f := range this.proguard.fields {
+			fields += len(f)
+		}
+
+		res["proguard"] = fmt.Sprintf("%d classes, %d fields", len(this.proguard.classes), fields)
+	}
+
+	if this.classPath != nil {
+		res["classPath"] = fmt.Sprintf("%d classes from %d jars", this.classPath.Len(), len(this.classPath.sources))
+	}
+
+	if this.stopAfterFirst {
+		res["stopAfterFirstObject"] = "true"
+	}
+
+	if len(this.unknownClasses) > 0 {
+		res["unknownClasses"] = strings.Join(this.unknownClasses, ",")
+	}
+
+	if this.src.recording {
+		res["keepAnnotationBytes"] = "true"
+	}
+
+	return res
+}
+
+// charsetName returns the first name of an encoding in Charsets.
+func charsetName(enc encoding.Encoding) string {
+	for _, name := range CharsetNames() {
+		if Charsets[name] == enc {
+			return name
+		}
+	}
+
+	return fmt.Sprint(enc)
+}
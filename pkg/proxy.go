@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterDetector("proxy", proxyDetector)
+}
+
+// maxProxyFields bounds the number of handler fields shown in a proxy summary.
+const maxProxyFields = 4
+
+// maxProxyValue bounds the length of a handler field value shown in a proxy summary.
+const maxProxyValue = 40
+
+// proxyDetector raises a finding per dynamic proxy, its title summarizing the proxy on one line, e.g.
+// Proxy[Map] → AnnotationInvocationHandler(memberValues=LazyMap, type=Override.class). Gadget chains wrap their
+// trigger in proxies, the handler tells what invoking the proxy does.
+func proxyDetector(_ *Analysis, content []interface{}) []Finding {
+	var findings []Finding
+
+	walkObjects(content, func(obj map[string]interface{}) {
+		if objectClassName(obj) != proxyClassName {
+			return
+		}
+
+		handler := "java.rmi.server.RemoteObjectInvocationHandler"
+		if h, isMap := obj["h"].(map[string]interface{}); isMap {
+			handler = objectClassName(h)
+		}
+
+		findings = append(findings, Finding{
+			RuleID:   "PROXY",
+			Severity: SeverityInfo,
+			Title:    ProxySummary(obj),
+			Class:    handler,
+		})
+	})
+
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Title < findings[j].Title })
+
+	return findings
+}
+
+// ProxySummary summarizes a parsed dynamic proxy on one line: the simple names of its interfaces and its
+// InvocationHandler with its key fields. The handler of a remote proxy is summarized by its endpoint. It returns ""
+// when obj is not a proxy.
+func ProxySummary(obj map[string]interface{}) string {
+	cls, _ := obj["class"].(*clazz)
+	if cls == nil || cls.name != proxyClassName {
+		return ""
+	}
+
+	interfaces := make([]string, len(cls.interfaces))
+	for i, name := range cls.interfaces {
+		interfaces[i] = simpleClassName(name)
+	}
+
+	summary := "Proxy[" + strings.Join(interfaces, ", ") + "] → "
+
+	h, isMap := obj["h"].(map[string]interface{})
+	if !isMap {
+		ref, _ := obj["value"].(map[string]interface{})
+
+		return summary + "RemoteObjectInvocationHandler(" + remoteRefSummary(ref) + ")"
+	}
+
+	return summary + simpleClassName(objectClassName(h)) + "(" + handlerFields(h) + ")"
+}
+
+// remoteRefSummary summarizes the RemoteRef decoded by remoteObjectPostProc: its class and endpoint.
+func remoteRefSummary(ref map[string]interface{}) string {
+	var parts []string
+
+	for _, key := range []string{"ref", "endpoint"} {
+		if s, isString := ref[key].(string); isString {
+			parts = append(parts, s)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// handlerFields lists the first fields of an invocation handler, by name, as name=value.
+func handlerFields(h map[string]interface{}) string {
+	names := make([]string, 0, len(h))
+
+	for name := range h {
+		if name != "class" && name != "extends" && name != "value" && !strings.HasPrefix(name, "@") {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	more := len(names) > maxProxyFields
+	if more {
+		names = names[:maxProxyFields]
+	}
+
+	fields := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		fields = append(fields, name+"="+briefValue(h[name]))
+	}
+
+	if more {
+		fields = append(fields, "…")
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// briefValue renders a field value of a proxy summary: objects by their simple class name (or their string value),
+// classes as Name.class, arrays by their length and strings quoted.
+func briefValue(v interface{}) string {
+	var s string
+
+	switch val := v.(type) {
+	case nil:
+		s = "null"
+	case string:
+		s = strconv.Quote(val)
+	case *clazz:
+		s = simpleClassName(val.name) + ".class"
+	case []interface{}:
+		s = "[" + strconv.Itoa(len(val)) + "]"
+	case map[string]interface{}:
+		if str, isString := val["value"].(string); isString {
+			s = strconv.Quote(str)
+		} else if name := objectClassName(val); name == proxyClassName {
+			s = "Proxy"
+		} else {
+			s = simpleClassName(name)
+		}
+	default:
+		s = fmt.Sprint(val)
+	}
+
+	if runes := []rune(s); len(runes) > maxProxyValue {
+		s = string(runes[:maxProxyValue]) + "…"
+	}
+
+	return s
+}
+
+// simpleClassName returns the name of a class without its package.
+func simpleClassName(name string) string {
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
@@ -0,0 +1,30 @@
+package pkg
+
+// KeepAnnotationBytes keeps the exact bytes of every classAnnotation and objectAnnotation region, TC_ENDBLOCKDATA
+// included, next to their parsed view: under "@raw" in objects and as the rawAnnotations of class descriptors. They
+// allow re-serializing byte for byte and analyzing writeObject data the parser does not understand. The input is
+// kept in memory while parsing.
+func KeepAnnotationBytes() Option {
+	return func(this *SerializedObjectParser) {
+		if this.src != nil {
+			this.src.recording = true
+		}
+	}
+}
+
+// rawSince returns a copy of the input consumed since start (a Consumed value), nil when the input is not recorded.
+func (this *SerializedObjectParser) rawSince(start int64) []byte {
+	if this.src == nil || !this.src.recording {
+		return nil
+	}
+
+	end := this.Consumed()
+	if start < 0 || end > int64(len(this.src.recorded)) || start > end {
+		return nil
+	}
+
+	raw := make([]byte, end-start)
+	copy(raw, this.src.recorded[start:end])
+
+	return raw
+}
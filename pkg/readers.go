@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// bufferOptions returns the options of a parser reading a whole buffer: the buffer defaults, then the defaults of
+// the helper, then the caller options which take precedence.
+func bufferOptions(buf []byte, options []Option, defaults ...Option) []Option {
+	return append(append([]Option{SetMaxDataBlockSize(len(buf)), withInput(buf)}, defaults...), options...)
+}
+
+func readStream(rd io.Reader) ([]byte, error) {
+	buf, err := ioutil.ReadAll(rd)
+
+	return buf, errors.Wrap(err, "error reading stream")
+}
+
+// ParseReader is ParseSerializedObject reading the stream from rd. Like the other Reader variants it reads the whole
+// stream first, so that the options, the memory cap estimate and the PanicError snippets behave exactly like with
+// a buffer; streams too large to be held in memory are parsed with NewSerializedObjectParser and NextElement.
+func ParseReader(rd io.Reader, options ...Option) ([]interface{}, error) {
+	buf, err := readStream(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSerializedObject(buf, options...)
+}
+
+// ParseReaderMinimal is ParseSerializedObjectMinimal reading the stream from rd.
+func ParseReaderMinimal(rd io.Reader, options ...Option) ([]interface{}, error) {
+	buf, err := readStream(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSerializedObjectMinimal(buf, options...)
+}
+
+// DumpReader is DumpSerializedObject reading the stream from rd.
+func DumpReader(rd io.Reader, options ...Option) error {
+	buf, err := readStream(rd)
+	if err != nil {
+		return err
+	}
+
+	return DumpSerializedObject(buf, options...)
+}
+
+// AnalyzeReader is Analyze reading the stream from rd, a read error is reported as the analysis error.
+func AnalyzeReader(rd io.Reader, options ...Option) *Analysis {
+	buf, err := readStream(rd)
+	if err != nil {
+		return &Analysis{Error: err.Error()}
+	}
+
+	return Analyze(buf, options...)
+}
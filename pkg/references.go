@@ -0,0 +1,117 @@
+package pkg
+
+import "fmt"
+
+// Kinds of ReferenceIssue.
+const (
+	ReferenceForward  = "forward"  // handle assigned later in the stream
+	ReferenceDangling = "dangling" // handle never assigned, or cleared by a reset
+	ReferenceMismatch = "mismatch" // handle of a kind the reading position does not accept
+)
+
+// ReferenceIssue is a TC_REFERENCE which ObjectInputStream rejects, and which no ObjectOutputStream writes: a strong
+// sign of a handcrafted stream.
+type ReferenceIssue struct {
+	Kind   string `json:"kind"`
+	Offset int    `json:"offset"` // offset of the TC_REFERENCE
+	Handle uint32 `json:"handle"`
+	Detail string `json:"detail"`
+}
+
+// referenceCheck collects the reference issues while an estimator walks a stream.
+type referenceCheck struct {
+	issues  []ReferenceIssue
+	pending []int // issues of unassigned handles, forward or dangling once the handle table is cleared
+}
+
+// check records the issue of a reference to handle h (index idx) read at offset where an element of kind expect
+// is read, 0 for any.
+func (this *referenceCheck) check(offset int, h uint32, idx int, expect byte, kinds []byte) {
+	issue := ReferenceIssue{Offset: offset, Handle: h}
+
+	switch {
+	case idx < 0:
+		issue.Kind, issue.Detail = ReferenceDangling, "handle below the first wire handle"
+	case idx >= len(kinds):
+		this.pending = append(this.pending, len(this.issues))
+		this.issues = append(this.issues, issue)
+
+		return
+	case expect == TC_CLASSDESC && kinds[idx] != TC_CLASSDESC && kinds[idx] != TC_PROXYCLASSDESC,
+		expect == TC_STRING && kinds[idx] != TC_STRING:
+		issue.Kind = ReferenceMismatch
+		issue.Detail = fmt.Sprintf("reference to a %s where a %s is expected", handleKindName(kinds[idx]),
+			handleKindName(expect))
+	default:
+		return
+	}
+
+	this.issues = append(this.issues, issue)
+}
+
+// classify settles the pending issues once the handle table holding assigned handles is cleared.
+func (this *referenceCheck) classify(assigned int) {
+	for _, i := range this.pending {
+		issue := &this.issues[i]
+
+		if int(issue.Handle)-baseWireHandle < assigned {
+			issue.Kind, issue.Detail = ReferenceForward, "handle assigned after the reference"
+		} else {
+			issue.Kind, issue.Detail = ReferenceDangling, "handle never assigned"
+		}
+	}
+
+	this.pending = this.pending[:0]
+}
+
+// handleKindName returns the TC_ name of a handle kind.
+func handleKindName(kind byte) string {
+	if tag, exists := contentTags[kind]; exists {
+		return elementConstName(tag.name)
+	}
+
+	return fmt.Sprintf("%#02x", kind)
+}
+
+// CheckReferences verifies that every TC_REFERENCE of a stream points to an already assigned handle of a kind its
+// position accepts: a class descriptor for the class of objects, arrays, enums and super classes, a string for
+// field types and enum constant names. The error tells why the walk stopped early, the issues found so far being
+// returned along with it.
+func CheckReferences(data []byte) ([]ReferenceIssue, error) {
+	this := &estimator{b: data, res: &SizeEstimate{}, refs: &referenceCheck{}}
+
+	err := this.walk()
+	this.refs.classify(len(this.handles))
+
+	return this.refs.issues, err
+}
+
+// referenceRules maps the ReferenceIssue kinds to their rule IDs and titles.
+var referenceRules = map[string][2]string{
+	ReferenceForward:  {"REF-FORWARD", "reference to a handle assigned later"},
+	ReferenceDangling: {"REF-DANGLING", "reference to an unassigned handle"},
+	ReferenceMismatch: {"REF-MISMATCH", "reference to a handle of the wrong kind"},
+}
+
+func init() {
+	RegisterDetector("references", referencesDetector)
+}
+
+// referencesDetector raises a finding per reference issue, ObjectInputStream would reject the stream.
+func referencesDetector(analysis *Analysis, _ []interface{}) []Finding {
+	issues, _ := CheckReferences(analysis.raw)
+
+	var findings []Finding
+
+	for _, issue := range issues {
+		rule := referenceRules[issue.Kind]
+		findings = append(findings, Finding{
+			RuleID:   rule[0],
+			Severity: SeverityMedium,
+			Title:    rule[1] + ", the stream was handcrafted",
+			Detail:   fmt.Sprintf("handle %#x at offset %d: %s", issue.Handle, issue.Offset, issue.Detail),
+		})
+	}
+
+	return findings
+}
@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// relocation maps a class name, or a package prefix ending with '.', to its original.
+type relocation struct {
+	from, to string
+}
+
+// Relocations maps the classes of shaded (relocated) libraries back to their original names, so that class
+// fingerprints and gadget detection see e.g. org.shaded.commons.collections.map.LazyMap as
+// org.apache.commons.collections.map.LazyMap.
+type Relocations struct {
+	rules []relocation // longest prefix first
+}
+
+// ParseRelocations reads a relocation map, one "from -> to" rule per line. A rule ending with ".*" relocates a
+// package and its sub-packages, other rules a single class. Blank lines and lines starting with '#' are ignored.
+//
+//	org.shaded.commons.* -> org.apache.commons.*
+//	com.vendor.Invoker -> org.apache.commons.collections.functors.InvokerTransformer
+func ParseRelocations(rd io.Reader) (*Relocations, error) {
+	res := &Relocations{}
+	sc := bufio.NewScanner(rd)
+
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		sep := "->"
+		if !strings.Contains(text, sep) {
+			sep = "→"
+		}
+
+		parts := strings.SplitN(text, sep, 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("line %d: invalid relocation '%s', want from -> to", line, text)
+		}
+
+		if err := res.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			return nil, errors.Wrapf(err, "line %d", line)
+		}
+	}
+
+	return res, errors.Wrap(sc.Err(), "error reading relocations")
+}
+
+// LoadRelocations reads a relocation map file, see ParseRelocations.
+func LoadRelocations(path string) (*Relocations, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening relocations")
+	}
+	defer f.Close()
+
+	return ParseRelocations(f)
+}
+
+// Add adds a relocation rule, both sides being packages (ending with ".*") or classes.
+func (this *Relocations) Add(from, to string) error {
+	fromPkg, toPkg := strings.HasSuffix(from, ".*"), strings.HasSuffix(to, ".*")
+	if fromPkg != toPkg || from == "" || to == "" {
+		return errors.Errorf("invalid relocation '%s -> %s'", from, to)
+	}
+
+	if fromPkg {
+		from, to = strings.TrimSuffix(from, "*"), strings.TrimSuffix(to, "*")
+	}
+
+	this.rules = append(this.rules, relocation{from, to})
+
+	sort.SliceStable(this.rules, func(i, j int) bool { return len(this.rules[i].from) > len(this.rules[j].from) })
+
+	return nil
+}
+
+// Relocate returns the original name of a class, array class names ([Lorg.shaded.X;) included.
+func (this *Relocations) Relocate(name string) string {
+	if this == nil {
+		return name
+	}
+
+	dims := strings.LastIndex(name, "[") + 1
+	if dims > 0 {
+		if !strings.HasPrefix(name[dims:], "L") || !strings.HasSuffix(name, ";") {
+			return name
+		}
+
+		return name[:dims+1] + this.Relocate(name[dims+1:len(name)-1]) + ";"
+	}
+
+	for _, rule := range this.rules {
+		switch {
+		case name == rule.from:
+			return rule.to
+		case strings.HasSuffix(rule.from, ".") && strings.HasPrefix(name, rule.from):
+			return rule.to + name[len(rule.from):]
+		}
+	}
+
+	return name
+}
+
+// SetRelocations maps the class names read from the stream back to their original names: the parsed objects, class
+// cache statistics and analysis see the relocated names, the dump prints the original name next to the shaded one.
+func SetRelocations(relocations *Relocations) Option {
+	return func(this *SerializedObjectParser) {
+		this.relocations = relocations
+	}
+}
@@ -0,0 +1,64 @@
+package pkg
+
+import "encoding/binary"
+
+// renumbering records the handle edits of RenumberHandles while an estimator walks a stream.
+type renumbering struct {
+	handles    []int // new handle of each handle assigned since the last reset
+	next       int   // next new handle
+	references []handleEdit
+	resets     []int // offsets of the TC_RESET bytes to drop
+}
+
+// handleEdit is a TC_REFERENCE handle to rewrite.
+type handleEdit struct {
+	offset int // offset of the 4 handle bytes
+	handle int
+}
+
+func (this *renumbering) assign() {
+	this.handles = append(this.handles, this.next)
+	this.next++
+}
+
+func (this *renumbering) reference(offset, idx int) {
+	this.references = append(this.references, handleEdit{offset: offset, handle: this.handles[idx]})
+}
+
+// reset follows a handle table reset at offset: a TC_RESET is dropped and the numbering goes on, the implicit resets
+// around a TC_EXCEPTION cannot be dropped and restart the numbering.
+func (this *renumbering) reset(offset int, implicit bool) {
+	this.handles = this.handles[:0]
+
+	if implicit {
+		this.next = baseWireHandle
+	} else {
+		this.resets = append(this.resets, offset)
+	}
+}
+
+// RenumberHandles re-encodes a stream with its handles numbered densely from 0x7E0000 in traversal order across the
+// whole stream, TC_REFERENCE values rewritten accordingly and the TC_RESET markers, which only restart the
+// numbering, dropped. Re-serialized variants of a graph then only differ by their values and structure, which makes
+// their diffs and dumps meaningful. Streams which Estimate cannot walk completely are refused.
+func RenumberHandles(data []byte) ([]byte, error) {
+	this := &estimator{b: data, res: &SizeEstimate{}, renum: &renumbering{next: baseWireHandle}}
+
+	if err := this.walk(); err != nil {
+		return nil, err
+	}
+
+	res := append([]byte(nil), data...)
+
+	for _, edit := range this.renum.references {
+		binary.BigEndian.PutUint32(res[edit.offset:], uint32(edit.handle))
+	}
+
+	// resets are recorded in stream order
+	for i := len(this.renum.resets) - 1; i >= 0; i-- {
+		offset := this.renum.resets[i]
+		res = append(res[:offset], res[offset+1:]...)
+	}
+
+	return res, nil
+}
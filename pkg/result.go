@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// ParseStats are the counters of a parse.
+type ParseStats struct {
+	Elements int           `json:"elements"` // top-level elements
+	Consumed int64         `json:"consumed"` // input bytes read
+	Protocol ProtocolInfo  `json:"protocol"`
+	Input    int64         `json:"input"`              // input bytes
+	Trailing *TrailingData `json:"trailing,omitempty"` // input left after the last complete top-level element
+}
+
+// ParseResult is the result of Parse. It is not modified once returned, and computes its expensive views (minimal
+// content, minimal JSON, dump text) on first use only, caching them; it is safe for concurrent use as long as the
+// callers do not modify the values they get.
+type ParseResult struct {
+	root     []interface{}
+	classes  []ClassStat
+	handles  int
+	stats    ParseStats
+	warnings []Warning
+	raw      []byte
+	options  []Option
+
+	minimalOnce sync.Once
+	minimal     []interface{}
+
+	jsonOnce sync.Once
+	json     []byte
+	jsonErr  error
+
+	dumpOnce sync.Once
+	dump     string
+	dumpErr  error
+}
+
+// Parse parses a serialized java object like ParseSerializedObject, and returns a ParseResult. Like the content of
+// ParseSerializedObject, the result holds the elements parsed before an error.
+func Parse(buf []byte, options ...Option) (*ParseResult, error) {
+	parser := NewSerializedObjectParser(bytes.NewReader(buf), bufferOptions(buf, options)...)
+	root, err := parser.parseWithinCap(buf)
+
+	res := &ParseResult{
+		root:    root,
+		classes: classStats(parser.handles),
+		handles: len(parser.handles),
+		stats: ParseStats{Elements: len(root), Consumed: parser.Consumed(), Protocol: *parser.Protocol(),
+			Input: int64(len(buf)), Trailing: parser.Trailing()},
+		raw:      buf,
+		options:  options,
+		warnings: parser.Warnings(),
+	}
+
+	return res, err
+}
+
+// classStats counts the class descriptors of the handles by layout, most frequent first.
+func classStats(handles []interface{}) []ClassStat {
+	index := map[string]*ClassStat{}
+
+	for _, h := range handles {
+		cls, isClass := h.(*clazz)
+		if !isClass {
+			continue
+		}
+
+		key := classLayoutKey(cls)
+		if index[key] == nil {
+			index[key] = &ClassStat{Key: key, Name: cls.name, SerialVersionUID: cls.serialVersionUID,
+				Flags: cls.flags, FieldCount: len(cls.fields), Streams: 1}
+		}
+
+		index[key].Count++
+	}
+
+	stats := make([]ClassStat, 0, len(index))
+	for _, stat := range index {
+		stats = append(stats, *stat)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+
+		return stats[i].Key < stats[j].Key
+	})
+
+	return stats
+}
+
+// Root returns the top-level elements, as returned by ParseSerializedObject.
+func (this *ParseResult) Root() []interface{} {
+	return append([]interface{}(nil), this.root...)
+}
+
+// Classes returns the class layouts declared by the stream, most frequent first.
+func (this *ParseResult) Classes() []ClassStat {
+	return append([]ClassStat(nil), this.classes...)
+}
+
+// Handles returns the number of handles assigned by the stream.
+func (this *ParseResult) Handles() int {
+	return this.handles
+}
+
+// Stats returns the counters of the parse.
+func (this *ParseResult) Stats() ParseStats {
+	return this.stats
+}
+
+// Warnings returns the anomalies met by the parse which did not stop it.
+func (this *ParseResult) Warnings() []Warning {
+	return append([]Warning(nil), this.warnings...)
+}
+
+// RawLength returns the length of the input.
+func (this *ParseResult) RawLength() int {
+	return len(this.raw)
+}
+
+// Minimal returns the minimal representation of the content, see ParseSerializedObjectMinimal.
+func (this *ParseResult) Minimal() []interface{} {
+	this.minimalOnce.Do(func() {
+		this.minimal = jsonFriendlyArray(this.root)
+	})
+
+	return this.minimal
+}
+
+// MinimalJSON returns the MarshalMinimal encoding of the content.
+func (this *ParseResult) MinimalJSON() ([]byte, error) {
+	this.jsonOnce.Do(func() {
+		this.json, this.jsonErr = MarshalMinimal(this.root)
+	})
+
+	return this.json, this.jsonErr
+}
+
+// DumpText returns the DumpSerializedObject output of the input, with the options of the parse.
+func (this *ParseResult) DumpText() (string, error) {
+	this.dumpOnce.Do(func() {
+		var buf bytes.Buffer
+
+		this.dumpErr = DumpSerializedObject(this.raw, append(append([]Option(nil), this.options...),
+			SetDumpWriter(&buf))...)
+		this.dump = buf.String()
+	})
+
+	return this.dump, this.dumpErr
+}
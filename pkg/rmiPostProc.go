@@ -0,0 +1,383 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// rmiStubSuffix ends the names of the stub classes generated by rmic, such as RegistryImpl_Stub.
+const rmiStubSuffix = "_Stub"
+
+// rmiRefPackage is the package of the RemoteRef classes written by their short name.
+const rmiRefPackage = "sun.rmi.server."
+
+// TCPEndpoint formats, the second one being followed by the client socket factory.
+const (
+	rmiFormatHostPort        = 0
+	rmiFormatHostPortFactory = 1
+)
+
+func init() {
+	KnownPostProcs["java.rmi.server.RemoteObject@d361b4910c61331e"] = remoteObjectPostProc
+	KnownPostProcs["java.rmi.activation.ActivationID@c00ab4463fdbaead"] = activationIDPostProc
+
+	objectPostProcs["java.rmi.server.UID"] = uidPostProc
+	objectPostProcs["java.rmi.activation.ActivationGroupID"] = activationGroupIDPostProc
+	objectPostProcs["java.rmi.activation.ActivationDesc"] = activationDescPostProc
+	objectPostProcs["java.rmi.activation.ActivationGroupDesc"] = activationGroupDescPostProc
+	objectPostProcs[proxyClassName] = proxyPostProc
+	objectPostProcs["*"+rmiStubSuffix] = stubPostProc
+}
+
+// rmiRefReader reads the external form of a RemoteRef from the annotation of an object, block data interleaved
+// with objects.
+type rmiRefReader struct {
+	data  []interface{}
+	block []byte
+}
+
+// bytes returns the next n bytes of block data.
+func (this *rmiRefReader) bytes(n int) (b []byte, err error) {
+	for len(this.block) < n && len(this.data) > 0 {
+		next, isBlock := this.data[0].([]byte)
+		if !isBlock {
+			break
+		}
+
+		this.block = append(this.block, next...)
+		this.data = this.data[1:]
+	}
+
+	if len(this.block) < n {
+		return nil, errors.Errorf("remote reference truncated: %d bytes required, %d available", n, len(this.block))
+	}
+
+	b, this.block = this.block[:n], this.block[n:]
+
+	return
+}
+
+// object returns the next object, which must follow the block data consumed so far.
+func (this *rmiRefReader) object() (obj interface{}, err error) {
+	if len(this.block) > 0 || len(this.data) == 0 {
+		return nil, errors.New("remote reference object not found")
+	}
+
+	if _, isBlock := this.data[0].([]byte); isBlock {
+		return nil, errors.New("remote reference object not found")
+	}
+
+	obj, this.data = this.data[0], this.data[1:]
+
+	return
+}
+
+func (this *rmiRefReader) byte() (byte, error) {
+	b, err := this.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+func (this *rmiRefReader) int16() (int16, error) {
+	b, err := this.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+
+	return int16(binary.BigEndian.Uint16(b)), nil
+}
+
+func (this *rmiRefReader) int32() (int32, error) {
+	b, err := this.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (this *rmiRefReader) int64() (int64, error) {
+	b, err := this.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+// utf reads a string written by writeUTF, ascii being the common case of host and class names.
+func (this *rmiRefReader) utf() (string, error) {
+	size, err := this.int16()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := this.bytes(int(uint16(size)))
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// ref reads the external form of a RemoteRef of the named class, as written by RemoteObject.writeObject, to a
+// summary: the ref class, the endpoint, the object number and the client socket factory. The refs of unknown
+// classes are only named.
+func (this *rmiRefReader) ref(refClass string) (summary map[string]interface{}, err error) {
+	summary = map[string]interface{}{"ref": refClass}
+
+	switch strings.TrimPrefix(refClass, rmiRefPackage) {
+	case "":
+		var ref interface{}
+
+		if ref, err = this.object(); err != nil {
+			return
+		}
+
+		if obj, isMap := ref.(map[string]interface{}); isMap {
+			summary["ref"] = objectClassName(obj)
+		}
+	case "UnicastRef", "UnicastServerRef":
+		err = this.liveRef(summary, false)
+	case "UnicastRef2", "UnicastServerRef2":
+		err = this.liveRef(summary, true)
+	case "ActivatableRef":
+		var id interface{}
+
+		if id, err = this.object(); err != nil {
+			return
+		}
+
+		if obj, isMap := id.(map[string]interface{}); isMap {
+			summary["activationID"] = obj["value"]
+		}
+
+		var router string
+
+		if router, err = this.utf(); err != nil || router == "" {
+			return
+		}
+
+		var routerRef map[string]interface{}
+
+		if routerRef, err = this.ref(router); err != nil {
+			return
+		}
+
+		for k, v := range routerRef {
+			if k != "ref" {
+				summary[k] = v
+			}
+		}
+	}
+
+	return
+}
+
+// liveRef reads a sun.rmi.transport.LiveRef: the TCPEndpoint, in its format with a leading format byte for the
+// UnicastRef2 classes, followed by the ObjID and a boolean.
+func (this *rmiRefReader) liveRef(summary map[string]interface{}, newFormat bool) (err error) {
+	format := byte(rmiFormatHostPort)
+
+	if newFormat {
+		if format, err = this.byte(); err != nil {
+			return
+		}
+	}
+
+	var host string
+	var port int32
+
+	if host, err = this.utf(); err != nil {
+		return
+	}
+
+	if port, err = this.int32(); err != nil {
+		return
+	}
+
+	summary["endpoint"] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+
+	if format == rmiFormatHostPortFactory {
+		var csf interface{}
+
+		if csf, err = this.object(); err != nil {
+			return
+		}
+
+		if obj, isMap := csf.(map[string]interface{}); isMap {
+			summary["factory"] = objectClassName(obj)
+		}
+	}
+
+	var objNum int64
+
+	if objNum, err = this.int64(); err != nil {
+		return
+	}
+
+	summary["objID"] = objNum
+
+	// the UID of the address space, then the boolean telling the ref was sent by the DGC
+	_, err = this.bytes(4 + 8 + 2 + 1)
+
+	return
+}
+
+// remoteObjectPostProc decodes the RemoteRef written by the writeObject of java.rmi.server.RemoteObject, the super
+// class of stubs and of the RemoteObjectInvocationHandler of remote proxies, to a summary of the remote endpoint.
+func remoteObjectPostProc(fields map[string]interface{}, data []interface{}) (map[string]interface{}, error) {
+	rd := &rmiRefReader{data: data}
+
+	refClass, err := rd.utf()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading remote reference class")
+	}
+
+	summary, err := rd.ref(refClass)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading remote reference %s", refClass)
+	}
+
+	fields["value"] = summary
+
+	return fields, nil
+}
+
+// activationIDPostProc decodes a java.rmi.activation.ActivationID, its UID followed by the RemoteRef of its
+// activator.
+func activationIDPostProc(fields map[string]interface{}, data []interface{}) (map[string]interface{}, error) {
+	rd := &rmiRefReader{data: data}
+
+	uid, err := rd.object()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading activation id uid")
+	}
+
+	refClass, err := rd.utf()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading activator reference class")
+	}
+
+	activator, err := rd.ref(refClass)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading activator reference %s", refClass)
+	}
+
+	value := map[string]interface{}{"activator": activator}
+
+	if obj, isMap := uid.(map[string]interface{}); isMap {
+		value["uid"] = obj["value"]
+	}
+
+	fields["value"] = value
+
+	return fields, nil
+}
+
+// uidPostProc formats a java.rmi.server.UID like its toString method, unique:time:count in hexadecimal.
+func uidPostProc(obj map[string]interface{}) {
+	unique, isInt := obj["unique"].(int32)
+	t, isLong := obj["time"].(int64)
+	count, isShort := obj["count"].(int16)
+
+	if isInt && isLong && isShort {
+		obj["value"] = strconv.FormatInt(int64(unique), 16) + ":" + strconv.FormatInt(t, 16) + ":" +
+			strconv.FormatInt(int64(count), 16)
+	}
+}
+
+// activationGroupIDPostProc summarizes a java.rmi.activation.ActivationGroupID by its UID and the endpoint of its
+// activation system.
+func activationGroupIDPostProc(obj map[string]interface{}) {
+	value := map[string]interface{}{}
+
+	if uid, isMap := obj["uid"].(map[string]interface{}); isMap {
+		value["uid"] = uid["value"]
+	}
+
+	if system, isMap := obj["system"].(map[string]interface{}); isMap {
+		value["system"] = system["value"]
+	}
+
+	obj["value"] = value
+}
+
+// activationDescPostProc summarizes a java.rmi.activation.ActivationDesc: the class activated, where it is loaded
+// from, the group it is activated in and its restart mode.
+func activationDescPostProc(obj map[string]interface{}) {
+	value := map[string]interface{}{
+		"className": obj["className"],
+		"location":  obj["location"],
+		"restart":   obj["restart"],
+	}
+
+	if group, isMap := obj["groupID"].(map[string]interface{}); isMap {
+		value["group"] = group["value"]
+	}
+
+	obj["value"] = value
+}
+
+// activationGroupDescPostProc summarizes a java.rmi.activation.ActivationGroupDesc: the group class, where it is
+// loaded from and the command line of the group JVM, a frequent gadget in activation traffic.
+func activationGroupDescPostProc(obj map[string]interface{}) {
+	value := map[string]interface{}{
+		"className": obj["className"],
+		"location":  obj["location"],
+	}
+
+	if env, isMap := obj["env"].(map[string]interface{}); isMap {
+		value["command"] = env["command"]
+		value["options"] = env["options"]
+	}
+
+	obj["value"] = value
+}
+
+// proxyPostProc summarizes a dynamic proxy by the interfaces it implements. The proxies of remote objects, with a
+// java.rmi.server.RemoteObjectInvocationHandler, take the endpoint summary of the handler.
+func proxyPostProc(obj map[string]interface{}) {
+	cls, _ := obj["class"].(*clazz)
+	if cls == nil {
+		return
+	}
+
+	value := map[string]interface{}{"interfaces": cls.interfaces}
+
+	h, isMap := obj["h"].(map[string]interface{})
+	if !isMap {
+		obj["value"] = value
+
+		return
+	}
+
+	value["handler"] = objectClassName(h)
+
+	if ref, isRef := h["value"].(map[string]interface{}); isRef &&
+		objectClassName(h) == "java.rmi.server.RemoteObjectInvocationHandler" {
+		delete(value, "handler")
+
+		for k, v := range ref {
+			value[k] = v
+		}
+
+		delete(obj, "h")
+	}
+
+	obj["value"] = value
+}
+
+// stubPostProc names the stub class in the endpoint summary of a stub generated by rmic.
+func stubPostProc(obj map[string]interface{}) {
+	if ref, isRef := obj["value"].(map[string]interface{}); isRef {
+		ref["stub"] = objectClassName(obj)
+	}
+}
@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrSandboxCrashed is the cause of the error returned by Sandbox.Analyze when the child process dies without
+// returning a result: crash, killed by a resource limit or garbled output.
+var ErrSandboxCrashed = errors.New("sandbox crashed")
+
+// SandboxEnv is the environment variable marking the sandbox children, it holds their limits.
+const SandboxEnv = "GO_PJS_SANDBOX"
+
+// maxSandboxStderr bounds the start of the child's stderr kept for the crash errors.
+const maxSandboxStderr = 4 << 10
+
+// SandboxLimits are the resource limits of a sandbox child, zero values leave a resource unlimited. They are applied
+// as rlimits on Linux, macOS and FreeBSD, and ignored elsewhere. On these systems the child also cannot open files or
+// sockets beyond its standard streams, write files nor dump core, whatever the limits.
+type SandboxLimits struct {
+	Memory int64         // data segment (heap) in bytes
+	CPU    time.Duration // CPU time, rounded up to the second
+}
+
+// String encodes the limits for SandboxEnv.
+func (this SandboxLimits) String() string {
+	return fmt.Sprintf("%d:%d", this.Memory, int64((this.CPU+time.Second-1)/time.Second))
+}
+
+func parseSandboxLimits(s string) (limits SandboxLimits, err error) {
+	var seconds int64
+
+	if _, err = fmt.Sscanf(s, "%d:%d", &limits.Memory, &seconds); err != nil {
+		return limits, errors.Wrapf(err, "invalid sandbox limits '%s'", s)
+	}
+
+	limits.CPU = time.Duration(seconds) * time.Second
+
+	return limits, nil
+}
+
+// Sandbox parses and analyzes payloads in child processes, so that a crash or a pathological payload only takes
+// down the child. The child is the current program re-executed with SandboxEnv set, which must call RunSandboxChild
+// with the same parser options instead of its usual work when IsSandboxChild is true.
+type Sandbox struct {
+	Command []string // child command line, the current executable and arguments by default
+	Limits  SandboxLimits
+}
+
+// sandboxResult is the message written by a child to its parent.
+type sandboxResult struct {
+	Analysis *Analysis     `json:"analysis,omitempty"`
+	Error    *RequestError `json:"error,omitempty"`
+}
+
+// IsSandboxChild tells whether the current process is a sandbox child.
+func IsSandboxChild() bool {
+	_, isChild := os.LookupEnv(SandboxEnv)
+
+	return isChild
+}
+
+// Analyze sends a payload to a new child and returns its analysis. The child is killed once ctx is done, the
+// context error being returned. Errors the child reports, such as ErrMemoryCap, are returned as a RequestError.
+func (this *Sandbox) Analyze(ctx context.Context, payload []byte) (*Analysis, error) {
+	command := this.Command
+	if len(command) == 0 {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, errors.Wrap(err, "error locating the sandbox executable")
+		}
+
+		command = append([]string{exe}, os.Args[1:]...)
+	}
+
+	var stdout bytes.Buffer
+
+	stderr := &headBuffer{max: maxSandboxStderr}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), SandboxEnv+"="+this.Limits.String())
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(ErrSandboxCrashed, "%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var res sandboxResult
+
+	if err = json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return nil, errors.Wrapf(ErrSandboxCrashed, "invalid child output: %v", err)
+	}
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	if res.Analysis == nil {
+		return nil, errors.Wrap(ErrSandboxCrashed, "empty child output")
+	}
+
+	res.Analysis.raw = payload
+
+	return res.Analysis, nil
+}
+
+// RunSandboxChild is the work of a sandbox child: it applies the limits of SandboxEnv, reads a payload from r,
+// analyzes it with options and writes the result to w for Sandbox.Analyze.
+func RunSandboxChild(r io.Reader, w io.Writer, options ...Option) error {
+	limits, err := parseSandboxLimits(os.Getenv(SandboxEnv))
+	if err != nil {
+		return err
+	}
+
+	if err = limits.apply(); err != nil {
+		return errors.Wrap(err, "error applying the sandbox limits")
+	}
+
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "error reading the sandbox payload")
+	}
+
+	var res sandboxResult
+
+	parsed := parseForAnalysis(payload, options)
+
+	if isAbortingParseError(parsed.err) {
+		res.Error = AsRequestError(parsed.err)
+	} else if err = parsed.analyze(); err != nil {
+		res.Error = AsRequestError(err)
+	} else {
+		res.Analysis = parsed.res
+	}
+
+	return json.NewEncoder(w).Encode(&res)
+}
+
+// headBuffer keeps the first max bytes written to it.
+type headBuffer struct {
+	buf []byte
+	max int
+}
+
+func (this *headBuffer) Write(p []byte) (int, error) {
+	if n := this.max - len(this.buf); n > 0 {
+		if len(p) < n {
+			n = len(p)
+		}
+
+		this.buf = append(this.buf, p[:n]...)
+	}
+
+	return len(p), nil
+}
+
+func (this *headBuffer) String() string {
+	return string(this.buf)
+}
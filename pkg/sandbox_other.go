@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package pkg
+
+// apply is a no-op where rlimits are not available, the child only isolates the host process from crashes.
+func (this SandboxLimits) apply() error {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build linux || darwin || freebsd
+
+package pkg
+
+import "syscall"
+
+// apply sets the limits of the current process, standard streams being the only files it may keep open.
+func (this SandboxLimits) apply() error {
+	limits := map[int]uint64{syscall.RLIMIT_CORE: 0, syscall.RLIMIT_FSIZE: 0, syscall.RLIMIT_NOFILE: 3}
+
+	if this.Memory > 0 {
+		limits[syscall.RLIMIT_DATA] = uint64(this.Memory)
+	}
+
+	if seconds := uint64(this.CPU.Seconds()); seconds > 0 {
+		limits[syscall.RLIMIT_CPU] = seconds
+	}
+
+	for resource, max := range limits {
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: max, Max: max}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
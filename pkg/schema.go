@@ -0,0 +1,211 @@
+package pkg
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jsonSchemaDraft is the JSON Schema dialect of the published schemas.
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonValueSchema matches any parsed value of the minimal JSON output.
+var jsonValueSchema = map[string]interface{}{
+	"type": []string{"null", "boolean", "number", "string", "array", "object"},
+}
+
+// classDescSchema describes a class descriptor as encoded in the full JSON output.
+var classDescSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name":             map[string]interface{}{"type": "string"},
+		"serialVersionUID": map[string]interface{}{"type": "string", "pattern": "^[0-9a-f]{16}$"},
+		"flags":            map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 255},
+		"isEnum":           map[string]interface{}{"type": "boolean"},
+		"interfaces":       map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"fields": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":      map[string]interface{}{"type": "string"},
+					"type":      map[string]interface{}{"type": "string"},
+					"className": map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"name", "type"},
+			},
+		},
+		"super":          map[string]interface{}{"$ref": "#/$defs/classDesc"},
+		"relocatedFrom":  map[string]interface{}{"type": "string"},
+		"obfuscatedName": map[string]interface{}{"type": "string"},
+		"rawAnnotations": map[string]interface{}{"type": "string", "contentEncoding": "base64"},
+	},
+	"required": []string{"name", "serialVersionUID", "flags"},
+}
+
+// Schemas maps the names of the JSON outputs to their JSON Schema:
+// "dump" for ParseSerializedObject, "minimal" for ParseSerializedObjectMinimal, "classes" for ClassCache.Stats,
+// "findings" for the findings of an analysis, "report" for a whole Analysis, "capabilities" for
+// CurrentCapabilities and "compat" for CheckCompatibility.
+var Schemas = map[string]map[string]interface{}{
+	"dump": {
+		"$schema":     jsonSchemaDraft,
+		"title":       "go-pjs full parse output",
+		"description": "top level elements of a stream, objects carry their class descriptor under \"class\" and their annotations under \"@\"",
+		"type":        "array",
+		"items":       map[string]interface{}{"$ref": "#/$defs/value"},
+		"$defs": map[string]interface{}{
+			"classDesc": classDescSchema,
+			"value": map[string]interface{}{
+				"anyOf": []interface{}{
+					map[string]interface{}{"type": []string{"null", "boolean", "number", "string"}},
+					map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/$defs/value"}},
+					map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"class":   map[string]interface{}{"$ref": "#/$defs/classDesc"},
+							"extends": map[string]interface{}{"type": "object"},
+							"@": map[string]interface{}{
+								"type":  []string{"array", "null"},
+								"items": map[string]interface{}{"$ref": "#/$defs/value"},
+							},
+							"@raw": map[string]interface{}{"type": "string", "contentEncoding": "base64"},
+						},
+						"additionalProperties": map[string]interface{}{"$ref": "#/$defs/value"},
+					},
+				},
+			},
+		},
+	},
+	"minimal": {
+		"$schema": jsonSchemaDraft,
+		"title":   "go-pjs minimal output",
+		"type":    "array",
+		"items":   jsonValueSchema,
+	},
+	"classes":      typeSchema("go-pjs class statistics", reflect.TypeOf([]ClassStat{})),
+	"findings":     typeSchema("go-pjs findings", reflect.TypeOf([]Finding{})),
+	"report":       typeSchema("go-pjs analysis report", reflect.TypeOf(Analysis{})),
+	"capabilities": typeSchema("go-pjs capabilities", reflect.TypeOf(Capabilities{})),
+	"compat":       typeSchema("go-pjs ObjectInputStream compatibility", reflect.TypeOf(Compatibility{})),
+}
+
+// SchemaNames returns the sorted names of Schemas.
+func SchemaNames() []string {
+	names := make([]string, 0, len(Schemas))
+	for name := range Schemas {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// typeSchema generates the JSON Schema of a Go type from its encoding/json mapping.
+func typeSchema(title string, t reflect.Type) map[string]interface{} {
+	schema := jsonSchemaOf(t)
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = title
+
+	return schema
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// jsonSchemaOf maps a Go type to the schema of its JSON encoding.
+func jsonSchemaOf(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(Severity(0)):
+		return map[string]interface{}{"type": "string", "enum": severityNames}
+	case t.Implements(textMarshalerType):
+		return map[string]interface{}{"type": "string"}
+	case t.Implements(jsonMarshalerType):
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := jsonSchemaOf(t.Elem())
+		if typ, isString := schema["type"].(string); isString {
+			schema["type"] = []string{typ, "null"}
+		}
+
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": jsonSchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+
+	// interface{} holds parsed content
+	return map[string]interface{}{}
+}
+
+// structSchema maps the exported fields of a struct following their json tags.
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		name, opts := f.Name, ""
+		if tag, hasTag := f.Tag.Lookup("json"); hasTag {
+			if tag == "-" {
+				continue
+			}
+
+			if idx := strings.Index(tag, ","); idx >= 0 {
+				name, opts = tag[:idx], tag[idx:]
+			} else {
+				name = tag
+			}
+
+			if name == "" {
+				name = f.Name
+			}
+		}
+
+		properties[name] = jsonSchemaOf(f.Type)
+
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
@@ -0,0 +1,252 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// schemaFixtures returns streams covering objects, block data, class descriptors, proxies, strings and
+// externalizable data.
+func schemaFixtures(t *testing.T) map[string][]byte {
+	t.Helper()
+
+	res := map[string][]byte{}
+
+	for name, build := range map[string]func() ([]byte, error){
+		"urldns":         func() ([]byte, error) { return URLDNSPayload("example.com") },
+		"proxy":          func() ([]byte, error) { return ProxyPayload("java.lang.Runnable,java.util.Map") },
+		"string":         func() ([]byte, error) { return EncodeString("go-pjs") },
+		"longstring":     func() ([]byte, error) { return EncodeString("go-pjs", WithStringForm(StringFormLong)) },
+		"externalizable": func() ([]byte, error) { return EncodeExternalizable("a.B", 1, []byte{1, 2, 3}) },
+	} {
+		b, err := build()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		res[name] = b
+	}
+
+	return res
+}
+
+func TestSchemas(t *testing.T) {
+	for name, data := range schemaFixtures(t) {
+		t.Run(name, func(t *testing.T) {
+			cache := NewClassCache()
+
+			content, err := ParseSerializedObject(data, SetClassCache(cache))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			validateSchema(t, "dump", content)
+			validateSchema(t, "classes", cache.Stats())
+
+			minimal, err := ParseSerializedObjectMinimal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			validateSchema(t, "minimal", minimal)
+
+			analysis := Analyze(data)
+			validateSchema(t, "report", analysis)
+			validateSchema(t, "findings", analysis.Findings)
+			validateSchema(t, "compat", CheckCompatibility(data))
+		})
+	}
+
+	validateSchema(t, "capabilities", CurrentCapabilities())
+}
+
+// validateSchema checks that the JSON encoding of v is valid against Schemas[name].
+func validateSchema(t *testing.T, name string, v interface{}) {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	var doc, schema interface{}
+	if err = json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	// round trip the schema too, so it is checked as published
+	if b, err = json.Marshal(Schemas[name]); err != nil {
+		t.Fatalf("%s schema: %v", name, err)
+	}
+
+	if err = json.Unmarshal(b, &schema); err != nil {
+		t.Fatalf("%s schema: %v", name, err)
+	}
+
+	validator := &schemaValidator{root: schema.(map[string]interface{})}
+	validator.validate(schema, doc, "$")
+
+	for _, e := range validator.errors {
+		t.Errorf("%s: %s", name, e)
+	}
+}
+
+// schemaValidator validates JSON documents against the subset of JSON Schema used by Schemas.
+type schemaValidator struct {
+	root   map[string]interface{}
+	errors []string
+}
+
+func (this *schemaValidator) fail(path, format string, args ...interface{}) {
+	this.errors = append(this.errors, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (this *schemaValidator) validate(schema, doc interface{}, path string) {
+	s, _ := schema.(map[string]interface{})
+	if s == nil {
+		if schema == false {
+			this.fail(path, "not allowed")
+		}
+
+		return
+	}
+
+	if ref, hasRef := s["$ref"].(string); hasRef {
+		def := this.root["$defs"].(map[string]interface{})[strings.TrimPrefix(ref, "#/$defs/")]
+		if def == nil {
+			this.fail(path, "unknown $ref %s", ref)
+
+			return
+		}
+
+		this.validate(def, doc, path)
+	}
+
+	if anyOf, hasAnyOf := s["anyOf"].([]interface{}); hasAnyOf {
+		matched := false
+
+		for _, sub := range anyOf {
+			v := &schemaValidator{root: this.root}
+			if v.validate(sub, doc, path); len(v.errors) == 0 {
+				matched = true
+
+				break
+			}
+		}
+
+		if !matched {
+			this.fail(path, "matches no anyOf schema")
+		}
+	}
+
+	if typ, hasType := s["type"]; hasType && !this.typeMatches(typ, doc) {
+		this.fail(path, "%T is not of type %v", doc, typ)
+
+		return
+	}
+
+	if enum, hasEnum := s["enum"].([]interface{}); hasEnum {
+		found := false
+
+		for _, e := range enum {
+			found = found || e == doc
+		}
+
+		if !found {
+			this.fail(path, "%v not in %v", doc, enum)
+		}
+	}
+
+	switch d := doc.(type) {
+	case string:
+		this.validateString(s, d, path)
+	case float64:
+		if min, hasMin := s["minimum"].(float64); hasMin && d < min {
+			this.fail(path, "%v below %v", d, min)
+		}
+
+		if max, hasMax := s["maximum"].(float64); hasMax && d > max {
+			this.fail(path, "%v above %v", d, max)
+		}
+	case []interface{}:
+		if items, hasItems := s["items"]; hasItems {
+			for i, item := range d {
+				this.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case map[string]interface{}:
+		this.validateObject(s, d, path)
+	}
+}
+
+func (this *schemaValidator) validateString(s map[string]interface{}, d, path string) {
+	if pattern, hasPattern := s["pattern"].(string); hasPattern && !regexp.MustCompile(pattern).MatchString(d) {
+		this.fail(path, "%q does not match %s", d, pattern)
+	}
+
+	if s["contentEncoding"] == "base64" {
+		if _, err := base64.StdEncoding.DecodeString(d); err != nil {
+			this.fail(path, "invalid base64: %v", err)
+		}
+	}
+
+	if s["format"] == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, d); err != nil {
+			this.fail(path, "invalid date-time: %v", err)
+		}
+	}
+}
+
+func (this *schemaValidator) validateObject(s, d map[string]interface{}, path string) {
+	properties, _ := s["properties"].(map[string]interface{})
+
+	if required, hasRequired := s["required"].([]interface{}); hasRequired {
+		for _, name := range required {
+			if _, exists := d[name.(string)]; !exists {
+				this.fail(path, "missing required property %s", name)
+			}
+		}
+	}
+
+	for name, value := range d {
+		if sub, exists := properties[name]; exists {
+			this.validate(sub, value, path+"."+name)
+		} else if additional, hasAdditional := s["additionalProperties"]; hasAdditional {
+			this.validate(additional, value, path+"."+name)
+		}
+	}
+}
+
+func (this *schemaValidator) typeMatches(typ, doc interface{}) bool {
+	if types, isList := typ.([]interface{}); isList {
+		for _, t := range types {
+			if this.typeMatches(t, doc) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	switch d := doc.(type) {
+	case nil:
+		return typ == "null"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || typ == "integer" && d == float64(int64(d))
+	case string:
+		return typ == "string"
+	case []interface{}:
+		return typ == "array"
+	case map[string]interface{}:
+		return typ == "object"
+	}
+
+	return false
+}
@@ -0,0 +1,270 @@
+package pkg
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.starlark.net/starlark"
+)
+
+// Script is a Starlark script hooking into the analysis of parsed streams. A script may define:
+//
+//	on_object(obj)   called for every parsed object, obj is a dict of its fields with the class name under "class"
+//	finish()         called once all objects were visited
+//
+// Both may return a value which is added to the extracted values of the run, and may call the builtin
+// finding(rule_id, severity, title, detail="", cls="") to raise findings. print() output is collected.
+type Script struct {
+	Name    string
+	globals starlark.StringDict
+}
+
+// ScriptResult is the outcome of running a script on a stream.
+type ScriptResult struct {
+	Findings  []Finding     `json:"findings,omitempty"`
+	Extracted []interface{} `json:"extracted,omitempty"`
+	Output    string        `json:"output,omitempty"`
+}
+
+// maxScriptSteps bounds the number of Starlark computation steps of a single hook call.
+const maxScriptSteps = 10_000_000
+
+// LoadScript loads a Starlark script from a file.
+func LoadScript(path string) (*Script, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read script")
+	}
+
+	return CompileScript(path, string(src))
+}
+
+// CompileScript compiles Starlark source, name is used in error messages.
+func CompileScript(name, src string) (*Script, error) {
+	thread := &starlark.Thread{Name: name}
+
+	globals, err := starlark.ExecFile(thread, name, src, starlark.StringDict{
+		"finding": starlark.NewBuiltin("finding", scriptFinding),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "load script %s", name)
+	}
+
+	return &Script{Name: name, globals: globals}, nil
+}
+
+// Run calls the script hooks on the parsed content.
+func (this *Script) Run(content []interface{}) (*ScriptResult, error) {
+	res := &ScriptResult{}
+
+	var output strings.Builder
+
+	thread := &starlark.Thread{
+		Name:  this.Name,
+		Print: func(_ *starlark.Thread, msg string) { output.WriteString(msg + "\n") },
+	}
+
+	thread.SetLocal("result", res)
+
+	call := func(hook string, args starlark.Tuple) error {
+		fn, defined := this.globals[hook].(starlark.Callable)
+		if !defined {
+			return nil
+		}
+
+		thread.SetMaxExecutionSteps(thread.ExecutionSteps() + maxScriptSteps)
+
+		v, err := starlark.Call(thread, fn, args, nil)
+		if err != nil {
+			return errors.Wrapf(err, "script %s: %s", this.Name, hook)
+		}
+
+		if v != starlark.None {
+			res.Extracted = append(res.Extracted, fromStarlark(v))
+		}
+
+		return nil
+	}
+
+	var err error
+
+	walkObjects(content, func(obj map[string]interface{}) {
+		if err == nil {
+			err = call("on_object", starlark.Tuple{toStarlark(obj, 0)})
+		}
+	})
+
+	if err == nil {
+		err = call("finish", nil)
+	}
+
+	res.Output = output.String()
+
+	return res, err
+}
+
+// Detector adapts the script to a Detector, extracted values are reported as info findings.
+func (this *Script) Detector() Detector {
+	return func(_ *Analysis, content []interface{}) []Finding {
+		res, err := this.Run(content)
+		if err != nil {
+			return []Finding{{RuleID: "SCRIPT-ERROR", Severity: SeverityInfo, Title: "script " + this.Name + " failed", Detail: err.Error()}}
+		}
+
+		findings := res.Findings
+
+		for _, v := range res.Extracted {
+			findings = append(findings, Finding{
+				RuleID: "SCRIPT-EXTRACT", Severity: SeverityInfo, Title: "extracted by " + this.Name, Detail: fmt.Sprint(v),
+			})
+		}
+
+		return findings
+	}
+}
+
+// scriptFinding implements the finding builtin, findings are added to the result of the running hook.
+func scriptFinding(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var ruleID, severity, title, detail, cls string
+
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs,
+		"rule_id", &ruleID, "severity", &severity, "title", &title, "detail?", &detail, "cls?", &cls); err != nil {
+		return nil, err
+	}
+
+	s, err := ParseSeverity(severity)
+	if err != nil {
+		return nil, err
+	}
+
+	res, running := thread.Local("result").(*ScriptResult)
+	if !running {
+		return nil, errors.New("finding called outside of a hook")
+	}
+
+	res.Findings = append(res.Findings, Finding{RuleID: ruleID, Severity: s, Title: title, Class: cls, Detail: detail})
+
+	return starlark.None, nil
+}
+
+// maxScriptDepth bounds the nesting of values converted for scripts, cyclic graphs are cut there.
+const maxScriptDepth = 64
+
+// toStarlark converts parsed content to Starlark values.
+func toStarlark(v interface{}, depth int) starlark.Value {
+	if depth > maxScriptDepth {
+		return starlark.None
+	}
+
+	switch o := v.(type) {
+	case nil:
+		return starlark.None
+	case bool:
+		return starlark.Bool(o)
+	case string:
+		return starlark.String(o)
+	case []byte:
+		return starlark.Bytes(o)
+	case int8:
+		return starlark.MakeInt(int(o))
+	case uint8:
+		return starlark.MakeInt(int(o))
+	case int16:
+		return starlark.MakeInt(int(o))
+	case uint16:
+		return starlark.MakeInt(int(o))
+	case int32:
+		return starlark.MakeInt(int(o))
+	case int64:
+		return starlark.MakeInt64(o)
+	case int:
+		return starlark.MakeInt(o)
+	case float32:
+		return starlark.Float(o)
+	case float64:
+		return starlark.Float(o)
+	case *clazz:
+		return starlark.String(o.name)
+	case []interface{}:
+		l := make([]starlark.Value, len(o))
+		for i, e := range o {
+			l[i] = toStarlark(e, depth+1)
+		}
+
+		return starlark.NewList(l)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(o))
+		for k := range o {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		d := starlark.NewDict(len(o))
+		for _, k := range keys {
+			if k == "extends" {
+				continue
+			}
+
+			_ = d.SetKey(starlark.String(k), toStarlark(o[k], depth+1))
+		}
+
+		return d
+	}
+
+	return starlark.String(fmt.Sprint(v))
+}
+
+// fromStarlark converts a Starlark value returned by a hook to a Go value.
+func fromStarlark(v starlark.Value) interface{} {
+	switch o := v.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.Bool:
+		return bool(o)
+	case starlark.String:
+		return string(o)
+	case starlark.Bytes:
+		return []byte(o)
+	case starlark.Int:
+		if i, ok := o.Int64(); ok {
+			return i
+		}
+
+		return new(big.Int).Set(o.BigInt())
+	case starlark.Float:
+		return float64(o)
+	case *starlark.List:
+		l := make([]interface{}, o.Len())
+		for i := range l {
+			l[i] = fromStarlark(o.Index(i))
+		}
+
+		return l
+	case starlark.Tuple:
+		l := make([]interface{}, len(o))
+		for i, e := range o {
+			l[i] = fromStarlark(e)
+		}
+
+		return l
+	case *starlark.Dict:
+		m := make(map[string]interface{}, o.Len())
+		for _, item := range o.Items() {
+			key := item[0].String()
+			if s, isString := item[0].(starlark.String); isString {
+				key = string(s)
+			}
+
+			m[key] = fromStarlark(item[1])
+		}
+
+		return m
+	}
+
+	return v.String()
+}
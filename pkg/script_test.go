@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScriptOnObject(t *testing.T) {
+	script, err := CompileScript("test.star", `
+def on_object(obj):
+    if obj.get("class") == "a.B":
+        finding("T1", "high", "saw a.B", detail="object", cls=obj["class"])
+        return obj["class"]
+
+def finish():
+    print("done")
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ParseSerializedObject(objectStream("a.B"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := script.Run(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Finding{{RuleID: "T1", Severity: SeverityHigh, Title: "saw a.B", Class: "a.B", Detail: "object"}}
+	if !reflect.DeepEqual(res.Findings, want) {
+		t.Errorf("got findings %+v, want %+v", res.Findings, want)
+	}
+
+	if !reflect.DeepEqual(res.Extracted, []interface{}{"a.B"}) || res.Output != "done\n" {
+		t.Errorf("got extracted %v, output %q", res.Extracted, res.Output)
+	}
+
+	findings := script.Detector()(nil, content)
+	if len(findings) != 2 || findings[0].RuleID != "T1" || findings[1].RuleID != "SCRIPT-EXTRACT" {
+		t.Errorf("got detector findings %+v, want T1 and the extracted value", findings)
+	}
+}
+
+func TestScriptStepLimit(t *testing.T) {
+	script, err := CompileScript("loop.star", `
+def finish():
+    for i in range(100000000):
+        pass
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = script.Run(nil); err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Fatalf("got %v, want the step limit", err)
+	}
+
+	if findings := script.Detector()(nil, nil); len(findings) != 1 || findings[0].RuleID != "SCRIPT-ERROR" {
+		t.Errorf("got %+v, want a script error finding", findings)
+	}
+}
+
+func TestScriptFindingErrors(t *testing.T) {
+	if _, err := CompileScript("top.star", `finding("T1", "high", "top level")`); err == nil {
+		t.Error("finding outside of a hook: got no error")
+	}
+
+	script, err := CompileScript("severity.star", `
+def finish():
+    finding("T1", "severe", "unknown severity")
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = script.Run(nil); err == nil {
+		t.Error("unknown severity: got no error")
+	}
+}
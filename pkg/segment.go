@@ -0,0 +1,67 @@
+package pkg
+
+import "bytes"
+
+// Segment is a run of top-level elements between TC_RESET elements, see SplitOnReset.
+type Segment struct {
+	Offset   int64         `json:"offset"` // offset of the first element in the original stream
+	Size     int64         `json:"size"`   // number of bytes of the elements, the resets around them excluded
+	Elements []interface{} `json:"elements"`
+}
+
+// SplitOnReset makes TC_RESET a record boundary: the top-level elements are also grouped into segments, returned by
+// Segments. Long-lived connections such as the JMX connectors reset the handles between their messages, each
+// segment is then a message. Consecutive resets make no empty segment.
+func SplitOnReset() Option {
+	return func(this *SerializedObjectParser) {
+		this.splitOnReset = true
+	}
+}
+
+// Segments returns the segments of the elements parsed so far when splitting on TC_RESET, nil otherwise.
+func (this *SerializedObjectParser) Segments() []*Segment {
+	return this.segments
+}
+
+// ParseSegments parses a serialized java object split on TC_RESET, see SplitOnReset. Like the content of
+// ParseSerializedObject, the segments hold the elements parsed before an error.
+func ParseSegments(buf []byte, options ...Option) (segments []*Segment, err error) {
+	options = bufferOptions(buf, options, SplitOnReset())
+	this := NewSerializedObjectParser(bytes.NewReader(buf), options...)
+
+	_, err = this.parseWithinCap(buf)
+
+	return this.Segments(), err
+}
+
+// skipResets consumes the TC_RESET elements at a top-level position: the handles assigned so far are discarded and,
+// when splitting, the current segment ends.
+func (this *SerializedObjectParser) skipResets() {
+	for {
+		if b, err := this.rd.Peek(1); err != nil || b[0] != TC_RESET {
+			return
+		}
+
+		_, _ = this.rd.Discard(1)
+		this.parsedEnd = this.Offset()
+		this.handleFloor = len(this.handles)
+		this.protocol.Resets++
+		this.segmentOpen = false
+	}
+}
+
+// addToSegment adds a top-level element starting at offset to the current segment, a new one when the last ended.
+func (this *SerializedObjectParser) addToSegment(content interface{}, offset int64) {
+	if !this.splitOnReset {
+		return
+	}
+
+	if !this.segmentOpen {
+		this.segments = append(this.segments, &Segment{Offset: offset})
+		this.segmentOpen = true
+	}
+
+	segment := this.segments[len(this.segments)-1]
+	segment.Elements = append(segment.Elements, content)
+	segment.Size = this.Offset() - segment.Offset
+}
@@ -0,0 +1,288 @@
+package pkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultReplayTimeout is the time allowed to a server response by Replay.
+const defaultReplayTimeout = 5 * time.Second
+
+// Directions of the messages of a session.
+const (
+	DirectionClient = "client" // sent by the client to the server
+	DirectionServer = "server" // sent by the server to the client
+)
+
+// SessionMessage is a chunk of a captured TCP session. Session files are JSON Lines, one message per line with its
+// data base64 encoded, in capture order.
+type SessionMessage struct {
+	Conn      int       `json:"conn"`      // connection of the message, in accept order from 1
+	Seq       int       `json:"seq"`       // position of the message in the session, from 1
+	Time      time.Time `json:"time"`      // capture time
+	Direction string    `json:"direction"` // DirectionClient or DirectionServer
+	Data      []byte    `json:"data"`
+}
+
+// SessionWriter appends messages to a session file, it is safe for concurrent use.
+type SessionWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	seq int
+}
+
+// NewSessionWriter creates a SessionWriter writing to w.
+func NewSessionWriter(w io.Writer) *SessionWriter {
+	return &SessionWriter{enc: json.NewEncoder(w)}
+}
+
+// Write appends a message captured now.
+func (this *SessionWriter) Write(conn int, direction string, data []byte) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.seq++
+
+	return this.enc.Encode(&SessionMessage{Conn: conn, Seq: this.seq, Time: time.Now(), Direction: direction,
+		Data: data})
+}
+
+// ReadSession reads the messages of a session file.
+func ReadSession(rd io.Reader) (msgs []SessionMessage, err error) {
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(nil, defaultMaxFrameSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var msg SessionMessage
+
+		if err = json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return nil, errors.Wrapf(err, "error reading session message on line %d", line)
+		}
+
+		if msg.Direction != DirectionClient && msg.Direction != DirectionServer {
+			return nil, errors.Errorf("invalid direction '%s' on line %d", msg.Direction, line)
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, scanner.Err()
+}
+
+// CoalesceSession merges the consecutive messages of a connection sent in the same direction, TCP reads splitting
+// the messages of the application arbitrarily. A merged message keeps the sequence number and time of its first
+// chunk.
+func CoalesceSession(msgs []SessionMessage) []SessionMessage {
+	var res []SessionMessage
+
+	last := map[int]int{} // connection -> index in res of its last message
+
+	for _, msg := range msgs {
+		if idx, exists := last[msg.Conn]; exists && idx == len(res)-1 && res[idx].Direction == msg.Direction {
+			res[idx].Data = append(res[idx].Data, msg.Data...)
+
+			continue
+		}
+
+		msg.Data = append([]byte(nil), msg.Data...)
+		res = append(res, msg)
+		last[msg.Conn] = len(res) - 1
+	}
+
+	return res
+}
+
+// Recorder is a TCP proxy capturing the sessions it relays to a session file.
+type Recorder struct {
+	Target  string                    // address of the server
+	Out     *SessionWriter            // capture of every connection
+	OnError func(conn int, err error) // called when a connection cannot be relayed, may be nil
+	// Rewrite changes the data read in a direction before it is relayed and captured, such as
+	// Substitutions.ApplyMessage for the client messages, may be nil
+	Rewrite func(direction string, data []byte) []byte
+	conns   int
+	mu      sync.Mutex
+}
+
+// Serve accepts connections on l and relays them to the target until l is closed.
+func (this *Recorder) Serve(l net.Listener) error {
+	for {
+		client, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		this.mu.Lock()
+		this.conns++
+		conn := this.conns
+		this.mu.Unlock()
+
+		go func() {
+			if err := this.relay(conn, client); err != nil && this.OnError != nil {
+				this.OnError(conn, err)
+			}
+		}()
+	}
+}
+
+// relay relays a connection to the target, capturing both directions.
+func (this *Recorder) relay(conn int, client net.Conn) error {
+	defer client.Close()
+
+	server, err := net.Dial("tcp", this.Target)
+	if err != nil {
+		return errors.Wrapf(err, "error connecting to %s", this.Target)
+	}
+
+	defer server.Close()
+
+	done := make(chan struct{}, 2)
+
+	pipe := func(dst, src net.Conn, direction string) {
+		defer func() { done <- struct{}{} }()
+
+		buf := make([]byte, 32<<10)
+
+		for {
+			n, err := src.Read(buf)
+			if n > 0 {
+				chunk := append([]byte(nil), buf[:n]...)
+				if this.Rewrite != nil {
+					chunk = this.Rewrite(direction, chunk)
+				}
+
+				_ = this.Out.Write(conn, direction, chunk)
+
+				if _, err := dst.Write(chunk); err != nil {
+					return
+				}
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	go pipe(server, client, DirectionClient)
+	go pipe(client, server, DirectionServer)
+
+	// either side closing ends the relay
+	<-done
+
+	return nil
+}
+
+// ReplayConfig configures Replay.
+type ReplayConfig struct {
+	Edits       map[int][]byte // data sent instead of the client message with this sequence number
+	ReadTimeout time.Duration  // time allowed to each server response, defaultReplayTimeout when zero
+	KeepTiming  bool           // wait between the client messages as long as in the capture
+	// Rewrite changes the client messages after the edits, such as Substitutions.ApplyMessage, may be nil
+	Rewrite func(data []byte) []byte
+}
+
+// Replay sends the client messages of a connection of a session to conn, in order, with the edits of config, and
+// returns the session as replayed: the client messages sent and the server responses received. After each client
+// message, as many bytes as the server sent in the capture are awaited, or the read timeout.
+func Replay(conn net.Conn, msgs []SessionMessage, config ReplayConfig) ([]SessionMessage, error) {
+	timeout := config.ReadTimeout
+	if timeout == 0 {
+		timeout = defaultReplayTimeout
+	}
+
+	msgs = CoalesceSession(msgs)
+
+	var (
+		replayed []SessionMessage
+		previous time.Time
+	)
+
+	record := func(direction string, data []byte) {
+		replayed = append(replayed, SessionMessage{Conn: 1, Seq: len(replayed) + 1, Time: time.Now(),
+			Direction: direction, Data: data})
+	}
+
+	for i, msg := range msgs {
+		if msg.Direction != DirectionClient {
+			continue
+		}
+
+		if config.KeepTiming && !previous.IsZero() && msg.Time.After(previous) {
+			time.Sleep(msg.Time.Sub(previous))
+		}
+
+		previous = msg.Time
+
+		data := msg.Data
+		if edit, exists := config.Edits[msg.Seq]; exists {
+			data = edit
+		}
+
+		if config.Rewrite != nil {
+			data = config.Rewrite(data)
+		}
+
+		if _, err := conn.Write(data); err != nil {
+			return replayed, errors.Wrapf(err, "error sending message %d", msg.Seq)
+		}
+
+		record(DirectionClient, data)
+
+		expected := 0
+		for _, next := range msgs[i+1:] {
+			if next.Direction != DirectionServer {
+				break
+			}
+
+			expected += len(next.Data)
+		}
+
+		if expected == 0 {
+			continue
+		}
+
+		response, err := readReplayResponse(conn, expected, timeout)
+		if len(response) > 0 {
+			record(DirectionServer, response)
+		}
+
+		if err != nil {
+			return replayed, errors.Wrapf(err, "error reading the response to message %d", msg.Seq)
+		}
+	}
+
+	return replayed, nil
+}
+
+// readReplayResponse reads up to expected bytes, stopping early on timeout without error.
+func readReplayResponse(conn net.Conn, expected int, timeout time.Duration) ([]byte, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	buf := make([]byte, expected)
+
+	n, err := io.ReadFull(conn, buf)
+	if ne, isNetError := err.(net.Error); isNetError && ne.Timeout() {
+		err = nil
+	}
+
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+
+	return buf[:n], err
+}
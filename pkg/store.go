@@ -0,0 +1,187 @@
+package pkg
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Store persists analysis results so that they can be queried across many samples.
+type Store interface {
+	// Save records the analysis of a sample, saving a sample again replaces its previous results.
+	Save(ctx context.Context, name string, analysis *Analysis) error
+	Close() error
+}
+
+// SQL dialects supported by SQLStore.
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// storeSchema creates the tables of a SQLStore, the statements are valid for both SQLite and Postgres.
+var storeSchema = []string{
+	`CREATE TABLE IF NOT EXISTS samples (
+		sha256      TEXT PRIMARY KEY,
+		name        TEXT NOT NULL,
+		size        INTEGER NOT NULL,
+		elements    INTEGER NOT NULL,
+		fingerprint TEXT NOT NULL,
+		error       TEXT NOT NULL,
+		analyzed_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS sample_classes (
+		sha256             TEXT NOT NULL REFERENCES samples(sha256),
+		name               TEXT NOT NULL,
+		serial_version_uid TEXT NOT NULL,
+		instances          INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS sample_findings (
+		sha256   TEXT NOT NULL REFERENCES samples(sha256),
+		rule_id  TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		title    TEXT NOT NULL,
+		class    TEXT NOT NULL,
+		detail   TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS sample_classes_name ON sample_classes(name)`,
+	`CREATE INDEX IF NOT EXISTS sample_findings_rule ON sample_findings(rule_id)`,
+	`CREATE INDEX IF NOT EXISTS samples_fingerprint ON samples(fingerprint)`,
+}
+
+// SQLStore is a Store backed by a database/sql connection. The driver is chosen by the caller (for example
+// modernc.org/sqlite or github.com/lib/pq), the dialect only selects the query placeholder syntax.
+type SQLStore struct {
+	db      *sql.DB
+	dialect string
+}
+
+// NewSQLStore creates the store tables if needed and returns a Store writing to db.
+func NewSQLStore(ctx context.Context, db *sql.DB, dialect string) (*SQLStore, error) {
+	if dialect != DialectSQLite && dialect != DialectPostgres {
+		return nil, errors.Errorf("unsupported SQL dialect '%s'", dialect)
+	}
+
+	for _, stmt := range storeSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, errors.Wrap(err, "error creating store schema")
+		}
+	}
+
+	return &SQLStore{db: db, dialect: dialect}, nil
+}
+
+// rebind rewrites ? placeholders to the $n syntax used by Postgres.
+func (this *SQLStore) rebind(query string) string {
+	if this.dialect != DialectPostgres {
+		return query
+	}
+
+	var sb strings.Builder
+
+	n := 0
+
+	for _, c := range query {
+		if c == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+		} else {
+			sb.WriteRune(c)
+		}
+	}
+
+	return sb.String()
+}
+
+// Save implements Store, the sample and its classes and findings are written in a single transaction.
+func (this *SQLStore) Save(ctx context.Context, name string, analysis *Analysis) (err error) {
+	tx, err := this.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "error beginning transaction")
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, table := range []string{"sample_classes", "sample_findings", "samples"} {
+		if _, err = tx.ExecContext(ctx, this.rebind("DELETE FROM "+table+" WHERE sha256 = ?"), analysis.SHA256); err != nil {
+			return errors.Wrapf(err, "error deleting previous %s", table)
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, this.rebind(
+		"INSERT INTO samples (sha256, name, size, elements, fingerprint, error, analyzed_at) VALUES (?, ?, ?, ?, ?, ?, ?)"),
+		analysis.SHA256, name, analysis.Size, analysis.Elements, analysis.Fingerprint, analysis.Error, time.Now().UTC(),
+	); err != nil {
+		return errors.Wrap(err, "error inserting sample")
+	}
+
+	for _, cls := range analysis.Classes {
+		if _, err = tx.ExecContext(ctx, this.rebind(
+			"INSERT INTO sample_classes (sha256, name, serial_version_uid, instances) VALUES (?, ?, ?, ?)"),
+			analysis.SHA256, cls.Name, cls.SerialVersionUID, cls.Instances,
+		); err != nil {
+			return errors.Wrap(err, "error inserting class")
+		}
+	}
+
+	for _, f := range analysis.Findings {
+		if _, err = tx.ExecContext(ctx, this.rebind(
+			"INSERT INTO sample_findings (sha256, rule_id, severity, title, class, detail) VALUES (?, ?, ?, ?, ?, ?)"),
+			analysis.SHA256, f.RuleID, f.Severity.String(), f.Title, f.Class, f.Detail,
+		); err != nil {
+			return errors.Wrap(err, "error inserting finding")
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "error committing transaction")
+}
+
+// SamplesWithClass returns the hashes of the samples holding the given class.
+func (this *SQLStore) SamplesWithClass(ctx context.Context, className string) ([]string, error) {
+	return this.hashes(ctx, "SELECT DISTINCT sha256 FROM sample_classes WHERE name = ? ORDER BY sha256", className)
+}
+
+// SamplesWithFingerprint returns the hashes of the samples sharing a structural fingerprint.
+func (this *SQLStore) SamplesWithFingerprint(ctx context.Context, fingerprint string) ([]string, error) {
+	return this.hashes(ctx, "SELECT sha256 FROM samples WHERE fingerprint = ? ORDER BY sha256", fingerprint)
+}
+
+// SamplesWithFinding returns the hashes of the samples which raised the given rule.
+func (this *SQLStore) SamplesWithFinding(ctx context.Context, ruleID string) ([]string, error) {
+	return this.hashes(ctx, "SELECT DISTINCT sha256 FROM sample_findings WHERE rule_id = ? ORDER BY sha256", ruleID)
+}
+
+func (this *SQLStore) hashes(ctx context.Context, query string, arg interface{}) ([]string, error) {
+	rows, err := this.db.QueryContext(ctx, this.rebind(query), arg)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying samples")
+	}
+
+	defer rows.Close()
+
+	var hashes []string
+
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			return nil, errors.Wrap(err, "error scanning sample")
+		}
+
+		hashes = append(hashes, h)
+	}
+
+	return hashes, errors.Wrap(rows.Err(), "error querying samples")
+}
+
+// Close closes the underlying database.
+func (this *SQLStore) Close() error {
+	return this.db.Close()
+}
@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+func init() {
+	objectPostProcs["java.lang.StringBuilder"] = stringBuilderPostProc
+	objectPostProcs["java.lang.StringBuffer"] = stringBufferPostProc
+}
+
+// charsString joins the first count chars of a char[], the whole array when count is negative.
+func charsString(chars []interface{}, count int) string {
+	if count < 0 || count > len(chars) {
+		count = len(chars)
+	}
+
+	var sb strings.Builder
+
+	for _, c := range chars[:count] {
+		if s, isString := c.(string); isString {
+			sb.WriteString(s)
+		}
+	}
+
+	return sb.String()
+}
+
+// stringBuilderPostProc decodes a java.lang.StringBuilder, its writeObject writes the count (int) followed by the
+// char[] value.
+func stringBuilderPostProc(obj map[string]interface{}) {
+	anns, isArray := obj["@"].([]interface{})
+	if !isArray {
+		return
+	}
+
+	count := -1
+	if b := blockBytes(anns); len(b) >= 4 {
+		count = int(int32(binary.BigEndian.Uint32(b)))
+	}
+
+	for _, ann := range anns {
+		if chars, isChars := ann.([]interface{}); isChars {
+			obj["value"] = charsString(chars, count)
+
+			return
+		}
+	}
+}
+
+// stringBufferPostProc decodes a java.lang.StringBuffer, written through its value (char[]) and count fields.
+func stringBufferPostProc(obj map[string]interface{}) {
+	chars, isChars := obj["value"].([]interface{})
+	if !isChars {
+		return
+	}
+
+	count := -1
+	if c, isInt := obj["count"].(int32); isInt {
+		count = int(c)
+	}
+
+	obj["value"] = charsString(chars, count)
+}
@@ -0,0 +1,531 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// SubstitutionRule replaces values of the streams. A rule matches the values of field Field of class Class, the
+// values at Path (field names and array indexes from a top-level element joined by dots: "user.roles.0"), or with
+// Class alone the objects of the class; the conditions given must all hold.
+type SubstitutionRule struct {
+	Class string `yaml:"class"`
+	Field string `yaml:"field"`
+	Path  string `yaml:"path"`
+	// Value replaces a primitive field value (number, boolean or single character) or an object by null or a string.
+	Value interface{} `yaml:"-"`
+	// File holds a serialized object replacing the matched object, its stream header being optional. Handles are
+	// renumbered, references to the replaced objects become null.
+	File string `yaml:"file"`
+
+	hasValue bool
+	content  []byte     // content of File, stream header excluded
+	handles  int        // handles assigned by content
+	refs     []substRef // references of content
+}
+
+// Substitutions are the rules of a substitution file:
+//
+//	rules:
+//	  - class: com.example.Session
+//	    field: token
+//	    value: forged
+//	  - path: user.roles
+//	    value: null
+//	  - class: com.example.Payload
+//	    file: gadget.ser
+type Substitutions struct {
+	Rules []*SubstitutionRule
+}
+
+// LoadSubstitutions reads a YAML substitution file, the files of the rules are relative to its directory.
+func LoadSubstitutions(path string) (*Substitutions, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	subs, err := ParseSubstitutions(b, filepath.Dir(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading substitutions %s", path)
+	}
+
+	return subs, nil
+}
+
+// ParseSubstitutions parses YAML substitution rules, dir being the directory of their relative files.
+func ParseSubstitutions(b []byte, dir string) (*Substitutions, error) {
+	var doc struct {
+		Rules []struct {
+			Class string    `yaml:"class"`
+			Field string    `yaml:"field"`
+			Path  string    `yaml:"path"`
+			Value yaml.Node `yaml:"value"`
+			File  string    `yaml:"file"`
+		} `yaml:"rules"`
+	}
+
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	subs := &Substitutions{}
+
+	for i, r := range doc.Rules {
+		rule := &SubstitutionRule{Class: r.Class, Field: r.Field, Path: r.Path, File: r.File, hasValue: r.Value.Kind != 0}
+
+		if rule.hasValue {
+			if err := r.Value.Decode(&rule.Value); err != nil {
+				return nil, errors.Wrapf(err, "rule %d", i+1)
+			}
+		}
+
+		switch {
+		case rule.Class == "" && rule.Path == "":
+			return nil, errors.Errorf("rule %d: class or path required", i+1)
+		case rule.hasValue == (rule.File != ""):
+			return nil, errors.Errorf("rule %d: either value or file required", i+1)
+		}
+
+		if rule.File != "" {
+			file := rule.File
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(dir, file)
+			}
+
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, errors.Wrapf(err, "rule %d", i+1)
+			}
+
+			if err = rule.setContent(data); err != nil {
+				return nil, errors.Wrapf(err, "rule %d: %s", i+1, rule.File)
+			}
+		}
+
+		subs.Rules = append(subs.Rules, rule)
+	}
+
+	return subs, nil
+}
+
+// setContent sets the serialized replacement of a rule, walking it for its handles and references.
+func (this *SubstitutionRule) setContent(data []byte) error {
+	if !bytes.HasPrefix(data, streamMagic) {
+		data = append(append([]byte(nil), streamMagic...), data...)
+	}
+
+	walker := &estimator{b: data, res: &SizeEstimate{}, subst: &substitution{}}
+	if err := walker.walk(); err != nil {
+		return err
+	}
+
+	if walker.res.Elements != 1 {
+		return errors.Errorf("%d elements instead of a single object", walker.res.Elements)
+	}
+
+	this.content, this.handles = data[len(streamMagic):], walker.res.Handles
+
+	for _, ref := range walker.subst.refs {
+		ref.offset -= len(streamMagic)
+		this.refs = append(this.refs, ref)
+	}
+
+	return nil
+}
+
+// substitution records the edits of Substitutions.Apply while an estimator walks a stream.
+type substitution struct {
+	rules []*SubstitutionRule
+	gen   int      // handle table resets so far
+	path  []string // field names and array indexes of the current value
+	spans []substSpan
+	refs  []substRef
+	err   error
+}
+
+// substSpan is a value to replace.
+type substSpan struct {
+	start, end int // bytes replaced
+	gen        int
+	from, to   int // handles assigned by the replaced bytes
+	data       []byte
+	handles    int        // handles assigned by data
+	refs       []substRef // references of data, relative to its first handle
+}
+
+// substRef is a TC_REFERENCE.
+type substRef struct {
+	offset     int // offset of the TC_REFERENCE byte
+	gen        int
+	idx        int  // handle index since the last reset
+	structural bool // to a class descriptor or a field type name, which cannot become null
+}
+
+func (this *substitution) enter(name string) {
+	this.path = append(this.path, name)
+}
+
+func (this *substitution) leave() {
+	this.path = this.path[:len(this.path)-1]
+}
+
+func (this *substitution) reference(offset, idx int, structural bool) {
+	this.refs = append(this.refs, substRef{offset: offset, gen: this.gen, idx: idx, structural: structural})
+}
+
+// value matches a field value or array element against the field and path rules.
+func (this *substitution) value(typeCode byte, class, field string, start, end, from, to int) {
+	path := strings.Join(this.path, ".")
+
+	for _, rule := range this.rules {
+		if (rule.Field == "" && rule.Path == "") || (rule.Class != "" && rule.Class != class) ||
+			(rule.Field != "" && rule.Field != field) || (rule.Path != "" && rule.Path != path) {
+			continue
+		}
+
+		this.replace(rule, typeCode, start, end, from, to)
+
+		return
+	}
+}
+
+// object matches an object against the class rules.
+func (this *substitution) object(cls *estimateClass, start, end, from, to int) {
+	if cls == nil {
+		return
+	}
+
+	for _, rule := range this.rules {
+		if rule.Class == cls.name && rule.Field == "" && rule.Path == "" {
+			this.replace(rule, 'L', start, end, from, to)
+
+			return
+		}
+	}
+}
+
+func (this *substitution) replace(rule *SubstitutionRule, typeCode byte, start, end, from, to int) {
+	span := substSpan{start: start, end: end, gen: this.gen, from: from, to: to}
+
+	if rule.File != "" {
+		span.data, span.handles, span.refs = rule.content, rule.handles, rule.refs
+	} else {
+		var err error
+		if span.data, span.handles, err = encodeValue(typeCode, rule.Value); err != nil {
+			if this.err == nil {
+				this.err = errors.Wrapf(err, "value at offset %d", start)
+			}
+
+			return
+		}
+	}
+
+	this.spans = append(this.spans, span)
+}
+
+// encodeValue encodes a rule value as a value of a type code, returning the handles it assigns.
+func encodeValue(typeCode byte, v interface{}) ([]byte, int, error) {
+	var buf bytes.Buffer
+
+	be := binary.BigEndian
+
+	switch typeCode {
+	case 'L', '[':
+		switch s := v.(type) {
+		case nil:
+			return []byte{TC_NULL}, 0, nil
+		case string:
+			b := modifiedUTF8(s)
+
+			if len(b) <= math.MaxUint16 {
+				buf.WriteByte(TC_STRING)
+				_ = binary.Write(&buf, be, uint16(len(b)))
+			} else {
+				buf.WriteByte(TC_LONGSTRING)
+				_ = binary.Write(&buf, be, uint64(len(b)))
+			}
+
+			buf.Write(b)
+
+			return buf.Bytes(), 1, nil
+		}
+
+		return nil, 0, errors.Errorf("%v cannot replace an object, only null or a string", v)
+	case 'Z':
+		b, isBool := v.(bool)
+		if !isBool {
+			return nil, 0, errors.Errorf("%v is not a boolean", v)
+		}
+
+		if b {
+			return []byte{1}, 0, nil
+		}
+
+		return []byte{0}, 0, nil
+	case 'F', 'D':
+		var f float64
+
+		switch n := v.(type) {
+		case int:
+			f = float64(n)
+		case float64:
+			f = n
+		default:
+			return nil, 0, errors.Errorf("%v is not a number", v)
+		}
+
+		if typeCode == 'F' {
+			_ = binary.Write(&buf, be, float32(f))
+		} else {
+			_ = binary.Write(&buf, be, f)
+		}
+
+		return buf.Bytes(), 0, nil
+	}
+
+	var n int64
+
+	switch x := v.(type) {
+	case int:
+		n = int64(x)
+	case string:
+		if r := []rune(x); typeCode == 'C' && len(r) == 1 && r[0] <= math.MaxUint16 {
+			n = int64(r[0])
+
+			break
+		}
+
+		return nil, 0, errors.Errorf("'%s' is not a number", x)
+	default:
+		return nil, 0, errors.Errorf("%v is not an integer", v)
+	}
+
+	var value interface{}
+
+	switch typeCode {
+	case 'B':
+		value = int8(n)
+	case 'C':
+		value = uint16(n)
+	case 'S':
+		value = int16(n)
+	case 'I':
+		value = int32(n)
+	case 'J':
+		value = n
+	default:
+		return nil, 0, errors.Errorf("unknown field type '%c'", typeCode)
+	}
+
+	_ = binary.Write(&buf, be, value)
+
+	return buf.Bytes(), 0, nil
+}
+
+// Apply replaces the values matched by the rules in a stream, renumbering the handles which follow the replaced
+// values, and returns the number of replacements. The later references to the objects of a replaced value become
+// null, a replacement removing a class descriptor used later is refused. Streams which Estimate cannot walk
+// completely are refused.
+func (this *Substitutions) Apply(data []byte) (res []byte, n int, err error) {
+	subst := &substitution{rules: this.Rules}
+	walker := &estimator{b: data, res: &SizeEstimate{}, subst: subst}
+
+	if err = walker.walk(); err != nil {
+		return nil, 0, err
+	}
+
+	if subst.err != nil {
+		return nil, 0, subst.err
+	}
+
+	spans := outermostSpans(subst.spans)
+	if len(spans) == 0 {
+		return data, 0, nil
+	}
+
+	type edit struct {
+		start, end int
+		data       []byte
+	}
+
+	var edits []edit
+
+	// first handle of the replacement of each span, the replacements of earlier spans counted
+	shift := map[int]int{}
+	bases := make([]int, len(spans))
+
+	for i, span := range spans {
+		bases[i] = span.from + shift[span.gen]
+		shift[span.gen] += span.handles - (span.to - span.from)
+
+		content := append([]byte(nil), span.data...)
+		for _, ref := range span.refs {
+			binary.BigEndian.PutUint32(content[ref.offset+1:], uint32(baseWireHandle+bases[i]+ref.idx))
+		}
+
+		edits = append(edits, edit{span.start, span.end, content})
+	}
+
+	index := newSpanIndex(spans)
+
+	for _, ref := range subst.refs {
+		if index.contains(ref.offset) {
+			continue
+		}
+
+		idx, dangling := index.handle(ref.gen, ref.idx)
+
+		if dangling && ref.structural {
+			return nil, 0, errors.Errorf("the reference at offset %d is to a class descriptor or type name of a "+
+				"replaced value, replace the later instances of its class as well", ref.offset)
+		}
+
+		if dangling {
+			edits = append(edits, edit{ref.offset, ref.offset + 5, []byte{TC_NULL}})
+
+			continue
+		}
+
+		b := []byte{TC_REFERENCE, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[1:], uint32(baseWireHandle+idx))
+		edits = append(edits, edit{ref.offset, ref.offset + 5, b})
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	pos := 0
+
+	for _, e := range edits {
+		res = append(append(res, data[pos:e.start]...), e.data...)
+		pos = e.end
+	}
+
+	return append(res, data[pos:]...), len(spans), nil
+}
+
+// ApplyMessage applies the rules to every serialized stream of a message, such as a read of a proxied TCP session,
+// carving the streams up to their last complete element. Streams which cannot be walked are left as they are, and
+// the length prefixes of the framing protocol are not updated.
+func (this *Substitutions) ApplyMessage(msg []byte) (res []byte, n int) {
+	pos := 0
+
+	for {
+		idx := bytes.Index(msg[pos:], streamMagic)
+		if idx < 0 {
+			return append(res, msg[pos:]...), n
+		}
+
+		res = append(res, msg[pos:pos+idx]...)
+		pos += idx
+
+		size := carveStream(msg[pos:])
+		if size == 0 {
+			res = append(res, msg[pos])
+			pos++
+
+			continue
+		}
+
+		if out, applied, err := this.Apply(msg[pos : pos+size]); err == nil {
+			res = append(res, out...)
+			n += applied
+		} else {
+			res = append(res, msg[pos:pos+size]...)
+		}
+
+		pos += size
+	}
+}
+
+// Rewrite returns ApplyMessage as a rewrite of Recorder or ReplayConfig, applied to the client messages.
+func (this *Substitutions) Rewrite(direction string, data []byte) []byte {
+	if direction != DirectionClient {
+		return data
+	}
+
+	res, _ := this.ApplyMessage(data)
+
+	return res
+}
+
+// outermostSpans sorts spans by offset, dropping the spans nested in another one.
+func outermostSpans(spans []substSpan) []substSpan {
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+
+		return spans[i].end > spans[j].end
+	})
+
+	var res []substSpan
+
+	for _, span := range spans {
+		if len(res) > 0 && span.start < res[len(res)-1].end {
+			continue
+		}
+
+		res = append(res, span)
+	}
+
+	return res
+}
+
+// spanIndex looks up the kept spans of Apply by offset and by handle.
+type spanIndex struct {
+	spans []substSpan
+	byGen map[int][]substSpan // spans of each handle generation, in stream order
+	shift map[int][]int       // handle shift after each span of a generation, cumulated
+}
+
+func newSpanIndex(spans []substSpan) *spanIndex {
+	this := &spanIndex{spans: spans, byGen: map[int][]substSpan{}, shift: map[int][]int{}}
+
+	for _, span := range spans {
+		shifts := this.shift[span.gen]
+
+		total := 0
+		if len(shifts) > 0 {
+			total = shifts[len(shifts)-1]
+		}
+
+		this.byGen[span.gen] = append(this.byGen[span.gen], span)
+		this.shift[span.gen] = append(shifts, total+span.handles-(span.to-span.from))
+	}
+
+	return this
+}
+
+// contains tells whether an offset is replaced.
+func (this *spanIndex) contains(offset int) bool {
+	i := sort.Search(len(this.spans), func(i int) bool { return this.spans[i].end > offset })
+
+	return i < len(this.spans) && this.spans[i].start <= offset
+}
+
+// handle returns the new index of a handle, dangling when it was assigned by a replaced value.
+func (this *spanIndex) handle(gen, idx int) (int, bool) {
+	spans := this.byGen[gen]
+
+	// handles increase with the offset within a generation: the spans before idx are those ending before it
+	i := sort.Search(len(spans), func(i int) bool { return spans[i].to > idx })
+
+	if i < len(spans) && spans[i].from <= idx {
+		return 0, true
+	}
+
+	if i == 0 {
+		return idx, false
+	}
+
+	return idx + this.shift[gen][i-1], false
+}
@@ -0,0 +1,171 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// contentTag describes a content element tag: its type name, which selects the parser of ParseSerializedObject in
+// knownParsers, and the reader of DumpSerializedObject. Vendor-specific tags have a handler instead of a parser.
+type contentTag struct {
+	name    string
+	dump    func(this *SerializedObjectParser)
+	handler TagHandler
+}
+
+// contentTags dispatches the content elements by tag in both engines.
+var contentTags map[byte]*contentTag
+
+func init() {
+	newClassDesc := func(this *SerializedObjectParser) { this.readNewClassDesc() }
+
+	contentTags = map[byte]*contentTag{
+		TC_NULL:           {name: "Null", dump: (*SerializedObjectParser).readNullReference},
+		TC_REFERENCE:      {name: "Reference", dump: func(this *SerializedObjectParser) { this.readPrevObject() }},
+		TC_CLASSDESC:      {name: "ClassDesc", dump: newClassDesc},
+		TC_OBJECT:         {name: "Object", dump: (*SerializedObjectParser).readNewObject},
+		TC_STRING:         {name: "String", dump: func(this *SerializedObjectParser) { this.readTC_STRING() }},
+		TC_ARRAY:          {name: "Array", dump: (*SerializedObjectParser).readNewArray},
+		TC_CLASS:          {name: "Class", dump: (*SerializedObjectParser).readNewClass},
+		TC_BLOCKDATA:      {name: "BlockData", dump: (*SerializedObjectParser).readBlockData},
+		TC_ENDBLOCKDATA:   {name: "EndBlockData"}, // only ends the annotations of a class
+		TC_RESET:          {name: "Reset", dump: (*SerializedObjectParser).handleReset},
+		TC_BLOCKDATALONG:  {name: "BlockDataLong", dump: (*SerializedObjectParser).readLongBlockData},
+		TC_EXCEPTION:      {name: "Exception", dump: (*SerializedObjectParser).readException},
+		TC_LONGSTRING:     {name: "LongString", dump: func(this *SerializedObjectParser) { this.readTC_LONGSTRING() }},
+		TC_PROXYCLASSDESC: {name: "ProxyClassDesc", dump: newClassDesc},
+		TC_ENUM:           {name: "Enum", dump: (*SerializedObjectParser).readNewEnum},
+	}
+}
+
+// TagHandler reads the payload of a vendor-specific content element, its tag byte being consumed. The value it
+// returns stands for the element; a nil value makes the element transparent, the next element being read in its
+// place.
+type TagHandler func(rd *TagReader) (interface{}, error)
+
+// RegisterTagHandler lets both engines read through the proprietary content elements some products embed between
+// standard elements, instead of failing with an illegal content element type. Standard tags cannot be overridden.
+// Handlers are registered before parsing starts.
+func RegisterTagHandler(tag byte, fn TagHandler) error {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	if existing, exists := contentTags[tag]; exists && existing.handler == nil {
+		return errors.Errorf("tag %#02x is a standard content element", tag)
+	}
+
+	contentTags[tag] = &contentTag{
+		name:    fmt.Sprintf("Vendor%02X", tag),
+		dump:    func(this *SerializedObjectParser) { this.dumpVendorElement(tag, fn) },
+		handler: fn,
+	}
+
+	return nil
+}
+
+// TagReader reads the payload of a vendor-specific content element from the stream being parsed or dumped.
+type TagReader struct {
+	p       *SerializedObjectParser
+	dumping bool
+}
+
+// ReadBytes reads n bytes.
+func (this *TagReader) ReadBytes(n int) ([]byte, error) {
+	if n < 0 || n > this.p.maxDataBlockSize {
+		return nil, errors.Errorf("invalid vendor element length %d", n)
+	}
+
+	b := make([]byte, n)
+
+	if this.dumping {
+		if this.p._data.size() < n {
+			return nil, errors.Errorf("vendor element exceeds the stream: %d bytes", n)
+		}
+
+		for i := range b {
+			b[i] = this.p._data.pop()
+		}
+
+		this.p.print("Value - 0x" + hex.EncodeToString(b))
+
+		return b, nil
+	}
+
+	if _, err := io.ReadFull(this.p.rd, b); err != nil {
+		return nil, errors.Wrap(err, "error reading vendor element")
+	}
+
+	return b, nil
+}
+
+// ReadByte reads a byte.
+func (this *TagReader) ReadByte() (byte, error) {
+	b, err := this.ReadBytes(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+// ReadUint16 reads a big-endian unsigned short.
+func (this *TagReader) ReadUint16() (uint16, error) {
+	b, err := this.ReadBytes(2)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint16(b), nil
+}
+
+// ReadUint32 reads a big-endian unsigned int.
+func (this *TagReader) ReadUint32() (uint32, error) {
+	b, err := this.ReadBytes(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// ReadUTF reads a modified UTF-8 string prefixed by its length (unsigned short), as written by writeUTF.
+func (this *TagReader) ReadUTF() (string, error) {
+	l, err := this.ReadUint16()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := this.ReadBytes(int(l))
+
+	return string(b), err
+}
+
+// ReadContent reads a standard content element (object, string, array...) nested in the vendor element. The dump
+// prints it and returns nil.
+func (this *TagReader) ReadContent() (interface{}, error) {
+	if this.dumping {
+		return nil, this.p.readContentElement()
+	}
+
+	return this.p.content(nil)
+}
+
+// dumpVendorElement dumps a vendor-specific content element.
+func (this *SerializedObjectParser) dumpVendorElement(tag byte, fn TagHandler) {
+	this.print("Vendor element - 0x" + this.byteToHex(this._data.pop()))
+	this.increaseIndent()
+	defer this.decreaseIndent()
+
+	value, err := fn(&TagReader{p: this, dumping: true})
+
+	switch {
+	case err != nil:
+		this.print("Error - " + err.Error())
+	case value != nil:
+		this.print(fmt.Sprintf("Decoded - %v", value))
+	}
+}
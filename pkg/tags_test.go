@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContentTags(t *testing.T) {
+	classDesc := newStreamWriter()
+	classDesc.classDesc("a.B", 1, SC_SERIALIZABLE, nil, nil)
+
+	proxyClassDesc := newStreamWriter()
+	proxyClassDesc.proxyClassDesc([]string{"java.lang.Runnable"})
+
+	shortString, _ := EncodeString("go-pjs", WithStringForm(StringFormShort))
+	longString, _ := EncodeString("go-pjs", WithStringForm(StringFormLong))
+
+	tests := []struct {
+		name  string
+		data  []byte
+		check func(t *testing.T, v interface{})
+		dump  []string // lines of the dump
+	}{
+		{
+			name:  "TC_STRING",
+			data:  shortString,
+			check: checkString("go-pjs"),
+			dump:  []string{"TC_STRING - 0x74", "Length - 6 - 0x00 06", "Value - go-pjs"},
+		},
+		{
+			name:  "TC_LONGSTRING",
+			data:  longString,
+			check: checkString("go-pjs"),
+			dump:  []string{"TC_LONGSTRING - 0x7c", "Length - 6 - 0x00 00 00 00 00 00 00 06", "Value - go-pjs"},
+		},
+		{
+			name: "TC_CLASSDESC",
+			data: classDesc.Bytes(),
+			check: func(t *testing.T, v interface{}) {
+				cls, ok := v.(*clazz)
+				if !ok || cls.name != "a.B" || cls.serialVersionUID != "0000000000000001" || cls.super != nil {
+					t.Errorf("got %#v, want the class descriptor of a.B", v)
+				}
+			},
+			dump: []string{"TC_CLASSDESC - 0x72", "Value - a.B", "classDescFlags - 0x02 - SC_SERIALIZABLE"},
+		},
+		{
+			name: "TC_PROXYCLASSDESC",
+			data: proxyClassDesc.Bytes(),
+			check: func(t *testing.T, v interface{}) {
+				cls, ok := v.(*clazz)
+				if !ok || !reflect.DeepEqual(cls.interfaces, []string{"java.lang.Runnable"}) || cls.super == nil ||
+					cls.super.name != "java.lang.reflect.Proxy" {
+					t.Errorf("got %#v, want a proxy class descriptor of java.lang.Runnable", v)
+				}
+			},
+			dump: []string{"TC_PROXYCLASSDESC - 0x7d", "Interface count - 1", "Value - java.lang.Runnable",
+				"Value - java.lang.reflect.Proxy"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, err := ParseSerializedObject(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(content) != 1 {
+				t.Fatalf("got %d elements, want 1", len(content))
+			}
+
+			test.check(t, content[0])
+
+			var out bytes.Buffer
+			if err = DumpSerializedObject(test.data, SetDumpWriter(&out)); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, line := range test.dump {
+				if !strings.Contains(out.String(), line) {
+					t.Errorf("dump lacks %q:\n%s", line, out.String())
+				}
+			}
+		})
+	}
+}
+
+func checkString(want string) func(t *testing.T, v interface{}) {
+	return func(t *testing.T, v interface{}) {
+		if v != want {
+			t.Errorf("got %#v, want %q", v, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/pkg/errors"
+)
+
+// ReportData is the data a report template is executed with.
+type ReportData struct {
+	Name     string
+	Analysis *Analysis
+	Content  []interface{} // minimal (JSON friendly) representation of the stream
+}
+
+// ReportTemplate is a user-supplied report template, either a text/template or, for .html and .htm files,
+// an html/template which escapes its output.
+type ReportTemplate struct {
+	execute func(w io.Writer, data interface{}) error
+}
+
+// templateFuncs are the functions available to report templates.
+var templateFuncs = map[string]interface{}{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"hex":   func(b []byte) string { return hex.EncodeToString(b) },
+	"json": func(v interface{}) (string, error) {
+		b, err := json.MarshalIndent(v, "", "  ")
+
+		return string(b), err
+	},
+	"csv": func(s string) string {
+		if strings.ContainsAny(s, ",\"\n\r") {
+			return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+		}
+
+		return s
+	},
+	"latex": strings.NewReplacer(`\`, `\textbackslash{}`, `&`, `\&`, `%`, `\%`, `$`, `\$`, `#`, `\#`,
+		`_`, `\_`, `{`, `\{`, `}`, `\}`, `~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`).Replace,
+	"atLeast": func(s, threshold Severity) bool { return s >= threshold },
+	"severity": func(name string) (Severity, error) {
+		return ParseSeverity(name)
+	},
+}
+
+// LoadReportTemplate loads a report template file.
+func LoadReportTemplate(path string) (*ReportTemplate, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read template")
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+
+	return ParseReportTemplate(filepath.Base(path), string(src), ext == ".html" || ext == ".htm")
+}
+
+// ParseReportTemplate parses a report template, html selects html/template.
+func ParseReportTemplate(name, src string, html bool) (*ReportTemplate, error) {
+	if html {
+		t, err := htmltemplate.New(name).Funcs(templateFuncs).Parse(src)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse template %s", name)
+		}
+
+		return &ReportTemplate{execute: t.Execute}, nil
+	}
+
+	t, err := texttemplate.New(name).Funcs(templateFuncs).Parse(src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse template %s", name)
+	}
+
+	return &ReportTemplate{execute: t.Execute}, nil
+}
+
+// Render executes the template for a sample.
+func (this *ReportTemplate) Render(w io.Writer, name string, analysis *Analysis, content []interface{}) error {
+	return errors.Wrap(this.execute(w, &ReportData{
+		Name:     name,
+		Analysis: analysis,
+		Content:  jsonFriendlyArray(content),
+	}), "render template")
+}
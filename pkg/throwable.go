@@ -0,0 +1,207 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"regexp"
+	"sort"
+)
+
+func init() {
+	RegisterDetector("throwable", throwableDetector)
+}
+
+// legacyCauseFields are the fields holding the cause of throwables predating Throwable.cause.
+var legacyCauseFields = []string{"cause", "target", "undeclaredThrowable", "exception", "ex"}
+
+// maxEmbeddedDepth bounds the nesting of streams embedded in throwables.
+const maxEmbeddedDepth = 4
+
+// base64StreamPattern matches a base64 encoded serialized stream (AC ED 00 05 encodes to rO0AB).
+var base64StreamPattern = regexp.MustCompile(`rO0AB[A-Za-z0-9+/]*={0,2}`)
+
+// ThrowableInfo is a throwable of a cause chain.
+type ThrowableInfo struct {
+	Class   string `json:"class"`
+	Message string `json:"message,omitempty"`
+}
+
+// EmbeddedStream is a serialized stream found inside a throwable, as a byte array field or base64 in a string.
+type EmbeddedStream struct {
+	Throwable  string             `json:"throwable"` // class of the throwable holding the stream
+	Field      string             `json:"field"`
+	Data       []byte             `json:"-"`
+	Classes    []string           `json:"classes,omitempty"`
+	Throwables *ThrowableAnalysis `json:"throwables,omitempty"` // throwables of the embedded stream
+}
+
+// ThrowableAnalysis resolves the cause chain of the throwables of a stream and the streams embedded in them.
+type ThrowableAnalysis struct {
+	Chain     []ThrowableInfo  `json:"chain"` // outermost throwable first, root cause last
+	RootCause *ThrowableInfo   `json:"rootCause,omitempty"`
+	Embedded  []EmbeddedStream `json:"embedded,omitempty"`
+}
+
+// AnalyzeThrowables walks the throwables of parsed content: it follows the cause chain of the outermost throwable
+// (legacy cause fields such as InvocationTargetException.target included) down to the root cause, and parses the
+// serialized streams embedded in throwable fields or messages, recursively. It returns nil when content holds no
+// throwable.
+func AnalyzeThrowables(content []interface{}, options ...Option) *ThrowableAnalysis {
+	return analyzeThrowables(content, options, 0)
+}
+
+func analyzeThrowables(content []interface{}, options []Option, depth int) *ThrowableAnalysis {
+	var throwables []map[string]interface{}
+
+	seen := map[interface{}]bool{}
+	for _, c := range content {
+		collectThrowables(c, &throwables, seen)
+	}
+
+	if len(throwables) == 0 {
+		return nil
+	}
+
+	res := &ThrowableAnalysis{}
+
+	visited := map[uintptr]bool{}
+	for t := throwables[0]; t != nil && !visited[mapIdentity(t)]; t = throwableCause(t) {
+		visited[mapIdentity(t)] = true
+		msg, _ := t["detailMessage"].(string)
+		res.Chain = append(res.Chain, ThrowableInfo{Class: objectClassName(t), Message: msg})
+	}
+
+	res.RootCause = &res.Chain[len(res.Chain)-1]
+
+	if depth >= maxEmbeddedDepth {
+		return res
+	}
+
+	found := map[string]bool{}
+	for _, t := range throwables {
+		res.Embedded = append(res.Embedded, embeddedStreams(t, options, depth, found)...)
+	}
+
+	return res
+}
+
+// throwableCause returns the cause of a throwable, a throwable being its own cause when it has none.
+func throwableCause(t map[string]interface{}) map[string]interface{} {
+	for _, field := range legacyCauseFields {
+		if cause, isMap := t[field].(map[string]interface{}); isMap && isThrowable(cause) &&
+			mapIdentity(cause) != mapIdentity(t) {
+			return cause
+		}
+	}
+
+	return nil
+}
+
+// embeddedStreams parses the serialized streams found in the fields of a throwable, the causes being walked on their
+// own. found holds the streams already reported.
+func embeddedStreams(t map[string]interface{}, options []Option, depth int, found map[string]bool) []EmbeddedStream {
+	fields := make([]string, 0, len(t))
+	for k, v := range t {
+		if cause, isMap := v.(map[string]interface{}); isMap && isThrowable(cause) {
+			continue
+		}
+
+		if k != "class" && k != "extends" {
+			fields = append(fields, k)
+		}
+	}
+
+	sort.Strings(fields)
+
+	var streams []EmbeddedStream
+
+	for _, field := range fields {
+		walkContent(t[field], func(v interface{}) {
+			var data []byte
+
+			switch o := v.(type) {
+			case []interface{}:
+				data = streamBytes(o)
+			case string:
+				for _, m := range base64StreamPattern.FindAllString(o, -1) {
+					if b, err := base64.StdEncoding.DecodeString(m); err == nil {
+						data = b
+
+						break
+					}
+				}
+			}
+
+			if len(data) == 0 || found[string(data)] {
+				return
+			}
+
+			found[string(data)] = true
+
+			stream := EmbeddedStream{Throwable: objectClassName(t), Field: field, Data: data}
+
+			cache := NewClassCache()
+			content, _ := ParseSerializedObject(data, append([]Option{SetClassCache(cache)}, options...)...)
+
+			for _, stat := range cache.Stats() {
+				stream.Classes = append(stream.Classes, stat.Name)
+			}
+
+			stream.Throwables = analyzeThrowables(content, options, depth+1)
+			streams = append(streams, stream)
+		})
+	}
+
+	return streams
+}
+
+// streamBytes returns the bytes of a byte array holding a serialized stream, nil for any other array.
+func streamBytes(arr []interface{}) []byte {
+	if len(arr) < 4 {
+		return nil
+	}
+
+	b := make([]byte, 0, len(arr))
+
+	for _, v := range arr {
+		c, isByte := v.(int8)
+		if !isByte {
+			return nil
+		}
+
+		b = append(b, byte(c))
+	}
+
+	if !bytes.HasPrefix(b, []byte{STREAM_MAGIC1, STREAM_MAGIC2}) {
+		return nil
+	}
+
+	return b
+}
+
+// throwableDetector reports the streams embedded in throwables, along with the findings of their own analysis.
+func throwableDetector(_ *Analysis, content []interface{}) []Finding {
+	throwables := AnalyzeThrowables(content)
+	if throwables == nil {
+		return nil
+	}
+
+	var findings []Finding
+
+	for _, stream := range throwables.Embedded {
+		findings = append(findings, Finding{
+			RuleID:   "THROWABLE-EMBEDDED-STREAM",
+			Severity: SeverityMedium,
+			Title:    "serialized stream embedded in a throwable",
+			Class:    stream.Throwable,
+			Detail:   "field " + stream.Field,
+		})
+
+		for _, f := range Analyze(stream.Data).Findings {
+			f.Detail = "embedded in " + stream.Throwable + "." + stream.Field
+			findings = append(findings, f)
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,401 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// java.time.Ser type codes.
+const (
+	javaTimeDuration       byte = 1
+	javaTimeInstant        byte = 2
+	javaTimeLocalDate      byte = 3
+	javaTimeLocalTime      byte = 4
+	javaTimeLocalDateTime  byte = 5
+	javaTimeZonedDateTime  byte = 6
+	javaTimeZoneRegion     byte = 7
+	javaTimeZoneOffset     byte = 8
+	javaTimeOffsetTime     byte = 9
+	javaTimeOffsetDateTime byte = 10
+	javaTimeYear           byte = 11
+	javaTimeYearMonth      byte = 12
+	javaTimeMonthDay       byte = 13
+	javaTimePeriod         byte = 14
+)
+
+func init() {
+	KnownPostProcs["java.time.Ser@955d84ba1b2248b2"] = javaTimePostProc
+	externalPostProcs["java.time.Ser@955d84ba1b2248b2"] = true
+	objectPostProcs["java.sql.Timestamp@2618d5c80153bf65"] = timestampPostProc
+	KnownPostProcs["java.util.Calendar@e6ea4d1ec8dc5b8e"] = calendarPostProc
+}
+
+// Calendar field indexes of the zone and daylight saving offsets.
+const (
+	calendarZoneOffset = 15
+	calendarDSTOffset  = 16
+)
+
+// calendarPostProc reconstructs the point in time and zone of a java.util.Calendar (GregorianCalendar included).
+// Calendar.writeObject writes its fields, holding the time in milliseconds and a SimpleTimeZone standing for the
+// actual zone, followed by the original zone (a sun.util.calendar.ZoneInfo) or null. The zone offset in effect is
+// taken from the computed calendar fields when they are set, from the raw offset of the zone otherwise.
+func calendarPostProc(fields map[string]interface{}, data []interface{}) (map[string]interface{}, error) {
+	millis, hasTime := fields["time"].(int64)
+	if !hasTime {
+		return nil, errors.New("calendar time not found")
+	}
+
+	var id string
+
+	offset, hasOffset := int32(0), false
+
+	for _, z := range []interface{}{fields["zone"], firstOrNil(data)} {
+		if zone, isMap := z.(map[string]interface{}); isMap {
+			if zoneID, isString := zone["ID"].(string); isString {
+				id = zoneID
+			}
+
+			if raw, isInt := zone["rawOffset"].(int32); isInt && !hasOffset {
+				offset, hasOffset = raw, true
+			}
+		}
+	}
+
+	if areFieldsSet, _ := fields["areFieldsSet"].(bool); areFieldsSet {
+		if calFields, isArray := fields["fields"].([]interface{}); isArray && len(calFields) > calendarDSTOffset {
+			zoneOffset, isZone := calFields[calendarZoneOffset].(int32)
+			dstOffset, isDST := calFields[calendarDSTOffset].(int32)
+
+			if isZone && isDST {
+				offset = zoneOffset + dstOffset
+			}
+		}
+	}
+
+	secs := int(offset / 1000)
+	if id == "" {
+		id = zoneOffsetID(secs)
+	}
+
+	fields["value"] = time.Unix(0, millis*int64(time.Millisecond)).In(time.FixedZone(id, secs))
+
+	return fields, nil
+}
+
+// firstOrNil returns the first element of data.
+func firstOrNil(data []interface{}) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return data[0]
+}
+
+// objectPostProcs maps the signature of the class of an object to a func completing the object once the data of
+// all its classes was read, for values spread over a class and its super classes. Classes without a stable
+// serialVersionUID are keyed by their name alone.
+var objectPostProcs = map[string]func(obj map[string]interface{}){}
+
+// timestampPostProc adds the nanoseconds of a java.sql.Timestamp to the time decoded from its java.util.Date part.
+func timestampPostProc(obj map[string]interface{}) {
+	t, isTime := obj["value"].(time.Time)
+	nanos, hasNanos := obj["nanos"].(int32)
+
+	if isTime && hasNanos {
+		obj["value"] = t.Truncate(time.Second).Add(time.Duration(nanos))
+	}
+}
+
+// blockBytes concatenates the block data of an object annotation.
+func blockBytes(data []interface{}) []byte {
+	var b []byte
+
+	for _, d := range data {
+		if block, isBlock := d.([]byte); isBlock {
+			b = append(b, block...)
+		}
+	}
+
+	return b
+}
+
+// javaTimeReader decodes the external form written by java.time.Ser.
+type javaTimeReader struct {
+	rd *bytes.Reader
+}
+
+func (this *javaTimeReader) byte() (b byte, err error) {
+	return this.rd.ReadByte()
+}
+
+func (this *javaTimeReader) read(v interface{}) error {
+	return binary.Read(this.rd, binary.BigEndian, v)
+}
+
+func (this *javaTimeReader) int() (i int32, err error) {
+	err = this.read(&i)
+
+	return
+}
+
+func (this *javaTimeReader) long() (l int64, err error) {
+	err = this.read(&l)
+
+	return
+}
+
+// date reads a LocalDate: year (int), month, day (bytes).
+func (this *javaTimeReader) date() (year int, month, day int, err error) {
+	var y int32
+
+	var md [2]byte
+
+	if y, err = this.int(); err == nil {
+		_, err = io.ReadFull(this.rd, md[:])
+	}
+
+	return int(y), int(md[0]), int(md[1]), err
+}
+
+// time reads a LocalTime, trailing zero components are omitted and the last written one is complemented.
+func (this *javaTimeReader) time() (hour, minute, second, nano int, err error) {
+	var b byte
+
+	components := []*int{&hour, &minute, &second}
+
+	for _, c := range components {
+		if b, err = this.byte(); err != nil {
+			return
+		}
+
+		if int8(b) < 0 {
+			*c = int(^b)
+
+			return
+		}
+
+		*c = int(b)
+	}
+
+	var n int32
+	n, err = this.int()
+
+	return hour, minute, second, int(n), err
+}
+
+// offset reads a ZoneOffset: quarter hours (byte), 127 being followed by the total seconds (int).
+func (this *javaTimeReader) offset() (seconds int, err error) {
+	var b byte
+	if b, err = this.byte(); err != nil {
+		return
+	}
+
+	if b == 127 {
+		var s int32
+		s, err = this.int()
+
+		return int(s), err
+	}
+
+	return int(int8(b)) * 900, nil
+}
+
+func (this *javaTimeReader) dateTime() (time.Time, error) {
+	year, month, day, err := this.date()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	hour, minute, second, nano, err := this.time()
+
+	return time.Date(year, time.Month(month), day, hour, minute, second, nano, time.UTC), err
+}
+
+// zone reads a ZoneId written by ZoneRegion or ZoneOffset.
+func (this *javaTimeReader) zone(offset int) (*time.Location, error) {
+	typ, err := this.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case javaTimeZoneRegion:
+		var l uint16
+		if err := this.read(&l); err != nil {
+			return nil, err
+		}
+
+		id := make([]byte, l)
+		if _, err := io.ReadFull(this.rd, id); err != nil {
+			return nil, err
+		}
+
+		// the offset in effect is known, tz data is not needed
+		return time.FixedZone(string(id), offset), nil
+	case javaTimeZoneOffset:
+		secs, err := this.offset()
+
+		return time.FixedZone(zoneOffsetID(secs), secs), err
+	}
+
+	return nil, errors.Errorf("unknown zone type %d", typ)
+}
+
+// zoneOffsetID formats an offset like ZoneOffset.getId().
+func zoneOffsetID(seconds int) string {
+	if seconds == 0 {
+		return "Z"
+	}
+
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+
+	if seconds%60 != 0 {
+		return fmt.Sprintf("%c%02d:%02d:%02d", sign, seconds/3600, seconds/60%60, seconds%60)
+	}
+
+	return fmt.Sprintf("%c%02d:%02d", sign, seconds/3600, seconds/60%60)
+}
+
+// javaTimePostProc decodes the java.time types serialized through their java.time.Ser proxy. Points in time
+// (Instant, ZonedDateTime, OffsetDateTime) become a time.Time, the other types a string in their ISO-8601 form.
+func javaTimePostProc(fields map[string]interface{}, data []interface{}) (map[string]interface{}, error) {
+	rd := &javaTimeReader{rd: bytes.NewReader(blockBytes(data))}
+
+	typ, err := rd.byte()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading java.time type")
+	}
+
+	var value interface{}
+
+	switch typ {
+	case javaTimeDuration, javaTimeInstant:
+		var secs int64
+
+		var nanos int32
+
+		if secs, err = rd.long(); err == nil {
+			nanos, err = rd.int()
+		}
+
+		if typ == javaTimeInstant {
+			value = time.Unix(secs, int64(nanos)).UTC()
+		} else {
+			value = (time.Duration(secs)*time.Second + time.Duration(nanos)).String()
+		}
+	case javaTimeLocalDate:
+		var year, month, day int
+		year, month, day, err = rd.date()
+		value = fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+	case javaTimeLocalTime:
+		var hour, minute, second, nano int
+		hour, minute, second, nano, err = rd.time()
+		value = localTimeString(hour, minute, second, nano)
+	case javaTimeLocalDateTime:
+		var t time.Time
+		t, err = rd.dateTime()
+		value = t.Format("2006-01-02T") + localTimeString(t.Hour(), t.Minute(), t.Second(), t.Nanosecond())
+	case javaTimeZonedDateTime, javaTimeOffsetDateTime:
+		var t time.Time
+
+		var offset int
+
+		if t, err = rd.dateTime(); err == nil {
+			offset, err = rd.offset()
+		}
+
+		loc := time.FixedZone(zoneOffsetID(offset), offset)
+
+		if err == nil && typ == javaTimeZonedDateTime {
+			loc, err = rd.zone(offset)
+		}
+
+		// the local date time is converted to the instant using the offset
+		if err == nil {
+			value = t.Add(-time.Duration(offset) * time.Second).In(loc)
+		}
+	case javaTimeOffsetTime:
+		var hour, minute, second, nano, offset int
+		if hour, minute, second, nano, err = rd.time(); err == nil {
+			offset, err = rd.offset()
+		}
+
+		value = localTimeString(hour, minute, second, nano) + zoneOffsetID(offset)
+	case javaTimeZoneRegion, javaTimeZoneOffset:
+		var loc *time.Location
+		if err = rd.rd.UnreadByte(); err == nil {
+			loc, err = rd.zone(0)
+		}
+
+		if err == nil {
+			value = loc.String()
+		}
+	case javaTimeYear:
+		var year int32
+		year, err = rd.int()
+		value = fmt.Sprintf("%d", year)
+	case javaTimeYearMonth:
+		var year int32
+
+		var month byte
+
+		if year, err = rd.int(); err == nil {
+			month, err = rd.byte()
+		}
+
+		value = fmt.Sprintf("%04d-%02d", year, month)
+	case javaTimeMonthDay:
+		var md [2]byte
+		_, err = io.ReadFull(rd.rd, md[:])
+		value = fmt.Sprintf("--%02d-%02d", md[0], md[1])
+	case javaTimePeriod:
+		var y, m, d int32
+		if y, err = rd.int(); err == nil {
+			if m, err = rd.int(); err == nil {
+				d, err = rd.int()
+			}
+		}
+
+		value = fmt.Sprintf("P%dY%dM%dD", y, m, d)
+	default:
+		return nil, errors.Errorf("unknown java.time type %d", typ)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading java.time type %d", typ)
+	}
+
+	fields["value"] = value
+
+	return fields, nil
+}
+
+// localTimeString formats a LocalTime like LocalTime.toString().
+func localTimeString(hour, minute, second, nano int) string {
+	s := fmt.Sprintf("%02d:%02d", hour, minute)
+
+	if second > 0 || nano > 0 {
+		s += fmt.Sprintf(":%02d", second)
+
+		switch {
+		case nano == 0:
+		case nano%1000000 == 0:
+			s += fmt.Sprintf(".%03d", nano/1000000)
+		case nano%1000 == 0:
+			s += fmt.Sprintf(".%06d", nano/1000)
+		default:
+			s += fmt.Sprintf(".%09d", nano)
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,50 @@
+package pkg
+
+import "bytes"
+
+// maxTrailingPreview is the number of trailing bytes shown in the preview of a TrailingData.
+const maxTrailingPreview = 64
+
+// TrailingData describes the input bytes left after the last complete top-level element. Trailing data frequently
+// hides a secondary payload, such as a second stream, or the framing of the protocol carrying the stream.
+type TrailingData struct {
+	Offset  int64  `json:"offset"`  // offset of the first byte not parsed
+	Length  int64  `json:"length"`  // number of bytes not parsed
+	Stream  bool   `json:"stream"`  // the bytes start with the magic of a serialized stream
+	Preview string `json:"preview"` // hexdump -C of the first bytes
+
+	Metadata *SampleMetadata `json:"metadata,omitempty"` // the bytes are the metadata block of a go-pjs sample
+}
+
+// Trailing returns the input bytes left after the stream header or the last complete top-level element, nil when
+// the whole input was parsed or the parser does not read a buffer. An element which failed to parse belongs to the
+// trailing data.
+func (this *SerializedObjectParser) Trailing() *TrailingData {
+	if this.input == nil {
+		return nil
+	}
+
+	return trailingData(this.input, this.parsedEnd)
+}
+
+// trailingData returns the bytes of input from offset, nil when there is none.
+func trailingData(input []byte, offset int64) *TrailingData {
+	if offset < 0 || offset >= int64(len(input)) {
+		return nil
+	}
+
+	rest := input[offset:]
+	preview := rest
+	if len(preview) > maxTrailingPreview {
+		preview = preview[:maxTrailingPreview]
+	}
+
+	return &TrailingData{
+		Offset:  offset,
+		Length:  int64(len(rest)),
+		Stream:  bytes.HasPrefix(rest, streamMagic),
+		Preview: hexDump(preview, int(offset)),
+
+		Metadata: ReadMetadata(rest),
+	}
+}
@@ -0,0 +1,169 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Transformer rewrites a value of the minimal representation, returning its replacement. Transformers are applied
+// bottom-up: the maps and slices they get already hold transformed values.
+type Transformer func(v interface{}) interface{}
+
+// Transformers are the built-in transformers of the minimal output, by name.
+var Transformers = map[string]Transformer{
+	// flatten merges nested objects into their parent with dotted keys ("a": {"b": 1} becomes "a.b": 1)
+	"flatten": flattenObject,
+	// strip-nulls removes the object entries whose value is null
+	"strip-nulls": stripNulls,
+	// lowercase-keys lowercases object keys, the last one winning when two keys only differ by case
+	"lowercase-keys": lowercaseKeys,
+	// bytes-base64 encodes non-empty byte arrays as base64 strings instead of arrays of numbers
+	"bytes-base64": bytesBase64,
+}
+
+// TransformerNames returns the sorted names of Transformers.
+func TransformerNames() []string {
+	names := make([]string, 0, len(Transformers))
+	for name := range Transformers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseTransformers returns the Transformers of a comma separated list of names, in order.
+func ParseTransformers(list string) ([]Transformer, error) {
+	var chain []Transformer
+
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+
+		t, exists := Transformers[name]
+		if !exists {
+			return nil, errors.Errorf("unknown transformer '%s', want one of %s", name,
+				strings.Join(TransformerNames(), ", "))
+		}
+
+		chain = append(chain, t)
+	}
+
+	return chain, nil
+}
+
+// JSONTransform applies a chain of transformers to the minimal representation before it is encoded by MarshalMinimal,
+// ahead of the numeric and date settings. Each transformer walks the whole content in turn.
+func JSONTransform(chain ...Transformer) JSONOption {
+	return func(this *jsonExport) {
+		this.transformers = append(this.transformers, chain...)
+	}
+}
+
+// Transform applies a chain of transformers to minimal content, updating its maps and slices in place.
+func Transform(minimal []interface{}, chain ...Transformer) []interface{} {
+	for _, t := range chain {
+		for i, v := range minimal {
+			minimal[i] = transformValue(v, t)
+		}
+	}
+
+	return minimal
+}
+
+// transformValue applies a transformer bottom-up.
+func transformValue(v interface{}, t Transformer) interface{} {
+	switch o := v.(type) {
+	case []interface{}:
+		for i, e := range o {
+			o[i] = transformValue(e, t)
+		}
+	case map[string]interface{}:
+		for k, e := range o {
+			o[k] = transformValue(e, t)
+		}
+	}
+
+	return t(v)
+}
+
+func flattenObject(v interface{}) interface{} {
+	obj, isMap := v.(map[string]interface{})
+	if !isMap {
+		return v
+	}
+
+	flat := make(map[string]interface{}, len(obj))
+
+	for k, e := range obj {
+		if nested, isNested := e.(map[string]interface{}); isNested && len(nested) > 0 {
+			for nk, ne := range nested {
+				flat[k+"."+nk] = ne
+			}
+
+			continue
+		}
+
+		flat[k] = e
+	}
+
+	return flat
+}
+
+func stripNulls(v interface{}) interface{} {
+	if obj, isMap := v.(map[string]interface{}); isMap {
+		for k, e := range obj {
+			if e == nil {
+				delete(obj, k)
+			}
+		}
+	}
+
+	return v
+}
+
+func lowercaseKeys(v interface{}) interface{} {
+	obj, isMap := v.(map[string]interface{})
+	if !isMap {
+		return v
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	lower := make(map[string]interface{}, len(obj))
+	for _, k := range keys {
+		lower[strings.ToLower(k)] = obj[k]
+	}
+
+	return lower
+}
+
+func bytesBase64(v interface{}) interface{} {
+	arr, isArray := v.([]interface{})
+	if !isArray || len(arr) == 0 {
+		return v
+	}
+
+	b := make([]byte, len(arr))
+
+	for i, e := range arr {
+		x, isByte := e.(int8)
+		if !isByte {
+			return v
+		}
+
+		b[i] = byte(x)
+	}
+
+	return base64.StdEncoding.EncodeToString(b)
+}
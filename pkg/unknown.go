@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// UnknownClasses decodes the instances of classes as ObjectInputStream does when it cannot resolve them: their field
+// values are read and discarded, their write-method data skipped up to its end (skipCustomData), without running
+// post-processors or hooks. The objects their data holds are decoded as usual, so that the rest of the graph stays
+// decodable; the instances themselves are left with their class and "@unknown". A class is unknown when it or one of
+// its super classes matches a pattern: a class name, or a package followed by ".*" (org.example.*).
+func UnknownClasses(patterns ...string) Option {
+	return func(this *SerializedObjectParser) {
+		this.unknownClasses = append(this.unknownClasses, patterns...)
+	}
+}
+
+// isUnknownClass tells whether a class or one of its super classes is unknown, see UnknownClasses.
+func (this *SerializedObjectParser) isUnknownClass(cls *clazz) bool {
+	if len(this.unknownClasses) == 0 {
+		return false
+	}
+
+	seen := map[*clazz]bool{}
+
+	for ; cls != nil && !seen[cls]; cls = cls.super {
+		seen[cls] = true
+
+		for _, pattern := range this.unknownClasses {
+			if cls.name == pattern ||
+				strings.HasSuffix(pattern, ".*") && strings.HasPrefix(cls.name, pattern[:len(pattern)-1]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// skipObjectData reads the class data of an object of an unknown class and discards it.
+func (this *SerializedObjectParser) skipObjectData(cls *clazz, obj map[string]interface{}) error {
+	skipping := this.skipCustomData
+	this.skipCustomData = true
+
+	defer func() {
+		this.skipCustomData = skipping
+	}()
+
+	if err := this.recursiveClassData(cls, obj, map[*clazz]bool{}); err != nil {
+		return errors.Wrapf(err, "error skipping the data of unknown class %s", cls.name)
+	}
+
+	for k := range obj {
+		delete(obj, k)
+	}
+
+	obj["class"] = cls
+	obj["extends"] = map[string]interface{}{}
+	obj["@unknown"] = true
+
+	return nil
+}
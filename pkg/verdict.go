@@ -0,0 +1,209 @@
+package pkg
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Verdict is the outcome of sending a probe to a deserialization endpoint.
+type Verdict string
+
+const (
+	VerdictNoResponse     Verdict = "no-response"     // nothing came back
+	VerdictNotSerialized  Verdict = "not-serialized"  // the response holds no serialized stream
+	VerdictEcho           Verdict = "echo"            // the endpoint answered with serialized data and no exception
+	VerdictClassNotFound  Verdict = "class-not-found" // ClassNotFoundException: deserialization happens, class missing
+	VerdictUIDMismatch    Verdict = "uid-mismatch"    // InvalidClassException: class present with another serialVersionUID
+	VerdictFilterRejected Verdict = "filter-rejected" // InvalidClassException: rejected by an ObjectInputFilter
+	VerdictException      Verdict = "exception"       // any other exception
+)
+
+// ProbeVerdict is a structured classification of an endpoint response.
+type ProbeVerdict struct {
+	Verdict   Verdict  `json:"verdict"`
+	Exception string   `json:"exception,omitempty"` // class of the exception that decided the verdict
+	Message   string   `json:"message,omitempty"`   // its detail message
+	ClassName string   `json:"className,omitempty"` // class named by the exception, if any
+	Causes    []string `json:"causes,omitempty"`    // "class: message" of every throwable found, outermost first
+	RootCause string   `json:"rootCause,omitempty"` // "class: message" of the root cause of the outermost throwable
+}
+
+var (
+	uidMismatchRe    = regexp.MustCompile(`^([^;]+); local class incompatible`)
+	filterRejectedRe = regexp.MustCompile(`filter status: REJECTED`)
+)
+
+// ClassifyResponse classifies the bytes returned by a target after sending probe (which may be nil),
+// by parsing the serialized Throwable it usually contains.
+func ClassifyResponse(resp, probe []byte, options ...Option) *ProbeVerdict {
+	v := &ProbeVerdict{}
+
+	if len(resp) == 0 {
+		v.Verdict = VerdictNoResponse
+
+		return v
+	}
+
+	content, err := parseProbeResponse(resp, options)
+	if err != nil && len(content) == 0 {
+		v.Verdict = VerdictNotSerialized
+		classifyRaw(v, resp)
+
+		return v
+	}
+
+	var throwables []map[string]interface{}
+
+	seen := map[interface{}]bool{}
+	for _, c := range content {
+		collectThrowables(c, &throwables, seen)
+	}
+
+	if len(throwables) == 0 {
+		v.Verdict = VerdictEcho
+
+		if len(probe) > 4 && !bytes.Contains(resp, probe[4:]) {
+			v.Message = "serialized response does not contain the probe"
+		}
+
+		return v
+	}
+
+	v.Verdict = VerdictException
+
+	if throwables := AnalyzeThrowables(content, options...); throwables != nil {
+		v.RootCause = throwables.RootCause.Class + ": " + throwables.RootCause.Message
+	}
+
+	for _, t := range throwables {
+		name := objectClassName(t)
+		msg, _ := t["detailMessage"].(string)
+		v.Causes = append(v.Causes, name+": "+msg)
+
+		if verdict, className := classifyThrowable(name, msg); verdict != VerdictException {
+			if v.Verdict == VerdictException {
+				v.Verdict, v.ClassName, v.Exception, v.Message = verdict, className, name, msg
+			}
+		} else if v.Exception == "" {
+			v.Exception, v.Message = name, msg
+		}
+	}
+
+	return v
+}
+
+// classifyThrowable classifies a single throwable by class name and detail message.
+func classifyThrowable(name, msg string) (Verdict, string) {
+	switch {
+	case name == "java.lang.ClassNotFoundException":
+		return VerdictClassNotFound, msg
+	case name == "java.io.InvalidClassException" && filterRejectedRe.MatchString(msg):
+		return VerdictFilterRejected, ""
+	case name == "java.io.InvalidClassException":
+		if m := uidMismatchRe.FindStringSubmatch(msg); m != nil {
+			return VerdictUIDMismatch, m[1]
+		}
+	}
+
+	return VerdictException, ""
+}
+
+// classifyRaw looks for well known exception names when the response could not be parsed.
+func classifyRaw(v *ProbeVerdict, resp []byte) {
+	for _, name := range []string{"java.lang.ClassNotFoundException", "java.io.InvalidClassException"} {
+		if idx := bytes.Index(resp, []byte(name)); idx >= 0 {
+			v.Exception = name
+
+			// the detail message usually follows the class descriptor, take the readable text around it
+			if verdict, className := classifyThrowable(name, printableAfter(resp[idx+len(name):])); verdict != VerdictException {
+				v.Verdict, v.ClassName = verdict, className
+			}
+
+			return
+		}
+	}
+}
+
+// printableAfter returns the printable characters of b joined into a single string.
+func printableAfter(b []byte) string {
+	var sb strings.Builder
+
+	for _, c := range b {
+		if c >= 0x20 && c < 0x7f {
+			sb.WriteByte(c)
+		} else if sb.Len() > 0 && !strings.HasSuffix(sb.String(), " ") {
+			sb.WriteByte(' ')
+		}
+	}
+
+	return sb.String()
+}
+
+// collectThrowables walks a parsed object graph and gathers throwables, a throwable's cause follows it.
+func collectThrowables(obj interface{}, out *[]map[string]interface{}, seen map[interface{}]bool) {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		if seen[mapIdentity(o)] {
+			return
+		}
+
+		seen[mapIdentity(o)] = true
+
+		if isThrowable(o) {
+			*out = append(*out, o)
+
+			if cause, isMap := o["cause"].(map[string]interface{}); isMap {
+				collectThrowables(cause, out, seen)
+			}
+
+			return
+		}
+
+		// sorted, so that the throwables come in the same order on every run
+		keys := make([]string, 0, len(o))
+		for k := range o {
+			if k != "class" && k != "extends" {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			collectThrowables(o[k], out, seen)
+		}
+	case []interface{}:
+		for _, v := range o {
+			collectThrowables(v, out, seen)
+		}
+	}
+}
+
+// isThrowable checks whether a parsed object extends java.lang.Throwable.
+func isThrowable(obj map[string]interface{}) bool {
+	cls, _ := obj["class"].(*clazz)
+	for ; cls != nil; cls = cls.super {
+		if cls.name == "java.lang.Throwable" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// objectClassName returns the class name of a parsed object.
+func objectClassName(obj map[string]interface{}) string {
+	if cls, isClazz := obj["class"].(*clazz); isClazz && cls != nil {
+		return cls.name
+	}
+
+	return ""
+}
+
+// mapIdentity identifies a parsed object, shared (referenced) objects have the same identity.
+func mapIdentity(m map[string]interface{}) uintptr {
+	return reflect.ValueOf(m).Pointer()
+}
@@ -0,0 +1,79 @@
+package pkg
+
+import "fmt"
+
+// Warning kinds.
+const (
+	WarningAnnotation = "annotation" // annotation content no post-processor interprets
+	WarningUID        = "uid"        // serialVersionUID differing from the JDK or from an earlier descriptor
+	WarningFlags      = "flags"      // deprecated or inconsistent class descriptor flags
+)
+
+// Warning is an anomaly of the stream which does not stop the parse.
+type Warning struct {
+	Kind   string `json:"kind"`
+	Offset int64  `json:"offset"` // position of the parser when the anomaly was found
+	Detail string `json:"detail"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s warning at %#x: %s", w.Kind, w.Offset, w.Detail)
+}
+
+// WithWarningHandler calls fn with every warning as soon as it is found, in addition to recording it, see Warnings.
+// Callers escalate a warning by aborting from fn, with a panic recovered as a PanicError.
+func WithWarningHandler(fn func(w Warning)) Option {
+	return func(this *SerializedObjectParser) {
+		this.warningHandler = fn
+	}
+}
+
+// Warnings returns the warnings of the stream read so far.
+func (this *SerializedObjectParser) Warnings() []Warning {
+	return append([]Warning(nil), this.warnings...)
+}
+
+// warn records a warning at the current position.
+func (this *SerializedObjectParser) warn(kind, format string, args ...interface{}) {
+	w := Warning{Kind: kind, Offset: this.Offset(), Detail: fmt.Sprintf(format, args...)}
+	this.warnings = append(this.warnings, w)
+
+	if this.warningHandler != nil {
+		this.warningHandler(w)
+	}
+}
+
+// checkClass warns about the serialVersionUID and flags of a class descriptor.
+func (this *SerializedObjectParser) checkClass(cls *clazz) {
+	if uid, known := jdkUIDs[cls.name]; known && uid != cls.serialVersionUID {
+		this.warn(WarningUID, "%s has serialVersionUID %s, the JDK declares %s", cls.name, cls.serialVersionUID, uid)
+	}
+
+	if this.classUIDs == nil {
+		this.classUIDs = map[string]string{}
+	}
+
+	if uid, seen := this.classUIDs[cls.name]; seen && uid != cls.serialVersionUID {
+		this.warn(WarningUID, "%s has serialVersionUID %s, an earlier descriptor declares %s", cls.name,
+			cls.serialVersionUID, uid)
+	} else if !seen {
+		this.classUIDs[cls.name] = cls.serialVersionUID
+	}
+
+	switch {
+	case cls.flags&SC_EXTERNALIZABLE != 0 && cls.flags&SC_BLOCK_DATA == 0:
+		this.warn(WarningFlags, "%s is externalizable without SC_BLOCK_DATA, the deprecated protocol version 1",
+			cls.name)
+	case cls.flags&SC_SERIALIZABLE == 0 && cls.flags&SC_EXTERNALIZABLE == 0:
+		this.warn(WarningFlags, "%s is neither serializable nor externalizable (flags %#x)", cls.name, cls.flags)
+	}
+
+	if cls.isEnum && cls.serialVersionUID != "0000000000000000" {
+		this.warn(WarningFlags, "enum %s has serialVersionUID %s instead of 0", cls.name, cls.serialVersionUID)
+	}
+
+	if len(cls.annotations) > 0 {
+		this.warn(WarningAnnotation, "%s has %d class annotation elements, ObjectOutputStream writes none", cls.name,
+			len(cls.annotations))
+	}
+}
@@ -0,0 +1,185 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Webhook payload formats.
+const (
+	WebhookJSON  = "json"
+	WebhookSlack = "slack"
+	WebhookTeams = "teams"
+)
+
+// Webhook notifies an endpoint when an analysis raises a finding at or above a severity threshold.
+type Webhook struct {
+	URL         string
+	Format      string   // WebhookJSON (default), WebhookSlack or WebhookTeams
+	MinSeverity Severity // findings below the threshold do not fire the webhook
+	ReportURL   string   // link to the report, %s is replaced by the sample hash
+	Attach      bool     // embed the analysis in generic JSON payloads
+	Client      *http.Client
+}
+
+// webhookEvent is the generic JSON payload.
+type webhookEvent struct {
+	Name     string    `json:"name"`
+	SHA256   string    `json:"sha256"`
+	Verdict  string    `json:"verdict"`
+	Findings []Finding `json:"findings"`
+	Report   string    `json:"report,omitempty"`
+	Analysis *Analysis `json:"analysis,omitempty"`
+}
+
+// ParseWebhook parses a url[,format[,min-severity]] webhook specification, JSON payloads and every finding by default.
+func ParseWebhook(spec string) (*Webhook, error) {
+	parts := strings.Split(spec, ",")
+	if len(parts) > 3 || parts[0] == "" {
+		return nil, errors.Errorf("invalid webhook '%s', want url[,format[,min-severity]]", spec)
+	}
+
+	webhook := &Webhook{URL: parts[0]}
+
+	if len(parts) > 1 {
+		switch webhook.Format = parts[1]; webhook.Format {
+		case "", WebhookJSON, WebhookSlack, WebhookTeams:
+		default:
+			return nil, errors.Errorf("unknown webhook format '%s'", webhook.Format)
+		}
+	}
+
+	if len(parts) > 2 && parts[2] != "" {
+		severity, err := ParseSeverity(parts[2])
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing webhook")
+		}
+
+		webhook.MinSeverity = severity
+	}
+
+	return webhook, nil
+}
+
+// Matches returns the findings of an analysis at or above the webhook threshold.
+func (this *Webhook) Matches(analysis *Analysis) []Finding {
+	var matches []Finding
+
+	for _, f := range analysis.Findings {
+		if f.Severity >= this.MinSeverity {
+			matches = append(matches, f)
+		}
+	}
+
+	return matches
+}
+
+// Notify fires the webhook if the analysis raised a finding above the threshold, fired reports whether it did.
+func (this *Webhook) Notify(ctx context.Context, name string, analysis *Analysis) (fired bool, err error) {
+	matches := this.Matches(analysis)
+	if len(matches) == 0 {
+		return false, nil
+	}
+
+	report := ""
+	if this.ReportURL != "" {
+		report = strings.Replace(this.ReportURL, "%s", analysis.SHA256, 1)
+	}
+
+	var payload interface{}
+
+	switch this.Format {
+	case "", WebhookJSON:
+		event := &webhookEvent{Name: name, SHA256: analysis.SHA256, Verdict: analysis.Verdict(), Findings: matches, Report: report}
+		if this.Attach {
+			event.Analysis = analysis
+		}
+
+		payload = event
+	case WebhookSlack:
+		payload = map[string]string{"text": webhookText(name, analysis, matches, report, "\n", "*")}
+	case WebhookTeams:
+		payload = map[string]interface{}{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    "go-pjs detection in " + name,
+			"themeColor": "d63333",
+			"text":       webhookText(name, analysis, matches, report, "<br>", "**"),
+		}
+	default:
+		return false, errors.Errorf("unknown webhook format '%s'", this.Format)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return false, errors.Wrap(err, "encode webhook payload")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, this.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := this.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "send webhook")
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+		return false, errors.Errorf("send webhook: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	return true, nil
+}
+
+// webhookText renders the chat message of a detection.
+func webhookText(name string, analysis *Analysis, matches []Finding, report, newline, bold string) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%sgo-pjs: %s payload%s %s (sha256 %s)", bold, analysis.Verdict(), bold, name, analysis.SHA256)
+
+	for _, f := range matches {
+		fmt.Fprintf(&sb, "%s- [%s] %s: %s", newline, f.Severity, f.RuleID, f.Title)
+
+		if f.Class != "" {
+			fmt.Fprintf(&sb, " (%s)", f.Class)
+		}
+	}
+
+	if report != "" {
+		sb.WriteString(newline + "Report: " + report)
+	}
+
+	return sb.String()
+}
+
+// NotifyAll fires every webhook for the analysis, the first error is returned after all webhooks were tried.
+func NotifyAll(ctx context.Context, webhooks []*Webhook, name string, analysis *Analysis) error {
+	var first error
+
+	for _, w := range webhooks {
+		if _, err := w.Notify(ctx, name, analysis); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
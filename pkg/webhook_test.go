@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// webhookServer records the bodies POSTed to it.
+func webhookServer(t *testing.T) (*httptest.Server, *[]map[string]interface{}) {
+	t.Helper()
+
+	var bodies []map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got content type %q", r.Header.Get("Content-Type"))
+		}
+
+		b, _ := io.ReadAll(r.Body)
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Errorf("invalid body %s: %v", b, err)
+		}
+
+		bodies = append(bodies, body)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &bodies
+}
+
+func TestWebhookPayloads(t *testing.T) {
+	analysis := &Analysis{SHA256: "abcd", Findings: []Finding{
+		{RuleID: "R1", Severity: SeverityCritical, Title: "gadget", Class: "a.B"},
+		{RuleID: "R2", Severity: SeverityLow, Title: "minor"},
+	}}
+
+	tests := []struct {
+		format string
+		check  func(t *testing.T, body map[string]interface{})
+	}{
+		{WebhookJSON, func(t *testing.T, body map[string]interface{}) {
+			findings, _ := body["findings"].([]interface{})
+			if body["name"] != "sample" || body["sha256"] != "abcd" || body["report"] != "https://r/abcd" ||
+				len(findings) != 1 {
+				t.Errorf("got %v, want the high findings of sample", body)
+			}
+		}},
+		{WebhookSlack, func(t *testing.T, body map[string]interface{}) {
+			text, _ := body["text"].(string)
+			if !strings.Contains(text, "[critical] R1: gadget (a.B)") || strings.Contains(text, "R2") ||
+				!strings.Contains(text, "\nReport: https://r/abcd") {
+				t.Errorf("got %v, want a Slack message of R1", body)
+			}
+		}},
+		{WebhookTeams, func(t *testing.T, body map[string]interface{}) {
+			text, _ := body["text"].(string)
+			if body["@type"] != "MessageCard" || !strings.Contains(text, "<br>- [critical] R1") ||
+				strings.Contains(text, "R2") {
+				t.Errorf("got %v, want a Teams card of R1", body)
+			}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			server, bodies := webhookServer(t)
+
+			webhook, err := ParseWebhook(server.URL + "," + test.format + ",high")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			webhook.ReportURL = "https://r/%s"
+
+			if fired, err := webhook.Notify(context.Background(), "sample", analysis); err != nil || !fired {
+				t.Fatalf("got fired %v, error %v", fired, err)
+			}
+
+			if len(*bodies) != 1 {
+				t.Fatalf("got %d requests, want 1", len(*bodies))
+			}
+
+			test.check(t, (*bodies)[0])
+		})
+	}
+}
+
+func TestWebhookBelowThreshold(t *testing.T) {
+	server, bodies := webhookServer(t)
+
+	webhook, err := ParseWebhook(server.URL + ",,critical")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analysis := &Analysis{Findings: []Finding{{RuleID: "R2", Severity: SeverityHigh}}}
+
+	if err = NotifyAll(context.Background(), []*Webhook{webhook}, "sample", analysis); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*bodies) != 0 {
+		t.Errorf("got %d requests, want none", len(*bodies))
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	for _, spec := range []string{"", ",json", "http://h,xml", "http://h,json,severe", "http://h,json,high,x"} {
+		if _, err := ParseWebhook(spec); err == nil {
+			t.Errorf("%q: got no error", spec)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// String forms of the stream encoders, see WithStringForm.
+const (
+	StringFormAuto  = iota // TC_STRING, TC_LONGSTRING above 65535 bytes like ObjectOutputStream
+	StringFormShort        // TC_STRING only, longer strings are an error
+	StringFormLong         // TC_LONGSTRING only
+)
+
+// writerField describes a field of a class descriptor written by a streamWriter.
+type writerField struct {
+	typeCode  byte
+	name      string
+	className string // JVM signature of object and array fields, e.g. Ljava/lang/String;
+}
+
+// streamWriter writes the elements of a serialization stream, assigning handles the same way ObjectOutputStream
+// does so strings and class descriptors written twice become references.
+type streamWriter struct {
+	buf             bytes.Buffer
+	handle          int
+	strings         map[string]int
+	classes         map[string]int
+	protocolVersion int   // see WithProtocolVersion
+	stringForm      int   // see WithStringForm
+	err             error // first string which could not be written
+}
+
+// EncoderOption configures the stream encoders.
+type EncoderOption func(*streamWriter)
+
+// WithProtocolVersion sets the ObjectOutputStream protocol version: ProtocolVersion2 (default) writes external data
+// in block data mode, ProtocolVersion1 writes it as is, like the JDK 1.1 streams some old payloads come from.
+func WithProtocolVersion(version int) EncoderOption {
+	return func(this *streamWriter) {
+		this.protocolVersion = version
+	}
+}
+
+// WithStringForm sets the form of the strings written: StringFormAuto (default), StringFormShort or StringFormLong.
+func WithStringForm(form int) EncoderOption {
+	return func(this *streamWriter) {
+		this.stringForm = form
+	}
+}
+
+// newStreamWriter creates a streamWriter and writes the stream header.
+func newStreamWriter(options ...EncoderOption) *streamWriter {
+	w := &streamWriter{
+		handle:          baseWireHandle,
+		strings:         map[string]int{},
+		classes:         map[string]int{},
+		protocolVersion: ProtocolVersion2,
+	}
+	w.buf.Write([]byte{STREAM_MAGIC1, STREAM_MAGIC2, 0x00, STREAM_VERSION})
+
+	for _, option := range options {
+		option(w)
+	}
+
+	return w
+}
+
+// Bytes returns the stream written so far.
+func (this *streamWriter) Bytes() []byte {
+	return this.buf.Bytes()
+}
+
+func (this *streamWriter) newHandle() int {
+	h := this.handle
+	this.handle++
+
+	return h
+}
+
+func (this *streamWriter) byte(b byte) {
+	this.buf.WriteByte(b)
+}
+
+func (this *streamWriter) int16(i int16) {
+	_ = binary.Write(&this.buf, binary.BigEndian, i)
+}
+
+func (this *streamWriter) int32(i int32) {
+	_ = binary.Write(&this.buf, binary.BigEndian, i)
+}
+
+func (this *streamWriter) int64(i int64) {
+	_ = binary.Write(&this.buf, binary.BigEndian, i)
+}
+
+func (this *streamWriter) float32(f float32) {
+	_ = binary.Write(&this.buf, binary.BigEndian, f)
+}
+
+// utf writes a string in modified UTF-8 with a 2-byte length, like DataOutput.writeUTF.
+func (this *streamWriter) utf(s string) {
+	b := modifiedUTF8(s)
+	this.int16(int16(uint16(len(b))))
+	this.buf.Write(b)
+}
+
+func (this *streamWriter) reference(handle int) {
+	this.byte(TC_REFERENCE)
+	this.int32(int32(handle))
+}
+
+func (this *streamWriter) null() {
+	this.byte(TC_NULL)
+}
+
+// string writes a TC_STRING or TC_LONGSTRING, see WithStringForm, or a reference when the same string was already
+// written.
+func (this *streamWriter) string(s string) {
+	if h, exists := this.strings[s]; exists {
+		this.reference(h)
+
+		return
+	}
+
+	b := modifiedUTF8(s)
+	long := this.stringForm == StringFormLong || len(b) > math.MaxUint16
+
+	if long && this.stringForm == StringFormShort {
+		if this.err == nil {
+			this.err = errors.Errorf("string too long for TC_STRING: %d bytes", len(b))
+		}
+
+		return
+	}
+
+	if long {
+		this.byte(TC_LONGSTRING)
+		this.strings[s] = this.newHandle()
+		this.int64(int64(len(b)))
+	} else {
+		this.byte(TC_STRING)
+		this.strings[s] = this.newHandle()
+		this.int16(int16(uint16(len(b))))
+	}
+
+	this.buf.Write(b)
+}
+
+// modifiedUTF8 encodes a string in the modified UTF-8 of Java: NUL takes two bytes and the characters outside the
+// Basic Multilingual Plane are written as their UTF-16 surrogate pair, 3 bytes each. Invalid UTF-8 is written as
+// U+FFFD, as decoding it in Java would.
+func modifiedUTF8(s string) []byte {
+	b := make([]byte, 0, len(s))
+
+	for _, r := range s {
+		switch {
+		case r == 0:
+			b = append(b, 0xc0, 0x80)
+		case r < utf8.RuneSelf:
+			b = append(b, byte(r))
+		case r <= 0xffff:
+			b = utf8.AppendRune(b, r)
+		default:
+			r -= 0x10000
+			b = appendSurrogate(b, 0xd800+(r>>10))
+			b = appendSurrogate(b, 0xdc00+(r&0x3ff))
+		}
+	}
+
+	return b
+}
+
+// appendSurrogate appends the 3-byte encoding of a UTF-16 surrogate, which utf8.AppendRune refuses.
+func appendSurrogate(b []byte, r rune) []byte {
+	return append(b, 0xe0|byte(r>>12), 0x80|byte(r>>6)&0x3f, 0x80|byte(r)&0x3f)
+}
+
+// classDesc writes a TC_CLASSDESC without annotations, or a reference when the class was already written: the
+// instances of a class share its descriptor, as with ObjectOutputStream.
+// super is called to write the super class descriptor, nil writes TC_NULL.
+func (this *streamWriter) classDesc(name string, uid int64, flags byte, fields []writerField, super func()) {
+	if h, exists := this.classes[name]; exists {
+		this.reference(h)
+
+		return
+	}
+
+	this.byte(TC_CLASSDESC)
+	this.utf(name)
+	this.int64(uid)
+	this.classes[name] = this.newHandle()
+	this.byte(flags)
+	this.int16(int16(len(fields)))
+
+	for _, f := range fields {
+		this.byte(f.typeCode)
+		this.utf(f.name)
+
+		if f.typeCode == 'L' || f.typeCode == '[' {
+			this.string(f.className)
+		}
+	}
+
+	this.byte(TC_ENDBLOCKDATA)
+
+	if super != nil {
+		super()
+	} else {
+		this.null()
+	}
+}
+
+// proxyClassDesc writes the TC_PROXYCLASSDESC of a dynamic proxy implementing interfaces, or a reference when the
+// same proxy class was already written. Its super class descriptor, java.lang.reflect.Proxy with its h field, is
+// written by the same rules.
+func (this *streamWriter) proxyClassDesc(interfaces []string) {
+	key := proxyClassName + "(" + strings.Join(interfaces, ",") + ")"
+	if h, exists := this.classes[key]; exists {
+		this.reference(h)
+
+		return
+	}
+
+	this.byte(TC_PROXYCLASSDESC)
+	this.classes[key] = this.newHandle()
+	this.int32(int32(len(interfaces)))
+
+	for _, name := range interfaces {
+		this.utf(name)
+	}
+
+	this.byte(TC_ENDBLOCKDATA)
+	this.classDesc("java.lang.reflect.Proxy", -2222568056686623797, SC_SERIALIZABLE, []writerField{
+		{typeCode: 'L', name: "h", className: "Ljava/lang/reflect/InvocationHandler;"},
+	}, nil)
+}
+
+// object starts a TC_OBJECT, desc writes its class descriptor, the class data has to be written by the caller.
+func (this *streamWriter) object(desc func()) {
+	this.byte(TC_OBJECT)
+	desc()
+	this.newHandle()
+}
+
+// blockData writes a TC_BLOCKDATA segment.
+func (this *streamWriter) blockData(data []byte) {
+	this.byte(TC_BLOCKDATA)
+	this.byte(byte(len(data)))
+	this.buf.Write(data)
+}
+
+func (this *streamWriter) endBlockData() {
+	this.byte(TC_ENDBLOCKDATA)
+}
+
+// externalFlags returns the class descriptor flags of an Externalizable class for the protocol version.
+func (this *streamWriter) externalFlags() byte {
+	if this.protocolVersion == ProtocolVersion1 {
+		return SC_EXTERNALIZABLE
+	}
+
+	return SC_EXTERNALIZABLE | SC_BLOCK_DATA
+}
+
+// externalData writes the data written by writeExternal: as is with protocol version 1, in block data segments
+// ended by TC_ENDBLOCKDATA with protocol version 2.
+func (this *streamWriter) externalData(data []byte) {
+	if this.protocolVersion == ProtocolVersion1 {
+		this.buf.Write(data)
+
+		return
+	}
+
+	const maxSegment = 0xff
+
+	for len(data) > 0 {
+		n := len(data)
+		if n > maxSegment {
+			n = maxSegment
+		}
+
+		this.blockData(data[:n])
+		data = data[n:]
+	}
+
+	this.endBlockData()
+}
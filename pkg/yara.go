@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GenerateYaraRule builds a YARA rule matching streams like the analyzed one: the stream magic and the names of the
+// classes which raised findings (all classes when there is no finding), as written in class descriptors, that is
+// before any ProGuard mapping or relocation renamed them.
+func GenerateYaraRule(name string, analysis *Analysis) string {
+	wireNames := map[string]string{}
+
+	for _, cls := range analysis.Classes {
+		if cls.WireName != "" {
+			wireNames[cls.Name] = cls.WireName
+		}
+	}
+
+	wireName := func(cls string) string {
+		if wire, renamed := wireNames[cls]; renamed {
+			return wire
+		}
+
+		return cls
+	}
+
+	classes := map[string]bool{}
+
+	for _, f := range analysis.Findings {
+		if f.Class != "" {
+			classes[wireName(f.Class)] = true
+		}
+	}
+
+	if len(classes) == 0 {
+		for _, cls := range analysis.Classes {
+			classes[wireName(cls.Name)] = true
+		}
+	}
+
+	names := make([]string, 0, len(classes))
+	for cls := range classes {
+		names = append(names, cls)
+	}
+
+	sort.Strings(names)
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "rule %s\n{\n", yaraIdentifier(name))
+	fmt.Fprintf(&sb, "    meta:\n        sha256 = \"%s\"\n        fingerprint = \"%s\"\n", analysis.SHA256, analysis.Fingerprint)
+	sb.WriteString("    strings:\n        $magic = { AC ED 00 05 }\n")
+
+	for i, cls := range names {
+		// class names are written as modified UTF-8 prefixed by their length
+		b := modifiedUTF8(cls)
+		fmt.Fprintf(&sb, "        $c%d = { %02X %02X % X } // %s\n", i, len(b)>>8&0xff, len(b)&0xff, b, cls)
+	}
+
+	sb.WriteString("    condition:\n        $magic")
+
+	if len(names) > 0 {
+		sb.WriteString(" and all of ($c*)")
+	}
+
+	sb.WriteString("\n}\n")
+
+	return sb.String()
+}
+
+// yaraIdentifier turns a name into a valid rule identifier.
+func yaraIdentifier(name string) string {
+	id := []byte("pjs_")
+
+	for _, c := range []byte(name) {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' {
+			id = append(id, c)
+		} else {
+			id = append(id, '_')
+		}
+	}
+
+	return string(id)
+}
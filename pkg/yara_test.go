@@ -0,0 +1,56 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// objectStream serializes an object without fields of the named class.
+func objectStream(className string) []byte {
+	w := newStreamWriter()
+	w.object(func() {
+		w.classDesc(className, 1, SC_SERIALIZABLE, nil, nil)
+	})
+
+	return w.Bytes()
+}
+
+// yaraPattern is the pattern of GenerateYaraRule matching a class name of the given modified UTF-8 bytes.
+func yaraPattern(b []byte) string {
+	return fmt.Sprintf("{ %02X %02X % X }", len(b)>>8, len(b)&0xff, b)
+}
+
+func TestGenerateYaraRuleWireNames(t *testing.T) {
+	relocations, err := ParseRelocations(strings.NewReader(
+		"org.shaded.Invoker -> org.apache.commons.collections.functors.InvokerTransformer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analysis := Analyze(objectStream("org.shaded.Invoker"), SetRelocations(relocations))
+	if len(analysis.Findings) == 0 {
+		t.Fatal("the relocated gadget class raised no finding")
+	}
+
+	rule := GenerateYaraRule("relocated", analysis)
+
+	if want := yaraPattern([]byte("org.shaded.Invoker")); !strings.Contains(rule, want) {
+		t.Errorf("rule lacks the on-wire name %s:\n%s", want, rule)
+	}
+
+	if strings.Contains(rule, yaraPattern([]byte("org.apache.commons.collections.functors.InvokerTransformer"))) {
+		t.Errorf("rule matches the relocated name:\n%s", rule)
+	}
+}
+
+func TestGenerateYaraRuleModifiedUTF8(t *testing.T) {
+	// NUL and supplementary characters take more bytes in modified UTF-8 than in UTF-8
+	name := "a.B\x00\U0001F600"
+	rule := GenerateYaraRule("utf", &Analysis{Classes: []ClassSummary{{Name: name, SerialVersionUID: "0000000000000001"}}})
+
+	want := yaraPattern([]byte{'a', '.', 'B', 0xc0, 0x80, 0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80})
+	if !strings.Contains(rule, want) {
+		t.Errorf("rule lacks %s:\n%s", want, rule)
+	}
+}